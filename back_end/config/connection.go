@@ -25,8 +25,22 @@ type Config struct {
 	ServerPort string
 	GinMode    string
 
-	JWTSecret      string
-	JWTExpiryHours string
+	JWTSecret               string
+	JWTExpiryMinutes        string
+	RefreshTokenExpiryHours string
+
+	LoginMaxAttempts    string
+	LoginIPMaxAttempts  string
+	LoginLockoutMinutes string
+
+	TOTPIssuer        string
+	TOTPRequiredRoles string
+	TOTPEncryptionKey string
+
+	PasswordMinLength          string
+	PasswordRequiredClasses    string
+	PasswordHistory            string
+	PasswordResetExpiryMinutes string
 
 	AdminUsername string
 	AdminPassword string
@@ -38,8 +52,48 @@ type Config struct {
 	SMTPPassword string
 	SMTPFrom     string
 
-	UploadPath  string
-	MaxFileSize string
+	EmailMaxAttempts          string
+	EmailWorkerIntervalSecond string
+
+	StorageDriver     string
+	UploadPath        string
+	MaxFileSize       string
+	MaxSubmissionSize string
+	AllowedMimeTypes  string
+
+	Scanner              string
+	ClamAVAddress        string
+	ClamAVTimeoutSeconds string
+	QuarantinePath       string
+
+	PreviewMaxSize    string
+	PDFPreviewCommand string
+
+	S3Endpoint  string
+	S3AccessKey string
+	S3SecretKey string
+	S3Bucket    string
+	S3Region    string
+	S3UseSSL    string
+
+	FrontendURL       string
+	APIBaseURL        string
+	RevisiExpiryHours string
+
+	DownloadURLSecret     string
+	DownloadURLTTLMinutes string
+
+	SuratKodeKlasifikasi string
+	SuratKodeUnit        string
+	SuratPenandatangan   string
+	SuratJabatan         string
+	SuratNIP             string
+	SuratAlamat          string
+	SuratLogoPath        string
+
+	NomorPermohonanFormat string
+	NomorPermohonanKode   string
+	NomorPermohonanDigit  string
 }
 
 // LoadConfig loads configuration from .env file
@@ -60,11 +114,25 @@ func LoadConfig() *Config {
 		ServerPort: getEnv("SERVER_PORT", "8080"),
 		GinMode:    getEnv("GIN_MODE", "debug"),
 
-		JWTSecret:      getEnv("JWT_SECRET", "secret"),
-		JWTExpiryHours: getEnv("JWT_EXPIRY_HOURS", "24"),
+		JWTSecret:               getEnv("JWT_SECRET", "secret"),
+		JWTExpiryMinutes:        getEnv("JWT_EXPIRY_MINUTES", "15"),
+		RefreshTokenExpiryHours: getEnv("REFRESH_TOKEN_EXPIRY_HOURS", "168"),
+
+		LoginMaxAttempts:    getEnv("LOGIN_MAX_ATTEMPTS", "5"),
+		LoginIPMaxAttempts:  getEnv("LOGIN_IP_MAX_ATTEMPTS", "20"),
+		LoginLockoutMinutes: getEnv("LOGIN_LOCKOUT_MINUTES", "15"),
+
+		TOTPIssuer:        getEnv("TOTP_ISSUER", "Dinkes Kota Makassar"),
+		TOTPRequiredRoles: getEnv("TOTP_REQUIRED_ROLES", ""),
+		TOTPEncryptionKey: getEnv("TOTP_ENCRYPTION_KEY", ""),
+
+		PasswordMinLength:          getEnv("PASSWORD_MIN_LENGTH", "8"),
+		PasswordRequiredClasses:    getEnv("PASSWORD_REQUIRED_CLASSES", "lower,upper,digit"),
+		PasswordHistory:            getEnv("PASSWORD_HISTORY", "5"),
+		PasswordResetExpiryMinutes: getEnv("PASSWORD_RESET_EXPIRY_MINUTES", "30"),
 
 		AdminUsername: getEnv("ADMIN_USERNAME", "admin"),
-		AdminPassword: getEnv("ADMIN_PASSWORD", "admin123"),
+		AdminPassword: getEnv("ADMIN_PASSWORD", "Admin12345"),
 		AdminEmail:    getEnv("ADMIN_EMAIL", "admin@dinkes.makassar.go.id"),
 
 		SMTPHost:     getEnv("SMTP_HOST", "smtp.gmail.com"),
@@ -73,8 +141,49 @@ func LoadConfig() *Config {
 		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
 		SMTPFrom:     getEnv("SMTP_FROM", ""),
 
-		UploadPath:  getEnv("UPLOAD_PATH", "./uploads"),
-		MaxFileSize: getEnv("MAX_FILE_SIZE", "10485760"),
+		EmailMaxAttempts:          getEnv("EMAIL_MAX_ATTEMPTS", "5"),
+		EmailWorkerIntervalSecond: getEnv("EMAIL_WORKER_INTERVAL_SECONDS", "15"),
+
+		StorageDriver:     getEnv("STORAGE_DRIVER", "local"),
+		UploadPath:        getEnv("UPLOAD_PATH", "./uploads"),
+		MaxFileSize:       getEnv("MAX_FILE_SIZE", "10485760"),
+		MaxSubmissionSize: getEnv("MAX_SUBMISSION_SIZE", "52428800"),
+		AllowedMimeTypes: getEnv("ALLOWED_MIME_TYPES",
+			"application/pdf,image/jpeg,image/png,application/vnd.openxmlformats-officedocument.wordprocessingml.document"),
+
+		Scanner:              getEnv("SCANNER", "none"),
+		ClamAVAddress:        getEnv("CLAMAV_ADDRESS", "tcp://localhost:3310"),
+		ClamAVTimeoutSeconds: getEnv("CLAMAV_TIMEOUT_SECONDS", "60"),
+		QuarantinePath:       getEnv("QUARANTINE_PATH", "./quarantine"),
+
+		PreviewMaxSize:    getEnv("PREVIEW_MAX_SIZE", "600"),
+		PDFPreviewCommand: getEnv("PDF_PREVIEW_COMMAND", "pdftoppm"),
+
+		S3Endpoint:  getEnv("S3_ENDPOINT", "localhost:9000"),
+		S3AccessKey: getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey: getEnv("S3_SECRET_KEY", ""),
+		S3Bucket:    getEnv("S3_BUCKET", "perizinan"),
+		S3Region:    getEnv("S3_REGION", "us-east-1"),
+		S3UseSSL:    getEnv("S3_USE_SSL", "false"),
+
+		FrontendURL:       getEnv("FRONTEND_URL", "http://localhost:3000"),
+		APIBaseURL:        getEnv("API_BASE_URL", "http://localhost:8080"),
+		RevisiExpiryHours: getEnv("REVISI_EXPIRY_HOURS", "168"),
+
+		DownloadURLSecret:     getEnv("DOWNLOAD_URL_SECRET", ""),
+		DownloadURLTTLMinutes: getEnv("DOWNLOAD_URL_TTL_MINUTES", "5"),
+
+		SuratKodeKlasifikasi: getEnv("SURAT_KODE_KLASIFIKASI", "440"),
+		SuratKodeUnit:        getEnv("SURAT_KODE_UNIT", "PSDK"),
+		SuratPenandatangan:   getEnv("SURAT_PENANDATANGAN", "Kepala Dinas Kesehatan"),
+		SuratJabatan:         getEnv("SURAT_JABATAN", "Kepala Dinas Kesehatan Kota Makassar"),
+		SuratNIP:             getEnv("SURAT_NIP", ""),
+		SuratAlamat:          getEnv("SURAT_ALAMAT", "Jl. Teduh Bersinar No. 1, Makassar"),
+		SuratLogoPath:        getEnv("SURAT_LOGO_PATH", ""),
+
+		NomorPermohonanFormat: getEnv("NOMOR_PERMOHONAN_FORMAT", "{KODE}-{TAHUN}-{URUT}-{CEK}"),
+		NomorPermohonanKode:   getEnv("NOMOR_PERMOHONAN_KODE", "PRM"),
+		NomorPermohonanDigit:  getEnv("NOMOR_PERMOHONAN_DIGIT", "5"),
 	}
 }
 
@@ -95,6 +204,8 @@ func ConnectDatabase(cfg *Config) (*gorm.DB, error) {
 
 	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
+		// Report unique index violations as gorm.ErrDuplicatedKey
+		TranslateError: true,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
@@ -1,11 +1,19 @@
 package controllers
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"math"
+	"mime"
+	"mime/multipart"
 	"net/http"
-	"os"
+	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/alifsyafan/backend-capston/dto"
@@ -15,6 +23,43 @@ import (
 	"github.com/google/uuid"
 )
 
+// statusCodeForError maps known service errors to an HTTP status code
+func statusCodeForError(err error) int {
+	switch {
+	case errors.Is(err, services.ErrTransisiStatus):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrPersyaratanTidakValid):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrScannerTidakTersedia):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, services.ErrFileTidakDitemukan):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrTautanTidakValid), errors.Is(err, services.ErrTautanKadaluarsa):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrSesiTidakValid):
+		return http.StatusUnauthorized
+	case errors.Is(err, services.ErrLoginDibatasi):
+		return http.StatusTooManyRequests
+	case errors.Is(err, services.ErrKode2FATidakValid):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrPasswordLemah), errors.Is(err, services.ErrTokenResetTidakValid):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrIzinDitolak):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// statusCodeForErrorOr is statusCodeForError with a different status for
+// errors it does not know
+func statusCodeForErrorOr(err error, fallback int) int {
+	if code := statusCodeForError(err); code != http.StatusInternalServerError {
+		return code
+	}
+	return fallback
+}
+
 // ============== Auth Controller ==============
 
 type AuthController struct {
@@ -36,16 +81,44 @@ func (c *AuthController) Login(ctx *gin.Context) {
 		return
 	}
 
-	response, err := c.authService.Login(req)
+	response, err := c.authService.Login(req, klienInfo(ctx))
 	if err != nil {
-		ctx.JSON(http.StatusUnauthorized, dto.APIResponse{
+		loginGagal(ctx, err)
+		return
+	}
+
+	message := "Login berhasil"
+	switch {
+	case response.Perlu2FA:
+		message = "Masukkan kode autentikasi"
+	case response.Setup2FA:
+		message = "Aktifkan autentikasi dua faktor untuk melanjutkan"
+	}
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: message,
+		Data:    response,
+	})
+}
+
+// Verifikasi2FA is the second login step for admins with 2FA
+func (c *AuthController) Verifikasi2FA(ctx *gin.Context) {
+	var req dto.Verifikasi2FARequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
 			Success: false,
-			Message: "Login gagal",
+			Message: "Data tidak valid",
 			Error:   err.Error(),
 		})
 		return
 	}
 
+	response, err := c.authService.Verifikasi2FA(req, klienInfo(ctx))
+	if err != nil {
+		loginGagal(ctx, err)
+		return
+	}
+
 	ctx.JSON(http.StatusOK, dto.APIResponse{
 		Success: true,
 		Message: "Login berhasil",
@@ -53,6 +126,200 @@ func (c *AuthController) Login(ctx *gin.Context) {
 	})
 }
 
+// MulaiSetup2FA starts enrollment for an admin whose role requires 2FA
+func (c *AuthController) MulaiSetup2FA(ctx *gin.Context) {
+	var req dto.TokenTantanganRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "Data tidak valid",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	response, err := c.authService.MulaiSetup2FA(req.TokenTantangan)
+	if err != nil {
+		loginGagal(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Pindai QR code dengan aplikasi autentikator",
+		Data:    response,
+	})
+}
+
+// AktifkanSetup2FA confirms enrollment during login and starts the session
+func (c *AuthController) AktifkanSetup2FA(ctx *gin.Context) {
+	var req dto.Verifikasi2FARequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "Data tidak valid",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	response, err := c.authService.AktifkanSetup2FA(req, klienInfo(ctx))
+	if err != nil {
+		loginGagal(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Autentikasi dua faktor aktif, login berhasil",
+		Data:    response,
+	})
+}
+
+// loginGagal answers a failed login step. Rate limited attempts get 429
+// with Retry-After, everything else 401.
+func loginGagal(ctx *gin.Context, err error) {
+	status := http.StatusUnauthorized
+	var dibatasi *services.LoginDibatasiError
+	if errors.As(err, &dibatasi) {
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(dibatasi.CobaLagi.Seconds()))))
+		status = http.StatusTooManyRequests
+	}
+	ctx.JSON(status, dto.APIResponse{
+		Success: false,
+		Message: "Login gagal",
+		Error:   err.Error(),
+	})
+}
+
+func (c *AuthController) Refresh(ctx *gin.Context) {
+	var req dto.RefreshTokenRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "Data tidak valid",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	response, err := c.authService.Refresh(req.RefreshToken, klienInfo(ctx))
+	if err != nil {
+		ctx.JSON(statusCodeForError(err), dto.APIResponse{
+			Success: false,
+			Message: "Gagal memperbarui token",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Token berhasil diperbarui",
+		Data:    response,
+	})
+}
+
+func (c *AuthController) Logout(ctx *gin.Context) {
+	sesiID, _ := ctx.Get("sesi_id")
+	if err := c.authService.Logout(sesiID.(uuid.UUID)); err != nil {
+		ctx.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Message: "Gagal logout",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Logout berhasil",
+	})
+}
+
+// TiketStream hands out a ticket for opening the notification stream
+func (c *AuthController) TiketStream(ctx *gin.Context) {
+	adminID, _ := ctx.Get("admin_id")
+	sesiID, _ := ctx.Get("sesi_id")
+
+	tiket, err := c.authService.BuatTiketStream(adminID.(uuid.UUID), sesiID.(uuid.UUID))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Message: "Gagal membuat tiket stream",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Data:    tiket,
+	})
+}
+
+// GetSesi lists the active sessions of the logged in admin
+func (c *AuthController) GetSesi(ctx *gin.Context) {
+	adminID, _ := ctx.Get("admin_id")
+	sesiID, _ := ctx.Get("sesi_id")
+
+	list, err := c.authService.GetSesi(adminID.(uuid.UUID), sesiID.(uuid.UUID))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Message: "Gagal mengambil daftar sesi",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Data:    list,
+	})
+}
+
+// CabutSesi signs out one of the logged in admin's own sessions
+func (c *AuthController) CabutSesi(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "ID tidak valid",
+		})
+		return
+	}
+
+	adminID, _ := ctx.Get("admin_id")
+	if err := c.authService.CabutSesi(adminID.(uuid.UUID), id); err != nil {
+		ctx.JSON(http.StatusNotFound, dto.APIResponse{
+			Success: false,
+			Message: "Gagal mencabut sesi",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Sesi berhasil dicabut",
+	})
+}
+
+func klienInfo(ctx *gin.Context) services.KlienInfo {
+	return services.KlienInfo{
+		IPAddress: ctx.ClientIP(),
+		UserAgent: ctx.Request.UserAgent(),
+	}
+}
+
+// aktorRole returns the role of the logged in admin set by AuthMiddleware
+func aktorRole(ctx *gin.Context) models.RoleAdmin {
+	role, _ := ctx.Get("role")
+	nama, _ := role.(string)
+	return models.RoleAdmin(nama)
+}
+
 func (c *AuthController) GetProfile(ctx *gin.Context) {
 	adminID, exists := ctx.Get("admin_id")
 	if !exists {
@@ -63,7 +330,7 @@ func (c *AuthController) GetProfile(ctx *gin.Context) {
 		return
 	}
 
-	admin, err := c.authService.GetAdminByID(adminID.(uuid.UUID))
+	profil, err := c.authService.GetProfil(adminID.(uuid.UUID))
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, dto.APIResponse{
 			Success: false,
@@ -74,13 +341,7 @@ func (c *AuthController) GetProfile(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, dto.APIResponse{
 		Success: true,
-		Data: dto.AdminInfo{
-			ID:          admin.ID,
-			Username:    admin.Username,
-			Email:       admin.Email,
-			NamaLengkap: admin.NamaLengkap,
-			Role:        string(admin.Role),
-		},
+		Data:    profil,
 	})
 }
 
@@ -105,9 +366,9 @@ func (c *AdminController) Create(ctx *gin.Context) {
 		return
 	}
 
-	admin, err := c.service.Create(req)
+	admin, err := c.service.Create(req, aktorRole(ctx))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+		ctx.JSON(statusCodeForErrorOr(err, http.StatusBadRequest), dto.APIResponse{
 			Success: false,
 			Message: "Gagal membuat admin",
 			Error:   err.Error(),
@@ -194,9 +455,9 @@ func (c *AdminController) Update(ctx *gin.Context) {
 		return
 	}
 
-	admin, err := c.service.Update(id, req)
+	admin, err := c.service.Update(id, req, aktorRole(ctx))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+		ctx.JSON(statusCodeForErrorOr(err, http.StatusBadRequest), dto.APIResponse{
 			Success: false,
 			Message: "Gagal mengupdate admin",
 			Error:   err.Error(),
@@ -231,9 +492,9 @@ func (c *AdminController) Delete(ctx *gin.Context) {
 		return
 	}
 
-	err = c.service.Delete(id)
+	err = c.service.Delete(id, aktorRole(ctx))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+		ctx.JSON(statusCodeForErrorOr(err, http.StatusBadRequest), dto.APIResponse{
 			Success: false,
 			Message: "Gagal menghapus admin",
 			Error:   err.Error(),
@@ -267,9 +528,9 @@ func (c *AdminController) ResetPassword(ctx *gin.Context) {
 		return
 	}
 
-	err = c.service.ResetPassword(id, req)
+	err = c.service.ResetPassword(id, req, aktorRole(ctx))
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+		ctx.JSON(statusCodeForErrorOr(err, http.StatusBadRequest), dto.APIResponse{
 			Success: false,
 			Message: "Gagal mereset password",
 			Error:   err.Error(),
@@ -303,7 +564,8 @@ func (c *AdminController) ChangePassword(ctx *gin.Context) {
 		return
 	}
 
-	err := c.service.ChangePassword(adminID.(uuid.UUID), req)
+	sesiID, _ := ctx.Get("sesi_id")
+	err := c.service.ChangePassword(adminID.(uuid.UUID), sesiID.(uuid.UUID), req)
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
 			Success: false,
@@ -319,51 +581,48 @@ func (c *AdminController) ChangePassword(ctx *gin.Context) {
 	})
 }
 
-// ============== Jenis Perizinan Controller ==============
-
-type JenisPerizinanController struct {
-	service services.JenisPerizinanService
-}
-
-func NewJenisPerizinanController(service services.JenisPerizinanService) *JenisPerizinanController {
-	return &JenisPerizinanController{service: service}
-}
-
-func (c *JenisPerizinanController) Create(ctx *gin.Context) {
-	var req dto.CreateJenisPerizinanRequest
-	if err := ctx.ShouldBindJSON(&req); err != nil {
+// GetSesi lists the active sessions of an admin
+func (c *AdminController) GetSesi(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
 		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
 			Success: false,
-			Message: "Data tidak valid",
-			Error:   err.Error(),
+			Message: "ID tidak valid",
 		})
 		return
 	}
 
-	jp, err := c.service.Create(req)
+	list, err := c.service.GetSesi(id)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, dto.APIResponse{
+		ctx.JSON(http.StatusNotFound, dto.APIResponse{
 			Success: false,
-			Message: "Gagal membuat jenis perizinan",
+			Message: "Gagal mengambil daftar sesi",
 			Error:   err.Error(),
 		})
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, dto.APIResponse{
+	ctx.JSON(http.StatusOK, dto.APIResponse{
 		Success: true,
-		Message: "Jenis perizinan berhasil dibuat",
-		Data:    jp,
+		Data:    list,
 	})
 }
 
-func (c *JenisPerizinanController) GetAll(ctx *gin.Context) {
-	aktifOnly := ctx.Query("aktif_only") == "true"
-	list, err := c.service.GetAll(aktifOnly)
+// CabutSemuaSesi signs an admin out everywhere
+func (c *AdminController) CabutSemuaSesi(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, dto.APIResponse{
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
 			Success: false,
-			Message: "Gagal mengambil data",
+			Message: "ID tidak valid",
+		})
+		return
+	}
+
+	if err := c.service.CabutSemuaSesi(id); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "Gagal mencabut sesi",
 			Error:   err.Error(),
 		})
 		return
@@ -371,46 +630,59 @@ func (c *JenisPerizinanController) GetAll(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, dto.APIResponse{
 		Success: true,
-		Data:    list,
+		Message: "Semua sesi admin berhasil dicabut",
 	})
 }
 
-func (c *JenisPerizinanController) GetByID(ctx *gin.Context) {
-	id, err := uuid.Parse(ctx.Param("id"))
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
-			Success: false,
-			Message: "ID tidak valid",
-		})
-		return
-	}
+// ============== Dua Faktor Controller ==============
 
-	jp, err := c.service.GetByID(id)
+type DuaFaktorController struct {
+	service services.DuaFaktorService
+}
+
+func NewDuaFaktorController(service services.DuaFaktorService) *DuaFaktorController {
+	return &DuaFaktorController{service: service}
+}
+
+func (c *DuaFaktorController) GetStatus(ctx *gin.Context) {
+	adminID, _ := ctx.Get("admin_id")
+	status, err := c.service.Status(adminID.(uuid.UUID))
 	if err != nil {
 		ctx.JSON(http.StatusNotFound, dto.APIResponse{
 			Success: false,
-			Message: "Jenis perizinan tidak ditemukan",
+			Message: "Gagal mengambil status 2FA",
+			Error:   err.Error(),
 		})
 		return
 	}
 
 	ctx.JSON(http.StatusOK, dto.APIResponse{
 		Success: true,
-		Data:    jp,
+		Data:    status,
 	})
 }
 
-func (c *JenisPerizinanController) Update(ctx *gin.Context) {
-	id, err := uuid.Parse(ctx.Param("id"))
+func (c *DuaFaktorController) Mulai(ctx *gin.Context) {
+	adminID, _ := ctx.Get("admin_id")
+	response, err := c.service.Mulai(adminID.(uuid.UUID))
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
 			Success: false,
-			Message: "ID tidak valid",
+			Message: "Gagal memulai pendaftaran 2FA",
+			Error:   err.Error(),
 		})
 		return
 	}
 
-	var req dto.UpdateJenisPerizinanRequest
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Pindai QR code dengan aplikasi autentikator",
+		Data:    response,
+	})
+}
+
+func (c *DuaFaktorController) Aktifkan(ctx *gin.Context) {
+	var req dto.Kode2FARequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
 			Success: false,
@@ -420,11 +692,12 @@ func (c *JenisPerizinanController) Update(ctx *gin.Context) {
 		return
 	}
 
-	jp, err := c.service.Update(id, req)
+	adminID, _ := ctx.Get("admin_id")
+	kode, err := c.service.Aktifkan(adminID.(uuid.UUID), req.Kode)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, dto.APIResponse{
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
 			Success: false,
-			Message: "Gagal update jenis perizinan",
+			Message: "Gagal mengaktifkan 2FA",
 			Error:   err.Error(),
 		})
 		return
@@ -432,26 +705,27 @@ func (c *JenisPerizinanController) Update(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, dto.APIResponse{
 		Success: true,
-		Message: "Jenis perizinan berhasil diupdate",
-		Data:    jp,
+		Message: "Autentikasi dua faktor berhasil diaktifkan",
+		Data:    dto.KodePemulihanResponse{KodePemulihan: kode},
 	})
 }
 
-func (c *JenisPerizinanController) Delete(ctx *gin.Context) {
-	id, err := uuid.Parse(ctx.Param("id"))
-	if err != nil {
+func (c *DuaFaktorController) Nonaktifkan(ctx *gin.Context) {
+	var req dto.Nonaktifkan2FARequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
 			Success: false,
-			Message: "ID tidak valid",
+			Message: "Data tidak valid",
+			Error:   err.Error(),
 		})
 		return
 	}
 
-	err = c.service.Delete(id)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, dto.APIResponse{
+	adminID, _ := ctx.Get("admin_id")
+	if err := c.service.Nonaktifkan(adminID.(uuid.UUID), req); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
 			Success: false,
-			Message: "Gagal menghapus jenis perizinan",
+			Message: "Gagal menonaktifkan 2FA",
 			Error:   err.Error(),
 		})
 		return
@@ -459,22 +733,573 @@ func (c *JenisPerizinanController) Delete(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, dto.APIResponse{
 		Success: true,
-		Message: "Jenis perizinan berhasil dihapus",
+		Message: "Autentikasi dua faktor berhasil dinonaktifkan",
 	})
 }
 
-// ============== Permohonan Controller ==============
-
-type PermohonanController struct {
-	service    services.PermohonanService
-	uploadPath string
-}
-
-func NewPermohonanController(service services.PermohonanService, uploadPath string) *PermohonanController {
-	return &PermohonanController{service: service, uploadPath: uploadPath}
-}
-
-func (c *PermohonanController) Create(ctx *gin.Context) {
+func (c *DuaFaktorController) BuatUlangKodePemulihan(ctx *gin.Context) {
+	var req dto.Kode2FARequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "Data tidak valid",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	adminID, _ := ctx.Get("admin_id")
+	kode, err := c.service.BuatUlangKodePemulihan(adminID.(uuid.UUID), req.Kode)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "Gagal membuat kode pemulihan",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Kode pemulihan baru berhasil dibuat",
+		Data:    dto.KodePemulihanResponse{KodePemulihan: kode},
+	})
+}
+
+// Reset removes 2FA from another admin, e.g. after a lost phone
+func (c *DuaFaktorController) Reset(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "ID tidak valid",
+		})
+		return
+	}
+
+	if err := c.service.Reset(id); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "Gagal mereset 2FA",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Autentikasi dua faktor admin berhasil direset",
+	})
+}
+
+// ============== Role Controller ==============
+
+type RoleController struct {
+	service services.RoleService
+}
+
+func NewRoleController(service services.RoleService) *RoleController {
+	return &RoleController{service: service}
+}
+
+func (c *RoleController) GetAll(ctx *gin.Context) {
+	roles, err := c.service.GetAll()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Message: "Gagal mengambil data role",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Data role berhasil diambil",
+		Data:    roles,
+	})
+}
+
+func (c *RoleController) GetByID(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "ID tidak valid",
+		})
+		return
+	}
+
+	role, err := c.service.GetByID(id)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, dto.APIResponse{
+			Success: false,
+			Message: "Role tidak ditemukan",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Data role berhasil diambil",
+		Data:    role,
+	})
+}
+
+func (c *RoleController) Create(ctx *gin.Context) {
+	var req dto.CreateRoleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "Data tidak valid",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	role, err := c.service.Create(req, aktorRole(ctx))
+	if err != nil {
+		ctx.JSON(statusCodeForErrorOr(err, http.StatusBadRequest), dto.APIResponse{
+			Success: false,
+			Message: "Gagal membuat role",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, dto.APIResponse{
+		Success: true,
+		Message: "Role berhasil dibuat",
+		Data:    role,
+	})
+}
+
+func (c *RoleController) Update(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "ID tidak valid",
+		})
+		return
+	}
+
+	var req dto.UpdateRoleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "Data tidak valid",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	role, err := c.service.Update(id, req, aktorRole(ctx))
+	if err != nil {
+		ctx.JSON(statusCodeForErrorOr(err, http.StatusBadRequest), dto.APIResponse{
+			Success: false,
+			Message: "Gagal mengubah role",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Role berhasil diubah",
+		Data:    role,
+	})
+}
+
+func (c *RoleController) Delete(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "ID tidak valid",
+		})
+		return
+	}
+
+	if err := c.service.Delete(id, aktorRole(ctx)); err != nil {
+		ctx.JSON(statusCodeForErrorOr(err, http.StatusBadRequest), dto.APIResponse{
+			Success: false,
+			Message: "Gagal menghapus role",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Role berhasil dihapus",
+	})
+}
+
+// GetDaftarIzin lists the permissions a role can grant
+func (c *RoleController) GetDaftarIzin(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Daftar izin berhasil diambil",
+		Data:    c.service.DaftarIzin(),
+	})
+}
+
+// ============== Lupa Password Controller ==============
+
+type LupaPasswordController struct {
+	service services.LupaPasswordService
+}
+
+func NewLupaPasswordController(service services.LupaPasswordService) *LupaPasswordController {
+	return &LupaPasswordController{service: service}
+}
+
+// Minta sends a reset link. The reply is the same whether or not the email
+// belongs to an admin.
+func (c *LupaPasswordController) Minta(ctx *gin.Context) {
+	var req dto.LupaPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "Data tidak valid",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := c.service.Minta(req, klienInfo(ctx)); err != nil {
+		ctx.JSON(statusCodeForError(err), dto.APIResponse{
+			Success: false,
+			Message: "Gagal memproses permintaan reset password",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Jika email terdaftar, tautan reset password telah dikirim ke email tersebut",
+	})
+}
+
+func (c *LupaPasswordController) CekToken(ctx *gin.Context) {
+	response, err := c.service.CekToken(ctx.Param("token"))
+	if err != nil {
+		ctx.JSON(statusCodeForError(err), dto.APIResponse{
+			Success: false,
+			Message: "Tautan reset password tidak dapat digunakan",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Tautan reset password valid",
+		Data:    response,
+	})
+}
+
+func (c *LupaPasswordController) Reset(ctx *gin.Context) {
+	var req dto.ResetPasswordTokenRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "Data tidak valid",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := c.service.Reset(req); err != nil {
+		ctx.JSON(statusCodeForError(err), dto.APIResponse{
+			Success: false,
+			Message: "Gagal mereset password",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Password berhasil direset, silakan login dengan password baru",
+	})
+}
+
+// GetKebijakan returns the password policy for forms
+func (c *LupaPasswordController) GetKebijakan(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Kebijakan password berhasil diambil",
+		Data:    c.service.Kebijakan(),
+	})
+}
+
+// ============== Pembatasan Login Controller ==============
+
+type PembatasanLoginController struct {
+	service services.PembatasanLoginService
+}
+
+func NewPembatasanLoginController(service services.PembatasanLoginService) *PembatasanLoginController {
+	return &PembatasanLoginController{service: service}
+}
+
+// GetPenguncian lists locked accounts and IP addresses
+func (c *PembatasanLoginController) GetPenguncian(ctx *gin.Context) {
+	response, err := c.service.GetPenguncian()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Message: "Gagal mengambil data penguncian login",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Data:    response,
+	})
+}
+
+// GetPercobaanByAdminID lists the latest failed logins of an admin
+func (c *PembatasanLoginController) GetPercobaanByAdminID(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "ID tidak valid",
+		})
+		return
+	}
+
+	list, err := c.service.GetPercobaanByAdminID(id)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, dto.APIResponse{
+			Success: false,
+			Message: "Gagal mengambil percobaan login",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Data:    list,
+	})
+}
+
+func (c *PembatasanLoginController) BukaAkun(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "ID tidak valid",
+		})
+		return
+	}
+
+	if err := c.service.BukaAkun(id); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "Gagal membuka kunci akun",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Kunci akun berhasil dibuka",
+	})
+}
+
+func (c *PembatasanLoginController) BukaIP(ctx *gin.Context) {
+	if err := c.service.BukaIP(ctx.Param("ip")); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "Gagal membuka kunci IP",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Kunci IP berhasil dibuka",
+	})
+}
+
+// ============== Jenis Perizinan Controller ==============
+
+type JenisPerizinanController struct {
+	service services.JenisPerizinanService
+}
+
+func NewJenisPerizinanController(service services.JenisPerizinanService) *JenisPerizinanController {
+	return &JenisPerizinanController{service: service}
+}
+
+func (c *JenisPerizinanController) Create(ctx *gin.Context) {
+	var req dto.CreateJenisPerizinanRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "Data tidak valid",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	jp, err := c.service.Create(req)
+	if err != nil {
+		ctx.JSON(statusCodeForError(err), dto.APIResponse{
+			Success: false,
+			Message: "Gagal membuat jenis perizinan",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, dto.APIResponse{
+		Success: true,
+		Message: "Jenis perizinan berhasil dibuat",
+		Data:    jp,
+	})
+}
+
+func (c *JenisPerizinanController) GetAll(ctx *gin.Context) {
+	aktifOnly := ctx.Query("aktif_only") == "true"
+	list, err := c.service.GetAll(aktifOnly)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Message: "Gagal mengambil data",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Data:    list,
+	})
+}
+
+func (c *JenisPerizinanController) GetByID(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "ID tidak valid",
+		})
+		return
+	}
+
+	jp, err := c.service.GetByID(id)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, dto.APIResponse{
+			Success: false,
+			Message: "Jenis perizinan tidak ditemukan",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Data:    jp,
+	})
+}
+
+func (c *JenisPerizinanController) Update(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "ID tidak valid",
+		})
+		return
+	}
+
+	var req dto.UpdateJenisPerizinanRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "Data tidak valid",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	jp, err := c.service.Update(id, req)
+	if err != nil {
+		ctx.JSON(statusCodeForError(err), dto.APIResponse{
+			Success: false,
+			Message: "Gagal update jenis perizinan",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Jenis perizinan berhasil diupdate",
+		Data:    jp,
+	})
+}
+
+func (c *JenisPerizinanController) Delete(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "ID tidak valid",
+		})
+		return
+	}
+
+	err = c.service.Delete(id)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Message: "Gagal menghapus jenis perizinan",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Jenis perizinan berhasil dihapus",
+	})
+}
+
+// ============== Permohonan Controller ==============
+
+type PermohonanController struct {
+	service        services.PermohonanService
+	storage        services.Storage
+	maxRequestSize int64
+}
+
+func NewPermohonanController(service services.PermohonanService, storage services.Storage, maxRequestSize int64) *PermohonanController {
+	return &PermohonanController{service: service, storage: storage, maxRequestSize: maxRequestSize}
+}
+
+// limitRequestBody stops reading uploads that exceed the submission limit
+// before they are spooled to disk
+func (c *PermohonanController) limitRequestBody(ctx *gin.Context) {
+	if c.maxRequestSize > 0 {
+		ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, c.maxRequestSize)
+	}
+}
+
+func (c *PermohonanController) Create(ctx *gin.Context) {
+	c.limitRequestBody(ctx)
+
 	// Parse multipart form
 	err := ctx.Request.ParseMultipartForm(10 << 20) // 10 MB max
 	if err != nil {
@@ -486,107 +1311,758 @@ func (c *PermohonanController) Create(ctx *gin.Context) {
 		return
 	}
 
-	// Get form data
-	var req dto.CreatePermohonanRequest
-	req.Pemohon.NamaLengkap = ctx.PostForm("nama_lengkap")
-	req.Pemohon.NomorTelepon = ctx.PostForm("nomor_telepon")
-	req.Pemohon.Email = ctx.PostForm("email")
-	req.Pemohon.Alamat = ctx.PostForm("alamat")
-	req.JenisPerizinanID = ctx.PostForm("jenis_perizinan_id")
-	req.Catatan = ctx.PostForm("catatan")
+	// Get form data
+	var req dto.CreatePermohonanRequest
+	req.Pemohon.NamaLengkap = ctx.PostForm("nama_lengkap")
+	req.Pemohon.NomorTelepon = ctx.PostForm("nomor_telepon")
+	req.Pemohon.Email = ctx.PostForm("email")
+	req.Pemohon.Alamat = ctx.PostForm("alamat")
+	req.JenisPerizinanID = ctx.PostForm("jenis_perizinan_id")
+	req.Catatan = ctx.PostForm("catatan")
+
+	// Validate required fields
+	if req.Pemohon.NamaLengkap == "" || req.Pemohon.Email == "" || req.JenisPerizinanID == "" {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "Data tidak lengkap",
+		})
+		return
+	}
+
+	// Files are written by the service so they can be removed on failure
+	permohonan, err := c.service.Create(req, berkasFromForm(ctx))
+	if err != nil {
+		var validasiErr *services.ValidasiBerkasError
+		if errors.As(err, &validasiErr) {
+			berkasErrorResponse(ctx, "Berkas persyaratan tidak lengkap atau tidak valid", validasiErr)
+			return
+		}
+		ctx.JSON(statusCodeForError(err), dto.APIResponse{
+			Success: false,
+			Message: "Gagal membuat permohonan",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, dto.APIResponse{
+		Success: true,
+		Message: "Permohonan berhasil diajukan",
+		Data:    permohonan,
+	})
+}
+
+// berkasFromForm returns the uploaded files keyed by requirement kode. Each
+// requirement is sent as its own field named berkas[<kode>].
+func berkasFromForm(ctx *gin.Context) map[string][]*multipart.FileHeader {
+	files := make(map[string][]*multipart.FileHeader)
+	form, err := ctx.MultipartForm()
+	if err != nil || form == nil {
+		return files
+	}
+
+	for field, list := range form.File {
+		if strings.HasPrefix(field, "berkas[") && strings.HasSuffix(field, "]") {
+			kode := strings.TrimSuffix(strings.TrimPrefix(field, "berkas["), "]")
+			files[kode] = append(files[kode], list...)
+		} else {
+			// Reported as unknown requirement by the service
+			files[field] = append(files[field], list...)
+		}
+	}
+	return files
+}
+
+// berkasErrorResponse answers a rejected upload with the error of each file
+func berkasErrorResponse(ctx *gin.Context, message string, err *services.ValidasiBerkasError) {
+	ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+		Success: false,
+		Message: message,
+		Data:    err.Detail,
+		Error:   err.Error(),
+	})
+}
+
+func (c *PermohonanController) GetAll(ctx *gin.Context) {
+	var pagination dto.PaginationQuery
+	if err := ctx.ShouldBindQuery(&pagination); err != nil {
+		pagination = dto.PaginationQuery{Page: 1, PerPage: 10}
+	}
+
+	if pagination.Page < 1 {
+		pagination.Page = 1
+	}
+
+	response, err := c.service.GetAll(pagination)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Message: "Gagal mengambil data",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Data:    response,
+	})
+}
+
+func (c *PermohonanController) GetByID(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "ID tidak valid",
+		})
+		return
+	}
+
+	permohonan, err := c.service.GetByID(id)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, dto.APIResponse{
+			Success: false,
+			Message: "Permohonan tidak ditemukan",
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Data:    permohonan,
+	})
+}
+
+func (c *PermohonanController) GetByStatus(ctx *gin.Context) {
+	status := ctx.Param("status")
+	if status == "" {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "Status tidak valid",
+		})
+		return
+	}
+
+	list, err := c.service.GetByStatus(status)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Message: "Gagal mengambil data",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Data:    list,
+	})
+}
+
+func (c *PermohonanController) Lacak(ctx *gin.Context) {
+	var query dto.LacakPermohonanQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "Nomor permohonan dan email harus diisi",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	result, err := c.service.Lacak(query)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, dto.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Data:    result,
+	})
+}
+
+func (c *PermohonanController) UpdateStatus(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "ID tidak valid",
+		})
+		return
+	}
+
+	adminID, exists := ctx.Get("admin_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, dto.APIResponse{
+			Success: false,
+			Message: "Unauthorized",
+		})
+		return
+	}
+
+	var req dto.UpdatePermohonanStatusRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "Data tidak valid",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	err = c.service.UpdateStatus(id, adminID.(uuid.UUID), req)
+	if err != nil {
+		ctx.JSON(statusCodeForError(err), dto.APIResponse{
+			Success: false,
+			Message: "Gagal update status",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Status berhasil diupdate",
+	})
+}
+
+func (c *PermohonanController) KirimBalasan(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "ID tidak valid",
+		})
+		return
+	}
+
+	adminID, exists := ctx.Get("admin_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, dto.APIResponse{
+			Success: false,
+			Message: "Unauthorized",
+		})
+		return
+	}
+
+	// Get form data
+	var req dto.KirimBalasanRequest
+	req.BalasanEmail = ctx.PostForm("balasan_email")
+	req.Status = ctx.PostForm("status")
+	req.CatatanAdmin = ctx.PostForm("catatan_admin")
+	req.Penandatangan = ctx.PostForm("penandatangan")
+
+	if req.BalasanEmail == "" || req.Status == "" {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "balasan_email dan status harus diisi",
+		})
+		return
+	}
+
+	if req.Status != "disetujui" && req.Status != "ditolak" {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "status harus disetujui atau ditolak",
+		})
+		return
+	}
+
+	// Handle optional file attachment; without it the official letter is
+	// generated as PDF
+	lampiran, _ := ctx.FormFile("lampiran")
+	if lampiran != nil {
+		// Validate file type
+		ext := strings.ToLower(filepath.Ext(lampiran.Filename))
+		allowedExts := map[string]bool{".pdf": true, ".doc": true, ".docx": true}
+		if !allowedExts[ext] {
+			ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+				Success: false,
+				Message: "Format file tidak didukung. Gunakan PDF, DOC, atau DOCX",
+			})
+			return
+		}
+	}
+
+	err = c.service.KirimBalasan(id, adminID.(uuid.UUID), req, lampiran)
+	if err != nil {
+		var validasiErr *services.ValidasiBerkasError
+		if errors.As(err, &validasiErr) {
+			berkasErrorResponse(ctx, "Lampiran surat ditolak", validasiErr)
+			return
+		}
+		ctx.JSON(statusCodeForError(err), dto.APIResponse{
+			Success: false,
+			Message: "Gagal mengirim balasan",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Balasan berhasil dikirim ke email pemohon",
+	})
+}
+
+func (c *PermohonanController) MintaRevisi(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "ID tidak valid",
+		})
+		return
+	}
+
+	adminID, exists := ctx.Get("admin_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, dto.APIResponse{
+			Success: false,
+			Message: "Unauthorized",
+		})
+		return
+	}
+
+	var req dto.KirimRevisiRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "Data tidak valid",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	err = c.service.MintaRevisi(id, adminID.(uuid.UUID), req)
+	if err != nil {
+		ctx.JSON(statusCodeForError(err), dto.APIResponse{
+			Success: false,
+			Message: "Gagal meminta revisi",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Permintaan revisi berhasil dikirim ke email pemohon",
+	})
+}
+
+func (c *PermohonanController) GetRevisiInfo(ctx *gin.Context) {
+	info, err := c.service.GetRevisiInfo(ctx.Param("token"))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, dto.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Data:    info,
+	})
+}
+
+func (c *PermohonanController) UploadRevisi(ctx *gin.Context) {
+	token := ctx.Param("token")
+
+	// Validate the link before writing anything to disk
+	if _, err := c.service.GetRevisiInfo(token); err != nil {
+		ctx.JSON(http.StatusNotFound, dto.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.limitRequestBody(ctx)
+	err := ctx.Request.ParseMultipartForm(10 << 20) // 10 MB max
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "Gagal parsing form data",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	err = c.service.UploadRevisi(token, berkasFromForm(ctx))
+	if err != nil {
+		var validasiErr *services.ValidasiBerkasError
+		if errors.As(err, &validasiErr) {
+			berkasErrorResponse(ctx, "Berkas revisi tidak lengkap atau tidak valid", validasiErr)
+			return
+		}
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "Gagal mengunggah revisi",
+			Error:   err.Error(),
+		})
+		return
+	}
 
-	// Validate required fields
-	if req.Pemohon.NamaLengkap == "" || req.Pemohon.Email == "" || req.JenisPerizinanID == "" {
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Berkas revisi berhasil diunggah",
+	})
+}
+
+func (c *PermohonanController) DownloadSurat(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
 		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
 			Success: false,
-			Message: "Data tidak lengkap",
+			Message: "ID tidak valid",
 		})
 		return
 	}
 
-	// Handle file uploads
-	var berkasFiles []models.Berkas
-	form, _ := ctx.MultipartForm()
-	if form != nil && form.File["berkas"] != nil {
-		files := form.File["berkas"]
-		for _, file := range files {
-			// Generate unique filename
-			ext := filepath.Ext(file.Filename)
-			newFilename := fmt.Sprintf("%s_%d%s", uuid.New().String(), time.Now().Unix(), ext)
-			filePath := filepath.Join(c.uploadPath, newFilename)
+	surat, err := c.service.GetSuratTerbaru(id)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, dto.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
 
-			// Ensure upload directory exists
-			os.MkdirAll(c.uploadPath, os.ModePerm)
+	downloadName := strings.ReplaceAll(surat.NomorSurat, "/", "-") + ".pdf"
+	serveFromStorage(ctx, c.storage, surat.Path, downloadName, "application/pdf", "")
+}
 
-			// Save file
-			src, err := file.Open()
-			if err != nil {
-				continue
-			}
-			defer src.Close()
+func (c *PermohonanController) GetStatistik(ctx *gin.Context) {
+	statistik, err := c.service.GetStatistik()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Message: "Gagal mengambil statistik",
+			Error:   err.Error(),
+		})
+		return
+	}
 
-			dst, err := os.Create(filePath)
-			if err != nil {
-				continue
-			}
-			defer dst.Close()
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Data:    statistik,
+	})
+}
 
-			io.Copy(dst, src)
+func (c *PermohonanController) GetRecentPermohonan(ctx *gin.Context) {
+	list, err := c.service.GetRecentPermohonan(5)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Message: "Gagal mengambil data",
+			Error:   err.Error(),
+		})
+		return
+	}
 
-			berkasFiles = append(berkasFiles, models.Berkas{
-				NamaFile: newFilename,
-				NamaAsli: file.Filename,
-				Path:     filePath,
-				Ukuran:   file.Size,
-				MimeType: file.Header.Get("Content-Type"),
-			})
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Data:    list,
+	})
+}
+
+// contentDisposition builds the header for a file name that may come from the
+// applicant: quotes and semicolons are escaped and non-ASCII names are
+// encoded as in RFC 2231
+func contentDisposition(disposisi, nama string) string {
+	if header := mime.FormatMediaType(disposisi, map[string]string{"filename": nama}); header != "" {
+		return header
+	}
+	return disposisi
+}
+
+// serveFromStorage streams a stored file with range request support. An
+// empty downloadName serves it inline; an empty contentType uses the type
+// recorded by the storage. A non-empty checksum is verified while the file
+// is streamed, see services.BacaTerverifikasi.
+func serveFromStorage(ctx *gin.Context, storage services.Storage, key, downloadName, contentType, checksum string) {
+	r, obj, err := storage.Open(ctx.Request.Context(), key)
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := "Gagal membaca file"
+		switch {
+		case errors.Is(err, services.ErrFileTidakDitemukan):
+			status, message = http.StatusNotFound, "File tidak ditemukan"
+		case errors.Is(err, services.ErrKeyTidakValid):
+			status, message = http.StatusBadRequest, "Filename tidak valid"
 		}
+		ctx.JSON(status, dto.APIResponse{
+			Success: false,
+			Message: message,
+		})
+		return
+	}
+	defer r.Close()
+
+	if contentType == "" {
+		contentType = obj.ContentType
+	}
+	if contentType != "" {
+		ctx.Header("Content-Type", contentType)
+	}
+	if downloadName != "" {
+		ctx.Header("Content-Disposition", contentDisposition("attachment", downloadName))
+	}
+	var content io.ReadSeeker = r
+	if checksum != "" {
+		content = services.BacaTerverifikasi(r, obj.Size, checksum)
+	}
+	http.ServeContent(ctx.Writer, ctx.Request, path.Base(obj.Key), obj.ModTime, content)
+}
+
+// ============== Surat Controller ==============
+
+type SuratController struct {
+	service services.SuratService
+}
+
+func NewSuratController(service services.SuratService) *SuratController {
+	return &SuratController{service: service}
+}
+
+// Verifikasi is the public endpoint behind the QR code printed on letters
+func (c *SuratController) Verifikasi(ctx *gin.Context) {
+	result, err := c.service.Verifikasi(ctx.Param("token"))
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, dto.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: result.Keterangan,
+		Data:    result,
+	})
+}
+
+func (c *SuratController) Cabut(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "ID tidak valid",
+		})
+		return
+	}
+
+	var req dto.CabutSuratRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "Data tidak valid",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	err = c.service.Cabut(id, req.Alasan)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "Gagal mencabut surat",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Surat izin berhasil dicabut",
+	})
+}
+
+// ============== Akses Berkas Controller ==============
+
+type AksesBerkasController struct {
+	service services.AksesBerkasService
+	storage services.Storage
+}
+
+func NewAksesBerkasController(service services.AksesBerkasService, storage services.Storage) *AksesBerkasController {
+	return &AksesBerkasController{service: service, storage: storage}
+}
+
+// UnduhBerkas serves an uploaded berkas to a signed-in admin
+func (c *AksesBerkasController) UnduhBerkas(ctx *gin.Context) {
+	c.unduhSesi(ctx, models.JenisAksesBerkas)
+}
+
+// UnduhLampiran serves the letter sent with the reply of a permohonan
+func (c *AksesBerkasController) UnduhLampiran(ctx *gin.Context) {
+	c.unduhSesi(ctx, models.JenisAksesLampiran)
+}
+
+// UnduhBundel streams all files of a permohonan as one ZIP
+func (c *AksesBerkasController) UnduhBundel(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "ID tidak valid",
+		})
+		return
+	}
+
+	adminID, _ := ctx.Get("admin_id")
+	c.bundel(ctx, id, adminID.(uuid.UUID), models.MetodeAksesSesi)
+}
+
+func (c *AksesBerkasController) TautanBundel(ctx *gin.Context) {
+	c.buatTautan(ctx, models.JenisAksesBundel)
+}
+
+// PratinjauBerkas serves the preview image of a berkas inline for the
+// detail view
+func (c *AksesBerkasController) PratinjauBerkas(ctx *gin.Context) {
+	c.unduhSesi(ctx, models.JenisAksesPratinjau)
+}
+
+func (c *AksesBerkasController) TautanBerkas(ctx *gin.Context) {
+	c.buatTautan(ctx, models.JenisAksesBerkas)
+}
+
+func (c *AksesBerkasController) TautanLampiran(ctx *gin.Context) {
+	c.buatTautan(ctx, models.JenisAksesLampiran)
+}
+
+// UnduhTautan is the public target of signed links; the signature stands
+// in for the bearer token
+func (c *AksesBerkasController) UnduhTautan(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "ID tidak valid",
+		})
+		return
+	}
+
+	jenis := ctx.Param("jenis")
+	adminID, err := c.service.VerifikasiTautan(jenis, id, ctx.Query("admin"), ctx.Query("sesi"), ctx.Query("kadaluarsa"), ctx.Query("signature"))
+	if err != nil {
+		ctx.JSON(statusCodeForError(err), dto.APIResponse{
+			Success: false,
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if jenis == models.JenisAksesBundel {
+		c.bundel(ctx, id, adminID, models.MetodeAksesTautan)
+		return
+	}
+	c.unduh(ctx, jenis, id, adminID, models.MetodeAksesTautan)
+}
+
+func (c *AksesBerkasController) GetByPermohonanID(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "ID tidak valid",
+		})
+		return
 	}
 
-	permohonan, err := c.service.Create(req, berkasFiles)
+	list, err := c.service.GetByPermohonanID(id)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, dto.APIResponse{
 			Success: false,
-			Message: "Gagal membuat permohonan",
+			Message: "Gagal mengambil riwayat akses berkas",
 			Error:   err.Error(),
 		})
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, dto.APIResponse{
+	ctx.JSON(http.StatusOK, dto.APIResponse{
 		Success: true,
-		Message: "Permohonan berhasil diajukan",
-		Data:    permohonan,
+		Data:    list,
 	})
 }
 
-func (c *PermohonanController) GetAll(ctx *gin.Context) {
-	var pagination dto.PaginationQuery
-	if err := ctx.ShouldBindQuery(&pagination); err != nil {
-		pagination = dto.PaginationQuery{Page: 1, PerPage: 10}
+func (c *AksesBerkasController) unduhSesi(ctx *gin.Context, jenis string) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "ID tidak valid",
+		})
+		return
+	}
+
+	adminID, _ := ctx.Get("admin_id")
+	c.unduh(ctx, jenis, id, adminID.(uuid.UUID), models.MetodeAksesSesi)
+}
+
+func (c *AksesBerkasController) unduh(ctx *gin.Context, jenis string, id, adminID uuid.UUID, metode string) {
+	unduhan, err := c.service.Unduh(jenis, id, services.AksesInfo{
+		AdminID:   adminID,
+		Metode:    metode,
+		IPAddress: ctx.ClientIP(),
+		UserAgent: ctx.Request.UserAgent(),
+	})
+	if err != nil {
+		ctx.JSON(statusCodeForError(err), dto.APIResponse{
+			Success: false,
+			Message: "Gagal mengunduh berkas",
+			Error:   err.Error(),
+		})
+		return
 	}
 
-	if pagination.Page < 1 {
-		pagination.Page = 1
+	// Personal documents must not linger in shared or proxy caches
+	ctx.Header("Cache-Control", "private, no-store")
+	if jenis == models.JenisAksesPratinjau {
+		ctx.Header("Content-Disposition", contentDisposition("inline", unduhan.NamaUnduh))
+		serveFromStorage(ctx, c.storage, unduhan.Key, "", unduhan.ContentType, unduhan.SHA256)
+		return
 	}
+	serveFromStorage(ctx, c.storage, unduhan.Key, unduhan.NamaUnduh, unduhan.ContentType, unduhan.SHA256)
+}
 
-	response, err := c.service.GetAll(pagination)
+func (c *AksesBerkasController) bundel(ctx *gin.Context, id, adminID uuid.UUID, metode string) {
+	bundel, err := c.service.BuatBundel(id, services.AksesInfo{
+		AdminID:   adminID,
+		Metode:    metode,
+		IPAddress: ctx.ClientIP(),
+		UserAgent: ctx.Request.UserAgent(),
+	})
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, dto.APIResponse{
+		ctx.JSON(statusCodeForError(err), dto.APIResponse{
 			Success: false,
-			Message: "Gagal mengambil data",
+			Message: "Gagal mengunduh berkas",
 			Error:   err.Error(),
 		})
 		return
 	}
 
-	ctx.JSON(http.StatusOK, dto.APIResponse{
-		Success: true,
-		Data:    response,
-	})
+	ctx.Header("Content-Type", "application/zip")
+	ctx.Header("Content-Disposition", contentDisposition("attachment", bundel.NamaFile))
+	ctx.Header("Cache-Control", "private, no-store")
+	ctx.Status(http.StatusOK)
+
+	// The archive is streamed, so once writing started the status can no
+	// longer change; a broken download leaves an archive that fails to open
+	if err := c.service.TulisBundel(ctx.Writer, bundel); err != nil {
+		log.Printf("Warning: gagal menulis bundel berkas %s: %v", id, err)
+	}
 }
 
-func (c *PermohonanController) GetByID(ctx *gin.Context) {
+func (c *AksesBerkasController) buatTautan(ctx *gin.Context, jenis string) {
 	id, err := uuid.Parse(ctx.Param("id"))
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
@@ -596,32 +2072,64 @@ func (c *PermohonanController) GetByID(ctx *gin.Context) {
 		return
 	}
 
-	permohonan, err := c.service.GetByID(id)
+	adminID, _ := ctx.Get("admin_id")
+	sesiID, _ := ctx.Get("sesi_id")
+	tautan, err := c.service.BuatTautan(jenis, id, adminID.(uuid.UUID), sesiID.(uuid.UUID))
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, dto.APIResponse{
+		ctx.JSON(statusCodeForError(err), dto.APIResponse{
 			Success: false,
-			Message: "Permohonan tidak ditemukan",
+			Message: "Gagal membuat tautan unduhan",
+			Error:   err.Error(),
 		})
 		return
 	}
 
 	ctx.JSON(http.StatusOK, dto.APIResponse{
 		Success: true,
-		Data:    permohonan,
+		Data:    tautan,
 	})
 }
 
-func (c *PermohonanController) GetByStatus(ctx *gin.Context) {
-	status := ctx.Param("status")
-	if status == "" {
+// ============== Template Email Controller ==============
+
+type TemplateEmailController struct {
+	service services.TemplateEmailService
+}
+
+func NewTemplateEmailController(service services.TemplateEmailService) *TemplateEmailController {
+	return &TemplateEmailController{service: service}
+}
+
+func (c *TemplateEmailController) Create(ctx *gin.Context) {
+	var req dto.CreateTemplateEmailRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
 			Success: false,
-			Message: "Status tidak valid",
+			Message: "Data tidak valid",
+			Error:   err.Error(),
 		})
 		return
 	}
 
-	list, err := c.service.GetByStatus(status)
+	tpl, err := c.service.Create(req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "Gagal membuat template email",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, dto.APIResponse{
+		Success: true,
+		Message: "Template email berhasil dibuat",
+		Data:    tpl,
+	})
+}
+
+func (c *TemplateEmailController) GetAll(ctx *gin.Context) {
+	list, err := c.service.GetAll()
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, dto.APIResponse{
 			Success: false,
@@ -637,7 +2145,7 @@ func (c *PermohonanController) GetByStatus(ctx *gin.Context) {
 	})
 }
 
-func (c *PermohonanController) UpdateStatus(ctx *gin.Context) {
+func (c *TemplateEmailController) GetByID(ctx *gin.Context) {
 	id, err := uuid.Parse(ctx.Param("id"))
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
@@ -647,16 +2155,32 @@ func (c *PermohonanController) UpdateStatus(ctx *gin.Context) {
 		return
 	}
 
-	adminID, exists := ctx.Get("admin_id")
-	if !exists {
-		ctx.JSON(http.StatusUnauthorized, dto.APIResponse{
+	tpl, err := c.service.GetByID(id)
+	if err != nil {
+		ctx.JSON(http.StatusNotFound, dto.APIResponse{
 			Success: false,
-			Message: "Unauthorized",
+			Message: err.Error(),
 		})
 		return
 	}
 
-	var req dto.UpdatePermohonanStatusRequest
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Data:    tpl,
+	})
+}
+
+func (c *TemplateEmailController) Update(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "ID tidak valid",
+		})
+		return
+	}
+
+	var req dto.UpdateTemplateEmailRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
 			Success: false,
@@ -666,11 +2190,11 @@ func (c *PermohonanController) UpdateStatus(ctx *gin.Context) {
 		return
 	}
 
-	err = c.service.UpdateStatus(id, adminID.(uuid.UUID), req)
+	tpl, err := c.service.Update(id, req)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, dto.APIResponse{
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
 			Success: false,
-			Message: "Gagal update status",
+			Message: "Gagal mengupdate template email",
 			Error:   err.Error(),
 		})
 		return
@@ -678,11 +2202,12 @@ func (c *PermohonanController) UpdateStatus(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, dto.APIResponse{
 		Success: true,
-		Message: "Status berhasil diupdate",
+		Message: "Template email berhasil diupdate",
+		Data:    tpl,
 	})
 }
 
-func (c *PermohonanController) KirimBalasan(ctx *gin.Context) {
+func (c *TemplateEmailController) Delete(ctx *gin.Context) {
 	id, err := uuid.Parse(ctx.Param("id"))
 	if err != nil {
 		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
@@ -692,80 +2217,75 @@ func (c *PermohonanController) KirimBalasan(ctx *gin.Context) {
 		return
 	}
 
-	adminID, exists := ctx.Get("admin_id")
-	if !exists {
-		ctx.JSON(http.StatusUnauthorized, dto.APIResponse{
+	err = c.service.Delete(id)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
 			Success: false,
-			Message: "Unauthorized",
+			Message: "Gagal menghapus template email",
+			Error:   err.Error(),
 		})
 		return
 	}
 
-	// Get form data
-	balasanEmail := ctx.PostForm("balasan_email")
-	status := ctx.PostForm("status")
-	catatanAdmin := ctx.PostForm("catatan_admin")
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Template email berhasil dihapus",
+	})
+}
 
-	if balasanEmail == "" || status == "" {
+func (c *TemplateEmailController) Preview(ctx *gin.Context) {
+	var req dto.PreviewTemplateEmailRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
 			Success: false,
-			Message: "balasan_email dan status harus diisi",
+			Message: "Data tidak valid",
+			Error:   err.Error(),
 		})
 		return
 	}
 
-	if status != "disetujui" && status != "ditolak" {
+	preview, err := c.service.Preview(req)
+	if err != nil {
 		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
 			Success: false,
-			Message: "status harus disetujui atau ditolak",
+			Message: "Gagal membuat pratinjau",
+			Error:   err.Error(),
 		})
 		return
 	}
 
-	// Handle optional file attachment
-	attachmentPath := ""
-	file, err := ctx.FormFile("lampiran")
-	if err == nil && file != nil {
-		// Validate file type
-		ext := filepath.Ext(file.Filename)
-		allowedExts := map[string]bool{".pdf": true, ".doc": true, ".docx": true}
-		if !allowedExts[ext] {
-			ctx.JSON(http.StatusBadRequest, dto.APIResponse{
-				Success: false,
-				Message: "Format file tidak didukung. Gunakan PDF, DOC, atau DOCX",
-			})
-			return
-		}
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Data:    preview,
+	})
+}
 
-		// Create surat folder if not exists
-		suratDir := "uploads/surat"
-		if err := os.MkdirAll(suratDir, 0755); err != nil {
-			ctx.JSON(http.StatusInternalServerError, dto.APIResponse{
-				Success: false,
-				Message: "Gagal membuat folder surat",
-			})
-			return
-		}
+// ============== Email Log Controller ==============
 
-		// Generate unique filename
-		filename := fmt.Sprintf("%d_%s%s", time.Now().Unix(), id.String()[:8], ext)
-		attachmentPath = filepath.Join(suratDir, filename)
+type EmailLogController struct {
+	service services.EmailService
+}
 
-		// Save file
-		if err := ctx.SaveUploadedFile(file, attachmentPath); err != nil {
-			ctx.JSON(http.StatusInternalServerError, dto.APIResponse{
-				Success: false,
-				Message: "Gagal menyimpan file lampiran",
-			})
-			return
-		}
+func NewEmailLogController(service services.EmailService) *EmailLogController {
+	return &EmailLogController{service: service}
+}
+
+func (c *EmailLogController) GetAll(ctx *gin.Context) {
+	var query dto.PaginationQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		query.Page = 1
+		query.PerPage = 10
+	}
+
+	if query.Page < 1 {
+		query.Page = 1
 	}
 
-	err = c.service.KirimBalasan(id, adminID.(uuid.UUID), balasanEmail, status, attachmentPath, catatanAdmin)
+	result, err := c.service.GetLogs(query)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, dto.APIResponse{
 			Success: false,
-			Message: "Gagal mengirim balasan",
+			Message: "Gagal mengambil data email",
 			Error:   err.Error(),
 		})
 		return
@@ -773,33 +2293,25 @@ func (c *PermohonanController) KirimBalasan(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, dto.APIResponse{
 		Success: true,
-		Message: "Balasan berhasil dikirim ke email pemohon",
+		Data:    result,
 	})
 }
 
-func (c *PermohonanController) GetStatistik(ctx *gin.Context) {
-	statistik, err := c.service.GetStatistik()
+func (c *EmailLogController) Retry(ctx *gin.Context) {
+	id, err := uuid.Parse(ctx.Param("id"))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, dto.APIResponse{
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
 			Success: false,
-			Message: "Gagal mengambil statistik",
-			Error:   err.Error(),
+			Message: "ID tidak valid",
 		})
 		return
 	}
 
-	ctx.JSON(http.StatusOK, dto.APIResponse{
-		Success: true,
-		Data:    statistik,
-	})
-}
-
-func (c *PermohonanController) GetRecentPermohonan(ctx *gin.Context) {
-	list, err := c.service.GetRecentPermohonan(5)
+	err = c.service.Retry(id)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, dto.APIResponse{
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
 			Success: false,
-			Message: "Gagal mengambil data",
+			Message: "Gagal mengirim ulang email",
 			Error:   err.Error(),
 		})
 		return
@@ -807,56 +2319,19 @@ func (c *PermohonanController) GetRecentPermohonan(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, dto.APIResponse{
 		Success: true,
-		Data:    list,
+		Message: "Email dimasukkan kembali ke antrean pengiriman",
 	})
 }
 
-func (c *PermohonanController) DownloadFile(ctx *gin.Context) {
-	filename := ctx.Param("filename")
-	originalName := ctx.Query("name")
-
-	if filename == "" {
-		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
-			Success: false,
-			Message: "Filename tidak valid",
-		})
-		return
-	}
-
-	filePath := filepath.Join(c.uploadPath, filename)
-
-	// Check if file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		ctx.JSON(http.StatusNotFound, dto.APIResponse{
-			Success: false,
-			Message: "File tidak ditemukan",
-		})
-		return
-	}
-
-	// Set download name
-	downloadName := filename
-	if originalName != "" {
-		downloadName = originalName
-	}
-
-	// Set headers for download
-	ctx.Header("Content-Description", "File Transfer")
-	ctx.Header("Content-Transfer-Encoding", "binary")
-	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", downloadName))
-	ctx.Header("Content-Type", "application/octet-stream")
-
-	ctx.File(filePath)
-}
-
 // ============== Notifikasi Controller ==============
 
 type NotifikasiController struct {
 	service services.NotifikasiService
+	broker  services.EventBroker
 }
 
-func NewNotifikasiController(service services.NotifikasiService) *NotifikasiController {
-	return &NotifikasiController{service: service}
+func NewNotifikasiController(service services.NotifikasiService, broker services.EventBroker) *NotifikasiController {
+	return &NotifikasiController{service: service, broker: broker}
 }
 
 func (c *NotifikasiController) GetAll(ctx *gin.Context) {
@@ -963,3 +2438,126 @@ func (c *NotifikasiController) CountUnread(ctx *gin.Context) {
 		Data:    map[string]int64{"count": count},
 	})
 }
+
+func (c *NotifikasiController) GetPreferensi(ctx *gin.Context) {
+	adminID, exists := ctx.Get("admin_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, dto.APIResponse{
+			Success: false,
+			Message: "Unauthorized",
+		})
+		return
+	}
+
+	pref, err := c.service.GetPreferensi(adminID.(uuid.UUID))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, dto.APIResponse{
+			Success: false,
+			Message: "Gagal mengambil preferensi notifikasi",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Data:    pref,
+	})
+}
+
+func (c *NotifikasiController) UpdatePreferensi(ctx *gin.Context) {
+	adminID, exists := ctx.Get("admin_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, dto.APIResponse{
+			Success: false,
+			Message: "Unauthorized",
+		})
+		return
+	}
+
+	var req dto.PreferensiNotifikasiRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "Data tidak valid",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	pref, err := c.service.UpdatePreferensi(adminID.(uuid.UUID), req)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, dto.APIResponse{
+			Success: false,
+			Message: "Gagal menyimpan preferensi notifikasi",
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, dto.APIResponse{
+		Success: true,
+		Message: "Preferensi notifikasi berhasil disimpan",
+		Data:    pref,
+	})
+}
+
+// Stream pushes notifications and status changes as Server-Sent Events.
+// Clients resume after a reconnect by sending the Last-Event-ID header (or
+// the last_event_id query parameter).
+func (c *NotifikasiController) Stream(ctx *gin.Context) {
+	adminID, exists := ctx.Get("admin_id")
+	if !exists {
+		ctx.JSON(http.StatusUnauthorized, dto.APIResponse{
+			Success: false,
+			Message: "Unauthorized",
+		})
+		return
+	}
+
+	lastEventID := ctx.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = ctx.Query("last_event_id")
+	}
+	missed, events, unsubscribe := c.broker.Subscribe(adminID.(uuid.UUID), lastEventID)
+	defer unsubscribe()
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+
+	fmt.Fprint(ctx.Writer, "retry: 3000\n\n")
+	for _, event := range missed {
+		writeSSEvent(ctx.Writer, event)
+	}
+	ctx.Writer.Flush()
+
+	heartbeat := time.NewTicker(25 * time.Second)
+	defer heartbeat.Stop()
+
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Request.Context().Done():
+			return false
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			writeSSEvent(w, event)
+			return true
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			return true
+		}
+	})
+}
+
+func writeSSEvent(w io.Writer, event services.Event) {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
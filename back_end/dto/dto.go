@@ -13,10 +13,42 @@ type LoginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// LoginResponse carries either a session or, for admins with two-factor
+// authentication, a TokenTantangan for the next login step
 type LoginResponse struct {
-	Token     string    `json:"token"`
-	ExpiresAt time.Time `json:"expires_at"`
-	Admin     AdminInfo `json:"admin"`
+	Token            string    `json:"token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	Admin            AdminInfo `json:"admin"`
+
+	Perlu2FA       bool     `json:"perlu_2fa,omitempty"` // enter a TOTP or recovery code
+	Setup2FA       bool     `json:"setup_2fa,omitempty"` // the role requires 2FA; enroll first
+	TokenTantangan string   `json:"token_tantangan,omitempty"`
+	KodePemulihan  []string `json:"kode_pemulihan,omitempty"` // shown once, after enrolling at login
+}
+
+type Verifikasi2FARequest struct {
+	TokenTantangan string `json:"token_tantangan" binding:"required"`
+	Kode           string `json:"kode" binding:"required"`
+}
+
+type TokenTantanganRequest struct {
+	TokenTantangan string `json:"token_tantangan" binding:"required"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type SesiResponse struct {
+	ID              uuid.UUID `json:"id"`
+	IPAddress       string    `json:"ip_address"`
+	UserAgent       string    `json:"user_agent"`
+	CreatedAt       time.Time `json:"created_at"`
+	TerakhirDipakai time.Time `json:"terakhir_dipakai"`
+	KadaluarsaPada  time.Time `json:"kadaluarsa_pada"`
+	SesiIni         bool      `json:"sesi_ini"` // the session making the request
 }
 
 type AdminInfo struct {
@@ -25,31 +57,98 @@ type AdminInfo struct {
 	Email       string    `json:"email"`
 	NamaLengkap string    `json:"nama_lengkap"`
 	Role        string    `json:"role"`
+	TOTPAktif   bool      `json:"totp_aktif"`
+	Izin        []string  `json:"izin"` // permissions of the role, for showing the right menus
+}
+
+// ============== Password DTOs ==============
+
+type LupaPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordTokenRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+type TokenResetPasswordResponse struct {
+	Username      string    `json:"username"`
+	BerlakuSampai time.Time `json:"berlaku_sampai"`
+}
+
+// KebijakanPasswordResponse describes the password policy so forms can show
+// the rules before submitting
+type KebijakanPasswordResponse struct {
+	MinPanjang int      `json:"min_panjang"`
+	KelasWajib []string `json:"kelas_wajib"` // lower, upper, digit, symbol
+	Riwayat    int      `json:"riwayat"`     // recent passwords that may not be reused
+}
+
+// ============== Two-Factor DTOs ==============
+
+type Setup2FAResponse struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauth_uri"`
+	QRCode     string `json:"qr_code"` // PNG data URI of OtpauthURI
+}
+
+type Kode2FARequest struct {
+	Kode string `json:"kode" binding:"required"`
+}
+
+type Nonaktifkan2FARequest struct {
+	Password string `json:"password" binding:"required"`
+	Kode     string `json:"kode" binding:"required"`
+}
+
+type Status2FAResponse struct {
+	Aktif             bool       `json:"aktif"`
+	Wajib             bool       `json:"wajib"`
+	AktifPada         *time.Time `json:"aktif_pada,omitempty"`
+	SisaKodePemulihan int64      `json:"sisa_kode_pemulihan"`
+}
+
+type KodePemulihanResponse struct {
+	KodePemulihan []string `json:"kode_pemulihan"`
 }
 
 // ============== Jenis Perizinan DTOs ==============
 
+// PersyaratanItem describes one required document of a jenis perizinan.
+// Kode is derived from Nama when left empty.
+type PersyaratanItem struct {
+	Kode       string   `json:"kode"`
+	Nama       string   `json:"nama" binding:"required"`
+	Wajib      bool     `json:"wajib"`
+	TipeFile   []string `json:"tipe_file"`
+	UkuranMaks int64    `json:"ukuran_maks" binding:"min=0"`
+}
+
 type CreateJenisPerizinanRequest struct {
-	Nama        string   `json:"nama" binding:"required"`
-	Deskripsi   string   `json:"deskripsi"`
-	Persyaratan []string `json:"persyaratan"`
-	Aktif       bool     `json:"aktif"`
+	Nama        string            `json:"nama" binding:"required"`
+	Kode        string            `json:"kode" binding:"omitempty,max=10"`
+	Deskripsi   string            `json:"deskripsi"`
+	Persyaratan []PersyaratanItem `json:"persyaratan" binding:"dive"`
+	Aktif       bool              `json:"aktif"`
 }
 
 type UpdateJenisPerizinanRequest struct {
-	Nama        string   `json:"nama"`
-	Deskripsi   string   `json:"deskripsi"`
-	Persyaratan []string `json:"persyaratan"`
-	Aktif       *bool    `json:"aktif"`
+	Nama        string            `json:"nama"`
+	Kode        string            `json:"kode" binding:"omitempty,max=10"`
+	Deskripsi   string            `json:"deskripsi"`
+	Persyaratan []PersyaratanItem `json:"persyaratan" binding:"dive"`
+	Aktif       *bool             `json:"aktif"`
 }
 
 type JenisPerizinanResponse struct {
-	ID          uuid.UUID `json:"id"`
-	Nama        string    `json:"nama"`
-	Deskripsi   string    `json:"deskripsi"`
-	Persyaratan []string  `json:"persyaratan"`
-	Aktif       bool      `json:"aktif"`
-	CreatedAt   time.Time `json:"created_at"`
+	ID          uuid.UUID         `json:"id"`
+	Nama        string            `json:"nama"`
+	Kode        string            `json:"kode"`
+	Deskripsi   string            `json:"deskripsi"`
+	Persyaratan []PersyaratanItem `json:"persyaratan"`
+	Aktif       bool              `json:"aktif"`
+	CreatedAt   time.Time         `json:"created_at"`
 }
 
 // ============== Pemohon DTOs ==============
@@ -79,40 +178,133 @@ type CreatePermohonanRequest struct {
 }
 
 type UpdatePermohonanStatusRequest struct {
-	Status       string `json:"status" binding:"required,oneof=baru diproses disetujui ditolak"`
+	Status       string `json:"status" binding:"required,oneof=diproses"` // disetujui/ditolak go through KirimBalasanRequest
 	CatatanAdmin string `json:"catatan_admin"`
 }
 
 type KirimBalasanRequest struct {
-	BalasanEmail string `json:"balasan_email" binding:"required"`
-	Status       string `json:"status" binding:"required,oneof=disetujui ditolak"`
+	BalasanEmail  string `json:"balasan_email" form:"balasan_email" binding:"required"`
+	Status        string `json:"status" form:"status" binding:"required,oneof=disetujui ditolak"`
+	CatatanAdmin  string `json:"catatan_admin" form:"catatan_admin"`
+	Penandatangan string `json:"penandatangan" form:"penandatangan"`
+}
+
+type SuratIzinResponse struct {
+	ID                   uuid.UUID `json:"id"`
+	NomorSurat           string    `json:"nomor_surat"`
+	Jenis                string    `json:"jenis"`
+	TanggalSurat         time.Time `json:"tanggal_surat"`
+	Penandatangan        string    `json:"penandatangan"`
+	JabatanPenandatangan string    `json:"jabatan_penandatangan"`
+	Path                 string    `json:"path"`
+	Dicabut              bool      `json:"dicabut"`
+}
+
+type CabutSuratRequest struct {
+	Alasan string `json:"alasan" binding:"required"`
+}
+
+// VerifikasiSuratResponse is the public result of scanning a letter's QR code
+type VerifikasiSuratResponse struct {
+	NomorSurat       string     `json:"nomor_surat"`
+	NomorPermohonan  string     `json:"nomor_permohonan"`
+	NamaPemohon      string     `json:"nama_pemohon"`
+	JenisPerizinan   string     `json:"jenis_perizinan"`
+	TanggalTerbit    time.Time  `json:"tanggal_terbit"`
+	Penandatangan    string     `json:"penandatangan"`
+	Berlaku          bool       `json:"berlaku"`
+	Keterangan       string     `json:"keterangan"`
+	DicabutPada      *time.Time `json:"dicabut_pada,omitempty"`
+	AlasanPencabutan string     `json:"alasan_pencabutan,omitempty"`
+}
+
+type KirimRevisiRequest struct {
+	Persyaratan   []string `json:"persyaratan" binding:"required,min=1"`
+	CatatanRevisi string   `json:"catatan_revisi" binding:"required"`
+	CatatanAdmin  string   `json:"catatan_admin"`
+}
+
+type RevisiInfoResponse struct {
+	NomorPermohonan   string   `json:"nomor_permohonan"`
+	NamaPemohon       string   `json:"nama_pemohon"`
+	JenisPerizinan    string   `json:"jenis_perizinan"`
+	PersyaratanRevisi []string `json:"persyaratan_revisi"`
+	// Berkas lists the requirements to re-upload; files are sent as berkas[<kode>]
+	Berkas        []PersyaratanItem `json:"berkas"`
+	CatatanRevisi string            `json:"catatan_revisi"`
+	BerlakuSampai time.Time         `json:"berlaku_sampai"`
 }
 
 type BerkasResponse struct {
-	ID        uuid.UUID `json:"id"`
-	NamaFile  string    `json:"nama_file"`
-	NamaAsli  string    `json:"nama_asli"`
-	Path      string    `json:"path"`
-	Ukuran    int64     `json:"ukuran"`
-	MimeType  string    `json:"mime_type"`
-	CreatedAt time.Time `json:"created_at"`
+	ID              uuid.UUID `json:"id"`
+	PersyaratanKode string    `json:"persyaratan_kode"`
+	NamaFile        string    `json:"nama_file"`
+	NamaAsli        string    `json:"nama_asli"`
+	Path            string    `json:"path"`
+	Ukuran          int64     `json:"ukuran"`
+	MimeType        string    `json:"mime_type"`
+	SHA256          string    `json:"sha256"`
+	StatusScan      string    `json:"status_scan"`
+	HasilScan       string    `json:"hasil_scan,omitempty"`
+	AdaPratinjau    bool      `json:"ada_pratinjau"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// BerkasErrorResponse explains why an uploaded document was rejected
+type BerkasErrorResponse struct {
+	Persyaratan string `json:"persyaratan,omitempty"`
+	NamaFile    string `json:"nama_file,omitempty"`
+	Pesan       string `json:"pesan"`
 }
 
 type PermohonanResponse struct {
-	ID              uuid.UUID              `json:"id"`
-	NomorPermohonan string                 `json:"nomor_permohonan"`
-	Pemohon         PemohonResponse        `json:"pemohon"`
-	JenisPerizinan  JenisPerizinanResponse `json:"jenis_perizinan"`
-	Berkas          []BerkasResponse       `json:"berkas"`
-	Catatan         string                 `json:"catatan"`
-	Status          string                 `json:"status"`
-	TanggalMasuk    time.Time              `json:"tanggal_masuk"`
-	TanggalDiproses *time.Time             `json:"tanggal_diproses"`
-	TanggalSelesai  *time.Time             `json:"tanggal_selesai"`
-	BalasanEmail    string                 `json:"balasan_email"`
-	CatatanAdmin    string                 `json:"catatan_admin"`
-	LampiranSurat   string                 `json:"lampiran_surat"`
-	CreatedAt       time.Time              `json:"created_at"`
+	ID                uuid.UUID                   `json:"id"`
+	NomorPermohonan   string                      `json:"nomor_permohonan"`
+	Pemohon           PemohonResponse             `json:"pemohon"`
+	JenisPerizinan    JenisPerizinanResponse      `json:"jenis_perizinan"`
+	Berkas            []BerkasResponse            `json:"berkas"`
+	Catatan           string                      `json:"catatan"`
+	Status            string                      `json:"status"`
+	TanggalMasuk      time.Time                   `json:"tanggal_masuk"`
+	TanggalDiproses   *time.Time                  `json:"tanggal_diproses"`
+	TanggalSelesai    *time.Time                  `json:"tanggal_selesai"`
+	BalasanEmail      string                      `json:"balasan_email"`
+	CatatanAdmin      string                      `json:"catatan_admin"`
+	LampiranSurat     string                      `json:"lampiran_surat"`
+	PersyaratanRevisi []string                    `json:"persyaratan_revisi,omitempty"`
+	CatatanRevisi     string                      `json:"catatan_revisi,omitempty"`
+	Riwayat           []RiwayatPermohonanResponse `json:"riwayat,omitempty"`
+	Surat             []SuratIzinResponse         `json:"surat,omitempty"`
+	CreatedAt         time.Time                   `json:"created_at"`
+}
+
+type RiwayatPermohonanResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	StatusDari string     `json:"status_dari"`
+	StatusKe   string     `json:"status_ke"`
+	AdminID    *uuid.UUID `json:"admin_id"`
+	NamaAdmin  string     `json:"nama_admin"`
+	Catatan    string     `json:"catatan"`
+	Tanggal    time.Time  `json:"tanggal"`
+}
+
+type LacakPermohonanQuery struct {
+	NomorPermohonan string `form:"nomor" binding:"required"`
+	Email           string `form:"email" binding:"required,email"`
+}
+
+// LacakPermohonanResponse is the public view of a permohonan; it must not
+// expose admin-only fields such as CatatanAdmin or other pemohon data.
+type LacakPermohonanResponse struct {
+	NomorPermohonan   string     `json:"nomor_permohonan"`
+	JenisPerizinan    string     `json:"jenis_perizinan"`
+	Status            string     `json:"status"`
+	TanggalMasuk      time.Time  `json:"tanggal_masuk"`
+	TanggalDiproses   *time.Time `json:"tanggal_diproses"`
+	TanggalSelesai    *time.Time `json:"tanggal_selesai"`
+	BalasanEmail      string     `json:"balasan_email"`
+	PersyaratanRevisi []string   `json:"persyaratan_revisi,omitempty"`
+	CatatanRevisi     string     `json:"catatan_revisi,omitempty"`
 }
 
 type PermohonanListResponse struct {
@@ -126,12 +318,13 @@ type PermohonanListResponse struct {
 // ============== Dashboard DTOs ==============
 
 type StatistikDashboard struct {
-	TotalPermohonan     int64 `json:"total_permohonan"`
-	PermohonanBaru      int64 `json:"permohonan_baru"`
-	PermohonanDiproses  int64 `json:"permohonan_diproses"`
-	PermohonanSelesai   int64 `json:"permohonan_selesai"`
-	PermohonanDisetujui int64 `json:"permohonan_disetujui"`
-	PermohonanDitolak   int64 `json:"permohonan_ditolak"`
+	TotalPermohonan       int64 `json:"total_permohonan"`
+	PermohonanBaru        int64 `json:"permohonan_baru"`
+	PermohonanDiproses    int64 `json:"permohonan_diproses"`
+	PermohonanSelesai     int64 `json:"permohonan_selesai"`
+	PermohonanDisetujui   int64 `json:"permohonan_disetujui"`
+	PermohonanDitolak     int64 `json:"permohonan_ditolak"`
+	PermohonanPerluRevisi int64 `json:"permohonan_perlu_revisi"`
 }
 
 // ============== Notifikasi DTOs ==============
@@ -139,51 +332,178 @@ type StatistikDashboard struct {
 type NotifikasiResponse struct {
 	ID           uuid.UUID `json:"id"`
 	PermohonanID uuid.UUID `json:"permohonan_id"`
+	Event        string    `json:"event"`
 	Pesan        string    `json:"pesan"`
 	Dibaca       bool      `json:"dibaca"`
 	Tanggal      time.Time `json:"tanggal"`
 }
 
+// PreferensiNotifikasiRequest replaces the admin's subscription; empty lists
+// mean all jenis perizinan / all events
+type PreferensiNotifikasiRequest struct {
+	JenisPerizinanID []string `json:"jenis_perizinan_id"`
+	Event            []string `json:"event" binding:"dive,oneof=permohonan_baru revisi_diunggah"`
+}
+
+type PreferensiNotifikasiResponse struct {
+	JenisPerizinanID []string `json:"jenis_perizinan_id"`
+	Event            []string `json:"event"`
+	EventTersedia    []string `json:"event_tersedia"`
+}
+
+// PermohonanStatusEvent is pushed over the notification stream whenever a
+// permohonan changes status
+type PermohonanStatusEvent struct {
+	PermohonanID uuid.UUID  `json:"permohonan_id"`
+	StatusDari   string     `json:"status_dari"`
+	StatusKe     string     `json:"status_ke"`
+	AdminID      *uuid.UUID `json:"admin_id"`
+	Tanggal      time.Time  `json:"tanggal"`
+}
+
 type MarkNotifikasiReadRequest struct {
 	NotifikasiIDs []string `json:"notifikasi_ids" binding:"required"`
 }
 
+// ============== Template Email DTOs ==============
+
+type CreateTemplateEmailRequest struct {
+	Status           string `json:"status" binding:"required,oneof=diproses disetujui ditolak perlu_revisi"`
+	JenisPerizinanID string `json:"jenis_perizinan_id"`
+	Subjek           string `json:"subjek" binding:"required"`
+	IsiHTML          string `json:"isi_html" binding:"required"`
+	IsiTeks          string `json:"isi_teks"`
+	Aktif            *bool  `json:"aktif"`
+}
+
+type UpdateTemplateEmailRequest struct {
+	Subjek  string `json:"subjek"`
+	IsiHTML string `json:"isi_html"`
+	IsiTeks string `json:"isi_teks"`
+	Aktif   *bool  `json:"aktif"`
+}
+
+// PreviewTemplateEmailRequest renders the given template content against a
+// permohonan (or built-in sample data when PermohonanID is empty)
+type PreviewTemplateEmailRequest struct {
+	Status       string `json:"status" binding:"required,oneof=diproses disetujui ditolak perlu_revisi"`
+	Subjek       string `json:"subjek" binding:"required"`
+	IsiHTML      string `json:"isi_html" binding:"required"`
+	IsiTeks      string `json:"isi_teks"`
+	PermohonanID string `json:"permohonan_id"`
+}
+
+type PreviewTemplateEmailResponse struct {
+	Subjek  string `json:"subjek"`
+	IsiHTML string `json:"isi_html"`
+	IsiTeks string `json:"isi_teks"`
+}
+
+type TemplateEmailResponse struct {
+	ID                  uuid.UUID  `json:"id"`
+	Status              string     `json:"status"`
+	JenisPerizinanID    *uuid.UUID `json:"jenis_perizinan_id"`
+	NamaJenisPerizinan  string     `json:"nama_jenis_perizinan"`
+	Subjek              string     `json:"subjek"`
+	IsiHTML             string     `json:"isi_html"`
+	IsiTeks             string     `json:"isi_teks"`
+	Aktif               bool       `json:"aktif"`
+	PlaceholderTersedia []string   `json:"placeholder_tersedia"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// ============== Email Log DTOs ==============
+
+type EmailLogResponse struct {
+	ID              uuid.UUID  `json:"id"`
+	PermohonanID    *uuid.UUID `json:"permohonan_id"`
+	EmailTujuan     string     `json:"email_tujuan"`
+	Subjek          string     `json:"subjek"`
+	Status          string     `json:"status"`
+	Error           string     `json:"error"`
+	Percobaan       int        `json:"percobaan"`
+	PercobaanMaks   int        `json:"percobaan_maks"`
+	KirimBerikutnya *time.Time `json:"kirim_berikutnya"`
+	SentAt          *time.Time `json:"sent_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+type EmailLogListResponse struct {
+	Data       []EmailLogResponse `json:"data"`
+	Total      int64              `json:"total"`
+	Page       int                `json:"page"`
+	PerPage    int                `json:"per_page"`
+	TotalPages int                `json:"total_pages"`
+}
+
+// ============== Akses Berkas DTOs ==============
+
+// TiketStreamResponse is a short-lived ticket for opening the notification
+// stream, passed as ?tiket=
+type TiketStreamResponse struct {
+	Tiket          string    `json:"tiket"`
+	KadaluarsaPada time.Time `json:"kadaluarsa_pada"`
+}
+
+// TautanUnduhResponse is a short-lived signed link to a stored file
+type TautanUnduhResponse struct {
+	URL            string    `json:"url"`
+	KadaluarsaPada time.Time `json:"kadaluarsa_pada"`
+}
+
+type AksesBerkasResponse struct {
+	ID           uuid.UUID  `json:"id"`
+	PermohonanID uuid.UUID  `json:"permohonan_id"`
+	BerkasID     *uuid.UUID `json:"berkas_id"`
+	Jenis        string     `json:"jenis"`
+	NamaFile     string     `json:"nama_file"`
+	AdminID      uuid.UUID  `json:"admin_id"`
+	NamaAdmin    string     `json:"nama_admin"`
+	Metode       string     `json:"metode"`
+	IPAddress    string     `json:"ip_address"`
+	UserAgent    string     `json:"user_agent"`
+	DiaksesPada  time.Time  `json:"diakses_pada"`
+}
+
 // ============== Admin Management DTOs ==============
 
 type CreateAdminRequest struct {
 	Username    string `json:"username" binding:"required,min=3,max=50"`
-	Password    string `json:"password" binding:"required,min=6"`
+	Password    string `json:"password" binding:"required"`
 	Email       string `json:"email" binding:"required,email"`
 	NamaLengkap string `json:"nama_lengkap" binding:"required"`
-	Role        string `json:"role" binding:"required,oneof=super_admin admin"`
+	Role        string `json:"role" binding:"required,max=50"`
 }
 
 type UpdateAdminRequest struct {
 	Username    string `json:"username" binding:"omitempty,min=3,max=50"`
 	Email       string `json:"email" binding:"omitempty,email"`
 	NamaLengkap string `json:"nama_lengkap"`
-	Role        string `json:"role" binding:"omitempty,oneof=super_admin admin"`
+	Role        string `json:"role" binding:"omitempty,max=50"`
 	IsActive    *bool  `json:"is_active"`
 }
 
 type ChangePasswordRequest struct {
 	OldPassword string `json:"old_password" binding:"required"`
-	NewPassword string `json:"new_password" binding:"required,min=6"`
+	NewPassword string `json:"new_password" binding:"required"`
 }
 
 type ResetPasswordRequest struct {
-	NewPassword string `json:"new_password" binding:"required,min=6"`
+	NewPassword string `json:"new_password" binding:"required"`
 }
 
 type AdminResponse struct {
-	ID          uuid.UUID `json:"id"`
-	Username    string    `json:"username"`
-	Email       string    `json:"email"`
-	NamaLengkap string    `json:"nama_lengkap"`
-	Role        string    `json:"role"`
-	IsActive    bool      `json:"is_active"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID             uuid.UUID  `json:"id"`
+	Username       string     `json:"username"`
+	Email          string     `json:"email"`
+	NamaLengkap    string     `json:"nama_lengkap"`
+	Role           string     `json:"role"`
+	IsActive       bool       `json:"is_active"`
+	TOTPAktif      bool       `json:"totp_aktif"`
+	GagalLogin     int        `json:"gagal_login"`
+	TerkunciSampai *time.Time `json:"terkunci_sampai,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
 }
 
 type AdminListResponse struct {
@@ -194,6 +514,71 @@ type AdminListResponse struct {
 	TotalPages int             `json:"total_pages"`
 }
 
+// ============== Role DTOs ==============
+
+type CreateRoleRequest struct {
+	Nama      string   `json:"nama" binding:"required,min=3,max=50"`
+	Label     string   `json:"label" binding:"required,max=100"`
+	Deskripsi string   `json:"deskripsi"`
+	Izin      []string `json:"izin" binding:"required"`
+}
+
+// UpdateRoleRequest changes a role; the name stays since admins refer to it
+type UpdateRoleRequest struct {
+	Label     string   `json:"label" binding:"omitempty,max=100"`
+	Deskripsi *string  `json:"deskripsi"`
+	Izin      []string `json:"izin"`
+}
+
+type RoleResponse struct {
+	ID          uuid.UUID `json:"id"`
+	Nama        string    `json:"nama"`
+	Label       string    `json:"label"`
+	Deskripsi   string    `json:"deskripsi"`
+	Izin        []string  `json:"izin"`
+	Bawaan      bool      `json:"bawaan"`
+	JumlahAdmin int64     `json:"jumlah_admin"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type IzinResponse struct {
+	Kode      string `json:"kode"`
+	Deskripsi string `json:"deskripsi"`
+}
+
+// ============== Login Protection DTOs ==============
+
+type AkunTerkunciResponse struct {
+	AdminID        uuid.UUID `json:"admin_id"`
+	Username       string    `json:"username"`
+	NamaLengkap    string    `json:"nama_lengkap"`
+	GagalLogin     int       `json:"gagal_login"`
+	TerkunciSampai time.Time `json:"terkunci_sampai"`
+}
+
+type IPTerbatasResponse struct {
+	IPAddress      string    `json:"ip_address"`
+	Jumlah         int64     `json:"jumlah"`
+	Terakhir       time.Time `json:"terakhir"`
+	TerkunciSampai time.Time `json:"terkunci_sampai"`
+}
+
+type PenguncianLoginResponse struct {
+	Akun []AkunTerkunciResponse `json:"akun"`
+	IP   []IPTerbatasResponse   `json:"ip"`
+}
+
+type PercobaanLoginResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Username  string    `json:"username"`
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	Alasan    string    `json:"alasan"`
+	Direset   bool      `json:"direset"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 // ============== Common DTOs ==============
 
 type APIResponse struct {
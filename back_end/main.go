@@ -1,8 +1,14 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/alifsyafan/backend-capston/config"
 	"github.com/alifsyafan/backend-capston/controllers"
@@ -29,6 +35,9 @@ func main() {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
+	// Must run before the unique index on templates is created
+	migrateTemplateEmailKunci(db)
+
 	// Auto migrate models
 	err = db.AutoMigrate(
 		&models.Admin{},
@@ -36,8 +45,21 @@ func main() {
 		&models.Pemohon{},
 		&models.Permohonan{},
 		&models.Berkas{},
+		&models.RiwayatPermohonan{},
 		&models.Notifikasi{},
+		&models.PreferensiNotifikasi{},
 		&models.EmailLog{},
+		&models.TemplateEmail{},
+		&models.SuratIzin{},
+		&models.NomorUrut{},
+		&models.KunciBerkas{},
+		&models.AksesBerkas{},
+		&models.SesiAdmin{},
+		&models.PercobaanLogin{},
+		&models.KodePemulihan{},
+		&models.TokenResetPassword{},
+		&models.RiwayatPassword{},
+		&models.Role{},
 	)
 	if err != nil {
 		log.Fatalf("Failed to migrate database: %v", err)
@@ -47,6 +69,39 @@ func main() {
 	// Run role migration for existing admins
 	migrateAdminRoles(db)
 
+	// Initialize file storage
+	storage, err := services.NewStorage(cfg)
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+
+	// Maintenance commands run instead of the server:
+	//   `go run . migrate-storage [-hapus-lokal]` moves existing files into
+	//   the configured storage
+	//   `go run . check-storage [-hapus-yatim]` reports missing, corrupt and
+	//   orphaned files; failed uploads clean up after themselves, but
+	//   running it with -hapus-yatim from cron catches what a crash leaves
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate-storage":
+			fs := flag.NewFlagSet("migrate-storage", flag.ExitOnError)
+			hapusLokal := fs.Bool("hapus-lokal", false, "delete local files after they are copied")
+			fs.Parse(os.Args[2:])
+			if err := migrateStorage(db, cfg, storage, *hapusLokal); err != nil {
+				log.Fatalf("Failed to migrate storage: %v", err)
+			}
+			return
+		case "check-storage":
+			fs := flag.NewFlagSet("check-storage", flag.ExitOnError)
+			hapusYatim := fs.Bool("hapus-yatim", false, "delete files no record refers to")
+			fs.Parse(os.Args[2:])
+			if err := checkStorage(db, storage, *hapusYatim); err != nil {
+				log.Fatalf("Storage check failed: %v", err)
+			}
+			return
+		}
+	}
+
 	// Initialize repositories
 	adminRepo := repositories.NewAdminRepository(db)
 	jpRepo := repositories.NewJenisPerizinanRepository(db)
@@ -54,6 +109,23 @@ func main() {
 	permohonanRepo := repositories.NewPermohonanRepository(db)
 	notifRepo := repositories.NewNotifikasiRepository(db)
 	emailLogRepo := repositories.NewEmailLogRepository(db)
+	riwayatRepo := repositories.NewRiwayatPermohonanRepository(db)
+	berkasRepo := repositories.NewBerkasRepository(db)
+	prefNotifRepo := repositories.NewPreferensiNotifikasiRepository(db)
+	templateEmailRepo := repositories.NewTemplateEmailRepository(db)
+	suratRepo := repositories.NewSuratIzinRepository(db)
+	nomorUrutRepo := repositories.NewNomorUrutRepository(db)
+	aksesBerkasRepo := repositories.NewAksesBerkasRepository(db)
+	sesiRepo := repositories.NewSesiAdminRepository(db)
+	percobaanLoginRepo := repositories.NewPercobaanLoginRepository(db)
+	kodePemulihanRepo := repositories.NewKodePemulihanRepository(db)
+	tokenResetPasswordRepo := repositories.NewTokenResetPasswordRepository(db)
+	riwayatPasswordRepo := repositories.NewRiwayatPasswordRepository(db)
+	roleRepo := repositories.NewRoleRepository(db)
+	transactor := repositories.NewTransactor(db)
+
+	// Create built-in roles if not exists
+	createDefaultRoles(roleRepo)
 
 	// Create default admin if not exists
 	createDefaultAdmin(adminRepo, cfg)
@@ -61,23 +133,47 @@ func main() {
 	// Create default jenis perizinan if not exists
 	createDefaultJenisPerizinan(jpRepo)
 
+	// Create default email templates if not exists
+	createDefaultTemplateEmail(templateEmailRepo)
+
 	// Initialize services
-	authService := services.NewAuthService(adminRepo, cfg)
-	adminService := services.NewAdminService(adminRepo)
+	roleService := services.NewRoleService(roleRepo)
+	pembatasanLoginService := services.NewPembatasanLoginService(percobaanLoginRepo, adminRepo, cfg)
+	duaFaktorService := services.NewDuaFaktorService(adminRepo, kodePemulihanRepo, cfg)
+	authService := services.NewAuthService(adminRepo, sesiRepo, pembatasanLoginService, duaFaktorService, roleService, cfg)
+	adminService := services.NewAdminService(adminRepo, sesiRepo, riwayatPasswordRepo, roleService, cfg)
 	jpService := services.NewJenisPerizinanService(jpRepo)
-	emailService := services.NewEmailService(cfg, emailLogRepo)
-	permohonanService := services.NewPermohonanService(permohonanRepo, pemohonRepo, jpRepo, notifRepo, adminRepo, emailService)
-	notifService := services.NewNotifikasiService(notifRepo)
+	emailService := services.NewEmailService(cfg, emailLogRepo, templateEmailRepo, storage)
+	lupaPasswordService := services.NewLupaPasswordService(adminRepo, tokenResetPasswordRepo, riwayatPasswordRepo, sesiRepo, emailService, cfg)
+	eventBroker := services.NewEventBroker()
+	suratService := services.NewSuratService(suratRepo, nomorUrutRepo, storage, cfg)
+	aksesBerkasService := services.NewAksesBerkasService(aksesBerkasRepo, berkasRepo, permohonanRepo, adminRepo, sesiRepo, roleService, storage, cfg)
+	templateEmailService := services.NewTemplateEmailService(templateEmailRepo, jpRepo, permohonanRepo)
+	notifService := services.NewNotifikasiService(notifRepo, prefNotifRepo, adminRepo, jpRepo, eventBroker)
+	permohonanService := services.NewPermohonanService(permohonanRepo, pemohonRepo, jpRepo, adminRepo, riwayatRepo, berkasRepo, nomorUrutRepo, transactor, notifService, emailService, suratService, eventBroker, services.NewScanner(cfg), storage, roleService, cfg)
 
 	// Initialize controllers
 	authController := controllers.NewAuthController(authService)
 	adminController := controllers.NewAdminController(adminService)
 	jpController := controllers.NewJenisPerizinanController(jpService)
-	permohonanController := controllers.NewPermohonanController(permohonanService, cfg.UploadPath)
-	notifController := controllers.NewNotifikasiController(notifService)
+	// Allow some room for the form fields on top of the files themselves
+	maxRequestSize, _ := strconv.ParseInt(cfg.MaxSubmissionSize, 10, 64)
+	if maxRequestSize > 0 {
+		maxRequestSize += 1 << 20
+	}
+	permohonanController := controllers.NewPermohonanController(permohonanService, storage, maxRequestSize)
+	notifController := controllers.NewNotifikasiController(notifService, eventBroker)
+	emailLogController := controllers.NewEmailLogController(emailService)
+	templateEmailController := controllers.NewTemplateEmailController(templateEmailService)
+	suratController := controllers.NewSuratController(suratService)
+	aksesBerkasController := controllers.NewAksesBerkasController(aksesBerkasService, storage)
+	pembatasanLoginController := controllers.NewPembatasanLoginController(pembatasanLoginService)
+	duaFaktorController := controllers.NewDuaFaktorController(duaFaktorService)
+	lupaPasswordController := controllers.NewLupaPasswordController(lupaPasswordService)
+	roleController := controllers.NewRoleController(roleService)
 
-	// Create uploads directory
-	os.MkdirAll(cfg.UploadPath, os.ModePerm)
+	// Deliver queued emails in the background
+	go emailService.StartWorker(context.Background())
 
 	// Setup router
 	router := gin.Default()
@@ -93,7 +189,16 @@ func main() {
 		permohonanController,
 		notifController,
 		adminController,
+		emailLogController,
+		templateEmailController,
+		suratController,
+		aksesBerkasController,
+		pembatasanLoginController,
+		duaFaktorController,
+		lupaPasswordController,
+		roleController,
 		authService,
+		roleService,
 	)
 
 	// Health check endpoint
@@ -122,6 +227,11 @@ func createDefaultAdmin(adminRepo repositories.AdminRepository, cfg *config.Conf
 		return
 	}
 
+	// The seeded password has to pass the same policy as every other password
+	if err := services.PeriksaKebijakanPassword(cfg, cfg.AdminPassword); err != nil {
+		log.Fatalf("ADMIN_PASSWORD tidak memenuhi kebijakan password: %v", err)
+	}
+
 	// Hash password
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(cfg.AdminPassword), bcrypt.DefaultCost)
 	if err != nil {
@@ -149,8 +259,35 @@ func createDefaultAdmin(adminRepo repositories.AdminRepository, cfg *config.Conf
 	log.Printf("   Password: %s", cfg.AdminPassword)
 }
 
-// migrateAdminRoles migrates existing admins without role to super_admin
+// createDefaultRoles adds the built-in roles that are missing, so roles
+// introduced by an update also reach existing databases
+func createDefaultRoles(roleRepo repositories.RoleRepository) {
+	for _, role := range services.DefaultRoles() {
+		if _, err := roleRepo.FindByNama(role.Nama); err == nil {
+			continue
+		}
+		if err := roleRepo.Create(&role); err != nil {
+			log.Printf("Warning: Failed to create role '%s': %v", role.Nama, err)
+			continue
+		}
+		log.Printf("✅ Role '%s' created successfully", role.Nama)
+	}
+}
+
+// migrateAdminRoles migrates existing admins without role to super_admin.
+// It runs once, on the first start before the built-in roles are seeded;
+// afterwards roles are managed in the database and a deliberate change to
+// the default admin's role must stick.
 func migrateAdminRoles(db *gorm.DB) {
+	var jumlahRole int64
+	if err := db.Model(&models.Role{}).Count(&jumlahRole).Error; err != nil {
+		log.Printf("Warning: Failed to migrate admin roles: %v", err)
+		return
+	}
+	if jumlahRole > 0 {
+		return
+	}
+
 	// Update all admins with empty or null role to super_admin
 	result := db.Model(&models.Admin{}).
 		Where("role IS NULL OR role = '' OR role = 'admin'").
@@ -167,6 +304,218 @@ func migrateAdminRoles(db *gorm.DB) {
 	}
 }
 
+// migrateTemplateEmailKunci adds TemplateEmail.JenisKunci to an existing
+// table and clears the rows that would break its unique index: soft-deleted
+// templates, and duplicates for one status and jenis perizinan, of which the
+// one FindByStatus returned so far is kept. It does nothing once the column
+// exists.
+func migrateTemplateEmailKunci(db *gorm.DB) {
+	m := db.Migrator()
+	if !m.HasTable(&models.TemplateEmail{}) || m.HasColumn(&models.TemplateEmail{}, "JenisKunci") {
+		return
+	}
+
+	if err := m.AddColumn(&models.TemplateEmail{}, "JenisKunci"); err != nil {
+		log.Fatalf("Failed to migrate email templates: %v", err)
+	}
+	err := db.Model(&models.TemplateEmail{}).Unscoped().
+		Where("jenis_perizinan_id IS NOT NULL").
+		UpdateColumn("jenis_kunci", gorm.Expr("jenis_perizinan_id")).Error
+	if err != nil {
+		log.Fatalf("Failed to migrate email templates: %v", err)
+	}
+	if err := db.Unscoped().Where("deleted_at IS NOT NULL").Delete(&models.TemplateEmail{}).Error; err != nil {
+		log.Fatalf("Failed to migrate email templates: %v", err)
+	}
+
+	result := db.Exec(`DELETE t1 FROM template_emails t1
+		JOIN template_emails t2 ON t1.status = t2.status AND t1.jenis_kunci = t2.jenis_kunci AND t1.id > t2.id`)
+	if result.Error != nil {
+		log.Fatalf("Failed to migrate email templates: %v", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("✅ Removed %d duplicate email template(s)", result.RowsAffected)
+	}
+}
+
+// migrateStorage copies every file below UPLOAD_PATH into the configured
+// storage and rewrites file paths in the database to storage keys. Files
+// already in storage are skipped, so it is safe to run more than once.
+func migrateStorage(db *gorm.DB, cfg *config.Config, storage services.Storage, hapusLokal bool) error {
+	ctx := context.Background()
+	local := services.NewLocalStorage(cfg.UploadPath)
+	remote := cfg.StorageDriver != "" && cfg.StorageDriver != "local"
+
+	if remote {
+		files, err := local.List(ctx, "")
+		if err != nil {
+			return err
+		}
+
+		copied := 0
+		for _, f := range files {
+			exists, err := storage.Exists(ctx, f.Key)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				if err := copyStorageFile(ctx, local, storage, f.Key); err != nil {
+					return fmt.Errorf("gagal menyalin %s: %w", f.Key, err)
+				}
+				copied++
+			}
+			if hapusLokal {
+				if err := local.Delete(ctx, f.Key); err != nil {
+					log.Printf("Warning: Failed to delete local file %s: %v", f.Key, err)
+				}
+			}
+		}
+		log.Printf("✅ Copied %d of %d file(s) to %s storage", copied, len(files), cfg.StorageDriver)
+	}
+
+	// Paths written before storage keys were introduced still include the
+	// upload directory
+	for _, c := range fileColumns {
+		var paths []string
+		if err := db.Unscoped().Model(c.model).Where(c.column+" <> ''").Distinct().Pluck(c.column, &paths).Error; err != nil {
+			return err
+		}
+
+		updated := 0
+		for _, p := range paths {
+			key, err := services.StorageKey(cfg.UploadPath, p)
+			if err != nil {
+				log.Printf("Warning: Skipping %s: %v", p, err)
+				continue
+			}
+			if exists, err := storage.Exists(ctx, key); err == nil && !exists {
+				log.Printf("Warning: File %s not found in storage", key)
+			}
+			if key == p {
+				continue
+			}
+			if err := db.Unscoped().Model(c.model).Where(c.column+" = ?", p).UpdateColumn(c.column, key).Error; err != nil {
+				return err
+			}
+			updated++
+		}
+		if updated > 0 {
+			log.Printf("✅ Rewrote %d %s value(s) to storage keys", updated, c.column)
+		}
+	}
+
+	return nil
+}
+
+// fileColumns are the database columns that refer to stored files
+var fileColumns = []struct {
+	model  interface{}
+	column string
+}{
+	{&models.Berkas{}, "path"},
+	{&models.Berkas{}, "pratinjau_path"},
+	{&models.SuratIzin{}, "path"},
+	{&models.Permohonan{}, "lampiran_surat"},
+	{&models.EmailLog{}, "lampiran"},
+}
+
+// checkStorage compares the database with the configured storage. It
+// reports files that are referenced but missing, berkas whose content no
+// longer matches the checksum taken at upload, and files no record refers
+// to. Berkas uploaded before checksums were introduced get theirs filled in.
+// With hapusYatim, orphaned files older than an hour are deleted; younger
+// ones may belong to an upload still in progress.
+func checkStorage(db *gorm.DB, storage services.Storage, hapusYatim bool) error {
+	ctx := context.Background()
+	referenced := make(map[string]bool)
+	missing := 0
+
+	for _, c := range fileColumns {
+		var keys []string
+		if err := db.Unscoped().Model(c.model).Where(c.column+" <> ''").Distinct().Pluck(c.column, &keys).Error; err != nil {
+			return err
+		}
+		for _, key := range keys {
+			referenced[key] = true
+			exists, err := storage.Exists(ctx, key)
+			if err != nil && !errors.Is(err, services.ErrKeyTidakValid) {
+				return err
+			}
+			if !exists {
+				log.Printf("❌ Missing: %s (%s)", key, c.column)
+				missing++
+			}
+		}
+	}
+
+	corrupt, backfilled := 0, 0
+	var batch []models.Berkas
+	err := db.Select("id", "path", "sha256").FindInBatches(&batch, 100, func(tx *gorm.DB, _ int) error {
+		for _, b := range batch {
+			sum, err := services.ChecksumFile(ctx, storage, b.Path)
+			if errors.Is(err, services.ErrFileTidakDitemukan) || errors.Is(err, services.ErrKeyTidakValid) {
+				continue // already reported as missing
+			}
+			if err != nil {
+				return err
+			}
+			switch {
+			case b.SHA256 == "":
+				if err := db.Model(&models.Berkas{}).Where("id = ?", b.ID).UpdateColumn("sha256", sum).Error; err != nil {
+					return err
+				}
+				backfilled++
+			case sum != b.SHA256:
+				log.Printf("❌ Corrupt: %s (berkas %s, expected %s, got %s)", b.Path, b.ID, b.SHA256, sum)
+				corrupt++
+			}
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	files, err := storage.List(ctx, "")
+	if err != nil {
+		return err
+	}
+	orphaned, deleted := 0, 0
+	for _, f := range files {
+		if referenced[f.Key] {
+			continue
+		}
+		orphaned++
+		if !hapusYatim || time.Since(f.ModTime) < time.Hour {
+			log.Printf("⚠️  Orphaned: %s", f.Key)
+			continue
+		}
+		if err := storage.Delete(ctx, f.Key); err != nil {
+			log.Printf("Warning: Failed to delete orphaned file %s: %v", f.Key, err)
+			continue
+		}
+		log.Printf("🗑️  Deleted orphaned file %s", f.Key)
+		deleted++
+	}
+
+	log.Printf("Checked %d file(s): %d missing, %d corrupt, %d orphaned (%d deleted), %d checksum(s) filled in",
+		len(files), missing, corrupt, orphaned, deleted, backfilled)
+	if missing > 0 || corrupt > 0 {
+		return fmt.Errorf("%d missing and %d corrupt file(s)", missing, corrupt)
+	}
+	log.Println("✅ Storage is consistent")
+	return nil
+}
+
+func copyStorageFile(ctx context.Context, from, to services.Storage, key string) error {
+	r, obj, err := from.Open(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return to.Put(ctx, key, r, obj.Size, obj.ContentType)
+}
+
 func createDefaultJenisPerizinan(jpRepo repositories.JenisPerizinanRepository) {
 	// Check if any jenis perizinan exists
 	list, _ := jpRepo.FindAll(false)
@@ -178,34 +527,56 @@ func createDefaultJenisPerizinan(jpRepo repositories.JenisPerizinanRepository) {
 	// Create default jenis perizinan
 	defaultJP := []models.JenisPerizinan{
 		{
-			Nama:        "Izin Penelitian",
-			Deskripsi:   "Izin untuk melakukan penelitian di lingkungan Dinas Kesehatan",
-			Persyaratan: models.StringArray{"Surat pengantar dari instansi", "Proposal penelitian", "KTP"},
-			Aktif:       true,
+			Nama:      "Izin Penelitian",
+			Kode:      "IPN",
+			Deskripsi: "Izin untuk melakukan penelitian di lingkungan Dinas Kesehatan",
+			Persyaratan: models.PersyaratanList{
+				{Kode: "surat_pengantar_dari_instansi", Nama: "Surat pengantar dari instansi", Wajib: true, TipeFile: []string{".pdf", ".docx"}},
+				{Kode: "proposal_penelitian", Nama: "Proposal penelitian", Wajib: true, TipeFile: []string{".pdf", ".docx"}},
+				{Kode: "ktp", Nama: "KTP", Wajib: true, TipeFile: []string{".pdf", ".jpg", ".jpeg", ".png"}},
+			},
+			Aktif: true,
 		},
 		{
-			Nama:        "Izin Pengambilan Data Awal",
-			Deskripsi:   "Izin untuk survei pendahuluan atau pengambilan data awal",
-			Persyaratan: models.StringArray{"Surat pengantar", "Proposal"},
-			Aktif:       true,
+			Nama:      "Izin Pengambilan Data Awal",
+			Kode:      "IPD",
+			Deskripsi: "Izin untuk survei pendahuluan atau pengambilan data awal",
+			Persyaratan: models.PersyaratanList{
+				{Kode: "surat_pengantar", Nama: "Surat pengantar", Wajib: true, TipeFile: []string{".pdf", ".docx"}},
+				{Kode: "proposal", Nama: "Proposal", Wajib: true, TipeFile: []string{".pdf", ".docx"}},
+			},
+			Aktif: true,
 		},
 		{
-			Nama:        "Izin Permohonan Magang",
-			Deskripsi:   "Izin untuk PKL/Magang di Dinas Kesehatan",
-			Persyaratan: models.StringArray{"Surat dari kampus", "CV", "Transkrip nilai"},
-			Aktif:       true,
+			Nama:      "Izin Permohonan Magang",
+			Kode:      "IPM",
+			Deskripsi: "Izin untuk PKL/Magang di Dinas Kesehatan",
+			Persyaratan: models.PersyaratanList{
+				{Kode: "surat_dari_kampus", Nama: "Surat dari kampus", Wajib: true, TipeFile: []string{".pdf", ".docx"}},
+				{Kode: "cv", Nama: "CV", Wajib: true, TipeFile: []string{".pdf", ".docx"}},
+				{Kode: "transkrip_nilai", Nama: "Transkrip nilai", Wajib: true, TipeFile: []string{".pdf", ".docx"}},
+			},
+			Aktif: true,
 		},
 		{
-			Nama:        "Izin Kepaniteraan Klinik (Coas)",
-			Deskripsi:   "Izin untuk mahasiswa profesi kesehatan",
-			Persyaratan: models.StringArray{"Surat pengantar fakultas", "Logbook"},
-			Aktif:       true,
+			Nama:      "Izin Kepaniteraan Klinik (Coas)",
+			Kode:      "IKK",
+			Deskripsi: "Izin untuk mahasiswa profesi kesehatan",
+			Persyaratan: models.PersyaratanList{
+				{Kode: "surat_pengantar_fakultas", Nama: "Surat pengantar fakultas", Wajib: true, TipeFile: []string{".pdf", ".docx"}},
+				{Kode: "logbook", Nama: "Logbook", Wajib: true, TipeFile: []string{".pdf", ".docx"}},
+			},
+			Aktif: true,
 		},
 		{
-			Nama:        "Izin Kunjungan Lapangan",
-			Deskripsi:   "Izin untuk kunjungan studi banding atau observasi lapangan",
-			Persyaratan: models.StringArray{"Surat permohonan resmi", "Daftar peserta"},
-			Aktif:       true,
+			Nama:      "Izin Kunjungan Lapangan",
+			Kode:      "IKL",
+			Deskripsi: "Izin untuk kunjungan studi banding atau observasi lapangan",
+			Persyaratan: models.PersyaratanList{
+				{Kode: "surat_permohonan_resmi", Nama: "Surat permohonan resmi", Wajib: true, TipeFile: []string{".pdf", ".docx"}},
+				{Kode: "daftar_peserta", Nama: "Daftar peserta", Wajib: true, TipeFile: []string{".pdf", ".docx"}},
+			},
+			Aktif: true,
 		},
 	}
 
@@ -218,3 +589,21 @@ func createDefaultJenisPerizinan(jpRepo repositories.JenisPerizinanRepository) {
 
 	log.Println("✅ Default jenis perizinan created successfully")
 }
+
+func createDefaultTemplateEmail(templateRepo repositories.TemplateEmailRepository) {
+	// Check if any template already exists
+	list, _ := templateRepo.FindAll()
+	if len(list) > 0 {
+		log.Println("ℹ️  Template email already exists")
+		return
+	}
+
+	for _, tpl := range services.DefaultTemplateEmail() {
+		err := templateRepo.Create(&tpl)
+		if err != nil {
+			log.Printf("Warning: Failed to create template email '%s': %v", tpl.Status, err)
+		}
+	}
+
+	log.Println("✅ Default template email created successfully")
+}
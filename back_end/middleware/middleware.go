@@ -11,7 +11,9 @@ import (
 	"github.com/google/uuid"
 )
 
-// AuthMiddleware checks if the request has a valid JWT token
+// AuthMiddleware checks if the request has a valid JWT token whose session
+// is still active. The account is looked up on every request, so revoked
+// sessions, deactivated admins and role changes take effect immediately.
 func AuthMiddleware(authService services.AuthService) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		// Get token from Authorization header
@@ -71,20 +73,68 @@ func AuthMiddleware(authService services.AuthService) gin.HandlerFunc {
 			return
 		}
 
-		// Extract role from claims
-		role, _ := (*claims)["role"].(string)
+		sesiIDStr, _ := (*claims)["sid"].(string)
+		sesiID, err := uuid.Parse(sesiIDStr)
+		if err != nil {
+			ctx.JSON(http.StatusUnauthorized, dto.APIResponse{
+				Success: false,
+				Message: "Token tidak valid",
+			})
+			ctx.Abort()
+			return
+		}
+
+		admin, err := authService.VerifikasiSesi(adminID, sesiID)
+		if err != nil {
+			ctx.JSON(http.StatusUnauthorized, dto.APIResponse{
+				Success: false,
+				Message: "Sesi tidak valid atau sudah berakhir",
+				Error:   err.Error(),
+			})
+			ctx.Abort()
+			return
+		}
 
-		// Set admin_id, username, and role in context
+		// Set admin_id, sesi_id, username, and role in context
 		ctx.Set("admin_id", adminID)
-		ctx.Set("username", (*claims)["username"])
-		ctx.Set("role", role)
+		ctx.Set("sesi_id", sesiID)
+		ctx.Set("username", admin.Username)
+		ctx.Set("role", string(admin.Role))
 
 		ctx.Next()
 	}
 }
 
-// RoleMiddleware checks if the user has the required role
-func RoleMiddleware(allowedRoles ...models.RoleAdmin) gin.HandlerFunc {
+// TiketStreamMiddleware authenticates the notification stream with a ticket
+// from AuthService.BuatTiketStream in the "tiket" query parameter, since the
+// browser EventSource API cannot set headers. It sets the same context keys
+// as AuthMiddleware.
+func TiketStreamMiddleware(authService services.AuthService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		admin, sesiID, err := authService.VerifikasiTiketStream(ctx.Query("tiket"))
+		if err != nil {
+			ctx.JSON(http.StatusUnauthorized, dto.APIResponse{
+				Success: false,
+				Message: "Tiket stream tidak valid atau sudah kadaluarsa",
+				Error:   err.Error(),
+			})
+			ctx.Abort()
+			return
+		}
+
+		ctx.Set("admin_id", admin.ID)
+		ctx.Set("sesi_id", sesiID)
+		ctx.Set("username", admin.Username)
+		ctx.Set("role", string(admin.Role))
+
+		ctx.Next()
+	}
+}
+
+// IzinMiddleware checks that the role of the user grants at least one of
+// the permissions. The role is read from the database on every request, so
+// changes to a role take effect immediately.
+func IzinMiddleware(roleService services.RoleService, izin ...models.Izin) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		roleStr, exists := ctx.Get("role")
 		if !exists {
@@ -96,12 +146,20 @@ func RoleMiddleware(allowedRoles ...models.RoleAdmin) gin.HandlerFunc {
 			return
 		}
 
-		userRole := models.RoleAdmin(roleStr.(string))
+		milik, err := roleService.IzinRole(models.RoleAdmin(roleStr.(string)))
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, dto.APIResponse{
+				Success: false,
+				Message: "Gagal memeriksa izin",
+				Error:   err.Error(),
+			})
+			ctx.Abort()
+			return
+		}
 
-		// Check if user's role is in allowed roles
 		allowed := false
-		for _, role := range allowedRoles {
-			if userRole == role {
+		for _, i := range izin {
+			if milik.Punya(i) {
 				allowed = true
 				break
 			}
@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -42,6 +45,81 @@ func (s StringArray) Value() (driver.Value, error) {
 	return json.Marshal(s)
 }
 
+// Persyaratan is one document required by a jenis perizinan. Uploaded
+// berkas refer to it by Kode.
+type Persyaratan struct {
+	Kode       string   `json:"kode"`
+	Nama       string   `json:"nama"`
+	Wajib      bool     `json:"wajib"`
+	TipeFile   []string `json:"tipe_file"`   // allowed extensions such as ".pdf", empty means any
+	UkuranMaks int64    `json:"ukuran_maks"` // in bytes, 0 means no specific limit
+}
+
+// PersyaratanList custom type for MySQL JSON
+type PersyaratanList []Persyaratan
+
+// Scan implements the sql.Scanner interface. Rows written before
+// requirements were structured hold plain names; those are read as
+// mandatory requirements keyed by KodePersyaratan.
+func (l *PersyaratanList) Scan(value interface{}) error {
+	var names StringArray
+	if err := names.Scan(value); err == nil {
+		list := make(PersyaratanList, 0, len(names))
+		for _, nama := range names {
+			list = append(list, Persyaratan{Kode: KodePersyaratan(nama), Nama: nama, Wajib: true})
+		}
+		*l = list
+		return nil
+	}
+
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return errors.New("failed to scan PersyaratanList: unsupported type")
+	}
+
+	return json.Unmarshal(bytes, l)
+}
+
+// Value implements the driver.Valuer interface
+func (l PersyaratanList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	return json.Marshal(l)
+}
+
+// Find returns the requirement matching a kode or, for older clients, a nama
+func (l PersyaratanList) Find(kodeOrNama string) (Persyaratan, bool) {
+	for _, item := range l {
+		if item.Kode == kodeOrNama || item.Nama == kodeOrNama {
+			return item, true
+		}
+	}
+	return Persyaratan{}, false
+}
+
+// KodePersyaratan derives a form-safe key from a requirement name,
+// e.g. "Proposal Penelitian" becomes "proposal_penelitian"
+func KodePersyaratan(nama string) string {
+	var b strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(strings.TrimSpace(nama)) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			underscore = false
+		} else if !underscore && b.Len() > 0 {
+			b.WriteByte('_')
+			underscore = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
+
 // Base model with UUID (stored as CHAR(36) in MySQL)
 type BaseModel struct {
 	ID        uuid.UUID      `gorm:"type:char(36);primary_key" json:"id"`
@@ -58,14 +136,109 @@ func (base *BaseModel) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
-// RoleAdmin enum
+// RoleAdmin is the Nama of the Role an admin has
 type RoleAdmin string
 
+// Built-in roles, seeded at startup
 const (
 	RoleSuperAdmin RoleAdmin = "super_admin"
 	RoleAdminUser  RoleAdmin = "admin"
+	RoleAuditor    RoleAdmin = "auditor"
+)
+
+// Izin is a named permission. Routes require permissions rather than role
+// names, and every Role grants a set of them.
+type Izin string
+
+const (
+	IzinLihatPermohonan Izin = "permohonan.lihat"
+	IzinUbahStatus      Izin = "permohonan.ubah_status"
+	IzinBukaKembali     Izin = "permohonan.buka_kembali"
+	IzinKirimBalasan    Izin = "permohonan.kirim_balasan"
+	IzinUnduhBerkas     Izin = "berkas.unduh"
+	IzinLihatLaporan    Izin = "laporan.lihat"
+	IzinKelolaJenis     Izin = "jenis_perizinan.kelola"
+	IzinKelolaAdmin     Izin = "admin.kelola"
+	IzinKelolaRole      Izin = "role.kelola"
+	IzinLihatEmail      Izin = "email.lihat"
+	IzinKirimUlangEmail Izin = "email.kirim_ulang"
+	IzinKelolaTemplate  Izin = "template_email.kelola"
+	IzinCabutSurat      Izin = "surat.cabut"
+	IzinLihatAudit      Izin = "audit.lihat"
 )
 
+// DaftarIzin lists every permission in display order
+var DaftarIzin = []Izin{
+	IzinLihatPermohonan, IzinUbahStatus, IzinBukaKembali, IzinKirimBalasan,
+	IzinUnduhBerkas, IzinLihatLaporan, IzinKelolaJenis, IzinKelolaAdmin,
+	IzinKelolaRole, IzinLihatEmail, IzinKirimUlangEmail, IzinKelolaTemplate,
+	IzinCabutSurat, IzinLihatAudit,
+}
+
+// DeskripsiIzin explains each permission for the role editor
+var DeskripsiIzin = map[Izin]string{
+	IzinLihatPermohonan: "Melihat daftar dan detail permohonan",
+	IzinUbahStatus:      "Memproses, menyetujui, menolak dan meminta revisi permohonan",
+	IzinBukaKembali:     "Membuka kembali permohonan yang sudah disetujui atau ditolak",
+	IzinKirimBalasan:    "Mengirim balasan email ke pemohon",
+	IzinUnduhBerkas:     "Mengunduh dan melihat berkas pemohon serta surat izin",
+	IzinLihatLaporan:    "Melihat statistik dan laporan dashboard",
+	IzinKelolaJenis:     "Menambah, mengubah dan menghapus jenis perizinan",
+	IzinKelolaAdmin:     "Mengelola akun admin, sesi, 2FA dan penguncian login",
+	IzinKelolaRole:      "Mengelola role dan izinnya",
+	IzinLihatEmail:      "Melihat antrean dan riwayat email",
+	IzinKirimUlangEmail: "Mengirim ulang email yang gagal",
+	IzinKelolaTemplate:  "Mengelola template email",
+	IzinCabutSurat:      "Mencabut surat izin yang sudah terbit",
+	IzinLihatAudit:      "Melihat log akses berkas dan percobaan login",
+}
+
+// IzinList custom type for MySQL JSON
+type IzinList []Izin
+
+// Scan implements the sql.Scanner interface
+func (l *IzinList) Scan(value interface{}) error {
+	var list StringArray
+	if err := list.Scan(value); err != nil {
+		return errors.New("failed to scan IzinList: unsupported type")
+	}
+	*l = make(IzinList, 0, len(list))
+	for _, izin := range list {
+		*l = append(*l, Izin(izin))
+	}
+	return nil
+}
+
+// Value implements the driver.Valuer interface
+func (l IzinList) Value() (driver.Value, error) {
+	if l == nil {
+		return "[]", nil
+	}
+	return json.Marshal(l)
+}
+
+// Punya reports whether the list grants every one of izin
+func (l IzinList) Punya(izin ...Izin) bool {
+	for _, i := range izin {
+		if !slices.Contains(l, i) {
+			return false
+		}
+	}
+	return true
+}
+
+// Role is a configurable set of permissions, assigned to admins by Nama.
+// Built-in roles cannot be deleted, and super_admin always has every
+// permission so the system cannot be locked out.
+type Role struct {
+	BaseModel
+	Nama      RoleAdmin `gorm:"uniqueIndex;size:50;not null" json:"nama"`
+	Label     string    `gorm:"size:100;not null" json:"label"`
+	Deskripsi string    `gorm:"type:text" json:"deskripsi"`
+	Izin      IzinList  `gorm:"type:json" json:"izin"`
+	Bawaan    bool      `gorm:"default:false" json:"bawaan"`
+}
+
 // Admin model for authentication
 type Admin struct {
 	BaseModel
@@ -73,17 +246,105 @@ type Admin struct {
 	Password    string    `gorm:"not null" json:"-"`
 	Email       string    `gorm:"uniqueIndex;not null;size:100" json:"email"`
 	NamaLengkap string    `gorm:"size:100" json:"nama_lengkap"`
-	Role        RoleAdmin `gorm:"type:varchar(20);default:'admin'" json:"role"`
+	Role        RoleAdmin `gorm:"type:varchar(50);default:'admin';index" json:"role"`
 	IsActive    bool      `gorm:"default:true" json:"is_active"`
+
+	// Recent failed logins; mirrors PercobaanLogin for quick display
+	GagalLogin     int        `gorm:"default:0" json:"gagal_login"`
+	TerkunciSampai *time.Time `json:"terkunci_sampai"`
+
+	// TOTP two-factor authentication. The secret is encrypted and is set
+	// before TOTPAktif while the admin is still enrolling.
+	TOTPSecret          string     `gorm:"column:totp_secret;size:255" json:"-"`
+	TOTPAktif           bool       `gorm:"column:totp_aktif;default:false" json:"totp_aktif"`
+	TOTPAktifPada       *time.Time `gorm:"column:totp_aktif_pada" json:"totp_aktif_pada"`
+	TOTPLangkahTerakhir int64      `gorm:"column:totp_langkah_terakhir;default:0" json:"-"` // last accepted time step, against replay
+}
+
+// SesiAdmin is one login of an admin. Access tokens carry the session ID,
+// so revoking the session ends them on the next request. The refresh token
+// is stored hashed and replaced on every refresh.
+type SesiAdmin struct {
+	BaseModel
+	AdminID          uuid.UUID  `gorm:"type:char(36);not null;index" json:"admin_id"`
+	RefreshTokenHash string     `gorm:"uniqueIndex;size:64;not null" json:"-"`
+	RefreshTokenLama string     `gorm:"index;size:64" json:"-"` // previous token, kept to detect reuse
+	IPAddress        string     `gorm:"size:45" json:"ip_address"`
+	UserAgent        string     `gorm:"size:255" json:"user_agent"`
+	TerakhirDipakai  time.Time  `json:"terakhir_dipakai"`
+	KadaluarsaPada   time.Time  `gorm:"not null;index" json:"kadaluarsa_pada"`
+	DicabutPada      *time.Time `gorm:"index" json:"dicabut_pada"`
+	AlasanDicabut    string     `gorm:"size:30" json:"alasan_dicabut"`
+}
+
+// Reasons a session was revoked
+const (
+	AlasanSesiLogout            = "logout"
+	AlasanSesiDicabut           = "dicabut"
+	AlasanSesiGantiPassword     = "ganti_password"
+	AlasanSesiResetPassword     = "reset_password"
+	AlasanSesiLupaPassword      = "lupa_password"
+	AlasanSesiAkunNonaktif      = "akun_nonaktif"
+	AlasanSesiAkunDihapus       = "akun_dihapus"
+	AlasanSesiTokenDipakaiUlang = "token_dipakai_ulang"
+)
+
+// KodePemulihan is a single-use recovery code for an admin who lost their
+// authenticator. Only the hash is stored.
+type KodePemulihan struct {
+	BaseModel
+	AdminID     uuid.UUID  `gorm:"type:char(36);not null;index" json:"admin_id"`
+	KodeHash    string     `gorm:"size:64;not null;index" json:"-"`
+	DipakaiPada *time.Time `json:"dipakai_pada"`
 }
 
+// TokenResetPassword is a one-time link emailed to an admin who forgot their
+// password. Only the hash of the token is stored.
+type TokenResetPassword struct {
+	BaseModel
+	AdminID        uuid.UUID  `gorm:"type:char(36);not null;index" json:"admin_id"`
+	TokenHash      string     `gorm:"uniqueIndex;size:64;not null" json:"-"`
+	IPAddress      string     `gorm:"size:45" json:"ip_address"` // client that requested the link
+	KadaluarsaPada time.Time  `gorm:"not null;index" json:"kadaluarsa_pada"`
+	DipakaiPada    *time.Time `gorm:"index" json:"dipakai_pada"` // also set when a newer link replaces it
+}
+
+// RiwayatPassword holds a previous password hash of an admin, checked so
+// recent passwords are not reused
+type RiwayatPassword struct {
+	BaseModel
+	AdminID      uuid.UUID `gorm:"type:char(36);not null;index" json:"admin_id"`
+	PasswordHash string    `gorm:"size:100;not null" json:"-"`
+}
+
+// PercobaanLogin is a failed login. Recent rows that are not reset count
+// towards the per-username and per-IP limits.
+type PercobaanLogin struct {
+	BaseModel
+	Username  string     `gorm:"size:100;not null;index" json:"username"`
+	AdminID   *uuid.UUID `gorm:"type:char(36);index" json:"admin_id"` // nil for unknown usernames
+	IPAddress string     `gorm:"size:45;not null;index" json:"ip_address"`
+	UserAgent string     `gorm:"size:255" json:"user_agent"`
+	Alasan    string     `gorm:"size:30" json:"alasan"`
+	Direset   bool       `gorm:"default:false;index" json:"direset"` // cleared by a successful login or a super admin
+}
+
+// Reasons a login failed
+const (
+	AlasanLoginPasswordSalah  = "password_salah"
+	AlasanLoginTidakDitemukan = "tidak_ditemukan"
+	AlasanLoginAkunTidakAktif = "akun_tidak_aktif"
+	AlasanLoginKode2FASalah   = "kode_2fa_salah"
+)
+
 // JenisPerizinan model
 type JenisPerizinan struct {
 	BaseModel
-	Nama        string      `gorm:"not null;size:100" json:"nama"`
-	Deskripsi   string      `gorm:"type:text" json:"deskripsi"`
-	Persyaratan StringArray `gorm:"type:json" json:"persyaratan"`
-	Aktif       bool        `gorm:"default:true" json:"aktif"`
+	Nama        string          `gorm:"not null;size:100" json:"nama"`
+	Kode        string          `gorm:"size:10" json:"kode"`
+	Deskripsi   string          `gorm:"type:text" json:"deskripsi"`
+	Persyaratan PersyaratanList `gorm:"type:json" json:"persyaratan"`
+	Aktif       bool            `gorm:"default:true" json:"aktif"`
 }
 
 // Pemohon model (embedded in Permohonan or separate table)
@@ -103,12 +364,14 @@ const (
 	StatusDiproses  StatusPermohonan = "diproses"
 	StatusDisetujui StatusPermohonan = "disetujui"
 	StatusDitolak   StatusPermohonan = "ditolak"
+	// StatusPerluRevisi means the applicant has to replace deficient berkas
+	StatusPerluRevisi StatusPermohonan = "perlu_revisi"
 )
 
 // Permohonan model
 type Permohonan struct {
 	BaseModel
-	NomorPermohonan  string           `gorm:"uniqueIndex;size:10;not null" json:"nomor_permohonan"`
+	NomorPermohonan  string           `gorm:"uniqueIndex;size:40;not null" json:"nomor_permohonan"`
 	PemohonID        uuid.UUID        `gorm:"type:char(36);not null" json:"pemohon_id"`
 	Pemohon          Pemohon          `gorm:"foreignKey:PemohonID" json:"pemohon"`
 	JenisPerizinanID uuid.UUID        `gorm:"type:char(36);not null" json:"jenis_perizinan_id"`
@@ -123,45 +386,263 @@ type Permohonan struct {
 	CatatanAdmin     string           `gorm:"type:text" json:"catatan_admin"`
 	LampiranSurat    string           `gorm:"type:varchar(500)" json:"lampiran_surat"`
 	DikelolaOleh     *uuid.UUID       `gorm:"type:char(36)" json:"dikelola_oleh"`
-	Admin            *Admin           `gorm:"foreignKey:DikelolaOleh" json:"admin,omitempty"`
+	// Revision request: deficient persyaratan, public note for the applicant
+	// and the SHA-256 hash of the one-time upload token sent by email
+	PersyaratanRevisi     StringArray         `gorm:"type:json" json:"persyaratan_revisi"`
+	CatatanRevisi         string              `gorm:"type:text" json:"catatan_revisi"`
+	TokenRevisi           string              `gorm:"size:64;index" json:"-"`
+	TokenRevisiKadaluarsa *time.Time          `json:"-"`
+	Admin                 *Admin              `gorm:"foreignKey:DikelolaOleh" json:"admin,omitempty"`
+	Riwayat               []RiwayatPermohonan `gorm:"foreignKey:PermohonanID" json:"riwayat,omitempty"`
+	Surat                 []SuratIzin         `gorm:"foreignKey:PermohonanID" json:"surat,omitempty"`
+}
+
+// FormatNomorPermohonan renders a nomor permohonan from its parts. The
+// sequence must come from the NomorUrut counter so numbers never collide.
+func FormatNomorPermohonan(format, kode string, tahun int, urut int64, digit int) string {
+	nomorUrut := fmt.Sprintf("%0*d", digit, urut)
+	return strings.NewReplacer(
+		"{KODE}", kode,
+		"{TAHUN}", strconv.Itoa(tahun),
+		"{URUT}", nomorUrut,
+		"{CEK}", strconv.Itoa(CheckDigit(strconv.Itoa(tahun)+nomorUrut)),
+	).Replace(format)
 }
 
-// GenerateNomorPermohonan generates a unique 10 digit number
-func GenerateNomorPermohonan() string {
-	return fmt.Sprintf("%010d", time.Now().UnixNano()%10000000000)
+// CheckDigit computes the Luhn check digit of a numeric string so typos in a
+// nomor permohonan can be detected
+func CheckDigit(digits string) int {
+	sum := 0
+	double := true
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return (10 - sum%10) % 10
+}
+
+// RiwayatPermohonan records every status change of a permohonan
+type RiwayatPermohonan struct {
+	BaseModel
+	PermohonanID uuid.UUID        `gorm:"type:char(36);not null;index" json:"permohonan_id"`
+	StatusDari   StatusPermohonan `gorm:"type:varchar(20)" json:"status_dari"`
+	StatusKe     StatusPermohonan `gorm:"type:varchar(20);not null" json:"status_ke"`
+	AdminID      *uuid.UUID       `gorm:"type:char(36)" json:"admin_id"`
+	Admin        *Admin           `gorm:"foreignKey:AdminID" json:"admin,omitempty"`
+	Catatan      string           `gorm:"type:text" json:"catatan"`
+	Tanggal      time.Time        `gorm:"not null" json:"tanggal"`
+}
+
+// JenisSurat enum
+type JenisSurat string
+
+const (
+	JenisSuratIzin      JenisSurat = "izin"
+	JenisSuratPenolakan JenisSurat = "penolakan"
+)
+
+// SuratIzin is an official reply letter generated for a permohonan. Izin
+// letters carry a verification token printed as QR code so the receiving
+// institution can check them via the public verification endpoint.
+type SuratIzin struct {
+	BaseModel
+	PermohonanID         uuid.UUID   `gorm:"type:char(36);not null;index" json:"permohonan_id"`
+	Permohonan           *Permohonan `gorm:"foreignKey:PermohonanID" json:"-"`
+	NomorSurat           string      `gorm:"uniqueIndex;size:100;not null" json:"nomor_surat"`
+	Jenis                JenisSurat  `gorm:"type:varchar(20);not null" json:"jenis"`
+	TanggalSurat         time.Time   `gorm:"not null" json:"tanggal_surat"`
+	Penandatangan        string      `gorm:"size:100" json:"penandatangan"`
+	JabatanPenandatangan string      `gorm:"size:150" json:"jabatan_penandatangan"`
+	NIPPenandatangan     string      `gorm:"size:30" json:"nip_penandatangan"`
+	Path                 string      `gorm:"size:500" json:"path"`
+	TokenVerifikasi      *string     `gorm:"uniqueIndex;size:64" json:"token_verifikasi"`
+	Dicabut              bool        `gorm:"default:false" json:"dicabut"`
+	DicabutPada          *time.Time  `json:"dicabut_pada"`
+	AlasanPencabutan     string      `gorm:"type:text" json:"alasan_pencabutan"`
+}
+
+// AksesBerkas records every time a stored file of a permohonan is served
+type AksesBerkas struct {
+	BaseModel
+	PermohonanID uuid.UUID  `gorm:"type:char(36);not null;index" json:"permohonan_id"`
+	BerkasID     *uuid.UUID `gorm:"type:char(36);index" json:"berkas_id"` // nil for the lampiran surat
+	Jenis        string     `gorm:"size:20;not null" json:"jenis"`        // berkas, lampiran
+	NamaFile     string     `gorm:"size:255" json:"nama_file"`
+	AdminID      uuid.UUID  `gorm:"type:char(36);not null;index" json:"admin_id"`
+	Admin        *Admin     `gorm:"foreignKey:AdminID" json:"admin,omitempty"`
+	Metode       string     `gorm:"size:20;not null" json:"metode"` // sesi, tautan
+	IPAddress    string     `gorm:"size:45" json:"ip_address"`
+	UserAgent    string     `gorm:"size:255" json:"user_agent"`
+	DiaksesPada  time.Time  `gorm:"not null;index" json:"diakses_pada"`
+}
+
+const (
+	JenisAksesBerkas    = "berkas"
+	JenisAksesLampiran  = "lampiran"
+	JenisAksesBundel    = "bundel"
+	JenisAksesPratinjau = "pratinjau"
+
+	// MetodeAksesSesi is a download with the admin's bearer token,
+	// MetodeAksesTautan one through a signed link
+	MetodeAksesSesi   = "sesi"
+	MetodeAksesTautan = "tautan"
+)
+
+// NomorUrut is a named counter handing out sequential numbers, e.g. the
+// yearly nomor surat counter
+type NomorUrut struct {
+	Kode      string `gorm:"primaryKey;size:50"`
+	Nilai     int64  `gorm:"not null;default:0"`
+	UpdatedAt time.Time
+}
+
+// KunciBerkas is a lock row per storage key of a berkas or its preview.
+// Uploads hold it while they store the file and commit the Berkas referring
+// to it; cleanup after a failed upload holds it while it decides whether the
+// file is still referenced.
+type KunciBerkas struct {
+	Path string `gorm:"primaryKey;size:500"`
 }
 
 // Berkas model for file uploads
 type Berkas struct {
 	BaseModel
-	PermohonanID uuid.UUID `gorm:"type:char(36);not null" json:"permohonan_id"`
-	NamaFile     string    `gorm:"not null;size:255" json:"nama_file"`
-	NamaAsli     string    `gorm:"not null;size:255" json:"nama_asli"`
-	Path         string    `gorm:"not null;size:500" json:"path"`
-	Ukuran       int64     `json:"ukuran"`
-	MimeType     string    `gorm:"size:100" json:"mime_type"`
+	PermohonanID    uuid.UUID  `gorm:"type:char(36);not null" json:"permohonan_id"`
+	PersyaratanKode string     `gorm:"size:100;index" json:"persyaratan_kode"`
+	NamaFile        string     `gorm:"not null;size:255" json:"nama_file"`
+	NamaAsli        string     `gorm:"not null;size:255" json:"nama_asli"`
+	Path            string     `gorm:"not null;size:500" json:"path"`
+	PratinjauPath   string     `gorm:"size:500" json:"pratinjau_path"` // JPEG preview, empty if none
+	Ukuran          int64      `json:"ukuran"`
+	MimeType        string     `gorm:"size:100" json:"mime_type"`
+	SHA256          string     `gorm:"column:sha256;size:64;index" json:"sha256"` // hex, taken at upload
+	StatusScan      StatusScan `gorm:"type:varchar(20);default:'tidak_dipindai'" json:"status_scan"`
+	HasilScan       string     `gorm:"size:255" json:"hasil_scan"`
+	ScanEngine      string     `gorm:"size:50" json:"scan_engine"`
+	DipindaiPada    *time.Time `json:"dipindai_pada"`
 }
 
+// StatusScan enum for the malware scan of a Berkas
+type StatusScan string
+
+const (
+	StatusScanBersih     StatusScan = "bersih"
+	StatusScanTerinfeksi StatusScan = "terinfeksi"
+	StatusScanDilewati   StatusScan = "tidak_dipindai"
+)
+
+// EventNotifikasi enum
+type EventNotifikasi string
+
+const (
+	EventPermohonanBaru EventNotifikasi = "permohonan_baru"
+	EventRevisiDiunggah EventNotifikasi = "revisi_diunggah"
+)
+
+// EventNotifikasiList lists every event an admin can subscribe to
+var EventNotifikasiList = []EventNotifikasi{EventPermohonanBaru, EventRevisiDiunggah}
+
 // Notifikasi model
 type Notifikasi struct {
 	BaseModel
-	AdminID      uuid.UUID  `gorm:"type:char(36);not null" json:"admin_id"`
-	Admin        Admin      `gorm:"foreignKey:AdminID" json:"-"`
-	PermohonanID uuid.UUID  `gorm:"type:char(36);not null" json:"permohonan_id"`
-	Permohonan   Permohonan `gorm:"foreignKey:PermohonanID" json:"-"`
-	Pesan        string     `gorm:"type:text;not null" json:"pesan"`
-	Dibaca       bool       `gorm:"default:false" json:"dibaca"`
-	Tanggal      time.Time  `gorm:"not null" json:"tanggal"`
+	AdminID      uuid.UUID       `gorm:"type:char(36);not null" json:"admin_id"`
+	Admin        Admin           `gorm:"foreignKey:AdminID" json:"-"`
+	PermohonanID uuid.UUID       `gorm:"type:char(36);not null" json:"permohonan_id"`
+	Permohonan   Permohonan      `gorm:"foreignKey:PermohonanID" json:"-"`
+	Event        EventNotifikasi `gorm:"type:varchar(30)" json:"event"`
+	Pesan        string          `gorm:"type:text;not null" json:"pesan"`
+	Dibaca       bool            `gorm:"default:false" json:"dibaca"`
+	Tanggal      time.Time       `gorm:"not null" json:"tanggal"`
+}
+
+// PreferensiNotifikasi stores which notifications an admin wants to receive.
+// An empty list means "all": all jenis perizinan or all events.
+type PreferensiNotifikasi struct {
+	BaseModel
+	AdminID          uuid.UUID   `gorm:"type:char(36);uniqueIndex;not null" json:"admin_id"`
+	JenisPerizinanID StringArray `gorm:"type:json" json:"jenis_perizinan_id"`
+	Event            StringArray `gorm:"type:json" json:"event"`
+}
+
+// Accepts reports whether a notification for the given jenis perizinan and
+// event should be delivered
+func (p *PreferensiNotifikasi) Accepts(jenisPerizinanID uuid.UUID, event EventNotifikasi) bool {
+	return matchesOrEmpty(p.JenisPerizinanID, jenisPerizinanID.String()) && matchesOrEmpty(p.Event, string(event))
+}
+
+func matchesOrEmpty(list StringArray, value string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// TemplateEmail is an admin-editable email for a permohonan status. A
+// template with JenisPerizinanID set overrides the default (nil) one for
+// that jenis perizinan. Placeholders use Go template syntax, e.g.
+// {{.NamaPemohon}} or {{.NomorPermohonan}}.
+type TemplateEmail struct {
+	BaseModel
+	Status           StatusPermohonan `gorm:"type:varchar(20);not null;uniqueIndex:idx_template_email_status_jenis,priority:1" json:"status"`
+	JenisPerizinanID *uuid.UUID       `gorm:"type:char(36);index" json:"jenis_perizinan_id"`
+	JenisPerizinan   *JenisPerizinan  `gorm:"foreignKey:JenisPerizinanID" json:"jenis_perizinan,omitempty"`
+	Subjek           string           `gorm:"not null;size:255" json:"subjek"`
+	IsiHTML          string           `gorm:"type:longtext;not null" json:"isi_html"`
+	IsiTeks          string           `gorm:"type:text" json:"isi_teks"`
+	Aktif            bool             `gorm:"default:true" json:"aktif"`
+
+	// JenisKunci mirrors JenisPerizinanID with "" for the default template.
+	// A unique index allows any number of NULLs, so it is built on this
+	// column instead. Set by BeforeSave.
+	JenisKunci string `gorm:"type:char(36);not null;default:'';uniqueIndex:idx_template_email_status_jenis,priority:2" json:"-"`
+}
+
+// BeforeSave keeps JenisKunci in step with JenisPerizinanID
+func (t *TemplateEmail) BeforeSave(tx *gorm.DB) error {
+	t.JenisKunci = ""
+	if t.JenisPerizinanID != nil {
+		t.JenisKunci = t.JenisPerizinanID.String()
+	}
+	return nil
 }
 
+// EmailLog status values. An EmailLog row doubles as the outbox entry that
+// the email worker picks up: pending/failed rows are retried until they are
+// sent or reach the maximum number of attempts (dead).
+const (
+	EmailStatusPending = "pending"
+	EmailStatusSending = "sending"
+	EmailStatusSent    = "sent"
+	EmailStatusFailed  = "failed"
+	EmailStatusDead    = "dead"
+)
+
 // EmailLog model for tracking sent emails
 type EmailLog struct {
 	BaseModel
-	PermohonanID uuid.UUID  `gorm:"type:char(36);not null" json:"permohonan_id"`
-	EmailTujuan  string     `gorm:"not null;size:100" json:"email_tujuan"`
-	Subjek       string     `gorm:"not null;size:255" json:"subjek"`
-	Isi          string     `gorm:"type:text;not null" json:"isi"`
-	Status       string     `gorm:"size:20" json:"status"` // sent, failed
-	Error        string     `gorm:"type:text" json:"error"`
-	SentAt       *time.Time `json:"sent_at"`
+	PermohonanID    *uuid.UUID `gorm:"type:char(36);index" json:"permohonan_id"` // nil for system emails such as password resets
+	EmailTujuan     string     `gorm:"not null;size:100" json:"email_tujuan"`
+	Subjek          string     `gorm:"not null;size:255" json:"subjek"`
+	Isi             string     `gorm:"type:text;not null" json:"isi"`
+	IsiHTML         string     `gorm:"type:longtext" json:"-"`
+	Lampiran        string     `gorm:"type:varchar(500)" json:"lampiran"`
+	Rahasia         string     `gorm:"type:text" json:"-"`          // encrypted token put into the body when sending, cleared once sent
+	Status          string     `gorm:"size:20;index" json:"status"` // pending, sending, sent, failed, dead
+	Error           string     `gorm:"type:text" json:"error"`
+	Percobaan       int        `gorm:"default:0" json:"percobaan"`
+	PercobaanMaks   int        `gorm:"default:5" json:"percobaan_maks"`
+	KirimBerikutnya *time.Time `gorm:"index" json:"kirim_berikutnya"`
+	SentAt          *time.Time `json:"sent_at"`
 }
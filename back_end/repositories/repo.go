@@ -1,11 +1,35 @@
 package repositories
 
 import (
+	"slices"
+	"time"
+
 	"github.com/alifsyafan/backend-capston/models"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ============== Transactor ==============
+
+// Transactor runs a unit of work in a single database transaction.
+// Repositories taking part in it are bound to the handle via WithTx.
+type Transactor interface {
+	WithTransaction(fn func(tx *gorm.DB) error) error
+}
+
+type transactor struct {
+	db *gorm.DB
+}
+
+func NewTransactor(db *gorm.DB) Transactor {
+	return &transactor{db: db}
+}
+
+func (t *transactor) WithTransaction(fn func(tx *gorm.DB) error) error {
+	return t.db.Transaction(fn)
+}
+
 // ============== Admin Repository ==============
 
 type AdminRepository interface {
@@ -14,7 +38,15 @@ type AdminRepository interface {
 	FindByUsername(username string) (*models.Admin, error)
 	FindByEmail(email string) (*models.Admin, error)
 	FindAllPaginated(offset, limit int, search string) ([]models.Admin, int64, error)
+	FindAllActive() ([]models.Admin, error)
 	Update(admin *models.Admin) error
+	// UpdatePenguncian stores the failed login counter without touching
+	// the rest of the account
+	UpdatePenguncian(id uuid.UUID, gagalLogin int, terkunciSampai *time.Time) error
+	FindTerkunci() ([]models.Admin, error)
+	// UpdateLangkahTOTP records the time step of an accepted TOTP code. It
+	// reports false when that step or a later one was already used.
+	UpdateLangkahTOTP(id uuid.UUID, langkah int64) (bool, error)
 	Delete(id uuid.UUID) error
 }
 
@@ -81,10 +113,34 @@ func (r *adminRepository) FindAllPaginated(offset, limit int, search string) ([]
 	return admins, total, nil
 }
 
+func (r *adminRepository) FindAllActive() ([]models.Admin, error) {
+	var admins []models.Admin
+	err := r.db.Where("is_active = ?", true).Find(&admins).Error
+	return admins, err
+}
+
 func (r *adminRepository) Update(admin *models.Admin) error {
 	return r.db.Save(admin).Error
 }
 
+func (r *adminRepository) UpdatePenguncian(id uuid.UUID, gagalLogin int, terkunciSampai *time.Time) error {
+	return r.db.Model(&models.Admin{}).Where("id = ?", id).
+		UpdateColumns(map[string]interface{}{"gagal_login": gagalLogin, "terkunci_sampai": terkunciSampai}).Error
+}
+
+func (r *adminRepository) FindTerkunci() ([]models.Admin, error) {
+	var admins []models.Admin
+	err := r.db.Where("terkunci_sampai > ?", time.Now()).Order("terkunci_sampai DESC").Find(&admins).Error
+	return admins, err
+}
+
+func (r *adminRepository) UpdateLangkahTOTP(id uuid.UUID, langkah int64) (bool, error) {
+	result := r.db.Model(&models.Admin{}).
+		Where("id = ? AND totp_langkah_terakhir < ?", id, langkah).
+		UpdateColumn("totp_langkah_terakhir", langkah)
+	return result.RowsAffected == 1, result.Error
+}
+
 func (r *adminRepository) Delete(id uuid.UUID) error {
 	return r.db.Delete(&models.Admin{}, id).Error
 }
@@ -145,6 +201,7 @@ type PemohonRepository interface {
 	FindByID(id uuid.UUID) (*models.Pemohon, error)
 	FindByEmail(email string) (*models.Pemohon, error)
 	Update(pemohon *models.Pemohon) error
+	WithTx(tx *gorm.DB) PemohonRepository
 }
 
 type pemohonRepository struct {
@@ -155,6 +212,10 @@ func NewPemohonRepository(db *gorm.DB) PemohonRepository {
 	return &pemohonRepository{db: db}
 }
 
+func (r *pemohonRepository) WithTx(tx *gorm.DB) PemohonRepository {
+	return &pemohonRepository{db: tx}
+}
+
 func (r *pemohonRepository) Create(pemohon *models.Pemohon) error {
 	return r.db.Create(pemohon).Error
 }
@@ -187,11 +248,14 @@ type PermohonanRepository interface {
 	Create(permohonan *models.Permohonan) error
 	FindAll(page, perPage int, status string, search string) ([]models.Permohonan, int64, error)
 	FindByID(id uuid.UUID) (*models.Permohonan, error)
+	FindByNomor(nomor string) (*models.Permohonan, error)
+	FindByTokenRevisi(tokenHash string) (*models.Permohonan, error)
 	FindByStatus(status models.StatusPermohonan) ([]models.Permohonan, error)
 	Update(permohonan *models.Permohonan) error
 	Delete(id uuid.UUID) error
 	CountByStatus() (map[string]int64, error)
 	GetRecentPermohonan(limit int) ([]models.Permohonan, error)
+	WithTx(tx *gorm.DB) PermohonanRepository
 }
 
 type permohonanRepository struct {
@@ -202,6 +266,10 @@ func NewPermohonanRepository(db *gorm.DB) PermohonanRepository {
 	return &permohonanRepository{db: db}
 }
 
+func (r *permohonanRepository) WithTx(tx *gorm.DB) PermohonanRepository {
+	return &permohonanRepository{db: tx}
+}
+
 func (r *permohonanRepository) Create(permohonan *models.Permohonan) error {
 	return r.db.Create(permohonan).Error
 }
@@ -237,6 +305,12 @@ func (r *permohonanRepository) FindAll(page, perPage int, status string, search
 func (r *permohonanRepository) FindByID(id uuid.UUID) (*models.Permohonan, error) {
 	var permohonan models.Permohonan
 	err := r.db.Preload("Pemohon").Preload("JenisPerizinan").Preload("Berkas").Preload("Admin").
+		Preload("Riwayat", func(db *gorm.DB) *gorm.DB {
+			return db.Order("tanggal ASC")
+		}).Preload("Riwayat.Admin").
+		Preload("Surat", func(db *gorm.DB) *gorm.DB {
+			return db.Order("tanggal_surat ASC")
+		}).
 		Where("id = ?", id).First(&permohonan).Error
 	if err != nil {
 		return nil, err
@@ -244,6 +318,26 @@ func (r *permohonanRepository) FindByID(id uuid.UUID) (*models.Permohonan, error
 	return &permohonan, nil
 }
 
+func (r *permohonanRepository) FindByNomor(nomor string) (*models.Permohonan, error) {
+	var permohonan models.Permohonan
+	err := r.db.Preload("Pemohon").Preload("JenisPerizinan").
+		Where("nomor_permohonan = ?", nomor).First(&permohonan).Error
+	if err != nil {
+		return nil, err
+	}
+	return &permohonan, nil
+}
+
+func (r *permohonanRepository) FindByTokenRevisi(tokenHash string) (*models.Permohonan, error) {
+	var permohonan models.Permohonan
+	err := r.db.Preload("Pemohon").Preload("JenisPerizinan").
+		Where("token_revisi = ?", tokenHash).First(&permohonan).Error
+	if err != nil {
+		return nil, err
+	}
+	return &permohonan, nil
+}
+
 func (r *permohonanRepository) FindByStatus(status models.StatusPermohonan) ([]models.Permohonan, error) {
 	var list []models.Permohonan
 	err := r.db.Preload("Pemohon").Preload("JenisPerizinan").Preload("Berkas").
@@ -282,6 +376,10 @@ func (r *permohonanRepository) CountByStatus() (map[string]int64, error) {
 	r.db.Model(&models.Permohonan{}).Where("status = ?", models.StatusDitolak).Count(&ditolak)
 	result["ditolak"] = ditolak
 
+	var perluRevisi int64
+	r.db.Model(&models.Permohonan{}).Where("status = ?", models.StatusPerluRevisi).Count(&perluRevisi)
+	result["perlu_revisi"] = perluRevisi
+
 	result["selesai"] = disetujui + ditolak
 
 	return result, nil
@@ -294,12 +392,164 @@ func (r *permohonanRepository) GetRecentPermohonan(limit int) ([]models.Permohon
 	return list, err
 }
 
+// ============== Riwayat Permohonan Repository ==============
+
+type RiwayatPermohonanRepository interface {
+	Create(riwayat *models.RiwayatPermohonan) error
+	FindByPermohonanID(permohonanID uuid.UUID) ([]models.RiwayatPermohonan, error)
+	WithTx(tx *gorm.DB) RiwayatPermohonanRepository
+}
+
+type riwayatPermohonanRepository struct {
+	db *gorm.DB
+}
+
+func NewRiwayatPermohonanRepository(db *gorm.DB) RiwayatPermohonanRepository {
+	return &riwayatPermohonanRepository{db: db}
+}
+
+func (r *riwayatPermohonanRepository) WithTx(tx *gorm.DB) RiwayatPermohonanRepository {
+	return &riwayatPermohonanRepository{db: tx}
+}
+
+func (r *riwayatPermohonanRepository) Create(riwayat *models.RiwayatPermohonan) error {
+	return r.db.Create(riwayat).Error
+}
+
+func (r *riwayatPermohonanRepository) FindByPermohonanID(permohonanID uuid.UUID) ([]models.RiwayatPermohonan, error) {
+	var list []models.RiwayatPermohonan
+	err := r.db.Preload("Admin").Where("permohonan_id = ?", permohonanID).Order("tanggal ASC").Find(&list).Error
+	return list, err
+}
+
+// ============== Surat Izin Repository ==============
+
+type SuratIzinRepository interface {
+	Create(surat *models.SuratIzin) error
+	FindByID(id uuid.UUID) (*models.SuratIzin, error)
+	FindLatestByPermohonanID(permohonanID uuid.UUID) (*models.SuratIzin, error)
+	FindByTokenVerifikasi(token string) (*models.SuratIzin, error)
+	Update(surat *models.SuratIzin) error
+	// CabutByPermohonanID revokes every permit of a permohonan that is
+	// still valid
+	CabutByPermohonanID(permohonanID uuid.UUID, alasan string, waktu time.Time) error
+	WithTx(tx *gorm.DB) SuratIzinRepository
+}
+
+type suratIzinRepository struct {
+	db *gorm.DB
+}
+
+func NewSuratIzinRepository(db *gorm.DB) SuratIzinRepository {
+	return &suratIzinRepository{db: db}
+}
+
+func (r *suratIzinRepository) WithTx(tx *gorm.DB) SuratIzinRepository {
+	return &suratIzinRepository{db: tx}
+}
+
+func (r *suratIzinRepository) Create(surat *models.SuratIzin) error {
+	return r.db.Create(surat).Error
+}
+
+func (r *suratIzinRepository) FindByID(id uuid.UUID) (*models.SuratIzin, error) {
+	var surat models.SuratIzin
+	err := r.db.Where("id = ?", id).First(&surat).Error
+	if err != nil {
+		return nil, err
+	}
+	return &surat, nil
+}
+
+func (r *suratIzinRepository) FindLatestByPermohonanID(permohonanID uuid.UUID) (*models.SuratIzin, error) {
+	var surat models.SuratIzin
+	err := r.db.Where("permohonan_id = ?", permohonanID).Order("tanggal_surat DESC").First(&surat).Error
+	if err != nil {
+		return nil, err
+	}
+	return &surat, nil
+}
+
+func (r *suratIzinRepository) FindByTokenVerifikasi(token string) (*models.SuratIzin, error) {
+	var surat models.SuratIzin
+	err := r.db.Preload("Permohonan").Preload("Permohonan.Pemohon").Preload("Permohonan.JenisPerizinan").
+		Where("token_verifikasi = ?", token).First(&surat).Error
+	if err != nil {
+		return nil, err
+	}
+	return &surat, nil
+}
+
+func (r *suratIzinRepository) Update(surat *models.SuratIzin) error {
+	return r.db.Omit("Permohonan").Save(surat).Error
+}
+
+func (r *suratIzinRepository) CabutByPermohonanID(permohonanID uuid.UUID, alasan string, waktu time.Time) error {
+	return r.db.Model(&models.SuratIzin{}).
+		Where("permohonan_id = ? AND jenis = ? AND dicabut = ?", permohonanID, models.JenisSuratIzin, false).
+		Updates(map[string]interface{}{
+			"dicabut":           true,
+			"dicabut_pada":      waktu,
+			"alasan_pencabutan": alasan,
+		}).Error
+}
+
+// ============== Nomor Urut Repository ==============
+
+type NomorUrutRepository interface {
+	Next(kode string) (int64, error)
+	WithTx(tx *gorm.DB) NomorUrutRepository
+}
+
+type nomorUrutRepository struct {
+	db *gorm.DB
+}
+
+func NewNomorUrutRepository(db *gorm.DB) NomorUrutRepository {
+	return &nomorUrutRepository{db: db}
+}
+
+func (r *nomorUrutRepository) WithTx(tx *gorm.DB) NomorUrutRepository {
+	return &nomorUrutRepository{db: tx}
+}
+
+// Next increments the counter and returns the new value. The row is locked
+// for the duration of the transaction so concurrent callers never receive
+// the same number. When bound to an outer transaction the lock is held until
+// it commits, so a rolled back submission does not leave a gap.
+func (r *nomorUrutRepository) Next(kode string) (int64, error) {
+	var nilai int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// Make sure the counter exists without racing other inserts
+		err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.NomorUrut{Kode: kode}).Error
+		if err != nil {
+			return err
+		}
+
+		var counter models.NomorUrut
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("kode = ?", kode).First(&counter).Error
+		if err != nil {
+			return err
+		}
+
+		nilai = counter.Nilai + 1
+		return tx.Model(&models.NomorUrut{}).Where("kode = ?", kode).Update("nilai", nilai).Error
+	})
+	return nilai, err
+}
+
 // ============== Berkas Repository ==============
 
 type BerkasRepository interface {
 	Create(berkas *models.Berkas) error
+	FindByID(id uuid.UUID) (*models.Berkas, error)
 	FindByPermohonanID(permohonanID uuid.UUID) ([]models.Berkas, error)
 	Delete(id uuid.UUID) error
+	CountByPath(path string) (int64, error)
+	Kunci(paths []string) error
+	WithTx(tx *gorm.DB) BerkasRepository
 }
 
 type berkasRepository struct {
@@ -310,10 +560,23 @@ func NewBerkasRepository(db *gorm.DB) BerkasRepository {
 	return &berkasRepository{db: db}
 }
 
+func (r *berkasRepository) WithTx(tx *gorm.DB) BerkasRepository {
+	return &berkasRepository{db: tx}
+}
+
 func (r *berkasRepository) Create(berkas *models.Berkas) error {
 	return r.db.Create(berkas).Error
 }
 
+func (r *berkasRepository) FindByID(id uuid.UUID) (*models.Berkas, error) {
+	var berkas models.Berkas
+	err := r.db.First(&berkas, "id = ?", id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &berkas, nil
+}
+
 func (r *berkasRepository) FindByPermohonanID(permohonanID uuid.UUID) ([]models.Berkas, error) {
 	var list []models.Berkas
 	err := r.db.Where("permohonan_id = ?", permohonanID).Find(&list).Error
@@ -324,6 +587,461 @@ func (r *berkasRepository) Delete(id uuid.UUID) error {
 	return r.db.Delete(&models.Berkas{}, id).Error
 }
 
+// CountByPath counts the berkas whose file or preview is stored under path.
+// Soft-deleted berkas count too; they keep their files.
+func (r *berkasRepository) CountByPath(path string) (int64, error) {
+	var count int64
+	err := r.db.Unscoped().Model(&models.Berkas{}).
+		Where("path = ? OR pratinjau_path = ?", path, path).Count(&count).Error
+	return count, err
+}
+
+// Kunci locks the given storage keys until the surrounding transaction ends.
+// Keys are locked in sorted order so two callers never wait on each other.
+func (r *berkasRepository) Kunci(paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	sorted := slices.Clone(paths)
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+
+	rows := make([]models.KunciBerkas, len(sorted))
+	for i, p := range sorted {
+		rows[i] = models.KunciBerkas{Path: p}
+	}
+	// Make sure the lock rows exist without racing other inserts
+	if err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error; err != nil {
+		return err
+	}
+
+	var locked []models.KunciBerkas
+	return r.db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("path IN ?", sorted).Order("path").Find(&locked).Error
+}
+
+// ============== Akses Berkas Repository ==============
+
+type AksesBerkasRepository interface {
+	Create(akses *models.AksesBerkas) error
+	FindByPermohonanID(permohonanID uuid.UUID) ([]models.AksesBerkas, error)
+}
+
+type aksesBerkasRepository struct {
+	db *gorm.DB
+}
+
+func NewAksesBerkasRepository(db *gorm.DB) AksesBerkasRepository {
+	return &aksesBerkasRepository{db: db}
+}
+
+func (r *aksesBerkasRepository) Create(akses *models.AksesBerkas) error {
+	return r.db.Create(akses).Error
+}
+
+func (r *aksesBerkasRepository) FindByPermohonanID(permohonanID uuid.UUID) ([]models.AksesBerkas, error) {
+	var list []models.AksesBerkas
+	err := r.db.Preload("Admin").Where("permohonan_id = ?", permohonanID).Order("diakses_pada DESC").Find(&list).Error
+	return list, err
+}
+
+// ============== Role Repository ==============
+
+type RoleRepository interface {
+	Create(role *models.Role) error
+	FindAll() ([]models.Role, error)
+	FindByID(id uuid.UUID) (*models.Role, error)
+	FindByNama(nama models.RoleAdmin) (*models.Role, error)
+	Update(role *models.Role) error
+	Delete(id uuid.UUID) error
+	// CountAdmin counts the admins that have the role
+	CountAdmin(nama models.RoleAdmin) (int64, error)
+}
+
+type roleRepository struct {
+	db *gorm.DB
+}
+
+func NewRoleRepository(db *gorm.DB) RoleRepository {
+	return &roleRepository{db: db}
+}
+
+func (r *roleRepository) Create(role *models.Role) error {
+	return r.db.Create(role).Error
+}
+
+func (r *roleRepository) FindAll() ([]models.Role, error) {
+	var roles []models.Role
+	err := r.db.Order("bawaan DESC, nama ASC").Find(&roles).Error
+	return roles, err
+}
+
+func (r *roleRepository) FindByID(id uuid.UUID) (*models.Role, error) {
+	var role models.Role
+	err := r.db.Where("id = ?", id).First(&role).Error
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *roleRepository) FindByNama(nama models.RoleAdmin) (*models.Role, error) {
+	var role models.Role
+	err := r.db.Where("nama = ?", nama).First(&role).Error
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *roleRepository) Update(role *models.Role) error {
+	return r.db.Save(role).Error
+}
+
+// Delete removes the row so the name can be used again
+func (r *roleRepository) Delete(id uuid.UUID) error {
+	return r.db.Unscoped().Delete(&models.Role{}, "id = ?", id).Error
+}
+
+func (r *roleRepository) CountAdmin(nama models.RoleAdmin) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.Admin{}).Where("role = ?", nama).Count(&count).Error
+	return count, err
+}
+
+// ============== Sesi Admin Repository ==============
+
+type SesiAdminRepository interface {
+	Create(sesi *models.SesiAdmin) error
+	FindByID(id uuid.UUID) (*models.SesiAdmin, error)
+	FindByRefreshToken(hash string) (*models.SesiAdmin, error)
+	FindByRefreshTokenLama(hash string) (*models.SesiAdmin, error)
+	// FindAktifByAdminID returns sessions that are neither revoked nor expired
+	FindAktifByAdminID(adminID uuid.UUID) ([]models.SesiAdmin, error)
+	// Rotate swaps the refresh token of a session. It reports false when the
+	// session was revoked or refreshed by someone else in the meantime.
+	Rotate(id uuid.UUID, hashLama, hashBaru string, kadaluarsa time.Time) (bool, error)
+	Revoke(id uuid.UUID, alasan string) error
+	// RevokeByAdminID revokes every active session of an admin except the
+	// given ones
+	RevokeByAdminID(adminID uuid.UUID, alasan string, kecuali ...uuid.UUID) error
+	DeleteExpired(before time.Time) error
+}
+
+type sesiAdminRepository struct {
+	db *gorm.DB
+}
+
+func NewSesiAdminRepository(db *gorm.DB) SesiAdminRepository {
+	return &sesiAdminRepository{db: db}
+}
+
+func (r *sesiAdminRepository) Create(sesi *models.SesiAdmin) error {
+	return r.db.Create(sesi).Error
+}
+
+func (r *sesiAdminRepository) FindByID(id uuid.UUID) (*models.SesiAdmin, error) {
+	var sesi models.SesiAdmin
+	err := r.db.Where("id = ?", id).First(&sesi).Error
+	if err != nil {
+		return nil, err
+	}
+	return &sesi, nil
+}
+
+func (r *sesiAdminRepository) FindByRefreshToken(hash string) (*models.SesiAdmin, error) {
+	var sesi models.SesiAdmin
+	err := r.db.Where("refresh_token_hash = ?", hash).First(&sesi).Error
+	if err != nil {
+		return nil, err
+	}
+	return &sesi, nil
+}
+
+func (r *sesiAdminRepository) FindByRefreshTokenLama(hash string) (*models.SesiAdmin, error) {
+	var sesi models.SesiAdmin
+	err := r.db.Where("refresh_token_lama = ?", hash).First(&sesi).Error
+	if err != nil {
+		return nil, err
+	}
+	return &sesi, nil
+}
+
+func (r *sesiAdminRepository) FindAktifByAdminID(adminID uuid.UUID) ([]models.SesiAdmin, error) {
+	var list []models.SesiAdmin
+	err := r.db.Where("admin_id = ? AND dicabut_pada IS NULL AND kadaluarsa_pada > ?", adminID, time.Now()).
+		Order("terakhir_dipakai DESC").Find(&list).Error
+	return list, err
+}
+
+func (r *sesiAdminRepository) Rotate(id uuid.UUID, hashLama, hashBaru string, kadaluarsa time.Time) (bool, error) {
+	result := r.db.Model(&models.SesiAdmin{}).
+		Where("id = ? AND refresh_token_hash = ? AND dicabut_pada IS NULL", id, hashLama).
+		Updates(map[string]interface{}{
+			"refresh_token_hash": hashBaru,
+			"refresh_token_lama": hashLama,
+			"terakhir_dipakai":   time.Now(),
+			"kadaluarsa_pada":    kadaluarsa,
+		})
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *sesiAdminRepository) Revoke(id uuid.UUID, alasan string) error {
+	return r.db.Model(&models.SesiAdmin{}).
+		Where("id = ? AND dicabut_pada IS NULL", id).
+		Updates(map[string]interface{}{"dicabut_pada": time.Now(), "alasan_dicabut": alasan}).Error
+}
+
+func (r *sesiAdminRepository) RevokeByAdminID(adminID uuid.UUID, alasan string, kecuali ...uuid.UUID) error {
+	query := r.db.Model(&models.SesiAdmin{}).Where("admin_id = ? AND dicabut_pada IS NULL", adminID)
+	if len(kecuali) > 0 {
+		query = query.Where("id NOT IN ?", kecuali)
+	}
+	return query.Updates(map[string]interface{}{"dicabut_pada": time.Now(), "alasan_dicabut": alasan}).Error
+}
+
+func (r *sesiAdminRepository) DeleteExpired(before time.Time) error {
+	return r.db.Unscoped().Where("kadaluarsa_pada < ?", before).Delete(&models.SesiAdmin{}).Error
+}
+
+// ============== Kode Pemulihan Repository ==============
+
+type KodePemulihanRepository interface {
+	// Replace swaps all recovery codes of an admin for new ones
+	Replace(adminID uuid.UUID, kode []models.KodePemulihan) error
+	// Pakai marks an unused code as used and reports whether it was found
+	Pakai(adminID uuid.UUID, kodeHash string) (bool, error)
+	CountSisa(adminID uuid.UUID) (int64, error)
+	DeleteByAdminID(adminID uuid.UUID) error
+}
+
+type kodePemulihanRepository struct {
+	db *gorm.DB
+}
+
+func NewKodePemulihanRepository(db *gorm.DB) KodePemulihanRepository {
+	return &kodePemulihanRepository{db: db}
+}
+
+func (r *kodePemulihanRepository) Replace(adminID uuid.UUID, kode []models.KodePemulihan) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("admin_id = ?", adminID).Delete(&models.KodePemulihan{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&kode).Error
+	})
+}
+
+func (r *kodePemulihanRepository) Pakai(adminID uuid.UUID, kodeHash string) (bool, error) {
+	result := r.db.Model(&models.KodePemulihan{}).
+		Where("admin_id = ? AND kode_hash = ? AND dipakai_pada IS NULL", adminID, kodeHash).
+		Limit(1).
+		UpdateColumn("dipakai_pada", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *kodePemulihanRepository) CountSisa(adminID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.KodePemulihan{}).Where("admin_id = ? AND dipakai_pada IS NULL", adminID).Count(&count).Error
+	return count, err
+}
+
+func (r *kodePemulihanRepository) DeleteByAdminID(adminID uuid.UUID) error {
+	return r.db.Unscoped().Where("admin_id = ?", adminID).Delete(&models.KodePemulihan{}).Error
+}
+
+// ============== Token Reset Password Repository ==============
+
+type TokenResetPasswordRepository interface {
+	Create(token *models.TokenResetPassword) error
+	FindByTokenHash(tokenHash string) (*models.TokenResetPassword, error)
+	// Pakai marks an unused token as used and reports whether it was still
+	// unused, so a link cannot be redeemed twice
+	Pakai(id uuid.UUID) (bool, error)
+	// BatalkanByAdminID marks all unused tokens of an admin as used
+	BatalkanByAdminID(adminID uuid.UUID) error
+	CountSejak(adminID uuid.UUID, sejak time.Time) (int64, error)
+	DeleteExpired(before time.Time) error
+}
+
+type tokenResetPasswordRepository struct {
+	db *gorm.DB
+}
+
+func NewTokenResetPasswordRepository(db *gorm.DB) TokenResetPasswordRepository {
+	return &tokenResetPasswordRepository{db: db}
+}
+
+func (r *tokenResetPasswordRepository) Create(token *models.TokenResetPassword) error {
+	return r.db.Create(token).Error
+}
+
+func (r *tokenResetPasswordRepository) FindByTokenHash(tokenHash string) (*models.TokenResetPassword, error) {
+	var token models.TokenResetPassword
+	err := r.db.Where("token_hash = ?", tokenHash).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+func (r *tokenResetPasswordRepository) Pakai(id uuid.UUID) (bool, error) {
+	result := r.db.Model(&models.TokenResetPassword{}).
+		Where("id = ? AND dipakai_pada IS NULL", id).
+		UpdateColumn("dipakai_pada", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *tokenResetPasswordRepository) BatalkanByAdminID(adminID uuid.UUID) error {
+	return r.db.Model(&models.TokenResetPassword{}).
+		Where("admin_id = ? AND dipakai_pada IS NULL", adminID).
+		UpdateColumn("dipakai_pada", time.Now()).Error
+}
+
+func (r *tokenResetPasswordRepository) CountSejak(adminID uuid.UUID, sejak time.Time) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.TokenResetPassword{}).
+		Where("admin_id = ? AND created_at >= ?", adminID, sejak).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *tokenResetPasswordRepository) DeleteExpired(before time.Time) error {
+	return r.db.Unscoped().Where("kadaluarsa_pada < ?", before).Delete(&models.TokenResetPassword{}).Error
+}
+
+// ============== Riwayat Password Repository ==============
+
+type RiwayatPasswordRepository interface {
+	Create(riwayat *models.RiwayatPassword) error
+	// FindTerbaru returns the latest limit password hashes of an admin
+	FindTerbaru(adminID uuid.UUID, limit int) ([]models.RiwayatPassword, error)
+	// Pangkas keeps only the latest simpan entries of an admin
+	Pangkas(adminID uuid.UUID, simpan int) error
+}
+
+type riwayatPasswordRepository struct {
+	db *gorm.DB
+}
+
+func NewRiwayatPasswordRepository(db *gorm.DB) RiwayatPasswordRepository {
+	return &riwayatPasswordRepository{db: db}
+}
+
+func (r *riwayatPasswordRepository) Create(riwayat *models.RiwayatPassword) error {
+	return r.db.Create(riwayat).Error
+}
+
+func (r *riwayatPasswordRepository) FindTerbaru(adminID uuid.UUID, limit int) ([]models.RiwayatPassword, error) {
+	var list []models.RiwayatPassword
+	err := r.db.Where("admin_id = ?", adminID).Order("created_at DESC").Limit(limit).Find(&list).Error
+	return list, err
+}
+
+func (r *riwayatPasswordRepository) Pangkas(adminID uuid.UUID, simpan int) error {
+	var simpanIDs []uuid.UUID
+	if simpan > 0 {
+		err := r.db.Model(&models.RiwayatPassword{}).
+			Where("admin_id = ?", adminID).
+			Order("created_at DESC").
+			Limit(simpan).
+			Pluck("id", &simpanIDs).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	query := r.db.Unscoped().Where("admin_id = ?", adminID)
+	if len(simpanIDs) > 0 {
+		query = query.Where("id NOT IN ?", simpanIDs)
+	}
+	return query.Delete(&models.RiwayatPassword{}).Error
+}
+
+// ============== Percobaan Login Repository ==============
+
+// RekapPercobaanLogin summarizes recent failed logins from one source
+type RekapPercobaanLogin struct {
+	Kunci    string
+	Jumlah   int64
+	Terakhir time.Time
+}
+
+type PercobaanLoginRepository interface {
+	Create(percobaan *models.PercobaanLogin) error
+	// RekapByUsername and RekapByIP count failed logins since a time that
+	// were not reset
+	RekapByUsername(username string, sejak time.Time) (*RekapPercobaanLogin, error)
+	RekapByIP(ip string, sejak time.Time) (*RekapPercobaanLogin, error)
+	// RekapIP lists addresses with at least minimal failed logins since a time
+	RekapIP(sejak time.Time, minimal int64) ([]RekapPercobaanLogin, error)
+	FindByAdminID(adminID uuid.UUID, limit int) ([]models.PercobaanLogin, error)
+	ResetByUsername(username string) error
+	ResetByIP(ip string) error
+}
+
+type percobaanLoginRepository struct {
+	db *gorm.DB
+}
+
+func NewPercobaanLoginRepository(db *gorm.DB) PercobaanLoginRepository {
+	return &percobaanLoginRepository{db: db}
+}
+
+func (r *percobaanLoginRepository) Create(percobaan *models.PercobaanLogin) error {
+	return r.db.Create(percobaan).Error
+}
+
+func (r *percobaanLoginRepository) rekap(kolom, nilai string, sejak time.Time) (*RekapPercobaanLogin, error) {
+	rekap := RekapPercobaanLogin{Kunci: nilai}
+	var terakhir *time.Time
+	row := r.db.Model(&models.PercobaanLogin{}).
+		Select("COUNT(*), MAX(created_at)").
+		Where(kolom+" = ? AND direset = ? AND created_at > ?", nilai, false, sejak).
+		Row()
+	if err := row.Scan(&rekap.Jumlah, &terakhir); err != nil {
+		return nil, err
+	}
+	if terakhir != nil {
+		rekap.Terakhir = *terakhir
+	}
+	return &rekap, nil
+}
+
+func (r *percobaanLoginRepository) RekapByUsername(username string, sejak time.Time) (*RekapPercobaanLogin, error) {
+	return r.rekap("username", username, sejak)
+}
+
+func (r *percobaanLoginRepository) RekapByIP(ip string, sejak time.Time) (*RekapPercobaanLogin, error) {
+	return r.rekap("ip_address", ip, sejak)
+}
+
+func (r *percobaanLoginRepository) RekapIP(sejak time.Time, minimal int64) ([]RekapPercobaanLogin, error) {
+	var list []RekapPercobaanLogin
+	err := r.db.Model(&models.PercobaanLogin{}).
+		Select("ip_address AS kunci, COUNT(*) AS jumlah, MAX(created_at) AS terakhir").
+		Where("direset = ? AND created_at > ?", false, sejak).
+		Group("ip_address").
+		Having("COUNT(*) >= ?", minimal).
+		Order("terakhir DESC").
+		Scan(&list).Error
+	return list, err
+}
+
+func (r *percobaanLoginRepository) FindByAdminID(adminID uuid.UUID, limit int) ([]models.PercobaanLogin, error) {
+	var list []models.PercobaanLogin
+	err := r.db.Where("admin_id = ?", adminID).Order("created_at DESC").Limit(limit).Find(&list).Error
+	return list, err
+}
+
+func (r *percobaanLoginRepository) ResetByUsername(username string) error {
+	return r.db.Model(&models.PercobaanLogin{}).Where("username = ? AND direset = ?", username, false).Update("direset", true).Error
+}
+
+func (r *percobaanLoginRepository) ResetByIP(ip string) error {
+	return r.db.Model(&models.PercobaanLogin{}).Where("ip_address = ? AND direset = ?", ip, false).Update("direset", true).Error
+}
+
 // ============== Notifikasi Repository ==============
 
 type NotifikasiRepository interface {
@@ -380,11 +1098,117 @@ func (r *notifikasiRepository) CountUnread(adminID uuid.UUID) (int64, error) {
 	return count, err
 }
 
+// ============== Preferensi Notifikasi Repository ==============
+
+type PreferensiNotifikasiRepository interface {
+	FindByAdminID(adminID uuid.UUID) (*models.PreferensiNotifikasi, error)
+	FindAll() ([]models.PreferensiNotifikasi, error)
+	Save(pref *models.PreferensiNotifikasi) error
+}
+
+type preferensiNotifikasiRepository struct {
+	db *gorm.DB
+}
+
+func NewPreferensiNotifikasiRepository(db *gorm.DB) PreferensiNotifikasiRepository {
+	return &preferensiNotifikasiRepository{db: db}
+}
+
+func (r *preferensiNotifikasiRepository) FindByAdminID(adminID uuid.UUID) (*models.PreferensiNotifikasi, error) {
+	var pref models.PreferensiNotifikasi
+	err := r.db.Where("admin_id = ?", adminID).First(&pref).Error
+	if err != nil {
+		return nil, err
+	}
+	return &pref, nil
+}
+
+func (r *preferensiNotifikasiRepository) FindAll() ([]models.PreferensiNotifikasi, error) {
+	var list []models.PreferensiNotifikasi
+	err := r.db.Find(&list).Error
+	return list, err
+}
+
+func (r *preferensiNotifikasiRepository) Save(pref *models.PreferensiNotifikasi) error {
+	return r.db.Save(pref).Error
+}
+
+// ============== Template Email Repository ==============
+
+type TemplateEmailRepository interface {
+	Create(tpl *models.TemplateEmail) error
+	FindAll() ([]models.TemplateEmail, error)
+	FindByID(id uuid.UUID) (*models.TemplateEmail, error)
+	FindByStatus(status models.StatusPermohonan, jenisPerizinanID *uuid.UUID) (*models.TemplateEmail, error)
+	Update(tpl *models.TemplateEmail) error
+	Delete(id uuid.UUID) error
+}
+
+type templateEmailRepository struct {
+	db *gorm.DB
+}
+
+func NewTemplateEmailRepository(db *gorm.DB) TemplateEmailRepository {
+	return &templateEmailRepository{db: db}
+}
+
+func (r *templateEmailRepository) Create(tpl *models.TemplateEmail) error {
+	return r.db.Create(tpl).Error
+}
+
+func (r *templateEmailRepository) FindAll() ([]models.TemplateEmail, error) {
+	var list []models.TemplateEmail
+	err := r.db.Preload("JenisPerizinan").Order("status ASC, jenis_perizinan_id ASC").Find(&list).Error
+	return list, err
+}
+
+func (r *templateEmailRepository) FindByID(id uuid.UUID) (*models.TemplateEmail, error) {
+	var tpl models.TemplateEmail
+	err := r.db.Preload("JenisPerizinan").Where("id = ?", id).First(&tpl).Error
+	if err != nil {
+		return nil, err
+	}
+	return &tpl, nil
+}
+
+// FindByStatus returns the template for exactly this status and jenis
+// perizinan; a nil jenisPerizinanID selects the default template
+func (r *templateEmailRepository) FindByStatus(status models.StatusPermohonan, jenisPerizinanID *uuid.UUID) (*models.TemplateEmail, error) {
+	var tpl models.TemplateEmail
+	query := r.db.Where("status = ?", status)
+	if jenisPerizinanID == nil {
+		query = query.Where("jenis_perizinan_id IS NULL")
+	} else {
+		query = query.Where("jenis_perizinan_id = ?", *jenisPerizinanID)
+	}
+	err := query.First(&tpl).Error
+	if err != nil {
+		return nil, err
+	}
+	return &tpl, nil
+}
+
+func (r *templateEmailRepository) Update(tpl *models.TemplateEmail) error {
+	return r.db.Save(tpl).Error
+}
+
+// Delete removes the template for good, so a new one for the same status
+// and jenis perizinan does not collide with it in the unique index
+func (r *templateEmailRepository) Delete(id uuid.UUID) error {
+	return r.db.Unscoped().Delete(&models.TemplateEmail{}, "id = ?", id).Error
+}
+
 // ============== Email Log Repository ==============
 
 type EmailLogRepository interface {
 	Create(log *models.EmailLog) error
+	Update(log *models.EmailLog) error
+	FindByID(id uuid.UUID) (*models.EmailLog, error)
 	FindByPermohonanID(permohonanID uuid.UUID) ([]models.EmailLog, error)
+	FindAllPaginated(offset, limit int, status string) ([]models.EmailLog, int64, error)
+	FindDue(now time.Time, limit int) ([]models.EmailLog, error)
+	Claim(id uuid.UUID, fromStatus string) (bool, error)
+	ReleaseStale(before time.Time) (int64, error)
 }
 
 type emailLogRepository struct {
@@ -399,8 +1223,70 @@ func (r *emailLogRepository) Create(log *models.EmailLog) error {
 	return r.db.Create(log).Error
 }
 
+func (r *emailLogRepository) Update(log *models.EmailLog) error {
+	return r.db.Save(log).Error
+}
+
+func (r *emailLogRepository) FindByID(id uuid.UUID) (*models.EmailLog, error) {
+	var log models.EmailLog
+	err := r.db.Where("id = ?", id).First(&log).Error
+	if err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
+
 func (r *emailLogRepository) FindByPermohonanID(permohonanID uuid.UUID) ([]models.EmailLog, error) {
 	var list []models.EmailLog
 	err := r.db.Where("permohonan_id = ?", permohonanID).Order("created_at DESC").Find(&list).Error
 	return list, err
 }
+
+func (r *emailLogRepository) FindAllPaginated(offset, limit int, status string) ([]models.EmailLog, int64, error) {
+	var list []models.EmailLog
+	var total int64
+
+	query := r.db.Model(&models.EmailLog{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	err := query.Count(&total).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&list).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return list, total, nil
+}
+
+// FindDue returns outbox entries that are waiting to be (re)sent
+func (r *emailLogRepository) FindDue(now time.Time, limit int) ([]models.EmailLog, error) {
+	var list []models.EmailLog
+	err := r.db.Where("status IN ?", []string{models.EmailStatusPending, models.EmailStatusFailed}).
+		Where("kirim_berikutnya IS NULL OR kirim_berikutnya <= ?", now).
+		Order("created_at ASC").Limit(limit).Find(&list).Error
+	return list, err
+}
+
+// Claim marks an entry as being sent. It returns false when another worker
+// has already taken it.
+func (r *emailLogRepository) Claim(id uuid.UUID, fromStatus string) (bool, error) {
+	result := r.db.Model(&models.EmailLog{}).
+		Where("id = ? AND status = ?", id, fromStatus).
+		Update("status", models.EmailStatusSending)
+	return result.RowsAffected == 1, result.Error
+}
+
+// ReleaseStale puts entries that were left in "sending" (e.g. after a crash)
+// back into the queue
+func (r *emailLogRepository) ReleaseStale(before time.Time) (int64, error) {
+	result := r.db.Model(&models.EmailLog{}).
+		Where("status = ? AND updated_at < ?", models.EmailStatusSending, before).
+		Update("status", models.EmailStatusFailed)
+	return result.RowsAffected, result.Error
+}
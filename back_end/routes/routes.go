@@ -15,16 +15,39 @@ func SetupRoutes(
 	permohonanController *controllers.PermohonanController,
 	notifikasiController *controllers.NotifikasiController,
 	adminController *controllers.AdminController,
+	emailLogController *controllers.EmailLogController,
+	templateEmailController *controllers.TemplateEmailController,
+	suratController *controllers.SuratController,
+	aksesBerkasController *controllers.AksesBerkasController,
+	pembatasanLoginController *controllers.PembatasanLoginController,
+	duaFaktorController *controllers.DuaFaktorController,
+	lupaPasswordController *controllers.LupaPasswordController,
+	roleController *controllers.RoleController,
 	authService services.AuthService,
+	roleService services.RoleService,
 ) {
 	// API v1 group
 	api := router.Group("/api/v1")
 
+	// izin guards a route with the permissions of the admin's role; any one
+	// of the listed permissions is enough
+	izin := func(perlu ...models.Izin) gin.HandlerFunc {
+		return middleware.IzinMiddleware(roleService, perlu...)
+	}
+
 	// Public routes (no authentication required)
 	public := api.Group("")
 	{
 		// Auth routes
 		public.POST("/auth/login", authController.Login)
+		public.POST("/auth/login/2fa", authController.Verifikasi2FA)
+		public.POST("/auth/login/2fa/setup", authController.MulaiSetup2FA)
+		public.POST("/auth/login/2fa/aktifkan", authController.AktifkanSetup2FA)
+		public.POST("/auth/refresh", authController.Refresh)
+		public.POST("/auth/lupa-password", lupaPasswordController.Minta)
+		public.GET("/auth/reset-password/:token", lupaPasswordController.CekToken)
+		public.POST("/auth/reset-password", lupaPasswordController.Reset)
+		public.GET("/auth/kebijakan-password", lupaPasswordController.GetKebijakan)
 
 		// Public jenis perizinan (for form dropdown)
 		public.GET("/jenis-perizinan", jenisPerizinanController.GetAll)
@@ -32,54 +55,118 @@ func SetupRoutes(
 
 		// Public permohonan submission
 		public.POST("/permohonan", permohonanController.Create)
+		public.GET("/permohonan/lacak", permohonanController.Lacak)
+		public.GET("/permohonan/revisi/:token", permohonanController.GetRevisiInfo)
+		public.POST("/permohonan/revisi/:token", permohonanController.UploadRevisi)
+
+		// Signed download links issued to admins; the signature replaces the token
+		public.GET("/unduh/:jenis/:id", aksesBerkasController.UnduhTautan)
 	}
 
-	// Protected routes (authentication required - all admin roles can access)
+	// Protected routes (authentication required, then the permission of each route)
 	protected := api.Group("")
 	protected.Use(middleware.AuthMiddleware(authService))
 	{
 		// Auth routes - accessible by all authenticated admins
 		protected.GET("/auth/profile", authController.GetProfile)
 		protected.POST("/auth/change-password", adminController.ChangePassword)
+		protected.POST("/auth/logout", authController.Logout)
+		protected.GET("/auth/sessions", authController.GetSesi)
+		protected.DELETE("/auth/sessions/:id", authController.CabutSesi)
 
-		// Admin - Permohonan management (accessible by all admin roles)
-		protected.GET("/admin/permohonan", permohonanController.GetAll)
-		protected.GET("/admin/permohonan/:id", permohonanController.GetByID)
-		protected.GET("/admin/permohonan/status/:status", permohonanController.GetByStatus)
-		protected.PATCH("/admin/permohonan/:id/status", permohonanController.UpdateStatus)
-		protected.POST("/admin/permohonan/:id/balasan", permohonanController.KirimBalasan)
+		// Auth routes - two-factor authentication of the logged in admin
+		protected.GET("/auth/2fa", duaFaktorController.GetStatus)
+		protected.POST("/auth/2fa/setup", duaFaktorController.Mulai)
+		protected.POST("/auth/2fa/aktifkan", duaFaktorController.Aktifkan)
+		protected.POST("/auth/2fa/nonaktifkan", duaFaktorController.Nonaktifkan)
+		protected.POST("/auth/2fa/kode-pemulihan", duaFaktorController.BuatUlangKodePemulihan)
 
-		// Admin - Dashboard (accessible by all admin roles)
-		protected.GET("/admin/dashboard/statistik", permohonanController.GetStatistik)
-		protected.GET("/admin/dashboard/recent", permohonanController.GetRecentPermohonan)
+		// Admin - Permohonan management
+		protected.GET("/admin/permohonan", izin(models.IzinLihatPermohonan), permohonanController.GetAll)
+		protected.GET("/admin/permohonan/:id", izin(models.IzinLihatPermohonan), permohonanController.GetByID)
+		protected.GET("/admin/permohonan/status/:status", izin(models.IzinLihatPermohonan), permohonanController.GetByStatus)
+		protected.PATCH("/admin/permohonan/:id/status", izin(models.IzinUbahStatus), permohonanController.UpdateStatus)
+		protected.POST("/admin/permohonan/:id/revisi", izin(models.IzinUbahStatus), permohonanController.MintaRevisi)
+		protected.POST("/admin/permohonan/:id/balasan", izin(models.IzinKirimBalasan), permohonanController.KirimBalasan)
+
+		// Admin - Applicant files and letters, resolved by ID and logged on every access
+		protected.GET("/admin/permohonan/:id/surat", izin(models.IzinUnduhBerkas), permohonanController.DownloadSurat)
+		protected.GET("/admin/berkas/:id/unduh", izin(models.IzinUnduhBerkas), aksesBerkasController.UnduhBerkas)
+		protected.GET("/admin/berkas/:id/tautan", izin(models.IzinUnduhBerkas), aksesBerkasController.TautanBerkas)
+		protected.GET("/admin/berkas/:id/pratinjau", izin(models.IzinUnduhBerkas), aksesBerkasController.PratinjauBerkas)
+		protected.GET("/admin/permohonan/:id/lampiran", izin(models.IzinUnduhBerkas), aksesBerkasController.UnduhLampiran)
+		protected.GET("/admin/permohonan/:id/lampiran/tautan", izin(models.IzinUnduhBerkas), aksesBerkasController.TautanLampiran)
+		protected.GET("/admin/permohonan/:id/bundel", izin(models.IzinUnduhBerkas), aksesBerkasController.UnduhBundel)
+		protected.GET("/admin/permohonan/:id/bundel/tautan", izin(models.IzinUnduhBerkas), aksesBerkasController.TautanBundel)
+
+		// Admin - Dashboard
+		protected.GET("/admin/dashboard/statistik", izin(models.IzinLihatLaporan), permohonanController.GetStatistik)
+		protected.GET("/admin/dashboard/recent", izin(models.IzinLihatLaporan), permohonanController.GetRecentPermohonan)
 
 		// Admin - Notifikasi (accessible by all admin roles)
 		protected.GET("/admin/notifikasi", notifikasiController.GetAll)
 		protected.GET("/admin/notifikasi/count", notifikasiController.CountUnread)
 		protected.PATCH("/admin/notifikasi/:id/read", notifikasiController.MarkAsRead)
 		protected.PATCH("/admin/notifikasi/read-all", notifikasiController.MarkAllAsRead)
+		protected.GET("/admin/notifikasi/preferensi", notifikasiController.GetPreferensi)
+		protected.PUT("/admin/notifikasi/preferensi", notifikasiController.UpdatePreferensi)
+		protected.POST("/admin/notifikasi/stream/tiket", authController.TiketStream)
+
+		// Admin - Email outbox and templates
+		protected.GET("/admin/email-log", izin(models.IzinLihatEmail), emailLogController.GetAll)
+		protected.POST("/admin/email-log/:id/retry", izin(models.IzinKirimUlangEmail), emailLogController.Retry)
+		protected.GET("/admin/template-email", izin(models.IzinKelolaTemplate), templateEmailController.GetAll)
+		protected.POST("/admin/template-email", izin(models.IzinKelolaTemplate), templateEmailController.Create)
+		protected.POST("/admin/template-email/preview", izin(models.IzinKelolaTemplate), templateEmailController.Preview)
+		protected.GET("/admin/template-email/:id", izin(models.IzinKelolaTemplate), templateEmailController.GetByID)
+		protected.PUT("/admin/template-email/:id", izin(models.IzinKelolaTemplate), templateEmailController.Update)
+		protected.DELETE("/admin/template-email/:id", izin(models.IzinKelolaTemplate), templateEmailController.Delete)
+
+		// Admin - Jenis Perizinan CRUD
+		protected.POST("/admin/jenis-perizinan", izin(models.IzinKelolaJenis), jenisPerizinanController.Create)
+		protected.PUT("/admin/jenis-perizinan/:id", izin(models.IzinKelolaJenis), jenisPerizinanController.Update)
+		protected.DELETE("/admin/jenis-perizinan/:id", izin(models.IzinKelolaJenis), jenisPerizinanController.Delete)
+
+		// Admin - Admin accounts; the role list is also needed to assign roles
+		protected.GET("/admin/admins", izin(models.IzinKelolaAdmin), adminController.GetAll)
+		protected.POST("/admin/admins", izin(models.IzinKelolaAdmin), adminController.Create)
+		protected.GET("/admin/admins/:id", izin(models.IzinKelolaAdmin), adminController.GetByID)
+		protected.PUT("/admin/admins/:id", izin(models.IzinKelolaAdmin), adminController.Update)
+		protected.DELETE("/admin/admins/:id", izin(models.IzinKelolaAdmin), adminController.Delete)
+		protected.POST("/admin/admins/:id/reset-password", izin(models.IzinKelolaAdmin), adminController.ResetPassword)
+		protected.GET("/admin/admins/:id/sessions", izin(models.IzinKelolaAdmin), adminController.GetSesi)
+		protected.DELETE("/admin/admins/:id/sessions", izin(models.IzinKelolaAdmin), adminController.CabutSemuaSesi)
+		protected.POST("/admin/admins/:id/reset-2fa", izin(models.IzinKelolaAdmin), duaFaktorController.Reset)
+
+		// Admin - Roles and their permissions
+		protected.GET("/admin/roles", izin(models.IzinKelolaRole, models.IzinKelolaAdmin), roleController.GetAll)
+		protected.GET("/admin/roles/:id", izin(models.IzinKelolaRole, models.IzinKelolaAdmin), roleController.GetByID)
+		protected.GET("/admin/izin", izin(models.IzinKelolaRole, models.IzinKelolaAdmin), roleController.GetDaftarIzin)
+		protected.POST("/admin/roles", izin(models.IzinKelolaRole), roleController.Create)
+		protected.PUT("/admin/roles/:id", izin(models.IzinKelolaRole), roleController.Update)
+		protected.DELETE("/admin/roles/:id", izin(models.IzinKelolaRole), roleController.Delete)
+
+		// Admin - Failed logins and lockouts
+		protected.GET("/admin/penguncian-login", izin(models.IzinLihatAudit, models.IzinKelolaAdmin), pembatasanLoginController.GetPenguncian)
+		protected.GET("/admin/admins/:id/percobaan-login", izin(models.IzinLihatAudit, models.IzinKelolaAdmin), pembatasanLoginController.GetPercobaanByAdminID)
+		protected.DELETE("/admin/penguncian-login/ip/:ip", izin(models.IzinKelolaAdmin), pembatasanLoginController.BukaIP)
+		protected.DELETE("/admin/admins/:id/penguncian", izin(models.IzinKelolaAdmin), pembatasanLoginController.BukaAkun)
+
+		// Admin - Revoke issued permit letters
+		protected.POST("/admin/surat/:id/cabut", izin(models.IzinCabutSurat), suratController.Cabut)
+
+		// Admin - Who opened the files of a permohonan
+		protected.GET("/admin/permohonan/:id/akses-berkas", izin(models.IzinLihatAudit), aksesBerkasController.GetByPermohonanID)
 	}
 
-	// Super Admin only routes (for managing perizinan types and admins)
-	superAdminRoutes := api.Group("")
-	superAdminRoutes.Use(middleware.AuthMiddleware(authService))
-	superAdminRoutes.Use(middleware.RoleMiddleware(models.RoleSuperAdmin))
+	// Real-time notification stream (Server-Sent Events), opened with a
+	// ticket from POST /admin/notifikasi/stream/tiket
+	stream := api.Group("")
+	stream.Use(middleware.TiketStreamMiddleware(authService))
 	{
-		// Super Admin - Jenis Perizinan CRUD (only super_admin can manage)
-		superAdminRoutes.POST("/admin/jenis-perizinan", jenisPerizinanController.Create)
-		superAdminRoutes.PUT("/admin/jenis-perizinan/:id", jenisPerizinanController.Update)
-		superAdminRoutes.DELETE("/admin/jenis-perizinan/:id", jenisPerizinanController.Delete)
-
-		// Super Admin - Admin Management CRUD
-		superAdminRoutes.GET("/admin/admins", adminController.GetAll)
-		superAdminRoutes.POST("/admin/admins", adminController.Create)
-		superAdminRoutes.GET("/admin/admins/:id", adminController.GetByID)
-		superAdminRoutes.PUT("/admin/admins/:id", adminController.Update)
-		superAdminRoutes.DELETE("/admin/admins/:id", adminController.Delete)
-		superAdminRoutes.POST("/admin/admins/:id/reset-password", adminController.ResetPassword)
+		stream.GET("/admin/notifikasi/stream", notifikasiController.Stream)
 	}
 
-	// Serve uploaded files with download endpoint
-	router.GET("/download/:filename", permohonanController.DownloadFile)
-	router.Static("/uploads", "./uploads")
+	// Public verification of issued letters (target of the QR code)
+	router.GET("/verifikasi/:token", suratController.Verifikasi)
 }
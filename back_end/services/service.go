@@ -1,848 +1,6024 @@
 package services
 
 import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
+	htmltemplate "html/template"
+	"image"
+	"io"
+	"io/fs"
+	"log"
+	"math"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
+	texttemplate "text/template"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/alifsyafan/backend-capston/config"
 	"github.com/alifsyafan/backend-capston/dto"
 	"github.com/alifsyafan/backend-capston/models"
 	"github.com/alifsyafan/backend-capston/repositories"
+	"github.com/disintegration/imaging"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"github.com/jung-kurt/gofpdf"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+	"github.com/skip2/go-qrcode"
 	"golang.org/x/crypto/bcrypt"
 	"gopkg.in/gomail.v2"
+	"gorm.io/gorm"
 )
 
 // ============== Auth Service ==============
 
+var (
+	// ErrSesiTidakValid is returned for refresh tokens and sessions that
+	// are unknown, expired or revoked
+	ErrSesiTidakValid = errors.New("sesi tidak valid atau sudah berakhir")
+	// ErrTantanganTidakValid is returned for an invalid or expired token of
+	// the two-factor login step
+	ErrTantanganTidakValid = errors.New("verifikasi login tidak valid atau sudah kadaluarsa, silakan login ulang")
+	// ErrTiketStreamTidakValid is returned for an invalid or expired ticket
+	// of the notification stream
+	ErrTiketStreamTidakValid = errors.New("tiket stream tidak valid atau sudah kadaluarsa")
+)
+
+// Purposes of the short-lived token handed out between password and
+// two-factor check
+const (
+	tujuanTantangan2FA      = "2fa"
+	tujuanTantanganSetup2FA = "2fa_setup"
+	masaBerlakuTantangan    = 5 * time.Minute
+
+	// The notification stream is opened with a ticket in the URL instead of
+	// the access token, see BuatTiketStream
+	tujuanTiketStream      = "stream"
+	masaBerlakuTiketStream = time.Minute
+)
+
+// KlienInfo describes the client a session was started from
+type KlienInfo struct {
+	IPAddress string
+	UserAgent string
+}
+
 type AuthService interface {
-	Login(req dto.LoginRequest) (*dto.LoginResponse, error)
+	// Login checks the password. Admins with two-factor authentication, or
+	// whose role requires it, get a TokenTantangan instead of a session.
+	Login(req dto.LoginRequest, klien KlienInfo) (*dto.LoginResponse, error)
+	// Verifikasi2FA completes a login with a TOTP or recovery code
+	Verifikasi2FA(req dto.Verifikasi2FARequest, klien KlienInfo) (*dto.LoginResponse, error)
+	// MulaiSetup2FA and AktifkanSetup2FA enroll an admin whose role requires
+	// 2FA during login; activating completes the login
+	MulaiSetup2FA(tokenTantangan string) (*dto.Setup2FAResponse, error)
+	AktifkanSetup2FA(req dto.Verifikasi2FARequest, klien KlienInfo) (*dto.LoginResponse, error)
+	// Refresh trades a refresh token for a new access token and a new
+	// refresh token. The old refresh token stops working.
+	Refresh(refreshToken string, klien KlienInfo) (*dto.LoginResponse, error)
+	Logout(sesiID uuid.UUID) error
 	ValidateToken(tokenString string) (*jwt.MapClaims, error)
+	// VerifikasiSesi checks that the session of an access token is still
+	// active and returns its admin as stored now
+	VerifikasiSesi(adminID, sesiID uuid.UUID) (*models.Admin, error)
 	GetAdminByID(id uuid.UUID) (*models.Admin, error)
+	// GetProfil returns the admin with the permissions of their role
+	GetProfil(id uuid.UUID) (*dto.AdminInfo, error)
+	GetSesi(adminID, sesiIni uuid.UUID) ([]dto.SesiResponse, error)
+	CabutSesi(adminID, sesiID uuid.UUID) error
+	// BuatTiketStream hands out a short-lived ticket for opening the
+	// notification stream. Browsers cannot set headers on an EventSource,
+	// so the ticket goes in the URL, where an access token would end up in
+	// logs and history. A ticket is only checked when the stream is opened;
+	// clients fetch a new one to reconnect and pass the last event ID as
+	// last_event_id.
+	BuatTiketStream(adminID, sesiID uuid.UUID) (*dto.TiketStreamResponse, error)
+	// VerifikasiTiketStream checks a stream ticket and the session it was
+	// issued in, and returns the admin as stored now
+	VerifikasiTiketStream(tiket string) (*models.Admin, uuid.UUID, error)
 }
 
 type authService struct {
-	adminRepo repositories.AdminRepository
-	cfg       *config.Config
+	adminRepo   repositories.AdminRepository
+	sesiRepo    repositories.SesiAdminRepository
+	pembatasan  PembatasanLoginService
+	duaFaktor   DuaFaktorService
+	roleService RoleService
+	cfg         *config.Config
 }
 
-func NewAuthService(adminRepo repositories.AdminRepository, cfg *config.Config) AuthService {
-	return &authService{adminRepo: adminRepo, cfg: cfg}
+func NewAuthService(
+	adminRepo repositories.AdminRepository,
+	sesiRepo repositories.SesiAdminRepository,
+	pembatasan PembatasanLoginService,
+	duaFaktor DuaFaktorService,
+	roleService RoleService,
+	cfg *config.Config,
+) AuthService {
+	return &authService{adminRepo: adminRepo, sesiRepo: sesiRepo, pembatasan: pembatasan, duaFaktor: duaFaktor, roleService: roleService, cfg: cfg}
 }
 
-func (s *authService) Login(req dto.LoginRequest) (*dto.LoginResponse, error) {
+func (s *authService) Login(req dto.LoginRequest, klien KlienInfo) (*dto.LoginResponse, error) {
+	if err := s.pembatasan.Cek(req.Username, klien.IPAddress); err != nil {
+		return nil, err
+	}
+
 	admin, err := s.adminRepo.FindByUsername(req.Username)
 	if err != nil {
+		s.pembatasan.CatatGagal(req.Username, nil, klien, models.AlasanLoginTidakDitemukan)
 		return nil, errors.New("username atau password salah")
 	}
 
 	if !admin.IsActive {
+		s.pembatasan.CatatGagal(req.Username, admin, klien, models.AlasanLoginAkunTidakAktif)
 		return nil, errors.New("akun tidak aktif")
 	}
 
 	err = bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(req.Password))
 	if err != nil {
+		s.pembatasan.CatatGagal(req.Username, admin, klien, models.AlasanLoginPasswordSalah)
 		return nil, errors.New("username atau password salah")
 	}
 
-	// Generate JWT token
-	expiryHours, _ := strconv.Atoi(s.cfg.JWTExpiryHours)
-	expiresAt := time.Now().Add(time.Duration(expiryHours) * time.Hour)
+	// The failure counter is only reset once the second factor passed too,
+	// otherwise a known password would allow unlimited code guesses
+	if admin.TOTPAktif || s.duaFaktor.Wajib(admin) {
+		return s.buatTantangan(admin)
+	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"admin_id": admin.ID.String(),
-		"username": admin.Username,
-		"role":     string(admin.Role),
-		"exp":      expiresAt.Unix(),
-	})
+	s.pembatasan.CatatBerhasil(admin)
+	return s.buatSesi(admin, klien)
+}
 
-	tokenString, err := token.SignedString([]byte(s.cfg.JWTSecret))
+func (s *authService) Verifikasi2FA(req dto.Verifikasi2FARequest, klien KlienInfo) (*dto.LoginResponse, error) {
+	admin, err := s.bacaTantangan(req.TokenTantangan, tujuanTantangan2FA)
 	if err != nil {
-		return nil, errors.New("gagal membuat token")
+		return nil, err
+	}
+	if err := s.pembatasan.Cek(admin.Username, klien.IPAddress); err != nil {
+		return nil, err
 	}
 
-	return &dto.LoginResponse{
-		Token:     tokenString,
-		ExpiresAt: expiresAt,
-		Admin: dto.AdminInfo{
-			ID:          admin.ID,
-			Username:    admin.Username,
-			Email:       admin.Email,
-			NamaLengkap: admin.NamaLengkap,
-			Role:        string(admin.Role),
-		},
-	}, nil
+	if err := s.duaFaktor.Verifikasi(admin, req.Kode); err != nil {
+		if errors.Is(err, ErrKode2FATidakValid) {
+			s.pembatasan.CatatGagal(admin.Username, admin, klien, models.AlasanLoginKode2FASalah)
+		}
+		return nil, err
+	}
+
+	s.pembatasan.CatatBerhasil(admin)
+	return s.buatSesi(admin, klien)
 }
 
-func (s *authService) ValidateToken(tokenString string) (*jwt.MapClaims, error) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(s.cfg.JWTSecret), nil
-	})
+func (s *authService) MulaiSetup2FA(tokenTantangan string) (*dto.Setup2FAResponse, error) {
+	admin, err := s.bacaTantangan(tokenTantangan, tujuanTantanganSetup2FA)
+	if err != nil {
+		return nil, err
+	}
+	return s.duaFaktor.Mulai(admin.ID)
+}
 
+func (s *authService) AktifkanSetup2FA(req dto.Verifikasi2FARequest, klien KlienInfo) (*dto.LoginResponse, error) {
+	admin, err := s.bacaTantangan(req.TokenTantangan, tujuanTantanganSetup2FA)
 	if err != nil {
 		return nil, err
 	}
+	if err := s.pembatasan.Cek(admin.Username, klien.IPAddress); err != nil {
+		return nil, err
+	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		return &claims, nil
+	kodePemulihan, err := s.duaFaktor.Aktifkan(admin.ID, req.Kode)
+	if err != nil {
+		if errors.Is(err, ErrKode2FATidakValid) {
+			s.pembatasan.CatatGagal(admin.Username, admin, klien, models.AlasanLoginKode2FASalah)
+		}
+		return nil, err
 	}
 
-	return nil, errors.New("invalid token")
+	s.pembatasan.CatatBerhasil(admin)
+	response, err := s.buatSesi(admin, klien)
+	if err != nil {
+		return nil, err
+	}
+	response.Admin.TOTPAktif = true
+	response.KodePemulihan = kodePemulihan
+	return response, nil
 }
 
-func (s *authService) GetAdminByID(id uuid.UUID) (*models.Admin, error) {
-	return s.adminRepo.FindByID(id)
-}
+// buatTantangan hands out the token for the two-factor login step. It is
+// not an access token: it carries no session and AuthMiddleware rejects it.
+func (s *authService) buatTantangan(admin *models.Admin) (*dto.LoginResponse, error) {
+	tujuan := tujuanTantangan2FA
+	if !admin.TOTPAktif {
+		tujuan = tujuanTantanganSetup2FA
+	}
 
-// ============== Admin Management Service ==============
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"admin_id": admin.ID.String(),
+		"tujuan":   tujuan,
+		"exp":      time.Now().Add(masaBerlakuTantangan).Unix(),
+	})
+	tokenString, err := token.SignedString([]byte(s.cfg.JWTSecret))
+	if err != nil {
+		return nil, errors.New("gagal membuat token")
+	}
 
-type AdminService interface {
-	Create(req dto.CreateAdminRequest) (*dto.AdminResponse, error)
-	GetAll(query dto.PaginationQuery) (*dto.AdminListResponse, error)
-	GetByID(id uuid.UUID) (*dto.AdminResponse, error)
-	Update(id uuid.UUID, req dto.UpdateAdminRequest) (*dto.AdminResponse, error)
-	Delete(id uuid.UUID) error
-	ResetPassword(id uuid.UUID, req dto.ResetPasswordRequest) error
-	ChangePassword(id uuid.UUID, req dto.ChangePasswordRequest) error
+	return &dto.LoginResponse{
+		Admin:          s.toAdminInfo(admin),
+		Perlu2FA:       admin.TOTPAktif,
+		Setup2FA:       !admin.TOTPAktif,
+		TokenTantangan: tokenString,
+	}, nil
 }
 
-type adminService struct {
-	repo repositories.AdminRepository
-}
+// bacaTantangan resolves a two-factor login token to its still active admin
+func (s *authService) bacaTantangan(tokenString, tujuan string) (*models.Admin, error) {
+	claims, err := s.ValidateToken(tokenString)
+	if err != nil {
+		return nil, ErrTantanganTidakValid
+	}
+	if t, _ := (*claims)["tujuan"].(string); t != tujuan {
+		return nil, ErrTantanganTidakValid
+	}
+	adminIDStr, _ := (*claims)["admin_id"].(string)
+	adminID, err := uuid.Parse(adminIDStr)
+	if err != nil {
+		return nil, ErrTantanganTidakValid
+	}
 
-func NewAdminService(repo repositories.AdminRepository) AdminService {
-	return &adminService{repo: repo}
+	admin, err := s.adminRepo.FindByID(adminID)
+	if err != nil || !admin.IsActive {
+		return nil, ErrTantanganTidakValid
+	}
+	return admin, nil
 }
 
-func (s *adminService) Create(req dto.CreateAdminRequest) (*dto.AdminResponse, error) {
-	// Check if username already exists
-	existing, _ := s.repo.FindByUsername(req.Username)
-	if existing != nil {
-		return nil, errors.New("username sudah digunakan")
+// buatSesi starts a session for an admin who passed every login step
+func (s *authService) buatSesi(admin *models.Admin, klien KlienInfo) (*dto.LoginResponse, error) {
+	if err := s.sesiRepo.DeleteExpired(time.Now()); err != nil {
+		log.Printf("Warning: gagal membersihkan sesi kadaluarsa: %v", err)
 	}
 
-	// Check if email already exists
-	existing, _ = s.repo.FindByEmail(req.Email)
-	if existing != nil {
-		return nil, errors.New("email sudah digunakan")
+	refreshToken, err := generateToken()
+	if err != nil {
+		return nil, errors.New("gagal membuat token")
 	}
+	sesi := &models.SesiAdmin{
+		AdminID:          admin.ID,
+		RefreshTokenHash: hashToken(refreshToken),
+		IPAddress:        klien.IPAddress,
+		UserAgent:        potongTeks(klien.UserAgent, 255),
+		TerakhirDipakai:  time.Now(),
+		KadaluarsaPada:   s.refreshExpiry(),
+	}
+	if err := s.sesiRepo.Create(sesi); err != nil {
+		return nil, errors.New("gagal membuat sesi")
+	}
+
+	return s.buatLoginResponse(admin, sesi, refreshToken)
+}
 
-	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+func (s *authService) Refresh(refreshToken string, klien KlienInfo) (*dto.LoginResponse, error) {
+	hash := hashToken(refreshToken)
+	sesi, err := s.sesiRepo.FindByRefreshToken(hash)
 	if err != nil {
-		return nil, errors.New("gagal mengenkripsi password")
+		// A refresh token that was already rotated away is being replayed,
+		// so whoever holds the current one may not be the admin. End it.
+		if lama, err := s.sesiRepo.FindByRefreshTokenLama(hash); err == nil && lama.DicabutPada == nil {
+			log.Printf("Warning: refresh token sesi %s dipakai ulang, sesi dicabut", lama.ID)
+			s.sesiRepo.Revoke(lama.ID, models.AlasanSesiTokenDipakaiUlang)
+		}
+		return nil, ErrSesiTidakValid
+	}
+	if sesi.DicabutPada != nil || time.Now().After(sesi.KadaluarsaPada) {
+		return nil, ErrSesiTidakValid
 	}
 
-	admin := &models.Admin{
-		Username:    req.Username,
-		Password:    string(hashedPassword),
-		Email:       req.Email,
-		NamaLengkap: req.NamaLengkap,
-		Role:        models.RoleAdmin(req.Role),
-		IsActive:    true,
+	admin, err := s.adminRepo.FindByID(sesi.AdminID)
+	if err != nil || !admin.IsActive {
+		return nil, ErrSesiTidakValid
+	}
+	// Sessions from before the role required 2FA end here, so the admin
+	// enrolls at the next login
+	if !admin.TOTPAktif && s.duaFaktor.Wajib(admin) {
+		return nil, ErrSesiTidakValid
 	}
 
-	err = s.repo.Create(admin)
+	baru, err := generateToken()
+	if err != nil {
+		return nil, errors.New("gagal membuat token")
+	}
+	sesi.KadaluarsaPada = s.refreshExpiry()
+	ok, err := s.sesiRepo.Rotate(sesi.ID, hash, hashToken(baru), sesi.KadaluarsaPada)
 	if err != nil {
 		return nil, err
 	}
+	if !ok {
+		return nil, ErrSesiTidakValid
+	}
 
-	return s.toAdminResponse(admin), nil
+	return s.buatLoginResponse(admin, sesi, baru)
 }
 
-func (s *adminService) GetAll(query dto.PaginationQuery) (*dto.AdminListResponse, error) {
-	admins, total, err := s.repo.FindAllPaginated(query.GetOffset(), query.GetLimit(), query.Search)
-	if err != nil {
-		return nil, err
-	}
+func (s *authService) Logout(sesiID uuid.UUID) error {
+	return s.sesiRepo.Revoke(sesiID, models.AlasanSesiLogout)
+}
 
-	var responses []dto.AdminResponse
-	for _, admin := range admins {
-		responses = append(responses, *s.toAdminResponse(&admin))
+// buatLoginResponse signs an access token for a session. Role and username
+// in the token are informational; AuthMiddleware reads them from the
+// database on every request.
+func (s *authService) buatLoginResponse(admin *models.Admin, sesi *models.SesiAdmin, refreshToken string) (*dto.LoginResponse, error) {
+	expiryMinutes, _ := strconv.Atoi(s.cfg.JWTExpiryMinutes)
+	if expiryMinutes <= 0 {
+		expiryMinutes = 15
 	}
+	expiresAt := time.Now().Add(time.Duration(expiryMinutes) * time.Minute)
 
-	totalPages := int(total) / query.GetLimit()
-	if int(total)%query.GetLimit() > 0 {
-		totalPages++
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"admin_id": admin.ID.String(),
+		"sid":      sesi.ID.String(),
+		"username": admin.Username,
+		"role":     string(admin.Role),
+		"exp":      expiresAt.Unix(),
+	})
+
+	tokenString, err := token.SignedString([]byte(s.cfg.JWTSecret))
+	if err != nil {
+		return nil, errors.New("gagal membuat token")
 	}
 
-	return &dto.AdminListResponse{
-		Data:       responses,
-		Total:      total,
-		Page:       query.Page,
-		PerPage:    query.GetLimit(),
-		TotalPages: totalPages,
+	return &dto.LoginResponse{
+		Token:            tokenString,
+		ExpiresAt:        expiresAt,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: sesi.KadaluarsaPada,
+		Admin:            s.toAdminInfo(admin),
 	}, nil
 }
 
-func (s *adminService) GetByID(id uuid.UUID) (*dto.AdminResponse, error) {
-	admin, err := s.repo.FindByID(id)
+func (s *authService) toAdminInfo(admin *models.Admin) dto.AdminInfo {
+	izin, err := s.roleService.IzinRole(admin.Role)
 	if err != nil {
-		return nil, errors.New("admin tidak ditemukan")
+		// The routes check permissions themselves, the list only picks menus
+		log.Printf("Warning: gagal membaca izin role %s: %v", admin.Role, err)
+	}
+	return dto.AdminInfo{
+		ID:          admin.ID,
+		Username:    admin.Username,
+		Email:       admin.Email,
+		NamaLengkap: admin.NamaLengkap,
+		Role:        string(admin.Role),
+		TOTPAktif:   admin.TOTPAktif,
+		Izin:        izinStrings(izin),
 	}
-	return s.toAdminResponse(admin), nil
 }
 
-func (s *adminService) Update(id uuid.UUID, req dto.UpdateAdminRequest) (*dto.AdminResponse, error) {
-	admin, err := s.repo.FindByID(id)
-	if err != nil {
-		return nil, errors.New("admin tidak ditemukan")
+func (s *authService) refreshExpiry() time.Time {
+	hours, _ := strconv.Atoi(s.cfg.RefreshTokenExpiryHours)
+	if hours <= 0 {
+		hours = 168
 	}
+	return time.Now().Add(time.Duration(hours) * time.Hour)
+}
 
-	// Check username uniqueness if changed
-	if req.Username != "" && req.Username != admin.Username {
-		existing, _ := s.repo.FindByUsername(req.Username)
-		if existing != nil {
-			return nil, errors.New("username sudah digunakan")
+func (s *authService) ValidateToken(tokenString string) (*jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
-		admin.Username = req.Username
-	}
+		return []byte(s.cfg.JWTSecret), nil
+	})
 
-	// Check email uniqueness if changed
-	if req.Email != "" && req.Email != admin.Email {
-		existing, _ := s.repo.FindByEmail(req.Email)
-		if existing != nil {
-			return nil, errors.New("email sudah digunakan")
-		}
-		admin.Email = req.Email
+	if err != nil {
+		return nil, err
 	}
 
-	if req.NamaLengkap != "" {
-		admin.NamaLengkap = req.NamaLengkap
+	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+		return &claims, nil
 	}
 
-	if req.Role != "" {
-		admin.Role = models.RoleAdmin(req.Role)
-	}
+	return nil, errors.New("invalid token")
+}
 
-	if req.IsActive != nil {
-		admin.IsActive = *req.IsActive
+func (s *authService) VerifikasiSesi(adminID, sesiID uuid.UUID) (*models.Admin, error) {
+	sesi, err := s.sesiRepo.FindByID(sesiID)
+	if err != nil || sesi.AdminID != adminID || sesi.DicabutPada != nil || time.Now().After(sesi.KadaluarsaPada) {
+		return nil, ErrSesiTidakValid
 	}
 
-	err = s.repo.Update(admin)
-	if err != nil {
-		return nil, err
+	admin, err := s.adminRepo.FindByID(adminID)
+	if err != nil || !admin.IsActive {
+		return nil, ErrSesiTidakValid
 	}
-
-	return s.toAdminResponse(admin), nil
+	return admin, nil
 }
 
-func (s *adminService) Delete(id uuid.UUID) error {
-	_, err := s.repo.FindByID(id)
+// BuatTiketStream issues a stream ticket. It is not an access token: it
+// carries no sid claim, so AuthMiddleware rejects it.
+func (s *authService) BuatTiketStream(adminID, sesiID uuid.UUID) (*dto.TiketStreamResponse, error) {
+	kadaluarsa := time.Now().Add(masaBerlakuTiketStream)
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"admin_id": adminID.String(),
+		"sesi_id":  sesiID.String(),
+		"tujuan":   tujuanTiketStream,
+		"exp":      kadaluarsa.Unix(),
+	})
+	tokenString, err := token.SignedString([]byte(s.cfg.JWTSecret))
 	if err != nil {
-		return errors.New("admin tidak ditemukan")
+		return nil, errors.New("gagal membuat tiket")
 	}
-	return s.repo.Delete(id)
+	return &dto.TiketStreamResponse{Tiket: tokenString, KadaluarsaPada: kadaluarsa}, nil
 }
 
-func (s *adminService) ResetPassword(id uuid.UUID, req dto.ResetPasswordRequest) error {
-	admin, err := s.repo.FindByID(id)
+func (s *authService) VerifikasiTiketStream(tiket string) (*models.Admin, uuid.UUID, error) {
+	claims, err := s.ValidateToken(tiket)
 	if err != nil {
-		return errors.New("admin tidak ditemukan")
+		return nil, uuid.Nil, ErrTiketStreamTidakValid
+	}
+	if t, _ := (*claims)["tujuan"].(string); t != tujuanTiketStream {
+		return nil, uuid.Nil, ErrTiketStreamTidakValid
+	}
+	adminIDStr, _ := (*claims)["admin_id"].(string)
+	adminID, err := uuid.Parse(adminIDStr)
+	if err != nil {
+		return nil, uuid.Nil, ErrTiketStreamTidakValid
+	}
+	sesiIDStr, _ := (*claims)["sesi_id"].(string)
+	sesiID, err := uuid.Parse(sesiIDStr)
+	if err != nil {
+		return nil, uuid.Nil, ErrTiketStreamTidakValid
 	}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	admin, err := s.VerifikasiSesi(adminID, sesiID)
 	if err != nil {
-		return errors.New("gagal mengenkripsi password")
+		return nil, uuid.Nil, err
 	}
+	return admin, sesiID, nil
+}
 
-	admin.Password = string(hashedPassword)
-	return s.repo.Update(admin)
+func (s *authService) GetAdminByID(id uuid.UUID) (*models.Admin, error) {
+	return s.adminRepo.FindByID(id)
 }
 
-func (s *adminService) ChangePassword(id uuid.UUID, req dto.ChangePasswordRequest) error {
-	admin, err := s.repo.FindByID(id)
+func (s *authService) GetProfil(id uuid.UUID) (*dto.AdminInfo, error) {
+	admin, err := s.adminRepo.FindByID(id)
 	if err != nil {
-		return errors.New("admin tidak ditemukan")
+		return nil, errors.New("admin tidak ditemukan")
 	}
+	info := s.toAdminInfo(admin)
+	return &info, nil
+}
 
-	// Verify old password
-	err = bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(req.OldPassword))
+func (s *authService) GetSesi(adminID, sesiIni uuid.UUID) ([]dto.SesiResponse, error) {
+	list, err := s.sesiRepo.FindAktifByAdminID(adminID)
 	if err != nil {
-		return errors.New("password lama tidak sesuai")
+		return nil, err
 	}
+	return toSesiResponses(list, sesiIni), nil
+}
 
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
-	if err != nil {
-		return errors.New("gagal mengenkripsi password")
+func (s *authService) CabutSesi(adminID, sesiID uuid.UUID) error {
+	sesi, err := s.sesiRepo.FindByID(sesiID)
+	if err != nil || sesi.AdminID != adminID {
+		return errors.New("sesi tidak ditemukan")
 	}
-
-	admin.Password = string(hashedPassword)
-	return s.repo.Update(admin)
+	return s.sesiRepo.Revoke(sesiID, models.AlasanSesiDicabut)
 }
 
-func (s *adminService) toAdminResponse(admin *models.Admin) *dto.AdminResponse {
-	return &dto.AdminResponse{
-		ID:          admin.ID,
-		Username:    admin.Username,
-		Email:       admin.Email,
-		NamaLengkap: admin.NamaLengkap,
-		Role:        string(admin.Role),
-		IsActive:    admin.IsActive,
-		CreatedAt:   admin.CreatedAt,
-		UpdatedAt:   admin.UpdatedAt,
+func toSesiResponses(list []models.SesiAdmin, sesiIni uuid.UUID) []dto.SesiResponse {
+	responses := make([]dto.SesiResponse, 0, len(list))
+	for _, sesi := range list {
+		responses = append(responses, dto.SesiResponse{
+			ID:              sesi.ID,
+			IPAddress:       sesi.IPAddress,
+			UserAgent:       sesi.UserAgent,
+			CreatedAt:       sesi.CreatedAt,
+			TerakhirDipakai: sesi.TerakhirDipakai,
+			KadaluarsaPada:  sesi.KadaluarsaPada,
+			SesiIni:         sesi.ID == sesiIni,
+		})
 	}
+	return responses
 }
 
-// ============== Jenis Perizinan Service ==============
+// ============== Dua Faktor Service ==============
 
-type JenisPerizinanService interface {
-	Create(req dto.CreateJenisPerizinanRequest) (*models.JenisPerizinan, error)
-	GetAll(aktifOnly bool) ([]dto.JenisPerizinanResponse, error)
-	GetByID(id uuid.UUID) (*dto.JenisPerizinanResponse, error)
-	Update(id uuid.UUID, req dto.UpdateJenisPerizinanRequest) (*models.JenisPerizinan, error)
-	Delete(id uuid.UUID) error
+// ErrKode2FATidakValid is returned for a wrong, reused or expired TOTP code
+// and for unknown or used recovery codes
+var ErrKode2FATidakValid = errors.New("kode autentikasi tidak valid")
+
+const (
+	jumlahKodePemulihan = 10
+	periodeTOTP         = 30 // seconds
+)
+
+// DuaFaktorService manages TOTP two-factor authentication (RFC 6238, as
+// used by Google Authenticator and similar apps) and recovery codes
+type DuaFaktorService interface {
+	Status(adminID uuid.UUID) (*dto.Status2FAResponse, error)
+	// Mulai creates a new secret for an admin without active 2FA. It takes
+	// effect once Aktifkan confirms a code from the authenticator app.
+	Mulai(adminID uuid.UUID) (*dto.Setup2FAResponse, error)
+	// Aktifkan turns on 2FA and returns the recovery codes, which are not
+	// shown again
+	Aktifkan(adminID uuid.UUID, kode string) ([]string, error)
+	Nonaktifkan(adminID uuid.UUID, req dto.Nonaktifkan2FARequest) error
+	BuatUlangKodePemulihan(adminID uuid.UUID, kode string) ([]string, error)
+	// Verifikasi accepts a current TOTP code or an unused recovery code
+	Verifikasi(admin *models.Admin, kode string) error
+	// Wajib reports whether TOTP_REQUIRED_ROLES requires 2FA for the admin
+	Wajib(admin *models.Admin) bool
+	// Reset removes 2FA from an admin who lost their authenticator and
+	// recovery codes
+	Reset(adminID uuid.UUID) error
 }
 
-type jenisPerizinanService struct {
-	repo repositories.JenisPerizinanRepository
+type duaFaktorService struct {
+	adminRepo repositories.AdminRepository
+	kodeRepo  repositories.KodePemulihanRepository
+	cfg       *config.Config
 }
 
-func NewJenisPerizinanService(repo repositories.JenisPerizinanRepository) JenisPerizinanService {
-	return &jenisPerizinanService{repo: repo}
+func NewDuaFaktorService(adminRepo repositories.AdminRepository, kodeRepo repositories.KodePemulihanRepository, cfg *config.Config) DuaFaktorService {
+	return &duaFaktorService{adminRepo: adminRepo, kodeRepo: kodeRepo, cfg: cfg}
 }
 
-func (s *jenisPerizinanService) Create(req dto.CreateJenisPerizinanRequest) (*models.JenisPerizinan, error) {
-	jp := &models.JenisPerizinan{
-		Nama:        req.Nama,
-		Deskripsi:   req.Deskripsi,
-		Persyaratan: req.Persyaratan,
-		Aktif:       req.Aktif,
+func (s *duaFaktorService) Status(adminID uuid.UUID) (*dto.Status2FAResponse, error) {
+	admin, err := s.adminRepo.FindByID(adminID)
+	if err != nil {
+		return nil, errors.New("admin tidak ditemukan")
 	}
-	err := s.repo.Create(jp)
-	return jp, err
+	status := &dto.Status2FAResponse{
+		Aktif:     admin.TOTPAktif,
+		Wajib:     s.Wajib(admin),
+		AktifPada: admin.TOTPAktifPada,
+	}
+	if admin.TOTPAktif {
+		if status.SisaKodePemulihan, err = s.kodeRepo.CountSisa(admin.ID); err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
 }
 
-func (s *jenisPerizinanService) GetAll(aktifOnly bool) ([]dto.JenisPerizinanResponse, error) {
-	list, err := s.repo.FindAll(aktifOnly)
+func (s *duaFaktorService) Mulai(adminID uuid.UUID) (*dto.Setup2FAResponse, error) {
+	admin, err := s.adminRepo.FindByID(adminID)
 	if err != nil {
+		return nil, errors.New("admin tidak ditemukan")
+	}
+	if admin.TOTPAktif {
+		return nil, errors.New("autentikasi dua faktor sudah aktif")
+	}
+
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      s.cfg.TOTPIssuer,
+		AccountName: admin.Username,
+		Period:      periodeTOTP,
+	})
+	if err != nil {
+		return nil, errors.New("gagal membuat kunci 2FA")
+	}
+	secret, err := enkripsiRahasia(s.kunci(), key.Secret())
+	if err != nil {
+		return nil, errors.New("gagal menyimpan kunci 2FA")
+	}
+	admin.TOTPSecret = secret
+	if err := s.adminRepo.Update(admin); err != nil {
+		return nil, err
+	}
+
+	png, err := qrcode.Encode(key.URL(), qrcode.Medium, 256)
+	if err != nil {
+		return nil, fmt.Errorf("gagal membuat QR code: %w", err)
+	}
+	return &dto.Setup2FAResponse{
+		Secret:     key.Secret(),
+		OtpauthURI: key.URL(),
+		QRCode:     "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	}, nil
+}
+
+func (s *duaFaktorService) Aktifkan(adminID uuid.UUID, kode string) ([]string, error) {
+	admin, err := s.adminRepo.FindByID(adminID)
+	if err != nil {
+		return nil, errors.New("admin tidak ditemukan")
+	}
+	if admin.TOTPAktif {
+		return nil, errors.New("autentikasi dua faktor sudah aktif")
+	}
+	if admin.TOTPSecret == "" {
+		return nil, errors.New("mulai pendaftaran autentikasi dua faktor terlebih dahulu")
+	}
+	if err := s.cocokkanTOTP(admin, kode); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	admin.TOTPAktif = true
+	admin.TOTPAktifPada = &now
+	if err := s.adminRepo.Update(admin); err != nil {
+		return nil, err
+	}
+	return s.buatKodePemulihan(admin.ID)
+}
+
+func (s *duaFaktorService) Nonaktifkan(adminID uuid.UUID, req dto.Nonaktifkan2FARequest) error {
+	admin, err := s.adminRepo.FindByID(adminID)
+	if err != nil {
+		return errors.New("admin tidak ditemukan")
+	}
+	if !admin.TOTPAktif {
+		return errors.New("autentikasi dua faktor belum aktif")
+	}
+	if s.Wajib(admin) {
+		return errors.New("autentikasi dua faktor wajib untuk role ini")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(req.Password)); err != nil {
+		return errors.New("password tidak sesuai")
+	}
+	if err := s.Verifikasi(admin, req.Kode); err != nil {
+		return err
+	}
+	return s.hapus(admin)
+}
+
+func (s *duaFaktorService) BuatUlangKodePemulihan(adminID uuid.UUID, kode string) ([]string, error) {
+	admin, err := s.adminRepo.FindByID(adminID)
+	if err != nil {
+		return nil, errors.New("admin tidak ditemukan")
+	}
+	if !admin.TOTPAktif {
+		return nil, errors.New("autentikasi dua faktor belum aktif")
+	}
+	if err := s.cocokkanTOTP(admin, kode); err != nil {
+		return nil, err
+	}
+	return s.buatKodePemulihan(admin.ID)
+}
+
+func (s *duaFaktorService) Verifikasi(admin *models.Admin, kode string) error {
+	if !admin.TOTPAktif {
+		return ErrKode2FATidakValid
+	}
+
+	kode = normalisasiKode2FA(kode)
+	if len(kode) == 6 {
+		return s.cocokkanTOTP(admin, kode)
+	}
+
+	ok, err := s.kodeRepo.Pakai(admin.ID, hashToken(kode))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrKode2FATidakValid
+	}
+	log.Printf("Admin %s login dengan kode pemulihan", admin.Username)
+	return nil
+}
+
+func (s *duaFaktorService) Wajib(admin *models.Admin) bool {
+	for _, role := range strings.Split(s.cfg.TOTPRequiredRoles, ",") {
+		if strings.TrimSpace(role) == string(admin.Role) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *duaFaktorService) Reset(adminID uuid.UUID) error {
+	admin, err := s.adminRepo.FindByID(adminID)
+	if err != nil {
+		return errors.New("admin tidak ditemukan")
+	}
+	return s.hapus(admin)
+}
+
+func (s *duaFaktorService) hapus(admin *models.Admin) error {
+	admin.TOTPSecret = ""
+	admin.TOTPAktif = false
+	admin.TOTPAktifPada = nil
+	if err := s.adminRepo.Update(admin); err != nil {
+		return err
+	}
+	return s.kodeRepo.DeleteByAdminID(admin.ID)
+}
+
+// cocokkanTOTP accepts the code of the current time step or one step
+// either side for clock drift. A step is only accepted once, so a code seen
+// over someone's shoulder cannot be replayed.
+func (s *duaFaktorService) cocokkanTOTP(admin *models.Admin, kode string) error {
+	secret, err := dekripsiRahasia(s.kunci(), admin.TOTPSecret)
+	if err != nil {
+		log.Printf("Warning: gagal membaca kunci 2FA admin %s: %v", admin.Username, err)
+		return ErrKode2FATidakValid
+	}
+
+	kode = normalisasiKode2FA(kode)
+	now := time.Now()
+	for _, geser := range []int{0, -1, 1} {
+		t := now.Add(time.Duration(geser*periodeTOTP) * time.Second)
+		expected, err := totp.GenerateCodeCustom(secret, t, totp.ValidateOpts{
+			Period:    periodeTOTP,
+			Digits:    otp.DigitsSix,
+			Algorithm: otp.AlgorithmSHA1,
+		})
+		if err != nil {
+			return err
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(kode)) != 1 {
+			continue
+		}
+
+		langkah := t.Unix() / periodeTOTP
+		ok, err := s.adminRepo.UpdateLangkahTOTP(admin.ID, langkah)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrKode2FATidakValid
+		}
+		admin.TOTPLangkahTerakhir = langkah
+		return nil
+	}
+	return ErrKode2FATidakValid
+}
+
+func (s *duaFaktorService) buatKodePemulihan(adminID uuid.UUID) ([]string, error) {
+	kode := make([]string, jumlahKodePemulihan)
+	records := make([]models.KodePemulihan, jumlahKodePemulihan)
+	for i := range kode {
+		b := make([]byte, 5)
+		if _, err := rand.Read(b); err != nil {
+			return nil, errors.New("gagal membuat kode pemulihan")
+		}
+		k := hex.EncodeToString(b)
+		kode[i] = k[:5] + "-" + k[5:]
+		records[i] = models.KodePemulihan{AdminID: adminID, KodeHash: hashToken(k)}
+	}
+	if err := s.kodeRepo.Replace(adminID, records); err != nil {
+		return nil, err
+	}
+	return kode, nil
+}
+
+func (s *duaFaktorService) kunci() string {
+	return kunciRahasia(s.cfg)
+}
+
+// normalisasiKode2FA drops the spaces and dashes people type between digit
+// groups
+func normalisasiKode2FA(kode string) string {
+	return strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(strings.TrimSpace(kode)))
+}
+
+// ============== Pembatasan Login Service ==============
+
+// ErrLoginDibatasi is returned while a username or IP address has too many
+// recent failed logins. The error is a *LoginDibatasiError carrying the wait.
+var ErrLoginDibatasi = errors.New("terlalu banyak percobaan login gagal")
+
+// LoginDibatasiError tells a client how long to wait before trying again
+type LoginDibatasiError struct {
+	CobaLagi time.Duration
+}
+
+func (e *LoginDibatasiError) Error() string {
+	if e.CobaLagi < time.Minute {
+		return fmt.Sprintf("%s, coba lagi dalam %d detik", ErrLoginDibatasi, int(math.Ceil(e.CobaLagi.Seconds())))
+	}
+	return fmt.Sprintf("%s, coba lagi dalam %d menit", ErrLoginDibatasi, int(math.Ceil(e.CobaLagi.Minutes())))
+}
+
+func (e *LoginDibatasiError) Unwrap() error {
+	return ErrLoginDibatasi
+}
+
+// PembatasanLoginService slows down password guessing. Failed logins within
+// LOGIN_LOCKOUT_MINUTES are counted per username and per IP address. Each
+// failure on a username doubles the wait before its next attempt, and
+// reaching the maximum locks it for the rest of the window. Unknown
+// usernames are limited the same way, so a lockout does not reveal which
+// accounts exist.
+type PembatasanLoginService interface {
+	// Cek returns a *LoginDibatasiError when username or ip may not try to
+	// log in right now
+	Cek(username, ip string) error
+	CatatGagal(username string, admin *models.Admin, klien KlienInfo, alasan string)
+	CatatBerhasil(admin *models.Admin)
+	GetPenguncian() (*dto.PenguncianLoginResponse, error)
+	GetPercobaanByAdminID(adminID uuid.UUID) ([]dto.PercobaanLoginResponse, error)
+	BukaAkun(adminID uuid.UUID) error
+	BukaIP(ip string) error
+}
+
+type pembatasanLoginService struct {
+	repo      repositories.PercobaanLoginRepository
+	adminRepo repositories.AdminRepository
+	cfg       *config.Config
+}
+
+func NewPembatasanLoginService(repo repositories.PercobaanLoginRepository, adminRepo repositories.AdminRepository, cfg *config.Config) PembatasanLoginService {
+	return &pembatasanLoginService{repo: repo, adminRepo: adminRepo, cfg: cfg}
+}
+
+// maksJedaLogin caps the progressive delay before the lockout kicks in
+const maksJedaLogin = 30 * time.Second
+
+func (s *pembatasanLoginService) Cek(username, ip string) error {
+	now := time.Now()
+	sejak := now.Add(-s.jendela())
+
+	rekap, err := s.repo.RekapByUsername(username, sejak)
+	if err != nil {
+		return err
+	}
+	var tunggu time.Duration
+	switch {
+	case rekap.Jumlah >= s.maks():
+		tunggu = rekap.Terakhir.Add(s.jendela()).Sub(now)
+	case rekap.Jumlah > 0:
+		jeda := time.Second << (rekap.Jumlah - 1)
+		if jeda > maksJedaLogin {
+			jeda = maksJedaLogin
+		}
+		tunggu = rekap.Terakhir.Add(jeda).Sub(now)
+	}
+	if tunggu > 0 {
+		return &LoginDibatasiError{CobaLagi: tunggu}
+	}
+
+	rekap, err = s.repo.RekapByIP(ip, sejak)
+	if err != nil {
+		return err
+	}
+	if rekap.Jumlah >= s.maksIP() {
+		if tunggu := rekap.Terakhir.Add(s.jendela()).Sub(now); tunggu > 0 {
+			return &LoginDibatasiError{CobaLagi: tunggu}
+		}
+	}
+	return nil
+}
+
+func (s *pembatasanLoginService) CatatGagal(username string, admin *models.Admin, klien KlienInfo, alasan string) {
+	percobaan := &models.PercobaanLogin{
+		Username:  username,
+		IPAddress: klien.IPAddress,
+		UserAgent: potongTeks(klien.UserAgent, 255),
+		Alasan:    alasan,
+	}
+	if admin != nil {
+		percobaan.AdminID = &admin.ID
+	}
+	if err := s.repo.Create(percobaan); err != nil {
+		log.Printf("Warning: gagal mencatat percobaan login %s: %v", username, err)
+		return
+	}
+	if admin == nil {
+		return
+	}
+
+	rekap, err := s.repo.RekapByUsername(username, time.Now().Add(-s.jendela()))
+	if err != nil {
+		log.Printf("Warning: gagal menghitung percobaan login %s: %v", username, err)
+		return
+	}
+	var terkunciSampai *time.Time
+	if rekap.Jumlah >= s.maks() {
+		t := rekap.Terakhir.Add(s.jendela())
+		terkunciSampai = &t
+		log.Printf("Warning: akun %s dikunci sampai %s setelah %d percobaan login gagal", admin.Username, t.Format(time.RFC3339), rekap.Jumlah)
+	}
+	if err := s.adminRepo.UpdatePenguncian(admin.ID, int(rekap.Jumlah), terkunciSampai); err != nil {
+		log.Printf("Warning: gagal memperbarui penguncian akun %s: %v", admin.Username, err)
+	}
+}
+
+func (s *pembatasanLoginService) CatatBerhasil(admin *models.Admin) {
+	if err := s.repo.ResetByUsername(admin.Username); err != nil {
+		log.Printf("Warning: gagal mereset percobaan login %s: %v", admin.Username, err)
+	}
+	if admin.GagalLogin > 0 || admin.TerkunciSampai != nil {
+		if err := s.adminRepo.UpdatePenguncian(admin.ID, 0, nil); err != nil {
+			log.Printf("Warning: gagal memperbarui penguncian akun %s: %v", admin.Username, err)
+		}
+	}
+}
+
+func (s *pembatasanLoginService) GetPenguncian() (*dto.PenguncianLoginResponse, error) {
+	admins, err := s.adminRepo.FindTerkunci()
+	if err != nil {
+		return nil, err
+	}
+	rekapIP, err := s.repo.RekapIP(time.Now().Add(-s.jendela()), s.maksIP())
+	if err != nil {
+		return nil, err
+	}
+
+	response := &dto.PenguncianLoginResponse{
+		Akun: make([]dto.AkunTerkunciResponse, 0, len(admins)),
+		IP:   make([]dto.IPTerbatasResponse, 0, len(rekapIP)),
+	}
+	for _, admin := range admins {
+		response.Akun = append(response.Akun, dto.AkunTerkunciResponse{
+			AdminID:        admin.ID,
+			Username:       admin.Username,
+			NamaLengkap:    admin.NamaLengkap,
+			GagalLogin:     admin.GagalLogin,
+			TerkunciSampai: *admin.TerkunciSampai,
+		})
+	}
+	for _, rekap := range rekapIP {
+		response.IP = append(response.IP, dto.IPTerbatasResponse{
+			IPAddress:      rekap.Kunci,
+			Jumlah:         rekap.Jumlah,
+			Terakhir:       rekap.Terakhir,
+			TerkunciSampai: rekap.Terakhir.Add(s.jendela()),
+		})
+	}
+	return response, nil
+}
+
+func (s *pembatasanLoginService) GetPercobaanByAdminID(adminID uuid.UUID) ([]dto.PercobaanLoginResponse, error) {
+	if _, err := s.adminRepo.FindByID(adminID); err != nil {
+		return nil, errors.New("admin tidak ditemukan")
+	}
+	list, err := s.repo.FindByAdminID(adminID, 100)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.PercobaanLoginResponse, 0, len(list))
+	for _, p := range list {
+		responses = append(responses, dto.PercobaanLoginResponse{
+			ID:        p.ID,
+			Username:  p.Username,
+			IPAddress: p.IPAddress,
+			UserAgent: p.UserAgent,
+			Alasan:    p.Alasan,
+			Direset:   p.Direset,
+			CreatedAt: p.CreatedAt,
+		})
+	}
+	return responses, nil
+}
+
+func (s *pembatasanLoginService) BukaAkun(adminID uuid.UUID) error {
+	admin, err := s.adminRepo.FindByID(adminID)
+	if err != nil {
+		return errors.New("admin tidak ditemukan")
+	}
+	if err := s.repo.ResetByUsername(admin.Username); err != nil {
+		return err
+	}
+	return s.adminRepo.UpdatePenguncian(admin.ID, 0, nil)
+}
+
+func (s *pembatasanLoginService) BukaIP(ip string) error {
+	if net.ParseIP(ip) == nil {
+		return errors.New("alamat IP tidak valid")
+	}
+	return s.repo.ResetByIP(ip)
+}
+
+func (s *pembatasanLoginService) jendela() time.Duration {
+	minutes, _ := strconv.Atoi(s.cfg.LoginLockoutMinutes)
+	if minutes <= 0 {
+		minutes = 15
+	}
+	return time.Duration(minutes) * time.Minute
+}
+
+func (s *pembatasanLoginService) maks() int64 {
+	n, _ := strconv.ParseInt(s.cfg.LoginMaxAttempts, 10, 64)
+	if n <= 0 {
+		n = 5
+	}
+	return n
+}
+
+func (s *pembatasanLoginService) maksIP() int64 {
+	n, _ := strconv.ParseInt(s.cfg.LoginIPMaxAttempts, 10, 64)
+	if n <= 0 {
+		n = 20
+	}
+	return n
+}
+
+// ============== Admin Management Service ==============
+
+// AdminService manages admin accounts. aktor is the role of the admin making
+// the change: accounts whose role has permissions aktor lacks cannot be
+// created, changed or taken over.
+type AdminService interface {
+	Create(req dto.CreateAdminRequest, aktor models.RoleAdmin) (*dto.AdminResponse, error)
+	GetAll(query dto.PaginationQuery) (*dto.AdminListResponse, error)
+	GetByID(id uuid.UUID) (*dto.AdminResponse, error)
+	Update(id uuid.UUID, req dto.UpdateAdminRequest, aktor models.RoleAdmin) (*dto.AdminResponse, error)
+	Delete(id uuid.UUID, aktor models.RoleAdmin) error
+	ResetPassword(id uuid.UUID, req dto.ResetPasswordRequest, aktor models.RoleAdmin) error
+	// ChangePassword ends every other session of the admin; sesiID is the
+	// session making the change and stays signed in
+	ChangePassword(id, sesiID uuid.UUID, req dto.ChangePasswordRequest) error
+	GetSesi(id uuid.UUID) ([]dto.SesiResponse, error)
+	CabutSemuaSesi(id uuid.UUID) error
+}
+
+type adminService struct {
+	repo        repositories.AdminRepository
+	sesiRepo    repositories.SesiAdminRepository
+	roleService RoleService
+	kebijakan   *kebijakanPassword
+}
+
+func NewAdminService(
+	repo repositories.AdminRepository,
+	sesiRepo repositories.SesiAdminRepository,
+	riwayatPasswordRepo repositories.RiwayatPasswordRepository,
+	roleService RoleService,
+	cfg *config.Config,
+) AdminService {
+	return &adminService{
+		repo:        repo,
+		sesiRepo:    sesiRepo,
+		roleService: roleService,
+		kebijakan:   newKebijakanPassword(repo, riwayatPasswordRepo, cfg),
+	}
+}
+
+func (s *adminService) Create(req dto.CreateAdminRequest, aktor models.RoleAdmin) (*dto.AdminResponse, error) {
+	if err := s.cekRole(models.RoleAdmin(req.Role), aktor); err != nil {
+		return nil, err
+	}
+
+	// Check if username already exists
+	existing, _ := s.repo.FindByUsername(req.Username)
+	if existing != nil {
+		return nil, errors.New("username sudah digunakan")
+	}
+
+	// Check if email already exists
+	existing, _ = s.repo.FindByEmail(req.Email)
+	if existing != nil {
+		return nil, errors.New("email sudah digunakan")
+	}
+
+	admin := &models.Admin{
+		Username:    req.Username,
+		Email:       req.Email,
+		NamaLengkap: req.NamaLengkap,
+		Role:        models.RoleAdmin(req.Role),
+		IsActive:    true,
+	}
+
+	// Hash password, a new account has no previous passwords to compare
+	hashedPassword, err := s.kebijakan.hash(admin, req.Password)
+	if err != nil {
+		return nil, err
+	}
+	admin.Password = hashedPassword
+
+	err = s.repo.Create(admin)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.toAdminResponse(admin), nil
+}
+
+func (s *adminService) GetAll(query dto.PaginationQuery) (*dto.AdminListResponse, error) {
+	admins, total, err := s.repo.FindAllPaginated(query.GetOffset(), query.GetLimit(), query.Search)
+	if err != nil {
+		return nil, err
+	}
+
+	var responses []dto.AdminResponse
+	for _, admin := range admins {
+		responses = append(responses, *s.toAdminResponse(&admin))
+	}
+
+	totalPages := int(total) / query.GetLimit()
+	if int(total)%query.GetLimit() > 0 {
+		totalPages++
+	}
+
+	return &dto.AdminListResponse{
+		Data:       responses,
+		Total:      total,
+		Page:       query.Page,
+		PerPage:    query.GetLimit(),
+		TotalPages: totalPages,
+	}, nil
+}
+
+func (s *adminService) GetByID(id uuid.UUID) (*dto.AdminResponse, error) {
+	admin, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, errors.New("admin tidak ditemukan")
+	}
+	return s.toAdminResponse(admin), nil
+}
+
+func (s *adminService) Update(id uuid.UUID, req dto.UpdateAdminRequest, aktor models.RoleAdmin) (*dto.AdminResponse, error) {
+	admin, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, errors.New("admin tidak ditemukan")
+	}
+	if err := s.roleService.BolehMengelola(aktor, admin.Role); err != nil {
+		return nil, err
+	}
+
+	// Check username uniqueness if changed
+	if req.Username != "" && req.Username != admin.Username {
+		existing, _ := s.repo.FindByUsername(req.Username)
+		if existing != nil {
+			return nil, errors.New("username sudah digunakan")
+		}
+		admin.Username = req.Username
+	}
+
+	// Check email uniqueness if changed
+	if req.Email != "" && req.Email != admin.Email {
+		existing, _ := s.repo.FindByEmail(req.Email)
+		if existing != nil {
+			return nil, errors.New("email sudah digunakan")
+		}
+		admin.Email = req.Email
+	}
+
+	if req.NamaLengkap != "" {
+		admin.NamaLengkap = req.NamaLengkap
+	}
+
+	if req.Role != "" && models.RoleAdmin(req.Role) != admin.Role {
+		if err := s.cekRole(models.RoleAdmin(req.Role), aktor); err != nil {
+			return nil, err
+		}
+		admin.Role = models.RoleAdmin(req.Role)
+	}
+
+	if req.IsActive != nil {
+		admin.IsActive = *req.IsActive
+	}
+
+	err = s.repo.Update(admin)
+	if err != nil {
+		return nil, err
+	}
+
+	if !admin.IsActive {
+		s.cabutSesi(id, models.AlasanSesiAkunNonaktif)
+	}
+
+	return s.toAdminResponse(admin), nil
+}
+
+func (s *adminService) Delete(id uuid.UUID, aktor models.RoleAdmin) error {
+	admin, err := s.repo.FindByID(id)
+	if err != nil {
+		return errors.New("admin tidak ditemukan")
+	}
+	if err := s.roleService.BolehMengelola(aktor, admin.Role); err != nil {
+		return err
+	}
+	if err := s.repo.Delete(id); err != nil {
+		return err
+	}
+	s.cabutSesi(id, models.AlasanSesiAkunDihapus)
+	return nil
+}
+
+func (s *adminService) ResetPassword(id uuid.UUID, req dto.ResetPasswordRequest, aktor models.RoleAdmin) error {
+	admin, err := s.repo.FindByID(id)
+	if err != nil {
+		return errors.New("admin tidak ditemukan")
+	}
+	if err := s.roleService.BolehMengelola(aktor, admin.Role); err != nil {
+		return err
+	}
+
+	if err := s.kebijakan.ganti(admin, req.NewPassword); err != nil {
+		return err
+	}
+	s.cabutSesi(id, models.AlasanSesiResetPassword)
+	return nil
+}
+
+func (s *adminService) ChangePassword(id, sesiID uuid.UUID, req dto.ChangePasswordRequest) error {
+	admin, err := s.repo.FindByID(id)
+	if err != nil {
+		return errors.New("admin tidak ditemukan")
+	}
+
+	// Verify old password
+	err = bcrypt.CompareHashAndPassword([]byte(admin.Password), []byte(req.OldPassword))
+	if err != nil {
+		return errors.New("password lama tidak sesuai")
+	}
+
+	if err := s.kebijakan.ganti(admin, req.NewPassword); err != nil {
+		return err
+	}
+	s.cabutSesi(id, models.AlasanSesiGantiPassword, sesiID)
+	return nil
+}
+
+func (s *adminService) GetSesi(id uuid.UUID) ([]dto.SesiResponse, error) {
+	if _, err := s.repo.FindByID(id); err != nil {
+		return nil, errors.New("admin tidak ditemukan")
+	}
+	list, err := s.sesiRepo.FindAktifByAdminID(id)
+	if err != nil {
+		return nil, err
+	}
+	return toSesiResponses(list, uuid.Nil), nil
+}
+
+func (s *adminService) CabutSemuaSesi(id uuid.UUID) error {
+	if _, err := s.repo.FindByID(id); err != nil {
+		return errors.New("admin tidak ditemukan")
+	}
+	return s.sesiRepo.RevokeByAdminID(id, models.AlasanSesiDicabut)
+}
+
+// cekRole checks that a role exists and may be assigned by aktor
+func (s *adminService) cekRole(role, aktor models.RoleAdmin) error {
+	if _, err := s.roleService.GetByNama(role); err != nil {
+		return err
+	}
+	return s.roleService.BolehMengelola(aktor, role)
+}
+
+// cabutSesi revokes the sessions of an admin after an account change. The
+// change itself already succeeded, so a failure is only logged; the
+// middleware still rejects inactive and deleted accounts.
+func (s *adminService) cabutSesi(id uuid.UUID, alasan string, kecuali ...uuid.UUID) {
+	if err := s.sesiRepo.RevokeByAdminID(id, alasan, kecuali...); err != nil {
+		log.Printf("Warning: gagal mencabut sesi admin %s: %v", id, err)
+	}
+}
+
+func (s *adminService) toAdminResponse(admin *models.Admin) *dto.AdminResponse {
+	response := &dto.AdminResponse{
+		ID:          admin.ID,
+		Username:    admin.Username,
+		Email:       admin.Email,
+		NamaLengkap: admin.NamaLengkap,
+		Role:        string(admin.Role),
+		IsActive:    admin.IsActive,
+		TOTPAktif:   admin.TOTPAktif,
+		GagalLogin:  admin.GagalLogin,
+		CreatedAt:   admin.CreatedAt,
+		UpdatedAt:   admin.UpdatedAt,
+	}
+	if admin.TerkunciSampai != nil && admin.TerkunciSampai.After(time.Now()) {
+		response.TerkunciSampai = admin.TerkunciSampai
+	}
+	return response
+}
+
+// ============== Role Service ==============
+
+// ErrIzinDitolak is returned when an admin tries to grant permissions, or
+// manage an account with permissions, that their own role does not have
+var ErrIzinDitolak = errors.New("tidak dapat mengelola izin yang tidak dimiliki role Anda")
+
+// namaRolePattern keeps role names usable in config such as
+// TOTP_REQUIRED_ROLES
+var namaRolePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+type RoleService interface {
+	GetAll() ([]dto.RoleResponse, error)
+	GetByID(id uuid.UUID) (*dto.RoleResponse, error)
+	GetByNama(nama models.RoleAdmin) (*dto.RoleResponse, error)
+	// Create, Update and Delete only touch permissions that aktor, the role
+	// of the acting admin, has itself
+	Create(req dto.CreateRoleRequest, aktor models.RoleAdmin) (*dto.RoleResponse, error)
+	Update(id uuid.UUID, req dto.UpdateRoleRequest, aktor models.RoleAdmin) (*dto.RoleResponse, error)
+	Delete(id uuid.UUID, aktor models.RoleAdmin) error
+	DaftarIzin() []dto.IzinResponse
+	// IzinRole returns the permissions of a role. super_admin always has
+	// all of them; unknown roles have none.
+	IzinRole(nama models.RoleAdmin) (models.IzinList, error)
+	// BolehMengelola returns ErrIzinDitolak unless aktor has every
+	// permission of target
+	BolehMengelola(aktor, target models.RoleAdmin) error
+}
+
+type roleService struct {
+	repo repositories.RoleRepository
+}
+
+func NewRoleService(repo repositories.RoleRepository) RoleService {
+	return &roleService{repo: repo}
+}
+
+// DefaultRoles returns the built-in roles for seeding the database
+func DefaultRoles() []models.Role {
+	return []models.Role{
+		{
+			Nama:      models.RoleSuperAdmin,
+			Label:     "Super Admin",
+			Deskripsi: "Akses penuh ke seluruh fitur",
+			Izin:      slices.Clone(models.DaftarIzin),
+			Bawaan:    true,
+		},
+		{
+			Nama:      models.RoleAdminUser,
+			Label:     "Administrator",
+			Deskripsi: "Memproses permohonan dan membalas pemohon",
+			Izin: models.IzinList{
+				models.IzinLihatPermohonan, models.IzinUbahStatus, models.IzinKirimBalasan,
+				models.IzinUnduhBerkas, models.IzinLihatLaporan, models.IzinLihatEmail,
+				models.IzinKirimUlangEmail,
+			},
+			Bawaan: true,
+		},
+		{
+			Nama:      models.RoleAuditor,
+			Label:     "Auditor",
+			Deskripsi: "Hanya dapat melihat permohonan, laporan dan log tanpa mengubah data",
+			Izin: models.IzinList{
+				models.IzinLihatPermohonan, models.IzinUnduhBerkas, models.IzinLihatLaporan,
+				models.IzinLihatEmail, models.IzinLihatAudit,
+			},
+			Bawaan: true,
+		},
+	}
+}
+
+func (s *roleService) GetAll() ([]dto.RoleResponse, error) {
+	roles, err := s.repo.FindAll()
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]dto.RoleResponse, 0, len(roles))
+	for i := range roles {
+		responses = append(responses, *s.toRoleResponse(&roles[i]))
+	}
+	return responses, nil
+}
+
+func (s *roleService) GetByID(id uuid.UUID) (*dto.RoleResponse, error) {
+	role, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, errors.New("role tidak ditemukan")
+	}
+	return s.toRoleResponse(role), nil
+}
+
+func (s *roleService) GetByNama(nama models.RoleAdmin) (*dto.RoleResponse, error) {
+	role, err := s.repo.FindByNama(nama)
+	if err != nil {
+		return nil, errors.New("role tidak ditemukan")
+	}
+	return s.toRoleResponse(role), nil
+}
+
+func (s *roleService) Create(req dto.CreateRoleRequest, aktor models.RoleAdmin) (*dto.RoleResponse, error) {
+	nama := models.RoleAdmin(strings.TrimSpace(req.Nama))
+	if !namaRolePattern.MatchString(string(nama)) {
+		return nil, errors.New("nama role hanya boleh berisi huruf kecil, angka dan garis bawah, diawali huruf")
+	}
+	if existing, _ := s.repo.FindByNama(nama); existing != nil {
+		return nil, errors.New("nama role sudah digunakan")
+	}
+
+	izin, err := parseIzin(req.Izin)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.bolehMemberi(aktor, izin); err != nil {
+		return nil, err
+	}
+
+	role := &models.Role{
+		Nama:      nama,
+		Label:     req.Label,
+		Deskripsi: req.Deskripsi,
+		Izin:      izin,
+	}
+	if err := s.repo.Create(role); err != nil {
+		return nil, err
+	}
+	return s.toRoleResponse(role), nil
+}
+
+func (s *roleService) Update(id uuid.UUID, req dto.UpdateRoleRequest, aktor models.RoleAdmin) (*dto.RoleResponse, error) {
+	role, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, errors.New("role tidak ditemukan")
+	}
+	if err := s.bolehMemberi(aktor, role.Izin); err != nil {
+		return nil, err
+	}
+
+	if req.Label != "" {
+		role.Label = req.Label
+	}
+	if req.Deskripsi != nil {
+		role.Deskripsi = *req.Deskripsi
+	}
+	if req.Izin != nil {
+		if role.Nama == models.RoleSuperAdmin {
+			return nil, errors.New("izin role super_admin tidak dapat diubah")
+		}
+		izin, err := parseIzin(req.Izin)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.bolehMemberi(aktor, izin); err != nil {
+			return nil, err
+		}
+		role.Izin = izin
+	}
+
+	if err := s.repo.Update(role); err != nil {
+		return nil, err
+	}
+	return s.toRoleResponse(role), nil
+}
+
+func (s *roleService) Delete(id uuid.UUID, aktor models.RoleAdmin) error {
+	role, err := s.repo.FindByID(id)
+	if err != nil {
+		return errors.New("role tidak ditemukan")
+	}
+	if role.Bawaan {
+		return errors.New("role bawaan tidak dapat dihapus")
+	}
+	if err := s.bolehMemberi(aktor, role.Izin); err != nil {
+		return err
+	}
+
+	jumlah, err := s.repo.CountAdmin(role.Nama)
+	if err != nil {
+		return err
+	}
+	if jumlah > 0 {
+		return fmt.Errorf("role masih dipakai oleh %d admin", jumlah)
+	}
+	return s.repo.Delete(id)
+}
+
+func (s *roleService) DaftarIzin() []dto.IzinResponse {
+	list := make([]dto.IzinResponse, 0, len(models.DaftarIzin))
+	for _, izin := range models.DaftarIzin {
+		list = append(list, dto.IzinResponse{Kode: string(izin), Deskripsi: models.DeskripsiIzin[izin]})
+	}
+	return list
+}
+
+func (s *roleService) IzinRole(nama models.RoleAdmin) (models.IzinList, error) {
+	if nama == models.RoleSuperAdmin {
+		return slices.Clone(models.DaftarIzin), nil
+	}
+	role, err := s.repo.FindByNama(nama)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.IzinList{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return role.Izin, nil
+}
+
+func (s *roleService) BolehMengelola(aktor, target models.RoleAdmin) error {
+	izin, err := s.IzinRole(target)
+	if err != nil {
+		return err
+	}
+	return s.bolehMemberi(aktor, izin)
+}
+
+// bolehMemberi checks that aktor has every one of izin
+func (s *roleService) bolehMemberi(aktor models.RoleAdmin, izin models.IzinList) error {
+	milikAktor, err := s.IzinRole(aktor)
+	if err != nil {
+		return err
+	}
+	if !milikAktor.Punya(izin...) {
+		return ErrIzinDitolak
+	}
+	return nil
+}
+
+func (s *roleService) toRoleResponse(role *models.Role) *dto.RoleResponse {
+	izin := role.Izin
+	if role.Nama == models.RoleSuperAdmin {
+		izin = models.DaftarIzin
+	}
+	jumlah, err := s.repo.CountAdmin(role.Nama)
+	if err != nil {
+		log.Printf("Warning: gagal menghitung admin role %s: %v", role.Nama, err)
+	}
+	return &dto.RoleResponse{
+		ID:          role.ID,
+		Nama:        string(role.Nama),
+		Label:       role.Label,
+		Deskripsi:   role.Deskripsi,
+		Izin:        izinStrings(izin),
+		Bawaan:      role.Bawaan,
+		JumlahAdmin: jumlah,
+		CreatedAt:   role.CreatedAt,
+		UpdatedAt:   role.UpdatedAt,
+	}
+}
+
+// parseIzin validates permission names and returns them without duplicates
+// in the order of models.DaftarIzin
+func parseIzin(list []string) (models.IzinList, error) {
+	for _, kode := range list {
+		if !slices.Contains(models.DaftarIzin, models.Izin(kode)) {
+			return nil, fmt.Errorf("izin tidak dikenal: %s", kode)
+		}
+	}
+	izin := models.IzinList{}
+	for _, i := range models.DaftarIzin {
+		if slices.Contains(list, string(i)) {
+			izin = append(izin, i)
+		}
+	}
+	return izin, nil
+}
+
+func izinStrings(izin models.IzinList) []string {
+	list := make([]string, 0, len(izin))
+	for _, i := range izin {
+		list = append(list, string(i))
+	}
+	return list
+}
+
+// ============== Password Policy ==============
+
+// ErrPasswordLemah is returned for a new password that breaks the policy.
+// The error is a *PasswordLemahError listing the unmet rules.
+var ErrPasswordLemah = errors.New("password tidak memenuhi kebijakan")
+
+// PasswordLemahError tells a client which password rules were not met
+type PasswordLemahError struct {
+	Alasan []string
+}
+
+func (e *PasswordLemahError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrPasswordLemah, strings.Join(e.Alasan, ", "))
+}
+
+func (e *PasswordLemahError) Unwrap() error {
+	return ErrPasswordLemah
+}
+
+// bcryptMaksPanjang is the longest password bcrypt accepts, in bytes
+const bcryptMaksPanjang = 72
+
+// kelasPassword are the character classes PASSWORD_REQUIRED_CLASSES can list
+var kelasPassword = map[string]struct {
+	label string
+	cocok func(rune) bool
+}{
+	"lower":  {"huruf kecil", unicode.IsLower},
+	"upper":  {"huruf besar", unicode.IsUpper},
+	"digit":  {"angka", unicode.IsDigit},
+	"symbol": {"simbol", func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsSpace(r) }},
+}
+
+// kebijakanPassword checks new admin passwords against the configured
+// length, character classes and recent passwords, and keeps the history of
+// replaced password hashes
+type kebijakanPassword struct {
+	adminRepo   repositories.AdminRepository
+	riwayatRepo repositories.RiwayatPasswordRepository
+	cfg         *config.Config
+}
+
+func newKebijakanPassword(adminRepo repositories.AdminRepository, riwayatRepo repositories.RiwayatPasswordRepository, cfg *config.Config) *kebijakanPassword {
+	return &kebijakanPassword{adminRepo: adminRepo, riwayatRepo: riwayatRepo, cfg: cfg}
+}
+
+func (k *kebijakanPassword) info() dto.KebijakanPasswordResponse {
+	return dto.KebijakanPasswordResponse{
+		MinPanjang: k.minPanjang(),
+		KelasWajib: k.kelasWajib(),
+		Riwayat:    k.riwayat(),
+	}
+}
+
+// periksa checks the length and character classes of a password
+func (k *kebijakanPassword) periksa(password string) error {
+	var alasan []string
+	if minimal := k.minPanjang(); utf8.RuneCountInString(password) < minimal {
+		alasan = append(alasan, fmt.Sprintf("minimal %d karakter", minimal))
+	}
+	if len(password) > bcryptMaksPanjang {
+		alasan = append(alasan, fmt.Sprintf("maksimal %d byte", bcryptMaksPanjang))
+	}
+	for _, kelas := range k.kelasWajib() {
+		if !strings.ContainsFunc(password, kelasPassword[kelas].cocok) {
+			alasan = append(alasan, "harus mengandung "+kelasPassword[kelas].label)
+		}
+	}
+	if len(alasan) > 0 {
+		return &PasswordLemahError{Alasan: alasan}
+	}
+	return nil
+}
+
+// PeriksaKebijakanPassword checks a password against the configured policy,
+// e.g. the seeded default admin password at startup
+func PeriksaKebijakanPassword(cfg *config.Config, password string) error {
+	return newKebijakanPassword(nil, nil, cfg).periksa(password)
+}
+
+// hash checks a new password of admin against the policy and its recent
+// passwords and returns the bcrypt hash to store
+func (k *kebijakanPassword) hash(admin *models.Admin, password string) (string, error) {
+	if err := k.periksa(password); err != nil {
+		return "", err
+	}
+
+	if n := k.riwayat(); n > 0 && admin.Password != "" {
+		// The current password counts as the most recent one
+		lama := []string{admin.Password}
+		if n > 1 {
+			list, err := k.riwayatRepo.FindTerbaru(admin.ID, n-1)
+			if err != nil {
+				return "", err
+			}
+			for _, r := range list {
+				lama = append(lama, r.PasswordHash)
+			}
+		}
+		for _, hash := range lama {
+			if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil {
+				if n == 1 {
+					return "", &PasswordLemahError{Alasan: []string{"tidak boleh sama dengan password saat ini"}}
+				}
+				return "", &PasswordLemahError{Alasan: []string{fmt.Sprintf("tidak boleh sama dengan %d password terakhir", n)}}
+			}
+		}
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", errors.New("gagal mengenkripsi password")
+	}
+	return string(hashed), nil
+}
+
+// ganti checks and stores a new password for admin
+func (k *kebijakanPassword) ganti(admin *models.Admin, password string) error {
+	hashed, err := k.hash(admin, password)
+	if err != nil {
+		return err
+	}
+	return k.simpan(admin, hashed)
+}
+
+// simpan stores a hash returned by hash and moves the replaced one into the
+// history. The password itself is already changed when the history fails,
+// so that is only logged.
+func (k *kebijakanPassword) simpan(admin *models.Admin, hashed string) error {
+	lama := admin.Password
+	admin.Password = hashed
+	if err := k.adminRepo.Update(admin); err != nil {
+		return err
+	}
+
+	// The current password is compared directly, the history only needs
+	// the ones before it
+	simpan := k.riwayat() - 1
+	if simpan > 0 && lama != "" {
+		if err := k.riwayatRepo.Create(&models.RiwayatPassword{AdminID: admin.ID, PasswordHash: lama}); err != nil {
+			log.Printf("Warning: gagal menyimpan riwayat password admin %s: %v", admin.ID, err)
+		}
+	}
+	if err := k.riwayatRepo.Pangkas(admin.ID, max(simpan, 0)); err != nil {
+		log.Printf("Warning: gagal memangkas riwayat password admin %s: %v", admin.ID, err)
+	}
+	return nil
+}
+
+func (k *kebijakanPassword) minPanjang() int {
+	n, err := strconv.Atoi(k.cfg.PasswordMinLength)
+	if err != nil || n < 1 {
+		n = 8
+	}
+	return n
+}
+
+// kelasWajib returns the known classes of PASSWORD_REQUIRED_CLASSES in the
+// configured order
+func (k *kebijakanPassword) kelasWajib() []string {
+	list := []string{}
+	for _, kelas := range strings.Split(k.cfg.PasswordRequiredClasses, ",") {
+		kelas = strings.ToLower(strings.TrimSpace(kelas))
+		if _, ok := kelasPassword[kelas]; ok && !slices.Contains(list, kelas) {
+			list = append(list, kelas)
+		}
+	}
+	return list
+}
+
+func (k *kebijakanPassword) riwayat() int {
+	n, err := strconv.Atoi(k.cfg.PasswordHistory)
+	if err != nil {
+		n = 5
+	}
+	return max(n, 0)
+}
+
+// ============== Lupa Password Service ==============
+
+// ErrTokenResetTidakValid is returned for a reset link that is unknown,
+// expired or already used
+var ErrTokenResetTidakValid = errors.New("tautan reset password tidak valid atau sudah kadaluarsa")
+
+const (
+	// batasPermintaanReset limits the links sent to one admin within
+	// jedaPermintaanReset, so the form cannot flood a mailbox
+	batasPermintaanReset = 3
+	jedaPermintaanReset  = time.Hour
+)
+
+type LupaPasswordService interface {
+	// Minta emails a reset link when email belongs to an active admin. It
+	// also returns nil for unknown addresses, so the form does not reveal
+	// which accounts exist.
+	Minta(req dto.LupaPasswordRequest, klien KlienInfo) error
+	CekToken(token string) (*dto.TokenResetPasswordResponse, error)
+	// Reset sets a new password with a link sent by Minta and ends every
+	// session of the admin
+	Reset(req dto.ResetPasswordTokenRequest) error
+	Kebijakan() dto.KebijakanPasswordResponse
+}
+
+type lupaPasswordService struct {
+	adminRepo    repositories.AdminRepository
+	tokenRepo    repositories.TokenResetPasswordRepository
+	sesiRepo     repositories.SesiAdminRepository
+	kebijakan    *kebijakanPassword
+	emailService EmailService
+	cfg          *config.Config
+}
+
+func NewLupaPasswordService(
+	adminRepo repositories.AdminRepository,
+	tokenRepo repositories.TokenResetPasswordRepository,
+	riwayatPasswordRepo repositories.RiwayatPasswordRepository,
+	sesiRepo repositories.SesiAdminRepository,
+	emailService EmailService,
+	cfg *config.Config,
+) LupaPasswordService {
+	return &lupaPasswordService{
+		adminRepo:    adminRepo,
+		tokenRepo:    tokenRepo,
+		sesiRepo:     sesiRepo,
+		kebijakan:    newKebijakanPassword(adminRepo, riwayatPasswordRepo, cfg),
+		emailService: emailService,
+		cfg:          cfg,
+	}
+}
+
+func (s *lupaPasswordService) Minta(req dto.LupaPasswordRequest, klien KlienInfo) error {
+	admin, err := s.adminRepo.FindByEmail(strings.TrimSpace(req.Email))
+	if err != nil || !admin.IsActive {
+		return nil
+	}
+
+	jumlah, err := s.tokenRepo.CountSejak(admin.ID, time.Now().Add(-jedaPermintaanReset))
+	if err != nil {
+		return err
+	}
+	if jumlah >= batasPermintaanReset {
+		log.Printf("Warning: permintaan reset password admin %s dibatasi", admin.Username)
+		return nil
+	}
+
+	// Keep expired links around for a while so they still count above
+	if err := s.tokenRepo.DeleteExpired(time.Now().Add(-24 * time.Hour)); err != nil {
+		log.Printf("Warning: gagal membersihkan token reset password: %v", err)
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return errors.New("gagal membuat token")
+	}
+	// Only the newest link works
+	if err := s.tokenRepo.BatalkanByAdminID(admin.ID); err != nil {
+		return err
+	}
+	masaBerlaku := s.masaBerlaku()
+	record := &models.TokenResetPassword{
+		AdminID:        admin.ID,
+		TokenHash:      hashToken(token),
+		IPAddress:      klien.IPAddress,
+		KadaluarsaPada: time.Now().Add(masaBerlaku),
+	}
+	if err := s.tokenRepo.Create(record); err != nil {
+		return errors.New("gagal membuat token")
+	}
+
+	// The outbox only keeps a placeholder; the token is filled in when sending
+	link := fmt.Sprintf("%s/admin/reset-password?token=%s", strings.TrimRight(s.cfg.FrontendURL, "/"), PenandaRahasia)
+	subjek, isiHTML, isiTeks := emailResetPassword(admin.NamaLengkap, link, masaBerlaku)
+	return s.emailService.KirimEmailSistem(admin.Email, subjek, isiHTML, isiTeks, token)
+}
+
+func (s *lupaPasswordService) CekToken(token string) (*dto.TokenResetPasswordResponse, error) {
+	record, admin, err := s.findToken(token)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.TokenResetPasswordResponse{
+		Username:      admin.Username,
+		BerlakuSampai: record.KadaluarsaPada,
+	}, nil
+}
+
+func (s *lupaPasswordService) Reset(req dto.ResetPasswordTokenRequest) error {
+	record, admin, err := s.findToken(req.Token)
+	if err != nil {
+		return err
+	}
+
+	// Check the policy before using up the link, so a rejected password can
+	// be corrected with the same link
+	hashed, err := s.kebijakan.hash(admin, req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	ok, err := s.tokenRepo.Pakai(record.ID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrTokenResetTidakValid
+	}
+
+	if err := s.kebijakan.simpan(admin, hashed); err != nil {
+		return err
+	}
+	if err := s.sesiRepo.RevokeByAdminID(admin.ID, models.AlasanSesiLupaPassword); err != nil {
+		log.Printf("Warning: gagal mencabut sesi admin %s: %v", admin.ID, err)
+	}
+	return nil
+}
+
+func (s *lupaPasswordService) Kebijakan() dto.KebijakanPasswordResponse {
+	return s.kebijakan.info()
+}
+
+// findToken resolves a reset link that is unused, not expired and belongs
+// to an active admin
+func (s *lupaPasswordService) findToken(token string) (*models.TokenResetPassword, *models.Admin, error) {
+	if token == "" {
+		return nil, nil, ErrTokenResetTidakValid
+	}
+	record, err := s.tokenRepo.FindByTokenHash(hashToken(token))
+	if err != nil || record.DipakaiPada != nil || time.Now().After(record.KadaluarsaPada) {
+		return nil, nil, ErrTokenResetTidakValid
+	}
+	admin, err := s.adminRepo.FindByID(record.AdminID)
+	if err != nil || !admin.IsActive {
+		return nil, nil, ErrTokenResetTidakValid
+	}
+	return record, admin, nil
+}
+
+func (s *lupaPasswordService) masaBerlaku() time.Duration {
+	menit, err := strconv.Atoi(s.cfg.PasswordResetExpiryMinutes)
+	if err != nil || menit < 1 {
+		menit = 30
+	}
+	return time.Duration(menit) * time.Minute
+}
+
+const emailResetPasswordHTML = `
+		<html>
+		<body style="font-family: Arial, sans-serif; line-height: 1.6;">
+			<div style="max-width: 600px; margin: 0 auto; padding: 20px;">
+				<div style="background-color: #1e40af; color: white; padding: 20px; text-align: center;">
+					<h1>Dinas Kesehatan Kota Makassar</h1>
+				</div>
+				<div style="padding: 20px; background-color: #f8fafc;">
+					<h2>Yth. %s,</h2>
+					<p>Kami menerima permintaan untuk mereset password akun admin Anda.</p>
+					<p style="text-align: center; margin: 30px 0;">
+						<a href="%s" style="background-color: #1e40af; color: white; padding: 12px 24px; text-decoration: none; border-radius: 6px;">Reset Password</a>
+					</p>
+					<p>Tautan ini hanya dapat dipakai sekali dan berlaku selama %d menit.</p>
+					<p>Jika Anda tidak meminta reset password, abaikan email ini. Password Anda tidak akan berubah.</p>
+					<hr style="margin: 20px 0;">
+					<p style="color: #666; font-size: 12px;">
+						Email ini dikirim secara otomatis dari sistem perizinan Dinas Kesehatan Kota Makassar.
+					</p>
+				</div>
+			</div>
+		</body>
+		</html>
+	`
+
+const emailResetPasswordTeks = `Yth. %s,
+
+Kami menerima permintaan untuk mereset password akun admin Anda. Buka tautan berikut untuk membuat password baru:
+
+%s
+
+Tautan ini hanya dapat dipakai sekali dan berlaku selama %d menit.
+
+Jika Anda tidak meminta reset password, abaikan email ini. Password Anda tidak akan berubah.
+
+Hormat kami,
+Dinas Kesehatan Kota Makassar`
+
+// emailResetPassword returns the subject, HTML and plain text body of a
+// reset link email
+func emailResetPassword(nama, link string, masaBerlaku time.Duration) (string, string, string) {
+	menit := int(masaBerlaku.Minutes())
+	isiHTML := fmt.Sprintf(emailResetPasswordHTML, htmltemplate.HTMLEscapeString(nama), htmltemplate.HTMLEscapeString(link), menit)
+	isiTeks := fmt.Sprintf(emailResetPasswordTeks, nama, link, menit)
+	return "Reset Password Admin - Dinas Kesehatan Kota Makassar", isiHTML, isiTeks
+}
+
+// ============== Jenis Perizinan Service ==============
+
+type JenisPerizinanService interface {
+	Create(req dto.CreateJenisPerizinanRequest) (*models.JenisPerizinan, error)
+	GetAll(aktifOnly bool) ([]dto.JenisPerizinanResponse, error)
+	GetByID(id uuid.UUID) (*dto.JenisPerizinanResponse, error)
+	Update(id uuid.UUID, req dto.UpdateJenisPerizinanRequest) (*models.JenisPerizinan, error)
+	Delete(id uuid.UUID) error
+}
+
+type jenisPerizinanService struct {
+	repo repositories.JenisPerizinanRepository
+}
+
+func NewJenisPerizinanService(repo repositories.JenisPerizinanRepository) JenisPerizinanService {
+	return &jenisPerizinanService{repo: repo}
+}
+
+func (s *jenisPerizinanService) Create(req dto.CreateJenisPerizinanRequest) (*models.JenisPerizinan, error) {
+	persyaratan, err := toPersyaratanList(req.Persyaratan)
+	if err != nil {
+		return nil, err
+	}
+
+	jp := &models.JenisPerizinan{
+		Nama:        req.Nama,
+		Kode:        strings.ToUpper(req.Kode),
+		Deskripsi:   req.Deskripsi,
+		Persyaratan: persyaratan,
+		Aktif:       req.Aktif,
+	}
+	err = s.repo.Create(jp)
+	return jp, err
+}
+
+func (s *jenisPerizinanService) GetAll(aktifOnly bool) ([]dto.JenisPerizinanResponse, error) {
+	list, err := s.repo.FindAll(aktifOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	var response []dto.JenisPerizinanResponse
+	for _, jp := range list {
+		response = append(response, dto.JenisPerizinanResponse{
+			ID:          jp.ID,
+			Nama:        jp.Nama,
+			Kode:        jp.Kode,
+			Deskripsi:   jp.Deskripsi,
+			Persyaratan: toPersyaratanItems(jp.Persyaratan),
+			Aktif:       jp.Aktif,
+			CreatedAt:   jp.CreatedAt,
+		})
+	}
+	return response, nil
+}
+
+func (s *jenisPerizinanService) GetByID(id uuid.UUID) (*dto.JenisPerizinanResponse, error) {
+	jp, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.JenisPerizinanResponse{
+		ID:          jp.ID,
+		Nama:        jp.Nama,
+		Kode:        jp.Kode,
+		Deskripsi:   jp.Deskripsi,
+		Persyaratan: toPersyaratanItems(jp.Persyaratan),
+		Aktif:       jp.Aktif,
+		CreatedAt:   jp.CreatedAt,
+	}, nil
+}
+
+func (s *jenisPerizinanService) Update(id uuid.UUID, req dto.UpdateJenisPerizinanRequest) (*models.JenisPerizinan, error) {
+	jp, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Nama != "" {
+		jp.Nama = req.Nama
+	}
+	if req.Kode != "" {
+		jp.Kode = strings.ToUpper(req.Kode)
+	}
+	if req.Deskripsi != "" {
+		jp.Deskripsi = req.Deskripsi
+	}
+	if req.Persyaratan != nil {
+		persyaratan, err := toPersyaratanList(req.Persyaratan)
+		if err != nil {
+			return nil, err
+		}
+		jp.Persyaratan = persyaratan
+	}
+	if req.Aktif != nil {
+		jp.Aktif = *req.Aktif
+	}
+
+	err = s.repo.Update(jp)
+	return jp, err
+}
+
+func (s *jenisPerizinanService) Delete(id uuid.UUID) error {
+	return s.repo.Delete(id)
+}
+
+// ErrPersyaratanTidakValid is returned when a jenis perizinan is saved with
+// an invalid requirement definition
+var ErrPersyaratanTidakValid = errors.New("persyaratan tidak valid")
+
+// toPersyaratanList validates requirement definitions and normalizes their
+// kode and file types
+func toPersyaratanList(items []dto.PersyaratanItem) (models.PersyaratanList, error) {
+	list := models.PersyaratanList{}
+	for _, item := range items {
+		kode := item.Kode
+		if kode == "" {
+			kode = item.Nama
+		}
+		kode = models.KodePersyaratan(kode)
+		if kode == "" {
+			return nil, fmt.Errorf("%w: kode untuk %q tidak dapat dibuat", ErrPersyaratanTidakValid, item.Nama)
+		}
+		if _, exists := list.Find(kode); exists {
+			return nil, fmt.Errorf("%w: kode %q digunakan lebih dari sekali", ErrPersyaratanTidakValid, kode)
+		}
+
+		var tipeFile []string
+		for _, tipe := range item.TipeFile {
+			tipe = strings.ToLower(strings.TrimSpace(tipe))
+			if tipe == "" {
+				continue
+			}
+			if !strings.HasPrefix(tipe, ".") {
+				tipe = "." + tipe
+			}
+			tipeFile = append(tipeFile, tipe)
+		}
+
+		list = append(list, models.Persyaratan{
+			Kode:       kode,
+			Nama:       strings.TrimSpace(item.Nama),
+			Wajib:      item.Wajib,
+			TipeFile:   tipeFile,
+			UkuranMaks: item.UkuranMaks,
+		})
+	}
+	return list, nil
+}
+
+func toPersyaratanItems(list models.PersyaratanList) []dto.PersyaratanItem {
+	items := make([]dto.PersyaratanItem, 0, len(list))
+	for _, item := range list {
+		items = append(items, dto.PersyaratanItem{
+			Kode:       item.Kode,
+			Nama:       item.Nama,
+			Wajib:      item.Wajib,
+			TipeFile:   item.TipeFile,
+			UkuranMaks: item.UkuranMaks,
+		})
+	}
+	return items
+}
+
+// ============== Permohonan Status Transitions ==============
+
+// ErrTransisiStatus is returned when a status change is not allowed by
+// transisiStatus for the current status or the permissions of the admin.
+var ErrTransisiStatus = errors.New("perubahan status tidak diizinkan")
+
+// transisiRule describes one allowed status change. Izin is the permission
+// needed on top of IzinUbahStatus, which the routes already require; empty
+// means none.
+type transisiRule struct {
+	To   models.StatusPermohonan
+	Izin models.Izin
+}
+
+// transisiStatus is the permohonan state machine:
+// baru -> diproses -> disetujui/ditolak. A request may be rejected straight
+// from baru, and a finished request can only be reopened with
+// IzinBukaKembali.
+// While processing, an admin may ask for a revision (perlu_revisi); the
+// applicant's re-upload moves it back to diproses (see UploadRevisi).
+var transisiStatus = map[models.StatusPermohonan][]transisiRule{
+	models.StatusBaru: {
+		{To: models.StatusDiproses},
+		{To: models.StatusDitolak},
+	},
+	models.StatusDiproses: {
+		{To: models.StatusDisetujui},
+		{To: models.StatusDitolak},
+		{To: models.StatusPerluRevisi},
+	},
+	models.StatusPerluRevisi: {
+		{To: models.StatusDiproses},
+		{To: models.StatusDitolak},
+	},
+	models.StatusDisetujui: {
+		{To: models.StatusDiproses, Izin: models.IzinBukaKembali},
+	},
+	models.StatusDitolak: {
+		{To: models.StatusDiproses, Izin: models.IzinBukaKembali},
+	},
+}
+
+// NextStatuses returns the statuses reachable from the given status with
+// the given permissions
+func NextStatuses(from models.StatusPermohonan, izin models.IzinList) []models.StatusPermohonan {
+	var next []models.StatusPermohonan
+	for _, rule := range transisiStatus[from] {
+		if rule.Izin == "" || izin.Punya(rule.Izin) {
+			next = append(next, rule.To)
+		}
+	}
+	return next
+}
+
+// applyStatus sets the new status and keeps the timestamps consistent:
+// TanggalDiproses is only set the first time a request is processed and
+// TanggalSelesai is cleared again when a finished request is reopened.
+func applyStatus(p *models.Permohonan, status models.StatusPermohonan) {
+	now := time.Now()
+	p.Status = status
+	switch status {
+	case models.StatusDiproses:
+		if p.TanggalDiproses == nil {
+			p.TanggalDiproses = &now
+		}
+		p.TanggalSelesai = nil
+	case models.StatusDisetujui, models.StatusDitolak:
+		p.TanggalSelesai = &now
+	}
+
+	// Leaving perlu_revisi invalidates the applicant's upload link
+	if status != models.StatusPerluRevisi {
+		p.TokenRevisi = ""
+		p.TokenRevisiKadaluarsa = nil
+	}
+}
+
+// ============== Permohonan Service ==============
+
+type PermohonanService interface {
+	Create(req dto.CreatePermohonanRequest, files map[string][]*multipart.FileHeader) (*models.Permohonan, error)
+	GetAll(pagination dto.PaginationQuery) (*dto.PermohonanListResponse, error)
+	GetByID(id uuid.UUID) (*dto.PermohonanResponse, error)
+	GetByStatus(status string) ([]dto.PermohonanResponse, error)
+	Lacak(query dto.LacakPermohonanQuery) (*dto.LacakPermohonanResponse, error)
+	UpdateStatus(id uuid.UUID, adminID uuid.UUID, req dto.UpdatePermohonanStatusRequest) error
+	KirimBalasan(id uuid.UUID, adminID uuid.UUID, req dto.KirimBalasanRequest, lampiran *multipart.FileHeader) error
+	GetSuratTerbaru(id uuid.UUID) (*models.SuratIzin, error)
+	MintaRevisi(id uuid.UUID, adminID uuid.UUID, req dto.KirimRevisiRequest) error
+	GetRevisiInfo(token string) (*dto.RevisiInfoResponse, error)
+	UploadRevisi(token string, files map[string][]*multipart.FileHeader) error
+	GetStatistik() (*dto.StatistikDashboard, error)
+	GetRecentPermohonan(limit int) ([]dto.PermohonanResponse, error)
+}
+
+type permohonanService struct {
+	permohonanRepo repositories.PermohonanRepository
+	pemohonRepo    repositories.PemohonRepository
+	jpRepo         repositories.JenisPerizinanRepository
+	adminRepo      repositories.AdminRepository
+	riwayatRepo    repositories.RiwayatPermohonanRepository
+	berkasRepo     repositories.BerkasRepository
+	nomorUrutRepo  repositories.NomorUrutRepository
+	transactor     repositories.Transactor
+	scanner        Scanner
+	storage        Storage
+	notifService   NotifikasiService
+	emailService   EmailService
+	suratService   SuratService
+	broker         EventBroker
+	roleService    RoleService
+	cfg            *config.Config
+}
+
+func NewPermohonanService(
+	permohonanRepo repositories.PermohonanRepository,
+	pemohonRepo repositories.PemohonRepository,
+	jpRepo repositories.JenisPerizinanRepository,
+	adminRepo repositories.AdminRepository,
+	riwayatRepo repositories.RiwayatPermohonanRepository,
+	berkasRepo repositories.BerkasRepository,
+	nomorUrutRepo repositories.NomorUrutRepository,
+	transactor repositories.Transactor,
+	notifService NotifikasiService,
+	emailService EmailService,
+	suratService SuratService,
+	broker EventBroker,
+	scanner Scanner,
+	storage Storage,
+	roleService RoleService,
+	cfg *config.Config,
+) PermohonanService {
+	return &permohonanService{
+		permohonanRepo: permohonanRepo,
+		pemohonRepo:    pemohonRepo,
+		jpRepo:         jpRepo,
+		adminRepo:      adminRepo,
+		riwayatRepo:    riwayatRepo,
+		berkasRepo:     berkasRepo,
+		nomorUrutRepo:  nomorUrutRepo,
+		transactor:     transactor,
+		scanner:        scanner,
+		storage:        storage,
+		notifService:   notifService,
+		emailService:   emailService,
+		suratService:   suratService,
+		broker:         broker,
+		roleService:    roleService,
+		cfg:            cfg,
+	}
+}
+
+// Create stores a new submission as one unit of work: the pemohon, the
+// permohonan and its berkas are written in a single transaction. Files put
+// into storage before a failure are removed again, see hapusBerkasGagal.
+func (s *permohonanService) Create(req dto.CreatePermohonanRequest, files map[string][]*multipart.FileHeader) (*models.Permohonan, error) {
+	// Validate everything that does not need a write first
+	jpID, err := uuid.Parse(req.JenisPerizinanID)
+	if err != nil {
+		return nil, fmt.Errorf("jenis perizinan ID tidak valid: %w", err)
+	}
+
+	jp, err := s.jpRepo.FindByID(jpID)
+	if err != nil {
+		return nil, fmt.Errorf("jenis perizinan tidak ditemukan: %w", err)
+	}
+
+	if err := validateBerkas(jp.Persyaratan, files, batasUnggahFromConfig(s.cfg)); err != nil {
+		return nil, err
+	}
+
+	unggahan, err := s.siapkanBerkasFiles(jp.Persyaratan, files)
+	if err != nil {
+		return nil, err
+	}
+
+	pemohon := &models.Pemohon{
+		NamaLengkap:  req.Pemohon.NamaLengkap,
+		NomorTelepon: req.Pemohon.NomorTelepon,
+		Email:        req.Pemohon.Email,
+		Alamat:       req.Pemohon.Alamat,
+	}
+	permohonan := &models.Permohonan{
+		JenisPerizinanID: jpID,
+		Catatan:          req.Catatan,
+		Status:           models.StatusBaru,
+		TanggalMasuk:     time.Now(),
+	}
+
+	var riwayat *models.RiwayatPermohonan
+	var disimpan []string
+	err = s.transactor.WithTransaction(func(tx *gorm.DB) error {
+		berkasFiles, err := s.simpanBerkasFiles(tx, unggahan, &disimpan)
+		if err != nil {
+			return err
+		}
+		permohonan.Berkas = berkasFiles
+
+		if err := s.pemohonRepo.WithTx(tx).Create(pemohon); err != nil {
+			return fmt.Errorf("gagal menyimpan data pemohon: %w", err)
+		}
+
+		nomor, err := s.nextNomorPermohonan(s.nomorUrutRepo.WithTx(tx), jp)
+		if err != nil {
+			return fmt.Errorf("gagal membuat nomor permohonan: %w", err)
+		}
+
+		permohonan.NomorPermohonan = nomor
+		permohonan.PemohonID = pemohon.ID
+		if err := s.permohonanRepo.WithTx(tx).Create(permohonan); err != nil {
+			return fmt.Errorf("gagal menyimpan permohonan: %w", err)
+		}
+
+		riwayat, err = s.catatRiwayat(tx, permohonan.ID, "", models.StatusBaru, nil, "Permohonan diajukan")
+		return err
+	})
+	if err != nil {
+		s.hapusBerkasGagal(disimpan)
+		return nil, err
+	}
+
+	s.publishRiwayat(riwayat)
+
+	// Create notification for all admins
+	go s.notifService.NotifyAdmins(permohonan.ID, permohonan.JenisPerizinanID, models.EventPermohonanBaru,
+		fmt.Sprintf("Permohonan baru dari %s - %s", pemohon.NamaLengkap, jp.Nama))
+
+	return permohonan, nil
+}
+
+// nextNomorPermohonan takes the next value of the yearly counter. The counter
+// is shared by all jenis perizinan so numbers stay unique even when two jenis
+// use the same kode.
+func (s *permohonanService) nextNomorPermohonan(nomorUrutRepo repositories.NomorUrutRepository, jp *models.JenisPerizinan) (string, error) {
+	now := time.Now()
+	urut, err := nomorUrutRepo.Next(fmt.Sprintf("permohonan-%d", now.Year()))
+	if err != nil {
+		return "", err
+	}
+
+	kode := jp.Kode
+	if kode == "" {
+		kode = s.cfg.NomorPermohonanKode
+	}
+	digit, err := strconv.Atoi(s.cfg.NomorPermohonanDigit)
+	if err != nil || digit <= 0 {
+		digit = 5
+	}
+
+	return models.FormatNomorPermohonan(s.cfg.NomorPermohonanFormat, kode, now.Year(), urut, digit), nil
+}
+
+// ValidasiBerkasError lists every uploaded document that does not satisfy the
+// requirements of the jenis perizinan
+type ValidasiBerkasError struct {
+	Detail []dto.BerkasErrorResponse
+}
+
+func (e *ValidasiBerkasError) Error() string {
+	pesan := make([]string, 0, len(e.Detail))
+	for _, d := range e.Detail {
+		if d.Persyaratan == "" {
+			pesan = append(pesan, d.Pesan)
+			continue
+		}
+		pesan = append(pesan, fmt.Sprintf("%s: %s", d.Persyaratan, d.Pesan))
+	}
+	return "berkas tidak valid: " + strings.Join(pesan, "; ")
+}
+
+// MimeTypeDOCX is the content type of Word documents. DOCX files are zip
+// archives and are told apart from other zips by their word/document.xml.
+const MimeTypeDOCX = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+
+// mimeExtensions lists the file extensions matching each detected type
+var mimeExtensions = map[string][]string{
+	"application/pdf": {".pdf"},
+	"image/jpeg":      {".jpg", ".jpeg"},
+	"image/png":       {".png"},
+	MimeTypeDOCX:      {".docx"},
+}
+
+// batasUnggah holds the deployment limits for applicant uploads
+type batasUnggah struct {
+	MimeTypes   []string
+	UkuranFile  int64
+	UkuranTotal int64
+}
+
+func batasUnggahFromConfig(cfg *config.Config) batasUnggah {
+	batas := batasUnggah{}
+	for _, mimeType := range strings.Split(cfg.AllowedMimeTypes, ",") {
+		if mimeType = strings.TrimSpace(strings.ToLower(mimeType)); mimeType != "" {
+			batas.MimeTypes = append(batas.MimeTypes, mimeType)
+		}
+	}
+	batas.UkuranFile, _ = strconv.ParseInt(cfg.MaxFileSize, 10, 64)
+	batas.UkuranTotal, _ = strconv.ParseInt(cfg.MaxSubmissionSize, 10, 64)
+	return batas
+}
+
+// detectMimeType determines the type of an uploaded file from its content;
+// the Content-Type sent by the client is ignored
+func detectMimeType(file *multipart.FileHeader) (string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(src, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+
+	mimeType := http.DetectContentType(head[:n])
+	if i := strings.Index(mimeType, ";"); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+
+	if mimeType == "application/zip" {
+		archive, err := zip.NewReader(src, file.Size)
+		if err == nil {
+			for _, entry := range archive.File {
+				if entry.Name == "word/document.xml" {
+					return MimeTypeDOCX, nil
+				}
+			}
+		}
+	}
+	return mimeType, nil
+}
+
+// validateBerkas checks files keyed by requirement kode against the
+// requirement list and the deployment limits: unknown keys, missing
+// mandatory documents, file content types and sizes
+func validateBerkas(persyaratan models.PersyaratanList, files map[string][]*multipart.FileHeader, batas batasUnggah) error {
+	var detail []dto.BerkasErrorResponse
+	var total int64
+
+	for kode, list := range files {
+		if _, ok := persyaratan.Find(kode); !ok && len(list) > 0 {
+			detail = append(detail, dto.BerkasErrorResponse{
+				Persyaratan: kode,
+				Pesan:       "persyaratan tidak dikenal untuk jenis perizinan ini",
+			})
+		}
+	}
+
+	for _, item := range persyaratan {
+		list := files[item.Kode]
+		if len(list) == 0 {
+			if item.Wajib {
+				detail = append(detail, dto.BerkasErrorResponse{
+					Persyaratan: item.Kode,
+					Pesan:       fmt.Sprintf("%s wajib diunggah", item.Nama),
+				})
+			}
+			continue
+		}
+
+		for _, file := range list {
+			total += file.Size
+			ext := strings.ToLower(filepath.Ext(file.Filename))
+
+			mimeType, err := detectMimeType(file)
+			switch {
+			case err != nil:
+				detail = append(detail, dto.BerkasErrorResponse{
+					Persyaratan: item.Kode,
+					NamaFile:    file.Filename,
+					Pesan:       "file tidak dapat dibaca",
+				})
+				continue
+			case !containsString(batas.MimeTypes, mimeType):
+				detail = append(detail, dto.BerkasErrorResponse{
+					Persyaratan: item.Kode,
+					NamaFile:    file.Filename,
+					Pesan:       fmt.Sprintf("jenis file %s tidak diizinkan", mimeType),
+				})
+				continue
+			case mimeExtensions[mimeType] != nil && !containsString(mimeExtensions[mimeType], ext):
+				detail = append(detail, dto.BerkasErrorResponse{
+					Persyaratan: item.Kode,
+					NamaFile:    file.Filename,
+					Pesan:       fmt.Sprintf("isi file (%s) tidak sesuai dengan ekstensi %s", mimeType, ext),
+				})
+				continue
+			}
+
+			if batas.UkuranFile > 0 && file.Size > batas.UkuranFile {
+				detail = append(detail, dto.BerkasErrorResponse{
+					Persyaratan: item.Kode,
+					NamaFile:    file.Filename,
+					Pesan:       fmt.Sprintf("ukuran file melebihi batas %d KB", batas.UkuranFile/1024),
+				})
+			}
+			if len(item.TipeFile) > 0 && !containsString(item.TipeFile, ext) {
+				detail = append(detail, dto.BerkasErrorResponse{
+					Persyaratan: item.Kode,
+					NamaFile:    file.Filename,
+					Pesan:       fmt.Sprintf("tipe file harus %s", strings.Join(item.TipeFile, ", ")),
+				})
+			}
+			if item.UkuranMaks > 0 && file.Size > item.UkuranMaks {
+				detail = append(detail, dto.BerkasErrorResponse{
+					Persyaratan: item.Kode,
+					NamaFile:    file.Filename,
+					Pesan:       fmt.Sprintf("ukuran file melebihi batas %d KB", item.UkuranMaks/1024),
+				})
+			}
+		}
+	}
+
+	if batas.UkuranTotal > 0 && total > batas.UkuranTotal {
+		detail = append(detail, dto.BerkasErrorResponse{
+			Pesan: fmt.Sprintf("total ukuran berkas melebihi batas %d KB", batas.UkuranTotal/1024),
+		})
+	}
+
+	if len(detail) > 0 {
+		return &ValidasiBerkasError{Detail: detail}
+	}
+	return nil
+}
+
+// berkasUnggahan is an uploaded file that passed the scan, waiting to be
+// stored together with its Berkas record
+type berkasUnggahan struct {
+	berkas    models.Berkas
+	file      *multipart.FileHeader
+	pratinjau []byte // JPEG preview, nil if none
+}
+
+// siapkanBerkasFiles describes and scans uploaded files in requirement order
+// and renders their previews. Everything is scanned before anything is
+// stored, so infected content never reaches storage. Nothing is written
+// here; see simpanBerkasFiles.
+func (s *permohonanService) siapkanBerkasFiles(persyaratan models.PersyaratanList, files map[string][]*multipart.FileHeader) ([]berkasUnggahan, error) {
+	var berkasFiles []models.Berkas
+	var uploads []*multipart.FileHeader
+	for _, item := range persyaratan {
+		for _, file := range files[item.Kode] {
+			berkas, err := newBerkas(item.Kode, file)
+			if err != nil {
+				return nil, err
+			}
+			berkasFiles = append(berkasFiles, *berkas)
+			uploads = append(uploads, file)
+		}
+	}
+
+	if err := s.scanBerkasFiles(berkasFiles, uploads); err != nil {
+		return nil, err
+	}
+
+	unggahan := make([]berkasUnggahan, len(berkasFiles))
+	for i := range berkasFiles {
+		unggahan[i] = berkasUnggahan{
+			berkas:    berkasFiles[i],
+			file:      uploads[i],
+			pratinjau: s.buatPratinjauBerkas(&berkasFiles[i], uploads[i]),
+		}
+	}
+	return unggahan, nil
+}
+
+// simpanBerkasFiles puts prepared uploads into storage inside tx and returns
+// their Berkas records, not yet persisted. Identical content is stored once,
+// see newBerkas, so the keys are locked first and stay locked until tx ends:
+// a failed upload cleaning up the same key waits until the Berkas of this
+// one is committed, see hapusBerkasGagal. Every key written is appended to
+// disimpan, also when tx is rolled back later. Files are put even when the
+// key exists, since a cleanup may have removed it just before the lock was
+// taken.
+func (s *permohonanService) simpanBerkasFiles(tx *gorm.DB, unggahan []berkasUnggahan, disimpan *[]string) ([]models.Berkas, error) {
+	var keys []string
+	for _, u := range unggahan {
+		keys = append(keys, u.berkas.Path)
+		if u.pratinjau != nil {
+			keys = append(keys, pathPratinjau(&u.berkas))
+		}
+	}
+	if err := s.berkasRepo.WithTx(tx).Kunci(keys); err != nil {
+		return nil, fmt.Errorf("gagal mengunci berkas: %w", err)
+	}
+
+	ctx := context.Background()
+	berkasFiles := make([]models.Berkas, len(unggahan))
+	for i, u := range unggahan {
+		b := u.berkas
+		// Recorded first so a partially written file is cleaned up too
+		*disimpan = append(*disimpan, b.Path)
+		if err := s.putUpload(b.Path, u.file, b.MimeType); err != nil {
+			return nil, fmt.Errorf("gagal menyimpan berkas %s: %w", u.file.Filename, err)
+		}
+
+		// Previews are only a convenience for reviewers, so failures are
+		// logged and the upload goes on
+		if u.pratinjau != nil {
+			key := pathPratinjau(&b)
+			*disimpan = append(*disimpan, key)
+			if err := s.storage.Put(ctx, key, bytes.NewReader(u.pratinjau), int64(len(u.pratinjau)), "image/jpeg"); err != nil {
+				log.Printf("Warning: gagal menyimpan pratinjau %s: %v", b.NamaAsli, err)
+			} else {
+				b.PratinjauPath = key
+			}
+		}
+		berkasFiles[i] = b
+	}
+	return berkasFiles, nil
+}
+
+// hapusBerkasGagal removes the files a failed upload put into storage once
+// its transaction is rolled back. Keys are shared by every upload with the
+// same content, so each key is locked and only deleted when no committed
+// Berkas refers to it. Files left behind when this fails are removed by
+// `check-storage -hapus-yatim`.
+func (s *permohonanService) hapusBerkasGagal(keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	ctx := context.Background()
+	err := s.transactor.WithTransaction(func(tx *gorm.DB) error {
+		berkasRepo := s.berkasRepo.WithTx(tx)
+		if err := berkasRepo.Kunci(keys); err != nil {
+			return err
+		}
+		for _, key := range keys {
+			count, err := berkasRepo.CountByPath(key)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				continue
+			}
+			if err := s.storage.Delete(ctx, key); err != nil && !errors.Is(err, ErrFileTidakDitemukan) {
+				log.Printf("Warning: gagal menghapus berkas %s: %v", key, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Warning: gagal membersihkan berkas unggahan yang gagal: %v", err)
+	}
+}
+
+// buatPratinjauBerkas renders the preview image of a berkas. Failures are
+// logged and yield no preview.
+func (s *permohonanService) buatPratinjauBerkas(b *models.Berkas, file *multipart.FileHeader) []byte {
+	src, err := file.Open()
+	if err != nil {
+		log.Printf("Warning: gagal membuat pratinjau %s: %v", b.NamaAsli, err)
+		return nil
+	}
+	defer src.Close()
+
+	img, err := buatPratinjau(context.Background(), s.cfg, src, b.MimeType)
+	if err != nil {
+		log.Printf("Warning: gagal membuat pratinjau %s: %v", b.NamaAsli, err)
+		return nil
+	}
+	return img
+}
+
+// pathPratinjau is the storage key of the preview of a berkas
+func pathPratinjau(b *models.Berkas) string {
+	return path.Join("pratinjau", strings.TrimSuffix(b.NamaFile, path.Ext(b.NamaFile))+".jpg")
+}
+
+// newBerkas describes one uploaded file. The storage key is derived from
+// the SHA-256 of the content, so the same KTP uploaded twice is stored once.
+// The stored extension and MimeType come from the detected content.
+func newBerkas(kode string, file *multipart.FileHeader) (*models.Berkas, error) {
+	mimeType, err := detectMimeType(file)
+	if err != nil {
+		return nil, fmt.Errorf("gagal membaca berkas %s: %w", file.Filename, err)
+	}
+	sum, err := checksumUpload(file)
+	if err != nil {
+		return nil, fmt.Errorf("gagal membaca berkas %s: %w", file.Filename, err)
+	}
+
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	if exts := mimeExtensions[mimeType]; exts != nil {
+		ext = exts[0]
+	}
+	newFilename := sum + ext
+
+	return &models.Berkas{
+		PersyaratanKode: kode,
+		NamaFile:        newFilename,
+		NamaAsli:        file.Filename,
+		Path:            path.Join("berkas", sum[:2], newFilename),
+		Ukuran:          file.Size,
+		MimeType:        mimeType,
+		SHA256:          sum,
+	}, nil
+}
+
+func checksumUpload(file *multipart.FileHeader) (string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, src); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// putUpload copies an uploaded file into storage under key
+func (s *permohonanService) putUpload(key string, file *multipart.FileHeader, contentType string) error {
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	return s.storage.Put(context.Background(), key, src, file.Size, contentType)
+}
+
+// scanBerkasFiles scans every upload and records the result on its Berkas.
+// If any file is infected it is quarantined and the submission is rejected.
+func (s *permohonanService) scanBerkasFiles(berkasFiles []models.Berkas, uploads []*multipart.FileHeader) error {
+	var detail []dto.BerkasErrorResponse
+
+	for i := range berkasFiles {
+		b := &berkasFiles[i]
+		result, err := scanUpload(s.scanner, uploads[i])
+		if err != nil {
+			log.Printf("Warning: gagal memindai berkas %s: %v", b.NamaAsli, err)
+			return ErrScannerTidakTersedia
+		}
+
+		now := time.Now()
+		b.ScanEngine = result.Engine
+		b.DipindaiPada = &now
+		switch {
+		case result.Engine == "":
+			b.StatusScan = models.StatusScanDilewati
+		case result.Bersih:
+			b.StatusScan = models.StatusScanBersih
+		default:
+			b.StatusScan = models.StatusScanTerinfeksi
+			b.HasilScan = result.Signature
+			log.Printf("Berkas %s terinfeksi %s", b.NamaAsli, b.HasilScan)
+			quarantineUpload(uploads[i], b.NamaFile, s.cfg.QuarantinePath)
+			detail = append(detail, dto.BerkasErrorResponse{
+				Persyaratan: b.PersyaratanKode,
+				NamaFile:    b.NamaAsli,
+				Pesan:       "berkas terdeteksi mengandung malware",
+			})
+		}
+	}
+
+	if len(detail) > 0 {
+		return &ValidasiBerkasError{Detail: detail}
+	}
+	return nil
+}
+
+func (s *permohonanService) GetAll(pagination dto.PaginationQuery) (*dto.PermohonanListResponse, error) {
+	list, total, err := s.permohonanRepo.FindAll(pagination.Page, pagination.GetLimit(), pagination.Status, pagination.Search)
+	if err != nil {
+		return nil, err
+	}
+
+	var responses []dto.PermohonanResponse
+	for _, p := range list {
+		responses = append(responses, s.mapPermohonanToResponse(p))
+	}
+
+	totalPages := int(total) / pagination.GetLimit()
+	if int(total)%pagination.GetLimit() > 0 {
+		totalPages++
+	}
+
+	return &dto.PermohonanListResponse{
+		Data:       responses,
+		Total:      total,
+		Page:       pagination.Page,
+		PerPage:    pagination.GetLimit(),
+		TotalPages: totalPages,
+	}, nil
+}
+
+func (s *permohonanService) GetByID(id uuid.UUID) (*dto.PermohonanResponse, error) {
+	p, err := s.permohonanRepo.FindByID(id)
+	if err != nil {
+		return nil, err
+	}
+	response := s.mapPermohonanToResponse(*p)
+	return &response, nil
+}
+
+func (s *permohonanService) GetByStatus(status string) ([]dto.PermohonanResponse, error) {
+	list, err := s.permohonanRepo.FindByStatus(models.StatusPermohonan(status))
+	if err != nil {
+		return nil, err
+	}
+
+	var responses []dto.PermohonanResponse
+	for _, p := range list {
+		responses = append(responses, s.mapPermohonanToResponse(p))
+	}
+	return responses, nil
+}
+
+func (s *permohonanService) Lacak(query dto.LacakPermohonanQuery) (*dto.LacakPermohonanResponse, error) {
+	// Use the same error for unknown nomor and wrong email so the endpoint
+	// cannot be used to probe which nomor permohonan exist
+	notFound := errors.New("permohonan tidak ditemukan atau email tidak sesuai")
+
+	p, err := s.permohonanRepo.FindByNomor(strings.TrimSpace(query.NomorPermohonan))
+	if err != nil {
+		return nil, notFound
+	}
+
+	if !strings.EqualFold(strings.TrimSpace(p.Pemohon.Email), strings.TrimSpace(query.Email)) {
+		return nil, notFound
+	}
+
+	return &dto.LacakPermohonanResponse{
+		NomorPermohonan:   p.NomorPermohonan,
+		JenisPerizinan:    p.JenisPerizinan.Nama,
+		Status:            string(p.Status),
+		TanggalMasuk:      p.TanggalMasuk,
+		TanggalDiproses:   p.TanggalDiproses,
+		TanggalSelesai:    p.TanggalSelesai,
+		BalasanEmail:      p.BalasanEmail,
+		PersyaratanRevisi: p.PersyaratanRevisi,
+		CatatanRevisi:     p.CatatanRevisi,
+	}, nil
+}
+
+// UpdateStatus takes a permohonan into review. Decisions are only made
+// through KirimBalasan, which also issues the surat and emails the pemohon.
+func (s *permohonanService) UpdateStatus(id uuid.UUID, adminID uuid.UUID, req dto.UpdatePermohonanStatusRequest) error {
+	target := models.StatusPermohonan(req.Status)
+	if target != models.StatusDiproses {
+		return fmt.Errorf("%w: status %s hanya dapat ditetapkan melalui balasan", ErrTransisiStatus, target)
+	}
+
+	p, err := s.permohonanRepo.FindByID(id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkTransisiStatus(p.Status, target, adminID); err != nil {
+		return err
+	}
+
+	previous := p.Status
+	applyStatus(p, target)
+	p.CatatanAdmin = req.CatatanAdmin
+	p.DikelolaOleh = &adminID
+
+	var riwayat *models.RiwayatPermohonan
+	err = s.transactor.WithTransaction(func(tx *gorm.DB) error {
+		if err := s.permohonanRepo.WithTx(tx).Update(p); err != nil {
+			return err
+		}
+		riwayat, err = s.catatRiwayat(tx, p.ID, previous, target, &adminID, req.CatatanAdmin)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.publishRiwayat(riwayat)
+
+	// Kirim email notifikasi saat status diproses
+	err = s.emailService.SendPermohonanEmail(p, NewEmailData(p, target), "")
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	return nil
+}
+
+// KirimBalasan finishes a permohonan. When staff do not upload their own
+// letter as lampiran, the official letter is generated as PDF and attached
+// to the email instead. The surat row, its nomor and the status change are
+// written in one transaction; stored files are removed if it fails.
+func (s *permohonanService) KirimBalasan(id uuid.UUID, adminID uuid.UUID, req dto.KirimBalasanRequest, lampiran *multipart.FileHeader) error {
+	p, err := s.permohonanRepo.FindByID(id)
+	if err != nil {
+		return err
+	}
+
+	target := models.StatusPermohonan(req.Status)
+	if err := s.checkTransisiStatus(p.Status, target, adminID); err != nil {
+		return err
+	}
+
+	attachmentPath := ""
+	if lampiran != nil {
+		mimeType, err := s.validateLampiran(lampiran)
+		if err != nil {
+			return err
+		}
+
+		ext := strings.ToLower(filepath.Ext(lampiran.Filename))
+		if exts := mimeExtensions[mimeType]; exts != nil {
+			ext = exts[0]
+		}
+		attachmentPath = path.Join("surat", fmt.Sprintf("%d_%s%s", time.Now().Unix(), p.ID.String()[:8], ext))
+		if err := s.putUpload(attachmentPath, lampiran, mimeType); err != nil {
+			return fmt.Errorf("gagal menyimpan file lampiran: %w", err)
+		}
+	}
+
+	// Update permohonan status
+	previous := p.Status
+	applyStatus(p, target)
+	p.BalasanEmail = req.BalasanEmail
+	p.CatatanAdmin = req.CatatanAdmin
+	p.DikelolaOleh = &adminID
+
+	// Every decision letter gets a SuratIzin record, including one uploaded
+	// by staff, so issued permits can always be verified
+	var surat *models.SuratIzin
+	var riwayat *models.RiwayatPermohonan
+	err = s.transactor.WithTransaction(func(tx *gorm.DB) error {
+		if attachmentPath == "" {
+			surat, err = s.suratService.Generate(tx, p, req.Penandatangan)
+		} else {
+			surat, err = s.suratService.CatatLampiran(tx, p, req.Penandatangan, attachmentPath)
+		}
+		if err != nil {
+			return fmt.Errorf("gagal membuat surat balasan: %w", err)
+		}
+		p.LampiranSurat = surat.Path
+		if err := s.permohonanRepo.WithTx(tx).Update(p); err != nil {
+			return err
+		}
+		riwayat, err = s.catatRiwayat(tx, p.ID, previous, target, &adminID, req.CatatanAdmin)
+		return err
+	})
+	if err != nil {
+		// Either the uploaded lampiran or the generated PDF is in storage
+		key := attachmentPath
+		if key == "" && surat != nil {
+			key = surat.Path
+		}
+		if key != "" {
+			if err := s.storage.Delete(context.Background(), key); err != nil && !errors.Is(err, ErrFileTidakDitemukan) {
+				log.Printf("Warning: gagal menghapus surat %s: %v", key, err)
+			}
+		}
+		return err
+	}
+
+	s.publishRiwayat(riwayat)
+
+	// Send email with the letter attached
+	data := NewEmailData(p, target)
+	data.Balasan = req.BalasanEmail
+	data.NomorSurat = surat.NomorSurat
+	if surat.TokenVerifikasi != nil {
+		data.LinkVerifikasi = linkVerifikasi(s.cfg, *surat.TokenVerifikasi)
+	}
+	err = s.emailService.SendPermohonanEmail(p, data, surat.Path)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	return nil
+}
+
+// validateLampiran applies the rules for applicant uploads (allowed content
+// types, matching extension and size limit) and the malware scan to a letter
+// uploaded by staff, and returns its detected content type
+func (s *permohonanService) validateLampiran(lampiran *multipart.FileHeader) (string, error) {
+	persyaratan := models.PersyaratanList{{Kode: "lampiran", Nama: "Lampiran surat", Wajib: true}}
+	files := map[string][]*multipart.FileHeader{"lampiran": {lampiran}}
+	if err := validateBerkas(persyaratan, files, batasUnggahFromConfig(s.cfg)); err != nil {
+		return "", err
+	}
+	if err := s.scanLampiran(lampiran); err != nil {
+		return "", err
+	}
+	return detectMimeType(lampiran)
+}
+
+// scanLampiran checks a letter uploaded by staff before it is stored
+func (s *permohonanService) scanLampiran(lampiran *multipart.FileHeader) error {
+	result, err := scanUpload(s.scanner, lampiran)
+	if err != nil {
+		log.Printf("Warning: gagal memindai lampiran %s: %v", lampiran.Filename, err)
+		return ErrScannerTidakTersedia
+	}
+	if !result.Bersih {
+		quarantineUpload(lampiran, filepath.Base(lampiran.Filename), s.cfg.QuarantinePath)
+		return &ValidasiBerkasError{Detail: []dto.BerkasErrorResponse{{
+			Persyaratan: "lampiran",
+			NamaFile:    lampiran.Filename,
+			Pesan:       "berkas terdeteksi mengandung malware",
+		}}}
+	}
+	return nil
+}
+
+func (s *permohonanService) GetSuratTerbaru(id uuid.UUID) (*models.SuratIzin, error) {
+	return s.suratService.GetTerbaru(id)
+}
+
+func (s *permohonanService) MintaRevisi(id uuid.UUID, adminID uuid.UUID, req dto.KirimRevisiRequest) error {
+	p, err := s.permohonanRepo.FindByID(id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.checkTransisiStatus(p.Status, models.StatusPerluRevisi, adminID); err != nil {
+		return err
+	}
+
+	// Only persyaratan of this jenis perizinan can be marked as deficient
+	var persyaratanRevisi models.StringArray
+	for _, item := range req.Persyaratan {
+		syarat, ok := p.JenisPerizinan.Persyaratan.Find(item)
+		if !ok {
+			return fmt.Errorf("persyaratan '%s' tidak terdaftar pada %s", item, p.JenisPerizinan.Nama)
+		}
+		persyaratanRevisi = append(persyaratanRevisi, syarat.Nama)
+	}
+
+	token, err := generateToken()
+	if err != nil {
+		return errors.New("gagal membuat token revisi")
+	}
+
+	expiryHours, err := strconv.Atoi(s.cfg.RevisiExpiryHours)
+	if err != nil || expiryHours <= 0 {
+		expiryHours = 168
+	}
+	expiresAt := time.Now().Add(time.Duration(expiryHours) * time.Hour)
+
+	previous := p.Status
+	applyStatus(p, models.StatusPerluRevisi)
+	p.PersyaratanRevisi = persyaratanRevisi
+	p.CatatanRevisi = req.CatatanRevisi
+	p.CatatanAdmin = req.CatatanAdmin
+	p.TokenRevisi = hashToken(token)
+	p.TokenRevisiKadaluarsa = &expiresAt
+	p.DikelolaOleh = &adminID
+
+	var riwayat *models.RiwayatPermohonan
+	err = s.transactor.WithTransaction(func(tx *gorm.DB) error {
+		if err := s.permohonanRepo.WithTx(tx).Update(p); err != nil {
+			return err
+		}
+		riwayat, err = s.catatRiwayat(tx, p.ID, previous, models.StatusPerluRevisi, &adminID, req.CatatanRevisi)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.publishRiwayat(riwayat)
+
+	data := NewEmailData(p, models.StatusPerluRevisi)
+	data.PersyaratanRevisi = persyaratanRevisi
+	data.CatatanRevisi = req.CatatanRevisi
+	data.LinkRevisi = fmt.Sprintf("%s/permohonan/revisi?token=%s", strings.TrimRight(s.cfg.FrontendURL, "/"), PenandaRahasia)
+	data.rahasia = token
+	data.BatasRevisi = expiresAt.Format("02-01-2006 15:04")
+
+	err = s.emailService.SendPermohonanEmail(p, data, "")
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	return nil
+}
+
+// findByTokenRevisi resolves a revision link to a permohonan that is still
+// waiting for the applicant's re-upload
+func (s *permohonanService) findByTokenRevisi(token string) (*models.Permohonan, error) {
+	invalid := errors.New("tautan revisi tidak valid atau sudah kadaluarsa")
+
+	if token == "" {
+		return nil, invalid
+	}
+
+	p, err := s.permohonanRepo.FindByTokenRevisi(hashToken(token))
+	if err != nil {
+		return nil, invalid
+	}
+
+	if p.Status != models.StatusPerluRevisi || p.TokenRevisiKadaluarsa == nil || time.Now().After(*p.TokenRevisiKadaluarsa) {
+		return nil, invalid
+	}
+
+	return p, nil
+}
+
+func (s *permohonanService) GetRevisiInfo(token string) (*dto.RevisiInfoResponse, error) {
+	p, err := s.findByTokenRevisi(token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.RevisiInfoResponse{
+		NomorPermohonan:   p.NomorPermohonan,
+		NamaPemohon:       p.Pemohon.NamaLengkap,
+		JenisPerizinan:    p.JenisPerizinan.Nama,
+		PersyaratanRevisi: p.PersyaratanRevisi,
+		Berkas:            toPersyaratanItems(persyaratanRevisi(p)),
+		CatatanRevisi:     p.CatatanRevisi,
+		BerlakuSampai:     *p.TokenRevisiKadaluarsa,
+	}, nil
+}
+
+// persyaratanRevisi returns the requirements the applicant has to upload
+// again; all of them are mandatory for the revision
+func persyaratanRevisi(p *models.Permohonan) models.PersyaratanList {
+	var list models.PersyaratanList
+	for _, nama := range p.PersyaratanRevisi {
+		item, ok := p.JenisPerizinan.Persyaratan.Find(nama)
+		if !ok {
+			// Requirement was renamed or removed after the request
+			item = models.Persyaratan{Kode: models.KodePersyaratan(nama), Nama: nama}
+		}
+		item.Wajib = true
+		list = append(list, item)
+	}
+	return list
+}
+
+func (s *permohonanService) UploadRevisi(token string, files map[string][]*multipart.FileHeader) error {
+	p, err := s.findByTokenRevisi(token)
+	if err != nil {
+		return err
+	}
+
+	persyaratan := persyaratanRevisi(p)
+	if err := validateBerkas(persyaratan, files, batasUnggahFromConfig(s.cfg)); err != nil {
+		return err
+	}
+
+	unggahan, err := s.siapkanBerkasFiles(persyaratan, files)
+	if err != nil {
+		return err
+	}
+
+	var riwayat *models.RiwayatPermohonan
+	var disimpan []string
+	err = s.transactor.WithTransaction(func(tx *gorm.DB) error {
+		berkasFiles, err := s.simpanBerkasFiles(tx, unggahan, &disimpan)
+		if err != nil {
+			return err
+		}
+		for i := range berkasFiles {
+			berkasFiles[i].PermohonanID = p.ID
+			if err := s.berkasRepo.WithTx(tx).Create(&berkasFiles[i]); err != nil {
+				return fmt.Errorf("gagal menyimpan berkas revisi: %w", err)
+			}
+		}
+
+		// Back to the review queue; applyStatus also invalidates the token
+		applyStatus(p, models.StatusDiproses)
+		if err := s.permohonanRepo.WithTx(tx).Update(p); err != nil {
+			return err
+		}
+
+		riwayat, err = s.catatRiwayat(tx, p.ID, models.StatusPerluRevisi, models.StatusDiproses, nil,
+			fmt.Sprintf("Pemohon mengunggah %d berkas revisi", len(berkasFiles)))
+		return err
+	})
+	if err != nil {
+		s.hapusBerkasGagal(disimpan)
+		return err
+	}
+
+	s.publishRiwayat(riwayat)
+
+	go s.notifService.NotifyAdmins(p.ID, p.JenisPerizinanID, models.EventRevisiDiunggah,
+		fmt.Sprintf("Revisi berkas dari %s - %s", p.Pemohon.NamaLengkap, p.JenisPerizinan.Nama))
+
+	return nil
+}
+
+// catatRiwayat appends an entry to the status timeline of a permohonan. It
+// runs in the transaction of the status change, so a status is never stored
+// without its history entry.
+func (s *permohonanService) catatRiwayat(tx *gorm.DB, permohonanID uuid.UUID, dari, ke models.StatusPermohonan, adminID *uuid.UUID, catatan string) (*models.RiwayatPermohonan, error) {
+	riwayat := &models.RiwayatPermohonan{
+		PermohonanID: permohonanID,
+		StatusDari:   dari,
+		StatusKe:     ke,
+		AdminID:      adminID,
+		Catatan:      catatan,
+		Tanggal:      time.Now(),
+	}
+	if err := s.riwayatRepo.WithTx(tx).Create(riwayat); err != nil {
+		return nil, fmt.Errorf("gagal mencatat riwayat permohonan: %w", err)
+	}
+	return riwayat, nil
+}
+
+// publishRiwayat broadcasts a committed status change to all dashboards
+func (s *permohonanService) publishRiwayat(riwayat *models.RiwayatPermohonan) {
+	s.broker.Publish(EventTypeStatus, nil, dto.PermohonanStatusEvent{
+		PermohonanID: riwayat.PermohonanID,
+		StatusDari:   string(riwayat.StatusDari),
+		StatusKe:     string(riwayat.StatusKe),
+		AdminID:      riwayat.AdminID,
+		Tanggal:      riwayat.Tanggal,
+	})
+}
+
+// checkTransisiStatus validates a status change against transisiStatus for
+// the permissions of the acting admin
+func (s *permohonanService) checkTransisiStatus(from, to models.StatusPermohonan, adminID uuid.UUID) error {
+	admin, err := s.adminRepo.FindByID(adminID)
+	if err != nil {
+		return errors.New("admin tidak ditemukan")
+	}
+	izin, err := s.roleService.IzinRole(admin.Role)
+	if err != nil {
+		return err
+	}
+
+	next := NextStatuses(from, izin)
+	for _, status := range next {
+		if status == to {
+			return nil
+		}
+	}
+
+	allowed := make([]string, 0, len(next))
+	for _, status := range next {
+		allowed = append(allowed, string(status))
+	}
+	if len(allowed) == 0 {
+		return fmt.Errorf("%w: status %s tidak dapat diubah", ErrTransisiStatus, from)
+	}
+	return fmt.Errorf("%w: dari %s ke %s, status berikutnya yang diizinkan: %s",
+		ErrTransisiStatus, from, to, strings.Join(allowed, ", "))
+}
+
+func (s *permohonanService) GetStatistik() (*dto.StatistikDashboard, error) {
+	counts, err := s.permohonanRepo.CountByStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.StatistikDashboard{
+		TotalPermohonan:       counts["total"],
+		PermohonanBaru:        counts["baru"],
+		PermohonanDiproses:    counts["diproses"],
+		PermohonanSelesai:     counts["selesai"],
+		PermohonanDisetujui:   counts["disetujui"],
+		PermohonanDitolak:     counts["ditolak"],
+		PermohonanPerluRevisi: counts["perlu_revisi"],
+	}, nil
+}
+
+func (s *permohonanService) GetRecentPermohonan(limit int) ([]dto.PermohonanResponse, error) {
+	list, err := s.permohonanRepo.GetRecentPermohonan(limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var responses []dto.PermohonanResponse
+	for _, p := range list {
+		responses = append(responses, s.mapPermohonanToResponse(p))
+	}
+	return responses, nil
+}
+
+func (s *permohonanService) mapPermohonanToResponse(p models.Permohonan) dto.PermohonanResponse {
+	var berkasResponses []dto.BerkasResponse
+	for _, b := range p.Berkas {
+		berkasResponses = append(berkasResponses, dto.BerkasResponse{
+			ID:              b.ID,
+			PersyaratanKode: b.PersyaratanKode,
+			NamaFile:        b.NamaFile,
+			NamaAsli:        b.NamaAsli,
+			Path:            b.Path,
+			Ukuran:          b.Ukuran,
+			MimeType:        b.MimeType,
+			SHA256:          b.SHA256,
+			StatusScan:      string(b.StatusScan),
+			HasilScan:       b.HasilScan,
+			AdaPratinjau:    b.PratinjauPath != "",
+			CreatedAt:       b.CreatedAt,
+		})
+	}
+
+	var riwayatResponses []dto.RiwayatPermohonanResponse
+	for _, r := range p.Riwayat {
+		namaAdmin := ""
+		if r.Admin != nil {
+			namaAdmin = r.Admin.NamaLengkap
+		}
+		riwayatResponses = append(riwayatResponses, dto.RiwayatPermohonanResponse{
+			ID:         r.ID,
+			StatusDari: string(r.StatusDari),
+			StatusKe:   string(r.StatusKe),
+			AdminID:    r.AdminID,
+			NamaAdmin:  namaAdmin,
+			Catatan:    r.Catatan,
+			Tanggal:    r.Tanggal,
+		})
+	}
+
+	var suratResponses []dto.SuratIzinResponse
+	for _, surat := range p.Surat {
+		suratResponses = append(suratResponses, dto.SuratIzinResponse{
+			ID:                   surat.ID,
+			NomorSurat:           surat.NomorSurat,
+			Jenis:                string(surat.Jenis),
+			TanggalSurat:         surat.TanggalSurat,
+			Penandatangan:        surat.Penandatangan,
+			JabatanPenandatangan: surat.JabatanPenandatangan,
+			Path:                 surat.Path,
+			Dicabut:              surat.Dicabut,
+		})
+	}
+
+	return dto.PermohonanResponse{
+		ID:              p.ID,
+		NomorPermohonan: p.NomorPermohonan,
+		Pemohon: dto.PemohonResponse{
+			ID:           p.Pemohon.ID,
+			NamaLengkap:  p.Pemohon.NamaLengkap,
+			NomorTelepon: p.Pemohon.NomorTelepon,
+			Email:        p.Pemohon.Email,
+			Alamat:       p.Pemohon.Alamat,
+		},
+		JenisPerizinan: dto.JenisPerizinanResponse{
+			ID:          p.JenisPerizinan.ID,
+			Nama:        p.JenisPerizinan.Nama,
+			Kode:        p.JenisPerizinan.Kode,
+			Deskripsi:   p.JenisPerizinan.Deskripsi,
+			Persyaratan: toPersyaratanItems(p.JenisPerizinan.Persyaratan),
+			Aktif:       p.JenisPerizinan.Aktif,
+			CreatedAt:   p.JenisPerizinan.CreatedAt,
+		},
+		Berkas:            berkasResponses,
+		Catatan:           p.Catatan,
+		Status:            string(p.Status),
+		TanggalMasuk:      p.TanggalMasuk,
+		TanggalDiproses:   p.TanggalDiproses,
+		TanggalSelesai:    p.TanggalSelesai,
+		BalasanEmail:      p.BalasanEmail,
+		CatatanAdmin:      p.CatatanAdmin,
+		LampiranSurat:     p.LampiranSurat,
+		PersyaratanRevisi: p.PersyaratanRevisi,
+		CatatanRevisi:     p.CatatanRevisi,
+		Riwayat:           riwayatResponses,
+		Surat:             suratResponses,
+		CreatedAt:         p.CreatedAt,
+	}
+}
+
+// ============== Notifikasi Service ==============
+
+type NotifikasiService interface {
+	GetByAdminID(adminID uuid.UUID, unreadOnly bool) ([]dto.NotifikasiResponse, error)
+	MarkAsRead(id uuid.UUID) error
+	MarkAllAsRead(adminID uuid.UUID) error
+	CountUnread(adminID uuid.UUID) (int64, error)
+	NotifyAdmins(permohonanID, jenisPerizinanID uuid.UUID, event models.EventNotifikasi, pesan string)
+	GetPreferensi(adminID uuid.UUID) (*dto.PreferensiNotifikasiResponse, error)
+	UpdatePreferensi(adminID uuid.UUID, req dto.PreferensiNotifikasiRequest) (*dto.PreferensiNotifikasiResponse, error)
+}
+
+type notifikasiService struct {
+	repo      repositories.NotifikasiRepository
+	prefRepo  repositories.PreferensiNotifikasiRepository
+	adminRepo repositories.AdminRepository
+	jpRepo    repositories.JenisPerizinanRepository
+	broker    EventBroker
+}
+
+func NewNotifikasiService(
+	repo repositories.NotifikasiRepository,
+	prefRepo repositories.PreferensiNotifikasiRepository,
+	adminRepo repositories.AdminRepository,
+	jpRepo repositories.JenisPerizinanRepository,
+	broker EventBroker,
+) NotifikasiService {
+	return &notifikasiService{repo: repo, prefRepo: prefRepo, adminRepo: adminRepo, jpRepo: jpRepo, broker: broker}
+}
+
+// NotifyAdmins creates a notification for every active admin whose
+// preferences accept the jenis perizinan and event. Admins without stored
+// preferences receive everything.
+func (s *notifikasiService) NotifyAdmins(permohonanID, jenisPerizinanID uuid.UUID, event models.EventNotifikasi, pesan string) {
+	admins, err := s.adminRepo.FindAllActive()
+	if err != nil {
+		log.Printf("Warning: gagal mengambil daftar admin untuk notifikasi: %v", err)
+		return
+	}
+
+	// Without preferences admins who opted out would be notified anyway
+	prefs, err := s.prefRepo.FindAll()
+	if err != nil {
+		log.Printf("Warning: gagal mengambil preferensi notifikasi: %v", err)
+		return
+	}
+	prefByAdmin := make(map[uuid.UUID]models.PreferensiNotifikasi, len(prefs))
+	for _, pref := range prefs {
+		prefByAdmin[pref.AdminID] = pref
+	}
+
+	now := time.Now()
+	for _, admin := range admins {
+		if pref, ok := prefByAdmin[admin.ID]; ok && !pref.Accepts(jenisPerizinanID, event) {
+			continue
+		}
+
+		notif := &models.Notifikasi{
+			AdminID:      admin.ID,
+			PermohonanID: permohonanID,
+			Event:        event,
+			Pesan:        pesan,
+			Dibaca:       false,
+			Tanggal:      now,
+		}
+		if err := s.repo.Create(notif); err != nil {
+			log.Printf("Warning: gagal membuat notifikasi untuk admin %s: %v", admin.Username, err)
+			continue
+		}
+
+		adminID := admin.ID
+		s.broker.Publish(EventTypeNotifikasi, &adminID, toNotifikasiResponse(notif))
+	}
+}
+
+func (s *notifikasiService) GetPreferensi(adminID uuid.UUID) (*dto.PreferensiNotifikasiResponse, error) {
+	pref, err := s.prefRepo.FindByAdminID(adminID)
+	if err != nil {
+		// No stored preferences yet: subscribed to everything
+		pref = &models.PreferensiNotifikasi{AdminID: adminID}
+	}
+	return toPreferensiResponse(pref), nil
+}
+
+func (s *notifikasiService) UpdatePreferensi(adminID uuid.UUID, req dto.PreferensiNotifikasiRequest) (*dto.PreferensiNotifikasiResponse, error) {
+	for _, idStr := range req.JenisPerizinanID {
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			return nil, fmt.Errorf("jenis perizinan ID tidak valid: %s", idStr)
+		}
+		if _, err := s.jpRepo.FindByID(id); err != nil {
+			return nil, fmt.Errorf("jenis perizinan tidak ditemukan: %s", idStr)
+		}
+	}
+
+	pref, err := s.prefRepo.FindByAdminID(adminID)
+	if err != nil {
+		pref = &models.PreferensiNotifikasi{AdminID: adminID}
+	}
+	pref.JenisPerizinanID = req.JenisPerizinanID
+	pref.Event = req.Event
+
+	if err := s.prefRepo.Save(pref); err != nil {
+		return nil, err
+	}
+	return toPreferensiResponse(pref), nil
+}
+
+func toPreferensiResponse(pref *models.PreferensiNotifikasi) *dto.PreferensiNotifikasiResponse {
+	tersedia := make([]string, 0, len(models.EventNotifikasiList))
+	for _, event := range models.EventNotifikasiList {
+		tersedia = append(tersedia, string(event))
+	}
+
+	jenis := []string(pref.JenisPerizinanID)
+	if jenis == nil {
+		jenis = []string{}
+	}
+	events := []string(pref.Event)
+	if events == nil {
+		events = []string{}
+	}
+
+	return &dto.PreferensiNotifikasiResponse{
+		JenisPerizinanID: jenis,
+		Event:            events,
+		EventTersedia:    tersedia,
+	}
+}
+
+func (s *notifikasiService) GetByAdminID(adminID uuid.UUID, unreadOnly bool) ([]dto.NotifikasiResponse, error) {
+	list, err := s.repo.FindByAdminID(adminID, unreadOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	var responses []dto.NotifikasiResponse
+	for _, n := range list {
+		responses = append(responses, toNotifikasiResponse(&n))
+	}
+	return responses, nil
+}
+
+func toNotifikasiResponse(n *models.Notifikasi) dto.NotifikasiResponse {
+	return dto.NotifikasiResponse{
+		ID:           n.ID,
+		PermohonanID: n.PermohonanID,
+		Event:        string(n.Event),
+		Pesan:        n.Pesan,
+		Dibaca:       n.Dibaca,
+		Tanggal:      n.Tanggal,
+	}
+}
+
+func (s *notifikasiService) MarkAsRead(id uuid.UUID) error {
+	return s.repo.MarkAsRead(id)
+}
+
+func (s *notifikasiService) MarkAllAsRead(adminID uuid.UUID) error {
+	return s.repo.MarkAllAsRead(adminID)
+}
+
+func (s *notifikasiService) CountUnread(adminID uuid.UUID) (int64, error) {
+	return s.repo.CountUnread(adminID)
+}
+
+// ============== Surat Service ==============
+
+type SuratService interface {
+	// Generate numbers, renders and stores the izin/penolakan letter for a
+	// finished permohonan inside the caller's transaction. An empty
+	// penandatangan uses the configured signer. The PDF is already in storage
+	// when Generate returns; if the transaction rolls back afterwards the
+	// caller deletes surat.Path.
+	Generate(tx *gorm.DB, p *models.Permohonan, penandatangan string) (*models.SuratIzin, error)
+	// CatatLampiran records a letter uploaded by staff the same way, without
+	// rendering it. The QR code cannot be printed on it, so the verification
+	// link is sent in the email instead.
+	CatatLampiran(tx *gorm.DB, p *models.Permohonan, penandatangan, lampiranPath string) (*models.SuratIzin, error)
+	GetTerbaru(permohonanID uuid.UUID) (*models.SuratIzin, error)
+	Verifikasi(token string) (*dto.VerifikasiSuratResponse, error)
+	Cabut(id uuid.UUID, alasan string) error
+}
+
+type suratService struct {
+	repo          repositories.SuratIzinRepository
+	nomorUrutRepo repositories.NomorUrutRepository
+	storage       Storage
+	cfg           *config.Config
+}
+
+func NewSuratService(repo repositories.SuratIzinRepository, nomorUrutRepo repositories.NomorUrutRepository, storage Storage, cfg *config.Config) SuratService {
+	return &suratService{repo: repo, nomorUrutRepo: nomorUrutRepo, storage: storage, cfg: cfg}
+}
+
+var bulanIndonesia = []string{
+	"Januari", "Februari", "Maret", "April", "Mei", "Juni",
+	"Juli", "Agustus", "September", "Oktober", "November", "Desember",
+}
+
+var bulanRomawi = []string{"I", "II", "III", "IV", "V", "VI", "VII", "VIII", "IX", "X", "XI", "XII"}
+
+func formatTanggalIndonesia(t time.Time) string {
+	return fmt.Sprintf("%d %s %d", t.Day(), bulanIndonesia[t.Month()-1], t.Year())
+}
+
+func (s *suratService) Generate(tx *gorm.DB, p *models.Permohonan, penandatangan string) (*models.SuratIzin, error) {
+	surat, err := s.buatSurat(tx, p, penandatangan)
+	if err != nil {
+		return nil, err
+	}
+	surat.Path = path.Join("surat", fmt.Sprintf("%d_%s_%s.pdf", surat.TanggalSurat.Unix(), p.ID.String()[:8], surat.Jenis))
+
+	var buf bytes.Buffer
+	if err := s.renderPDF(&buf, p, surat); err != nil {
+		return nil, err
+	}
+	if err := s.storage.Put(context.Background(), surat.Path, &buf, int64(buf.Len()), "application/pdf"); err != nil {
+		return nil, fmt.Errorf("gagal menyimpan surat: %w", err)
+	}
+
+	if err := s.terbitkan(tx, surat); err != nil {
+		s.storage.Delete(context.Background(), surat.Path)
+		return nil, err
+	}
+	return surat, nil
+}
+
+func (s *suratService) CatatLampiran(tx *gorm.DB, p *models.Permohonan, penandatangan, lampiranPath string) (*models.SuratIzin, error) {
+	surat, err := s.buatSurat(tx, p, penandatangan)
+	if err != nil {
+		return nil, err
+	}
+	surat.Path = lampiranPath
+
+	if err := s.terbitkan(tx, surat); err != nil {
+		return nil, err
+	}
+	return surat, nil
+}
+
+// buatSurat numbers a new letter for the decision on p. Issued permits get
+// a verification token.
+func (s *suratService) buatSurat(tx *gorm.DB, p *models.Permohonan, penandatangan string) (*models.SuratIzin, error) {
+	jenis := models.JenisSuratIzin
+	if p.Status == models.StatusDitolak {
+		jenis = models.JenisSuratPenolakan
+	} else if p.Status != models.StatusDisetujui {
+		return nil, fmt.Errorf("surat tidak dapat dibuat untuk status %s", p.Status)
+	}
+
+	if penandatangan == "" {
+		penandatangan = s.cfg.SuratPenandatangan
+	}
+
+	now := time.Now()
+	nomorSurat, err := s.nextNomorSurat(s.nomorUrutRepo.WithTx(tx), now)
+	if err != nil {
+		return nil, fmt.Errorf("gagal membuat nomor surat: %w", err)
+	}
+
+	surat := &models.SuratIzin{
+		PermohonanID:         p.ID,
+		NomorSurat:           nomorSurat,
+		Jenis:                jenis,
+		TanggalSurat:         now,
+		Penandatangan:        penandatangan,
+		JabatanPenandatangan: s.cfg.SuratJabatan,
+		NIPPenandatangan:     s.cfg.SuratNIP,
+	}
+
+	// Only issued permits need to be verifiable by third parties
+	if jenis == models.JenisSuratIzin {
+		token, err := generateToken()
+		if err != nil {
+			return nil, errors.New("gagal membuat token verifikasi")
+		}
+		surat.TokenVerifikasi = &token
+	}
+	return surat, nil
+}
+
+// terbitkan stores a new letter and revokes the permits issued earlier for
+// the same permohonan, e.g. before it was reopened, so only the newest
+// decision verifies as valid
+func (s *suratService) terbitkan(tx *gorm.DB, surat *models.SuratIzin) error {
+	repo := s.repo.WithTx(tx)
+	alasan := fmt.Sprintf("Digantikan oleh surat nomor %s", surat.NomorSurat)
+	if err := repo.CabutByPermohonanID(surat.PermohonanID, alasan, surat.TanggalSurat); err != nil {
+		return fmt.Errorf("gagal mencabut surat sebelumnya: %w", err)
+	}
+	return repo.Create(surat)
+}
+
+func (s *suratService) GetTerbaru(permohonanID uuid.UUID) (*models.SuratIzin, error) {
+	surat, err := s.repo.FindLatestByPermohonanID(permohonanID)
+	if err != nil {
+		return nil, errors.New("surat belum dibuat untuk permohonan ini")
+	}
+	return surat, nil
+}
+
+func (s *suratService) Verifikasi(token string) (*dto.VerifikasiSuratResponse, error) {
+	surat, err := s.repo.FindByTokenVerifikasi(token)
+	if err != nil || surat.Permohonan == nil {
+		return nil, errors.New("surat tidak ditemukan, dokumen tidak dapat diverifikasi")
+	}
+
+	p := surat.Permohonan
+	response := &dto.VerifikasiSuratResponse{
+		NomorSurat:      surat.NomorSurat,
+		NomorPermohonan: p.NomorPermohonan,
+		NamaPemohon:     p.Pemohon.NamaLengkap,
+		JenisPerizinan:  p.JenisPerizinan.Nama,
+		TanggalTerbit:   surat.TanggalSurat,
+		Penandatangan:   surat.Penandatangan,
+		Berlaku:         true,
+		Keterangan:      "Surat izin asli dan masih berlaku",
+	}
+
+	switch {
+	case surat.Dicabut:
+		response.Berlaku = false
+		response.Keterangan = "Surat izin telah dicabut"
+		response.DicabutPada = surat.DicabutPada
+		response.AlasanPencabutan = surat.AlasanPencabutan
+	case p.Status != models.StatusDisetujui:
+		// The permohonan was reopened after this letter was issued
+		response.Berlaku = false
+		response.Keterangan = "Surat izin tidak berlaku lagi karena permohonan sedang ditinjau ulang"
+	}
+
+	return response, nil
+}
+
+func (s *suratService) Cabut(id uuid.UUID, alasan string) error {
+	surat, err := s.repo.FindByID(id)
+	if err != nil {
+		return errors.New("surat tidak ditemukan")
+	}
+	if surat.Jenis != models.JenisSuratIzin {
+		return errors.New("hanya surat izin yang dapat dicabut")
+	}
+	if surat.Dicabut {
+		return errors.New("surat sudah dicabut")
+	}
+
+	now := time.Now()
+	surat.Dicabut = true
+	surat.DicabutPada = &now
+	surat.AlasanPencabutan = alasan
+	return s.repo.Update(surat)
+}
+
+// linkVerifikasi is the public address behind the QR code of a letter
+func linkVerifikasi(cfg *config.Config, token string) string {
+	return fmt.Sprintf("%s/verifikasi/%s", strings.TrimRight(cfg.APIBaseURL, "/"), token)
+}
+
+// nextNomorSurat returns e.g. 440/007/PSDK/X/2026. The sequence restarts
+// every year.
+func (s *suratService) nextNomorSurat(nomorUrutRepo repositories.NomorUrutRepository, now time.Time) (string, error) {
+	urut, err := nomorUrutRepo.Next(fmt.Sprintf("surat-%d", now.Year()))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%03d/%s/%s/%d",
+		s.cfg.SuratKodeKlasifikasi, urut, s.cfg.SuratKodeUnit, bulanRomawi[now.Month()-1], now.Year()), nil
+}
+
+// renderPDF draws the letter on A4 with the Dinas Kesehatan letterhead
+func (s *suratService) renderPDF(w io.Writer, p *models.Permohonan, surat *models.SuratIzin) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(25, 20, 25)
+	pdf.SetAutoPageBreak(true, 20)
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pageWidth, _ := pdf.GetPageSize()
+	left, _, right, _ := pdf.GetMargins()
+	contentWidth := pageWidth - left - right
+
+	// Kop surat
+	if s.cfg.SuratLogoPath != "" {
+		if _, err := os.Stat(s.cfg.SuratLogoPath); err == nil {
+			pdf.ImageOptions(s.cfg.SuratLogoPath, left, 18, 22, 0, false, gofpdf.ImageOptions{ReadDpi: true}, 0, "")
+		}
+	}
+	pdf.SetFont("Arial", "B", 14)
+	pdf.CellFormat(contentWidth, 7, "PEMERINTAH KOTA MAKASSAR", "", 1, "C", false, 0, "")
+	pdf.SetFont("Arial", "B", 16)
+	pdf.CellFormat(contentWidth, 8, "DINAS KESEHATAN", "", 1, "C", false, 0, "")
+	pdf.SetFont("Arial", "", 10)
+	pdf.CellFormat(contentWidth, 5, tr(s.cfg.SuratAlamat), "", 1, "C", false, 0, "")
+	pdf.Ln(3)
+	y := pdf.GetY()
+	pdf.SetLineWidth(0.8)
+	pdf.Line(left, y, pageWidth-right, y)
+	pdf.SetLineWidth(0.2)
+	pdf.Line(left, y+1.2, pageWidth-right, y+1.2)
+	pdf.Ln(8)
+
+	// Judul dan nomor
+	judul := "SURAT IZIN"
+	if surat.Jenis == models.JenisSuratPenolakan {
+		judul = "SURAT PENOLAKAN PERMOHONAN"
+	}
+	pdf.SetFont("Arial", "BU", 13)
+	pdf.CellFormat(contentWidth, 7, judul, "", 1, "C", false, 0, "")
+	pdf.SetFont("Arial", "", 11)
+	pdf.CellFormat(contentWidth, 6, tr("Nomor: "+surat.NomorSurat), "", 1, "C", false, 0, "")
+	pdf.Ln(6)
+
+	pembuka := fmt.Sprintf("Berdasarkan permohonan %s nomor %s tanggal %s, dengan ini Dinas Kesehatan Kota Makassar ",
+		p.JenisPerizinan.Nama, p.NomorPermohonan, formatTanggalIndonesia(p.TanggalMasuk))
+	if surat.Jenis == models.JenisSuratIzin {
+		pembuka += "memberikan izin kepada:"
+	} else {
+		pembuka += "menyampaikan bahwa permohonan dari pemohon berikut tidak dapat disetujui:"
+	}
+	pdf.MultiCell(contentWidth, 6, tr(pembuka), "", "J", false)
+	pdf.Ln(2)
+
+	rows := [][2]string{
+		{"Nama", p.Pemohon.NamaLengkap},
+		{"Email", p.Pemohon.Email},
+		{"Nomor Telepon", p.Pemohon.NomorTelepon},
+		{"Alamat", p.Pemohon.Alamat},
+		{"Jenis Perizinan", p.JenisPerizinan.Nama},
+	}
+	for _, row := range rows {
+		pdf.SetX(left + 10)
+		pdf.CellFormat(40, 6, row[0], "", 0, "L", false, 0, "")
+		pdf.CellFormat(5, 6, ":", "", 0, "L", false, 0, "")
+		pdf.MultiCell(contentWidth-55, 6, tr(row[1]), "", "L", false)
+	}
+	pdf.Ln(4)
+
+	if p.BalasanEmail != "" {
+		pdf.MultiCell(contentWidth, 6, tr(p.BalasanEmail), "", "J", false)
+		pdf.Ln(4)
+	}
+
+	penutup := "Demikian surat izin ini dibuat untuk dipergunakan sebagaimana mestinya."
+	if surat.Jenis == models.JenisSuratPenolakan {
+		penutup = "Demikian surat ini disampaikan untuk diketahui."
+	}
+	pdf.MultiCell(contentWidth, 6, tr(penutup), "", "J", false)
+	pdf.Ln(10)
+
+	// QR code for verification on the left of the signature block
+	if surat.TokenVerifikasi != nil {
+		png, err := qrcode.Encode(linkVerifikasi(s.cfg, *surat.TokenVerifikasi), qrcode.Medium, 256)
+		if err != nil {
+			return fmt.Errorf("gagal membuat QR code: %w", err)
+		}
+
+		qrY := pdf.GetY()
+		pdf.RegisterImageOptionsReader("qr-verifikasi", gofpdf.ImageOptions{ImageType: "PNG"}, bytes.NewReader(png))
+		pdf.ImageOptions("qr-verifikasi", left, qrY, 30, 30, false, gofpdf.ImageOptions{ImageType: "PNG"}, 0, "")
+		pdf.SetFont("Arial", "", 7)
+		pdf.SetXY(left, qrY+31)
+		pdf.MultiCell(contentWidth/2-10, 3.5, "Pindai kode QR untuk memverifikasi keaslian surat ini", "", "L", false)
+		pdf.SetFont("Arial", "", 11)
+		pdf.SetY(qrY)
+	}
+
+	// Tanda tangan
+	signX := left + contentWidth/2 + 5
+	signWidth := contentWidth/2 - 5
+	pdf.SetX(signX)
+	pdf.CellFormat(signWidth, 6, tr("Makassar, "+formatTanggalIndonesia(surat.TanggalSurat)), "", 1, "L", false, 0, "")
+	pdf.SetX(signX)
+	pdf.MultiCell(signWidth, 6, tr(surat.JabatanPenandatangan+","), "", "L", false)
+	pdf.Ln(20)
+	pdf.SetX(signX)
+	pdf.SetFont("Arial", "BU", 11)
+	pdf.CellFormat(signWidth, 6, tr(surat.Penandatangan), "", 1, "L", false, 0, "")
+	if surat.NIPPenandatangan != "" {
+		pdf.SetFont("Arial", "", 11)
+		pdf.SetX(signX)
+		pdf.CellFormat(signWidth, 6, tr("NIP. "+surat.NIPPenandatangan), "", 1, "L", false, 0, "")
+	}
+
+	return pdf.Output(w)
+}
+
+// ============== Akses Berkas Service ==============
+
+var (
+	// ErrTautanTidakValid is returned for download links with a wrong
+	// signature, or whose admin is no longer active, signed out of the
+	// session the link was issued in or no longer allowed to download
+	ErrTautanTidakValid = errors.New("tautan unduhan tidak valid")
+	// ErrTautanKadaluarsa is returned for download links past their expiry
+	ErrTautanKadaluarsa = errors.New("tautan unduhan sudah kadaluarsa")
+)
+
+// BerkasUnduhan is a stored file resolved for download
+type BerkasUnduhan struct {
+	Key         string
+	NamaUnduh   string
+	ContentType string
+	SHA256      string // empty when no checksum was taken; see BacaTerverifikasi
+}
+
+// AksesInfo describes who downloads a file and how
+type AksesInfo struct {
+	AdminID   uuid.UUID
+	Metode    string
+	IPAddress string
+	UserAgent string
+}
+
+// BundelBerkas is a permohonan resolved for a ZIP download
+type BundelBerkas struct {
+	NamaFile   string
+	Permohonan *models.Permohonan
+}
+
+// AksesBerkasService serves applicant files by ID instead of by filename.
+// jenis is models.JenisAksesBerkas (id of a Berkas), models.JenisAksesLampiran
+// (id of the Permohonan whose letter is served) or models.JenisAksesBundel
+// (id of the Permohonan whose files are bundled, signed links only).
+type AksesBerkasService interface {
+	// Unduh resolves the stored file and records the access
+	Unduh(jenis string, id uuid.UUID, akses AksesInfo) (*BerkasUnduhan, error)
+	// BuatTautan issues a signed link that works without a bearer token
+	// until it expires, so files can be opened directly in the browser. The
+	// link is bound to the session of the admin who asked for it.
+	BuatTautan(jenis string, id uuid.UUID, adminID, sesiID uuid.UUID) (*dto.TautanUnduhResponse, error)
+	// VerifikasiTautan checks a signed link and returns the admin it was
+	// issued to. The session and the download permission of the admin are
+	// checked again, so logging out or losing the permission invalidates
+	// links already handed out.
+	VerifikasiTautan(jenis string, id uuid.UUID, adminID, sesiID, kadaluarsa, signature string) (uuid.UUID, error)
+	// BuatBundel resolves a permohonan for TulisBundel and records an access
+	// for every file in the bundle
+	BuatBundel(permohonanID uuid.UUID, akses AksesInfo) (*BundelBerkas, error)
+	// TulisBundel streams a ZIP with every berkas, the reply letter and a
+	// summary sheet to w. Files are copied one at a time and never held in
+	// memory as a whole.
+	TulisBundel(w io.Writer, bundel *BundelBerkas) error
+	GetByPermohonanID(permohonanID uuid.UUID) ([]dto.AksesBerkasResponse, error)
+}
+
+type aksesBerkasService struct {
+	aksesRepo      repositories.AksesBerkasRepository
+	berkasRepo     repositories.BerkasRepository
+	permohonanRepo repositories.PermohonanRepository
+	adminRepo      repositories.AdminRepository
+	sesiRepo       repositories.SesiAdminRepository
+	roleService    RoleService
+	storage        Storage
+	cfg            *config.Config
+}
+
+func NewAksesBerkasService(
+	aksesRepo repositories.AksesBerkasRepository,
+	berkasRepo repositories.BerkasRepository,
+	permohonanRepo repositories.PermohonanRepository,
+	adminRepo repositories.AdminRepository,
+	sesiRepo repositories.SesiAdminRepository,
+	roleService RoleService,
+	storage Storage,
+	cfg *config.Config,
+) AksesBerkasService {
+	return &aksesBerkasService{
+		aksesRepo:      aksesRepo,
+		berkasRepo:     berkasRepo,
+		permohonanRepo: permohonanRepo,
+		adminRepo:      adminRepo,
+		sesiRepo:       sesiRepo,
+		roleService:    roleService,
+		storage:        storage,
+		cfg:            cfg,
+	}
+}
+
+func (s *aksesBerkasService) Unduh(jenis string, id uuid.UUID, akses AksesInfo) (*BerkasUnduhan, error) {
+	unduhan, record, err := s.resolve(jenis, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Applicant documents are only served when the access is on record
+	if err := s.catatAkses(record, akses); err != nil {
+		return nil, err
+	}
+
+	return unduhan, nil
+}
+
+func (s *aksesBerkasService) catatAkses(record *models.AksesBerkas, akses AksesInfo) error {
+	record.AdminID = akses.AdminID
+	record.Metode = akses.Metode
+	record.IPAddress = akses.IPAddress
+	record.UserAgent = akses.UserAgent
+	if len(record.UserAgent) > 255 {
+		record.UserAgent = record.UserAgent[:255]
+	}
+	record.DiaksesPada = time.Now()
+
+	if err := s.aksesRepo.Create(record); err != nil {
+		return fmt.Errorf("gagal mencatat akses berkas: %w", err)
+	}
+	return nil
+}
+
+// resolve finds the stored file behind jenis and id, together with an
+// access record that still needs the admin and method filled in
+func (s *aksesBerkasService) resolve(jenis string, id uuid.UUID) (*BerkasUnduhan, *models.AksesBerkas, error) {
+	switch jenis {
+	case models.JenisAksesBerkas:
+		berkas, err := s.berkasRepo.FindByID(id)
+		if err != nil {
+			return nil, nil, ErrFileTidakDitemukan
+		}
+		return &BerkasUnduhan{
+			Key:         berkas.Path,
+			NamaUnduh:   berkas.NamaAsli,
+			ContentType: berkas.MimeType,
+			SHA256:      berkas.SHA256,
+		}, &models.AksesBerkas{
+			PermohonanID: berkas.PermohonanID,
+			BerkasID:     &berkas.ID,
+			Jenis:        jenis,
+			NamaFile:     berkas.NamaAsli,
+		}, nil
+
+	case models.JenisAksesPratinjau:
+		berkas, err := s.berkasRepo.FindByID(id)
+		if err != nil || berkas.PratinjauPath == "" {
+			return nil, nil, ErrFileTidakDitemukan
+		}
+		return &BerkasUnduhan{
+			Key:         berkas.PratinjauPath,
+			NamaUnduh:   strings.TrimSuffix(berkas.NamaAsli, path.Ext(berkas.NamaAsli)) + ".jpg",
+			ContentType: "image/jpeg",
+		}, &models.AksesBerkas{
+			PermohonanID: berkas.PermohonanID,
+			BerkasID:     &berkas.ID,
+			Jenis:        jenis,
+			NamaFile:     berkas.NamaAsli,
+		}, nil
+
+	case models.JenisAksesLampiran:
+		p, err := s.permohonanRepo.FindByID(id)
+		if err != nil || p.LampiranSurat == "" {
+			return nil, nil, ErrFileTidakDitemukan
+		}
+		ext := strings.ToLower(path.Ext(p.LampiranSurat))
+		nama := "Surat_Keputusan_" + p.NomorPermohonan + ext
+		return &BerkasUnduhan{
+			Key:         p.LampiranSurat,
+			NamaUnduh:   nama,
+			ContentType: mime.TypeByExtension(ext),
+		}, &models.AksesBerkas{
+			PermohonanID: p.ID,
+			Jenis:        jenis,
+			NamaFile:     nama,
+		}, nil
+
+	default:
+		return nil, nil, ErrFileTidakDitemukan
+	}
+}
+
+func (s *aksesBerkasService) BuatTautan(jenis string, id uuid.UUID, adminID, sesiID uuid.UUID) (*dto.TautanUnduhResponse, error) {
+	// Only hand out links for files that exist
+	if jenis == models.JenisAksesBundel {
+		if _, err := s.permohonanRepo.FindByID(id); err != nil {
+			return nil, ErrFileTidakDitemukan
+		}
+	} else if _, _, err := s.resolve(jenis, id); err != nil {
+		return nil, err
+	}
+
+	ttl, _ := strconv.Atoi(s.cfg.DownloadURLTTLMinutes)
+	if ttl <= 0 {
+		ttl = 5
+	}
+	kadaluarsa := time.Now().Add(time.Duration(ttl) * time.Minute)
+
+	query := url.Values{}
+	query.Set("admin", adminID.String())
+	query.Set("sesi", sesiID.String())
+	query.Set("kadaluarsa", strconv.FormatInt(kadaluarsa.Unix(), 10))
+	query.Set("signature", s.sign(jenis, id, adminID, sesiID, kadaluarsa.Unix()))
+
+	return &dto.TautanUnduhResponse{
+		URL:            fmt.Sprintf("%s/api/v1/unduh/%s/%s?%s", strings.TrimRight(s.cfg.APIBaseURL, "/"), jenis, id, query.Encode()),
+		KadaluarsaPada: kadaluarsa,
+	}, nil
+}
+
+func (s *aksesBerkasService) VerifikasiTautan(jenis string, id uuid.UUID, adminID, sesiID, kadaluarsa, signature string) (uuid.UUID, error) {
+	admin, err := uuid.Parse(adminID)
+	if err != nil {
+		return uuid.Nil, ErrTautanTidakValid
+	}
+	sesi, err := uuid.Parse(sesiID)
+	if err != nil {
+		return uuid.Nil, ErrTautanTidakValid
+	}
+	exp, err := strconv.ParseInt(kadaluarsa, 10, 64)
+	if err != nil {
+		return uuid.Nil, ErrTautanTidakValid
+	}
+
+	// The expiry is part of the signature, so it is only trusted once the
+	// signature matches
+	expected := s.sign(jenis, id, admin, sesi, exp)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return uuid.Nil, ErrTautanTidakValid
+	}
+	if time.Now().Unix() > exp {
+		return uuid.Nil, ErrTautanKadaluarsa
+	}
+
+	// Deactivating an admin, ending the session or taking the permission
+	// away also invalidates the links they still hold
+	a, err := s.adminRepo.FindByID(admin)
+	if err != nil || !a.IsActive {
+		return uuid.Nil, ErrTautanTidakValid
+	}
+	ses, err := s.sesiRepo.FindByID(sesi)
+	if err != nil || ses.AdminID != admin || ses.DicabutPada != nil || time.Now().After(ses.KadaluarsaPada) {
+		return uuid.Nil, ErrTautanTidakValid
+	}
+	izin, err := s.roleService.IzinRole(a.Role)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if !izin.Punya(models.IzinUnduhBerkas) {
+		return uuid.Nil, ErrTautanTidakValid
+	}
+
+	return admin, nil
+}
+
+func (s *aksesBerkasService) sign(jenis string, id, adminID, sesiID uuid.UUID, kadaluarsa int64) string {
+	secret := s.cfg.DownloadURLSecret
+	if secret == "" {
+		secret = s.cfg.JWTSecret
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%s:%s:%s:%s:%d", jenis, id, adminID, sesiID, kadaluarsa)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *aksesBerkasService) BuatBundel(permohonanID uuid.UUID, akses AksesInfo) (*BundelBerkas, error) {
+	p, err := s.permohonanRepo.FindByID(permohonanID)
+	if err != nil {
+		return nil, ErrFileTidakDitemukan
+	}
+
+	for _, b := range p.Berkas {
+		record := &models.AksesBerkas{
+			PermohonanID: p.ID,
+			BerkasID:     &b.ID,
+			Jenis:        models.JenisAksesBerkas,
+			NamaFile:     b.NamaAsli,
+		}
+		if err := s.catatAkses(record, akses); err != nil {
+			return nil, err
+		}
+	}
+	if p.LampiranSurat != "" {
+		_, record, err := s.resolve(models.JenisAksesLampiran, p.ID)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.catatAkses(record, akses); err != nil {
+			return nil, err
+		}
+	}
+
+	return &BundelBerkas{
+		NamaFile:   fmt.Sprintf("Berkas_%s.zip", namaZip(p.NomorPermohonan)),
+		Permohonan: p,
+	}, nil
+}
+
+// isiBundel describes one file of a bundle for the summary sheet
+type isiBundel struct {
+	Persyaratan string
+	Nama        string
+	Ukuran      int64
+	StatusScan  models.StatusScan
+	Ada         bool
+	Rusak       bool // content does not match the checksum taken at upload
+}
+
+func (s *aksesBerkasService) TulisBundel(w io.Writer, bundel *BundelBerkas) error {
+	ctx := context.Background()
+	p := bundel.Permohonan
+	zw := zip.NewWriter(w)
+	dipakai := map[string]bool{}
+	var isi []isiBundel
+
+	for _, b := range p.Berkas {
+		persyaratan := b.PersyaratanKode
+		if item, ok := p.JenisPerizinan.Persyaratan.Find(b.PersyaratanKode); ok {
+			persyaratan = item.Nama
+		}
+		if persyaratan == "" {
+			persyaratan = "Lainnya"
+		}
+
+		nama := namaUnik(path.Join("berkas", namaZip(persyaratan), namaZip(b.NamaAsli)), dipakai)
+		ada, sum, err := s.tambahFileZip(ctx, zw, b.Path, nama, b.CreatedAt)
+		if err != nil {
+			return err
+		}
+		rusak := ada && b.SHA256 != "" && sum != b.SHA256
+		if rusak {
+			log.Printf("Warning: checksum %s tidak cocok (tercatat %s, dihitung %s)", b.Path, b.SHA256, sum)
+		}
+		isi = append(isi, isiBundel{Persyaratan: persyaratan, Nama: nama, Ukuran: b.Ukuran, StatusScan: b.StatusScan, Ada: ada, Rusak: rusak})
+	}
+
+	if p.LampiranSurat != "" {
+		nama := namaUnik("Surat_Balasan"+strings.ToLower(path.Ext(p.LampiranSurat)), dipakai)
+		ada, _, err := s.tambahFileZip(ctx, zw, p.LampiranSurat, nama, p.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		isi = append(isi, isiBundel{Persyaratan: "Surat balasan", Nama: nama, Ada: ada})
+	}
+
+	// The summary goes last so it can report files missing from storage
+	fw, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     fmt.Sprintf("Ringkasan_%s.pdf", namaZip(p.NomorPermohonan)),
+		Method:   zip.Deflate,
+		Modified: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := renderRingkasan(fw, p, isi); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// tambahFileZip copies a stored file into the archive and returns the
+// SHA-256 of what was copied. A file missing from storage is skipped and
+// reported as false.
+func (s *aksesBerkasService) tambahFileZip(ctx context.Context, zw *zip.Writer, key, nama string, modified time.Time) (bool, string, error) {
+	r, _, err := s.storage.Open(ctx, key)
+	if errors.Is(err, ErrFileTidakDitemukan) || errors.Is(err, ErrKeyTidakValid) {
+		log.Printf("Warning: berkas %s tidak ditemukan saat membuat bundel", key)
+		return false, "", nil
+	}
+	if err != nil {
+		return false, "", err
+	}
+	defer r.Close()
+
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: nama, Method: zip.Deflate, Modified: modified})
+	if err != nil {
+		return false, "", err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(fw, h), r); err != nil {
+		return false, "", err
+	}
+	return true, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// renderRingkasan draws a one page overview of a permohonan for the bundle
+func renderRingkasan(w io.Writer, p *models.Permohonan, isi []isiBundel) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(20, 20, 20)
+	pdf.SetAutoPageBreak(true, 20)
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pageWidth, _ := pdf.GetPageSize()
+	left, _, right, _ := pdf.GetMargins()
+	contentWidth := pageWidth - left - right
+
+	pdf.SetFont("Arial", "B", 14)
+	pdf.CellFormat(contentWidth, 8, "RINGKASAN PERMOHONAN", "", 1, "C", false, 0, "")
+	pdf.SetFont("Arial", "", 10)
+	pdf.CellFormat(contentWidth, 6, tr("Dinas Kesehatan Kota Makassar"), "", 1, "C", false, 0, "")
+	pdf.Ln(6)
+
+	rows := [][2]string{
+		{"Nomor Permohonan", p.NomorPermohonan},
+		{"Jenis Perizinan", p.JenisPerizinan.Nama},
+		{"Status", getStatusText(string(p.Status))},
+		{"Tanggal Masuk", formatTanggalIndonesia(p.TanggalMasuk)},
+		{"Nama Pemohon", p.Pemohon.NamaLengkap},
+		{"Email", p.Pemohon.Email},
+		{"Nomor Telepon", p.Pemohon.NomorTelepon},
+		{"Alamat", p.Pemohon.Alamat},
+	}
+	if p.Catatan != "" {
+		rows = append(rows, [2]string{"Catatan Pemohon", p.Catatan})
+	}
+	if p.CatatanAdmin != "" {
+		rows = append(rows, [2]string{"Catatan Admin", p.CatatanAdmin})
+	}
+	pdf.SetFont("Arial", "", 10)
+	for _, row := range rows {
+		pdf.CellFormat(45, 6, row[0], "", 0, "L", false, 0, "")
+		pdf.CellFormat(5, 6, ":", "", 0, "L", false, 0, "")
+		pdf.MultiCell(contentWidth-50, 6, tr(row[1]), "", "L", false)
+	}
+	pdf.Ln(4)
+
+	// Files in this bundle
+	pdf.SetFont("Arial", "B", 11)
+	pdf.CellFormat(contentWidth, 7, "Isi Bundel", "", 1, "L", false, 0, "")
+	widths := []float64{50, 75, 20, contentWidth - 145}
+	pdf.SetFont("Arial", "B", 9)
+	pdf.SetFillColor(230, 230, 230)
+	for i, h := range []string{"Persyaratan", "File", "Ukuran", "Keterangan"} {
+		pdf.CellFormat(widths[i], 7, h, "1", 0, "L", true, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetFont("Arial", "", 9)
+	for _, item := range isi {
+		ukuran := "-"
+		if item.Ukuran > 0 {
+			ukuran = fmt.Sprintf("%.1f KB", float64(item.Ukuran)/1024)
+		}
+		keterangan := string(item.StatusScan)
+		switch {
+		case !item.Ada:
+			keterangan = "tidak ditemukan"
+		case item.Rusak:
+			keterangan = "checksum tidak cocok"
+		case keterangan == "":
+			keterangan = "-"
+		}
+		pdf.CellFormat(widths[0], 6, tr(potongTeks(item.Persyaratan, 30)), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[1], 6, tr(potongTeks(path.Base(item.Nama), 45)), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[2], 6, ukuran, "1", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[3], 6, tr(keterangan), "1", 1, "L", false, 0, "")
+	}
+	pdf.Ln(4)
+
+	// Status history
+	if len(p.Riwayat) > 0 {
+		pdf.SetFont("Arial", "B", 11)
+		pdf.CellFormat(contentWidth, 7, "Riwayat Status", "", 1, "L", false, 0, "")
+		pdf.SetFont("Arial", "", 9)
+		for _, r := range p.Riwayat {
+			baris := fmt.Sprintf("%s  %s", r.Tanggal.Format("02-01-2006 15:04"), getStatusText(string(r.StatusKe)))
+			if r.Admin != nil {
+				baris += " oleh " + r.Admin.NamaLengkap
+			}
+			if r.Catatan != "" {
+				baris += " - " + r.Catatan
+			}
+			pdf.MultiCell(contentWidth, 5, tr(baris), "", "L", false)
+		}
+	}
+
+	pdf.Ln(4)
+	pdf.SetFont("Arial", "I", 8)
+	pdf.CellFormat(contentWidth, 5, tr("Dibuat "+time.Now().Format("02-01-2006 15:04")), "", 1, "R", false, 0, "")
+
+	return pdf.Output(w)
+}
+
+// namaZip makes a file or folder name safe to use inside a ZIP archive
+func namaZip(nama string) string {
+	nama = strings.Map(func(r rune) rune {
+		if r < 32 || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, nama)
+	nama = strings.Trim(nama, ". ")
+	if nama == "" {
+		return "berkas"
+	}
+	return nama
+}
+
+// namaUnik appends " (2)", " (3)", ... until nama is not in dipakai yet
+func namaUnik(nama string, dipakai map[string]bool) string {
+	ext := path.Ext(nama)
+	base := strings.TrimSuffix(nama, ext)
+	hasil := nama
+	for i := 2; dipakai[hasil]; i++ {
+		hasil = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+	dipakai[hasil] = true
+	return hasil
+}
+
+func potongTeks(teks string, maks int) string {
+	r := []rune(teks)
+	if len(r) <= maks {
+		return teks
+	}
+	return string(r[:maks-3]) + "..."
+}
+
+func (s *aksesBerkasService) GetByPermohonanID(permohonanID uuid.UUID) ([]dto.AksesBerkasResponse, error) {
+	list, err := s.aksesRepo.FindByPermohonanID(permohonanID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := []dto.AksesBerkasResponse{}
+	for _, a := range list {
+		resp := dto.AksesBerkasResponse{
+			ID:           a.ID,
+			PermohonanID: a.PermohonanID,
+			BerkasID:     a.BerkasID,
+			Jenis:        a.Jenis,
+			NamaFile:     a.NamaFile,
+			AdminID:      a.AdminID,
+			Metode:       a.Metode,
+			IPAddress:    a.IPAddress,
+			UserAgent:    a.UserAgent,
+			DiaksesPada:  a.DiaksesPada,
+		}
+		if a.Admin != nil {
+			resp.NamaAdmin = a.Admin.NamaLengkap
+		}
+		responses = append(responses, resp)
+	}
+	return responses, nil
+}
+
+// ============== Event Broker ==============
+
+const (
+	EventTypeNotifikasi = "notifikasi"
+	EventTypeStatus     = "status"
+)
+
+// Event is a message pushed to connected admin dashboards. A nil AdminID
+// means the event is broadcast to every admin.
+type Event struct {
+	ID      string // "<epoch>-<seq>", see eventBroker
+	Type    string
+	AdminID *uuid.UUID
+	Data    interface{}
+	seq     uint64
+}
+
+// EventBroker fans out events to subscribers in this process and keeps a
+// short backlog so reconnecting clients can resume from Last-Event-ID.
+type EventBroker interface {
+	Publish(eventType string, adminID *uuid.UUID, data interface{})
+	// Subscribe returns the missed events after lastEventID, a channel for
+	// new events and a function that must be called to unsubscribe. The
+	// channel is closed when the subscriber is too slow to keep up.
+	Subscribe(adminID uuid.UUID, lastEventID string) ([]Event, <-chan Event, func())
+}
+
+const (
+	eventBacklogSize    = 256
+	subscriberQueueSize = 32
+)
+
+type subscriber struct {
+	adminID uuid.UUID
+	ch      chan Event
+}
+
+// eventBroker numbers events from 1 in every process. IDs are prefixed with
+// the start time of the process, so an ID a client kept across a restart is
+// recognised as belonging to an earlier run.
+type eventBroker struct {
+	mu          sync.Mutex
+	epoch       string
+	nextID      uint64
+	backlog     []Event
+	subscribers map[*subscriber]struct{}
+}
+
+func NewEventBroker() EventBroker {
+	return &eventBroker{
+		epoch:       strconv.FormatInt(time.Now().UnixMilli(), 10),
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+func (e Event) visibleTo(adminID uuid.UUID) bool {
+	return e.AdminID == nil || *e.AdminID == adminID
+}
+
+func (b *eventBroker) Publish(eventType string, adminID *uuid.UUID, data interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	event := Event{
+		ID:      fmt.Sprintf("%s-%d", b.epoch, b.nextID),
+		Type:    eventType,
+		AdminID: adminID,
+		Data:    data,
+		seq:     b.nextID,
+	}
+
+	b.backlog = append(b.backlog, event)
+	if len(b.backlog) > eventBacklogSize {
+		b.backlog = b.backlog[len(b.backlog)-eventBacklogSize:]
+	}
+
+	for sub := range b.subscribers {
+		if !event.visibleTo(sub.adminID) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			// Drop slow subscribers; the client reconnects with
+			// Last-Event-ID and replays from the backlog
+			delete(b.subscribers, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+func (b *eventBroker) Subscribe(adminID uuid.UUID, lastEventID string) ([]Event, <-chan Event, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var missed []Event
+	if lastEventID != "" {
+		// An ID of an earlier run, or one we cannot read, predates every
+		// event in the backlog, so all of it is replayed
+		var after uint64
+		epoch, seq, ok := strings.Cut(lastEventID, "-")
+		if ok && epoch == b.epoch {
+			if n, err := strconv.ParseUint(seq, 10, 64); err == nil && n <= b.nextID {
+				after = n
+			}
+		}
+		for _, event := range b.backlog {
+			if event.seq > after && event.visibleTo(adminID) {
+				missed = append(missed, event)
+			}
+		}
+	}
+
+	sub := &subscriber{adminID: adminID, ch: make(chan Event, subscriberQueueSize)}
+	b.subscribers[sub] = struct{}{}
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[sub]; ok {
+			delete(b.subscribers, sub)
+			close(sub.ch)
+		}
+	}
+
+	return missed, sub.ch, unsubscribe
+}
+
+// ============== Email Service ==============
+
+type EmailService interface {
+	// SendPermohonanEmail renders the template for data.Status and queues the
+	// email in the outbox; it is delivered by the worker started with StartWorker
+	SendPermohonanEmail(p *models.Permohonan, data EmailData, attachmentPath string) error
+	// KirimEmailSistem queues an email that is not about a permohonan, such
+	// as a password reset link. A non-empty rahasia replaces PenandaRahasia in
+	// the body when the email is sent, see EmailLog.Rahasia.
+	KirimEmailSistem(tujuan, subjek, isiHTML, isiTeks, rahasia string) error
+	StartWorker(ctx context.Context)
+	GetLogs(query dto.PaginationQuery) (*dto.EmailLogListResponse, error)
+	Retry(id uuid.UUID) error
+}
+
+// PenandaRahasia stands in for a token in a queued email body. The token
+// itself is stored encrypted in EmailLog.Rahasia and removed once the email
+// is sent, so the outbox never shows a usable reset or revision link. It
+// only uses characters that survive URL escaping in templates.
+const PenandaRahasia = "__TOKEN_RAHASIA__"
+
+const (
+	emailBatchSize      = 20
+	emailBackoffBase    = time.Minute
+	emailBackoffMax     = time.Hour
+	emailSendingTimeout = 10 * time.Minute
+)
+
+type emailService struct {
+	cfg          *config.Config
+	emailLogRepo repositories.EmailLogRepository
+	templateRepo repositories.TemplateEmailRepository
+	storage      Storage
+}
+
+func NewEmailService(cfg *config.Config, emailLogRepo repositories.EmailLogRepository, templateRepo repositories.TemplateEmailRepository, storage Storage) EmailService {
+	return &emailService{cfg: cfg, emailLogRepo: emailLogRepo, templateRepo: templateRepo, storage: storage}
+}
+
+func (s *emailService) SendPermohonanEmail(p *models.Permohonan, data EmailData, attachmentPath string) error {
+	data.AdaLampiran = attachmentPath != ""
+
+	tpl := s.findTemplate(models.StatusPermohonan(data.Status), p.JenisPerizinanID)
+	subject, body, text, err := RenderTemplateEmail(tpl.Subjek, tpl.IsiHTML, tpl.IsiTeks, data)
+	if err != nil {
+		return fmt.Errorf("gagal membuat isi email: %w", err)
+	}
+
+	// Create outbox entry, the worker picks it up on its next run
+	return s.antrekan(&models.EmailLog{
+		PermohonanID: &p.ID,
+		EmailTujuan:  p.Pemohon.Email,
+		Subjek:       subject,
+		Isi:          text,
+		IsiHTML:      body,
+		Lampiran:     attachmentPath,
+	}, data.rahasia)
+}
+
+func (s *emailService) KirimEmailSistem(tujuan, subjek, isiHTML, isiTeks, rahasia string) error {
+	return s.antrekan(&models.EmailLog{
+		EmailTujuan: tujuan,
+		Subjek:      subjek,
+		Isi:         isiTeks,
+		IsiHTML:     isiHTML,
+	}, rahasia)
+}
+
+// antrekan stores an email as a pending outbox entry; rahasia is kept
+// encrypted until the email is sent
+func (s *emailService) antrekan(emailLog *models.EmailLog, rahasia string) error {
+	maxAttempts, err := strconv.Atoi(s.cfg.EmailMaxAttempts)
+	if err != nil || maxAttempts < 1 {
+		maxAttempts = 5
+	}
+	emailLog.Status = models.EmailStatusPending
+	emailLog.PercobaanMaks = maxAttempts
+	if rahasia != "" {
+		if emailLog.Rahasia, err = enkripsiRahasia(kunciRahasia(s.cfg), rahasia); err != nil {
+			return fmt.Errorf("gagal menyimpan email ke antrean: %w", err)
+		}
+	}
+
+	if err := s.emailLogRepo.Create(emailLog); err != nil {
+		return fmt.Errorf("gagal menyimpan email ke antrean: %w", err)
+	}
+	return nil
+}
+
+// findTemplate picks the active template for the jenis perizinan, then the
+// active default template, then the built-in one
+func (s *emailService) findTemplate(status models.StatusPermohonan, jenisPerizinanID uuid.UUID) models.TemplateEmail {
+	if tpl, err := s.templateRepo.FindByStatus(status, &jenisPerizinanID); err == nil && tpl.Aktif {
+		return *tpl
+	}
+	if tpl, err := s.templateRepo.FindByStatus(status, nil); err == nil && tpl.Aktif {
+		return *tpl
+	}
+	return defaultTemplateEmail(status)
+}
+
+// StartWorker processes the outbox until ctx is cancelled
+func (s *emailService) StartWorker(ctx context.Context) {
+	interval, err := strconv.Atoi(s.cfg.EmailWorkerIntervalSecond)
+	if err != nil || interval < 1 {
+		interval = 15
+	}
+
+	ticker := time.NewTicker(time.Duration(interval) * time.Second)
+	defer ticker.Stop()
+
+	log.Printf("📧 Email worker started (interval %ds)", interval)
+	for {
+		s.processOutbox()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *emailService) processOutbox() {
+	if n, err := s.emailLogRepo.ReleaseStale(time.Now().Add(-emailSendingTimeout)); err == nil && n > 0 {
+		log.Printf("Warning: %d email tertahan dikembalikan ke antrean", n)
+	}
+
+	due, err := s.emailLogRepo.FindDue(time.Now(), emailBatchSize)
+	if err != nil {
+		log.Printf("Warning: gagal membaca antrean email: %v", err)
+		return
+	}
+
+	for i := range due {
+		emailLog := &due[i]
+		claimed, err := s.emailLogRepo.Claim(emailLog.ID, emailLog.Status)
+		if err != nil || !claimed {
+			continue
+		}
+		s.deliver(emailLog)
+	}
+}
+
+// deliver sends one outbox entry and schedules a retry with exponential
+// backoff on failure
+func (s *emailService) deliver(emailLog *models.EmailLog) {
+	emailLog.Percobaan++
+	err := s.dialAndSend(emailLog)
+	now := time.Now()
+
+	if err == nil {
+		emailLog.Status = models.EmailStatusSent
+		emailLog.Error = ""
+		emailLog.SentAt = &now
+		emailLog.KirimBerikutnya = nil
+		emailLog.Rahasia = ""
+	} else {
+		emailLog.Error = err.Error()
+		if emailLog.Percobaan >= emailLog.PercobaanMaks {
+			emailLog.Status = models.EmailStatusDead
+			emailLog.KirimBerikutnya = nil
+			log.Printf("Warning: email %s ke %s gagal setelah %d percobaan: %v", emailLog.ID, emailLog.EmailTujuan, emailLog.Percobaan, err)
+		} else {
+			next := now.Add(emailBackoff(emailLog.Percobaan))
+			emailLog.Status = models.EmailStatusFailed
+			emailLog.KirimBerikutnya = &next
+		}
+	}
+
+	if err := s.emailLogRepo.Update(emailLog); err != nil {
+		log.Printf("Warning: gagal memperbarui status email %s: %v", emailLog.ID, err)
+	}
+}
+
+func (s *emailService) dialAndSend(emailLog *models.EmailLog) error {
+	isi, isiHTML := emailLog.Isi, emailLog.IsiHTML
+	if emailLog.Rahasia != "" {
+		rahasia, err := dekripsiRahasia(kunciRahasia(s.cfg), emailLog.Rahasia)
+		if err != nil {
+			return fmt.Errorf("gagal membuka token email: %w", err)
+		}
+		isi = strings.ReplaceAll(isi, PenandaRahasia, rahasia)
+		isiHTML = strings.ReplaceAll(isiHTML, PenandaRahasia, rahasia)
+	}
+
+	port, _ := strconv.Atoi(s.cfg.SMTPPort)
+	m := gomail.NewMessage()
+	m.SetHeader("From", s.cfg.SMTPFrom)
+	m.SetHeader("To", emailLog.EmailTujuan)
+	m.SetHeader("Subject", emailLog.Subjek)
+	if isi != "" {
+		m.SetBody("text/plain", isi)
+		m.AddAlternative("text/html", isiHTML)
+	} else {
+		m.SetBody("text/html", isiHTML)
+	}
+
+	// Attach file if provided; it is read from storage when the message is sent
+	if emailLog.Lampiran != "" {
+		key := emailLog.Lampiran
+		m.Attach(path.Base(filepath.ToSlash(key)), gomail.SetCopyFunc(func(w io.Writer) error {
+			r, _, err := s.storage.Open(context.Background(), key)
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+			_, err = io.Copy(w, r)
+			return err
+		}))
+	}
+
+	d := gomail.NewDialer(s.cfg.SMTPHost, port, s.cfg.SMTPUsername, s.cfg.SMTPPassword)
+	return d.DialAndSend(m)
+}
+
+// emailBackoff returns the delay before the next attempt: 1m, 2m, 4m, ...
+// capped at one hour
+func emailBackoff(attempt int) time.Duration {
+	delay := emailBackoffBase
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= emailBackoffMax {
+			return emailBackoffMax
+		}
+	}
+	return delay
+}
+
+func (s *emailService) GetLogs(query dto.PaginationQuery) (*dto.EmailLogListResponse, error) {
+	list, total, err := s.emailLogRepo.FindAllPaginated(query.GetOffset(), query.GetLimit(), query.Status)
+	if err != nil {
+		return nil, err
+	}
+
+	var responses []dto.EmailLogResponse
+	for _, l := range list {
+		responses = append(responses, dto.EmailLogResponse{
+			ID:              l.ID,
+			PermohonanID:    l.PermohonanID,
+			EmailTujuan:     l.EmailTujuan,
+			Subjek:          l.Subjek,
+			Status:          l.Status,
+			Error:           l.Error,
+			Percobaan:       l.Percobaan,
+			PercobaanMaks:   l.PercobaanMaks,
+			KirimBerikutnya: l.KirimBerikutnya,
+			SentAt:          l.SentAt,
+			CreatedAt:       l.CreatedAt,
+		})
+	}
+
+	totalPages := int(total) / query.GetLimit()
+	if int(total)%query.GetLimit() > 0 {
+		totalPages++
+	}
+
+	return &dto.EmailLogListResponse{
+		Data:       responses,
+		Total:      total,
+		Page:       query.Page,
+		PerPage:    query.GetLimit(),
+		TotalPages: totalPages,
+	}, nil
+}
+
+// Retry puts a failed or dead email back into the queue with a fresh set of
+// attempts
+func (s *emailService) Retry(id uuid.UUID) error {
+	emailLog, err := s.emailLogRepo.FindByID(id)
+	if err != nil {
+		return errors.New("email tidak ditemukan")
+	}
+
+	if emailLog.Status != models.EmailStatusFailed && emailLog.Status != models.EmailStatusDead {
+		return fmt.Errorf("email dengan status %s tidak dapat dikirim ulang", emailLog.Status)
+	}
+
+	emailLog.Status = models.EmailStatusPending
+	emailLog.Percobaan = 0
+	emailLog.KirimBerikutnya = nil
+	return s.emailLogRepo.Update(emailLog)
+}
+
+// ============== Template Email Service ==============
+
+// ErrTemplateEmailSudahAda is returned when a template for the status and
+// jenis perizinan exists already
+var ErrTemplateEmailSudahAda = errors.New("template untuk status dan jenis perizinan ini sudah ada")
+
+// EmailData holds the values available as placeholders in email templates
+type EmailData struct {
+	NamaPemohon       string
+	NomorPermohonan   string
+	JenisPerizinan    string
+	Status            string
+	StatusText        string
+	WarnaStatus       string
+	TanggalMasuk      string
+	Balasan           string
+	CatatanRevisi     string
+	PersyaratanRevisi []string
+	LinkRevisi        string
+	BatasRevisi       string
+	AdaLampiran       bool
+	NomorSurat        string
+	LinkVerifikasi    string // only for issued permits
+
+	// rahasia is the token behind PenandaRahasia in LinkRevisi; templates
+	// cannot reach it
+	rahasia string
+}
+
+// EmailPlaceholders documents the fields of EmailData for template editors
+var EmailPlaceholders = []string{
+	"{{.NamaPemohon}}", "{{.NomorPermohonan}}", "{{.JenisPerizinan}}",
+	"{{.Status}}", "{{.StatusText}}", "{{.WarnaStatus}}", "{{.TanggalMasuk}}",
+	"{{.Balasan}}", "{{nl2br .Balasan}}", "{{.CatatanRevisi}}",
+	"{{range .PersyaratanRevisi}}...{{end}}", "{{.LinkRevisi}}", "{{.BatasRevisi}}",
+	"{{if .AdaLampiran}}...{{end}}", "{{.NomorSurat}}", "{{.LinkVerifikasi}}",
+}
+
+// NewEmailData fills the placeholders available for every permohonan
+func NewEmailData(p *models.Permohonan, status models.StatusPermohonan) EmailData {
+	return EmailData{
+		NamaPemohon:     p.Pemohon.NamaLengkap,
+		NomorPermohonan: p.NomorPermohonan,
+		JenisPerizinan:  p.JenisPerizinan.Nama,
+		Status:          string(status),
+		StatusText:      getStatusText(string(status)),
+		WarnaStatus:     getStatusColor(string(status)),
+		TanggalMasuk:    p.TanggalMasuk.Format("02-01-2006"),
+	}
+}
+
+var emailTemplateFuncs = map[string]interface{}{
+	// nl2br escapes text and keeps its line breaks in HTML emails
+	"nl2br": func(text string) htmltemplate.HTML {
+		return htmltemplate.HTML(strings.ReplaceAll(htmltemplate.HTMLEscapeString(text), "\n", "<br>"))
+	},
+}
+
+// RenderTemplateEmail renders the subject, HTML and plain text parts. The
+// HTML part is escaped with html/template so placeholder values cannot
+// inject markup.
+func RenderTemplateEmail(subjek, isiHTML, isiTeks string, data EmailData) (string, string, string, error) {
+	var subjectBuf, htmlBuf, textBuf bytes.Buffer
+
+	subjectTpl, err := texttemplate.New("subjek").Parse(subjek)
+	if err != nil {
+		return "", "", "", fmt.Errorf("subjek: %w", err)
+	}
+	if err := subjectTpl.Execute(&subjectBuf, data); err != nil {
+		return "", "", "", fmt.Errorf("subjek: %w", err)
+	}
+
+	htmlTpl, err := htmltemplate.New("isi_html").Funcs(emailTemplateFuncs).Parse(isiHTML)
+	if err != nil {
+		return "", "", "", fmt.Errorf("isi_html: %w", err)
+	}
+	if err := htmlTpl.Execute(&htmlBuf, data); err != nil {
+		return "", "", "", fmt.Errorf("isi_html: %w", err)
+	}
+
+	if isiTeks != "" {
+		textTpl, err := texttemplate.New("isi_teks").Funcs(emailTemplateFuncs).Parse(isiTeks)
+		if err != nil {
+			return "", "", "", fmt.Errorf("isi_teks: %w", err)
+		}
+		if err := textTpl.Execute(&textBuf, data); err != nil {
+			return "", "", "", fmt.Errorf("isi_teks: %w", err)
+		}
+	}
+
+	return strings.TrimSpace(subjectBuf.String()), htmlBuf.String(), textBuf.String(), nil
+}
+
+const defaultSubjekEmail = "Balasan Permohonan {{.JenisPerizinan}} - {{.StatusText}}"
+
+// defaultLayoutEmail wraps the status specific content (%s) in the
+// Dinas Kesehatan letterhead
+const defaultLayoutEmail = `
+		<html>
+		<body style="font-family: Arial, sans-serif; line-height: 1.6;">
+			<div style="max-width: 600px; margin: 0 auto; padding: 20px;">
+				<div style="background-color: #1e40af; color: white; padding: 20px; text-align: center;">
+					<h1>Dinas Kesehatan Kota Makassar</h1>
+				</div>
+				<div style="padding: 20px; background-color: #f8fafc;">
+					<h2>Yth. {{.NamaPemohon}},</h2>
+					<p>Berikut adalah balasan untuk permohonan <strong>{{.JenisPerizinan}}</strong> Anda:</p>
+					<div style="background-color: white; border-left: 4px solid {{.WarnaStatus}}; padding: 15px; margin: 20px 0;">
+						<p><strong>Status: {{.StatusText}}</strong></p>
+						%s
+					</div>
+					{{if .AdaLampiran}}<p style="color: #666; font-size: 14px; margin-top: 15px;">📎 <strong>Surat balasan terlampir pada email ini.</strong></p>{{end}}
+					<p>Jika ada pertanyaan lebih lanjut, silakan hubungi kami.</p>
+					<hr style="margin: 20px 0;">
+					<p style="color: #666; font-size: 12px;">
+						Email ini dikirim secara otomatis dari sistem perizinan Dinas Kesehatan Kota Makassar.
+					</p>
+				</div>
+			</div>
+		</body>
+		</html>
+	`
+
+const defaultFooterTeks = `
+
+Jika ada pertanyaan lebih lanjut, silakan hubungi kami.
+
+Hormat kami,
+Dinas Kesehatan Kota Makassar`
+
+// defaultKontenEmail holds the built-in HTML and plain text content per status
+var defaultKontenEmail = map[models.StatusPermohonan][2]string{
+	models.StatusDiproses: {
+		`<p>Permohonan {{.JenisPerizinan}} Anda dengan nomor <strong>{{.NomorPermohonan}}</strong> sedang dalam proses verifikasi.</p>
+						<p>Kami akan menghubungi Anda kembali setelah proses verifikasi selesai.</p>
+						<p>Terima kasih atas kesabaran Anda.</p>`,
+		`Yth. {{.NamaPemohon}},
+
+Dengan hormat,
+
+Permohonan {{.JenisPerizinan}} Anda dengan nomor {{.NomorPermohonan}} sedang dalam proses verifikasi.
+
+Kami akan menghubungi Anda kembali setelah proses verifikasi selesai.
+
+Terima kasih atas kesabaran Anda.` + defaultFooterTeks,
+	},
+	models.StatusDisetujui: {
+		`<p>{{nl2br .Balasan}}</p>{{if .LinkVerifikasi}}
+						<p>Keaslian surat izin nomor {{.NomorSurat}} dapat diverifikasi melalui:<br>
+						<a href="{{.LinkVerifikasi}}">{{.LinkVerifikasi}}</a></p>{{end}}`,
+		`Yth. {{.NamaPemohon}},
+
+Permohonan {{.JenisPerizinan}} Anda dengan nomor {{.NomorPermohonan}} telah DISETUJUI.
+
+{{.Balasan}}{{if .AdaLampiran}}
+
+Surat balasan terlampir pada email ini.{{end}}{{if .LinkVerifikasi}}
+
+Keaslian surat izin nomor {{.NomorSurat}} dapat diverifikasi melalui:
+{{.LinkVerifikasi}}{{end}}` + defaultFooterTeks,
+	},
+	models.StatusDitolak: {
+		`<p>{{nl2br .Balasan}}</p>`,
+		`Yth. {{.NamaPemohon}},
+
+Permohonan {{.JenisPerizinan}} Anda dengan nomor {{.NomorPermohonan}} DITOLAK.
+
+{{.Balasan}}{{if .AdaLampiran}}
+
+Surat balasan terlampir pada email ini.{{end}}` + defaultFooterTeks,
+	},
+	models.StatusPerluRevisi: {
+		`<p>Permohonan Anda dengan nomor <strong>{{.NomorPermohonan}}</strong> memerlukan perbaikan berkas berikut:</p>
+						<ul>{{range .PersyaratanRevisi}}<li>{{.}}</li>{{end}}</ul>
+						<p>{{nl2br .CatatanRevisi}}</p>
+						<p>Silakan unggah berkas pengganti melalui tautan berikut sebelum {{.BatasRevisi}}:<br>
+						<a href="{{.LinkRevisi}}">{{.LinkRevisi}}</a></p>`,
+		`Yth. {{.NamaPemohon}},
+
+Permohonan Anda dengan nomor {{.NomorPermohonan}} memerlukan perbaikan berkas berikut:
+{{range .PersyaratanRevisi}}
+- {{.}}{{end}}
+
+{{.CatatanRevisi}}
+
+Silakan unggah berkas pengganti melalui tautan berikut sebelum {{.BatasRevisi}}:
+{{.LinkRevisi}}` + defaultFooterTeks,
+	},
+}
+
+// defaultTemplateEmail returns the built-in template used when no template
+// is stored in the database
+func defaultTemplateEmail(status models.StatusPermohonan) models.TemplateEmail {
+	konten, ok := defaultKontenEmail[status]
+	if !ok {
+		konten = [2]string{`<p>{{nl2br .Balasan}}</p>`, `{{.Balasan}}`}
+	}
+	return models.TemplateEmail{
+		Status:  status,
+		Subjek:  defaultSubjekEmail,
+		IsiHTML: fmt.Sprintf(defaultLayoutEmail, konten[0]),
+		IsiTeks: konten[1],
+		Aktif:   true,
+	}
+}
+
+// DefaultTemplateEmail returns the built-in templates for seeding the database
+func DefaultTemplateEmail() []models.TemplateEmail {
+	statuses := []models.StatusPermohonan{
+		models.StatusDiproses, models.StatusDisetujui, models.StatusDitolak, models.StatusPerluRevisi,
+	}
+	list := make([]models.TemplateEmail, 0, len(statuses))
+	for _, status := range statuses {
+		list = append(list, defaultTemplateEmail(status))
+	}
+	return list
+}
+
+type TemplateEmailService interface {
+	Create(req dto.CreateTemplateEmailRequest) (*dto.TemplateEmailResponse, error)
+	GetAll() ([]dto.TemplateEmailResponse, error)
+	GetByID(id uuid.UUID) (*dto.TemplateEmailResponse, error)
+	Update(id uuid.UUID, req dto.UpdateTemplateEmailRequest) (*dto.TemplateEmailResponse, error)
+	Delete(id uuid.UUID) error
+	Preview(req dto.PreviewTemplateEmailRequest) (*dto.PreviewTemplateEmailResponse, error)
+}
+
+type templateEmailService struct {
+	repo           repositories.TemplateEmailRepository
+	jpRepo         repositories.JenisPerizinanRepository
+	permohonanRepo repositories.PermohonanRepository
+}
+
+func NewTemplateEmailService(
+	repo repositories.TemplateEmailRepository,
+	jpRepo repositories.JenisPerizinanRepository,
+	permohonanRepo repositories.PermohonanRepository,
+) TemplateEmailService {
+	return &templateEmailService{repo: repo, jpRepo: jpRepo, permohonanRepo: permohonanRepo}
+}
+
+func (s *templateEmailService) Create(req dto.CreateTemplateEmailRequest) (*dto.TemplateEmailResponse, error) {
+	status := models.StatusPermohonan(req.Status)
+
+	var jpID *uuid.UUID
+	if req.JenisPerizinanID != "" {
+		id, err := uuid.Parse(req.JenisPerizinanID)
+		if err != nil {
+			return nil, errors.New("jenis perizinan ID tidak valid")
+		}
+		if _, err := s.jpRepo.FindByID(id); err != nil {
+			return nil, errors.New("jenis perizinan tidak ditemukan")
+		}
+		jpID = &id
+	}
+
+	if existing, _ := s.repo.FindByStatus(status, jpID); existing != nil {
+		return nil, ErrTemplateEmailSudahAda
+	}
+
+	if err := validateTemplateEmail(status, req.Subjek, req.IsiHTML, req.IsiTeks); err != nil {
 		return nil, err
 	}
 
-	var response []dto.JenisPerizinanResponse
-	for _, jp := range list {
-		response = append(response, dto.JenisPerizinanResponse{
-			ID:          jp.ID,
-			Nama:        jp.Nama,
-			Deskripsi:   jp.Deskripsi,
-			Persyaratan: jp.Persyaratan,
-			Aktif:       jp.Aktif,
-			CreatedAt:   jp.CreatedAt,
-		})
+	tpl := &models.TemplateEmail{
+		Status:           status,
+		JenisPerizinanID: jpID,
+		Subjek:           req.Subjek,
+		IsiHTML:          req.IsiHTML,
+		IsiTeks:          req.IsiTeks,
+		Aktif:            true,
 	}
-	return response, nil
+	if req.Aktif != nil {
+		tpl.Aktif = *req.Aktif
+	}
+
+	if err := s.repo.Create(tpl); err != nil {
+		// Lost the race against a concurrent save of the same template
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrTemplateEmailSudahAda
+		}
+		return nil, err
+	}
+	return s.GetByID(tpl.ID)
 }
 
-func (s *jenisPerizinanService) GetByID(id uuid.UUID) (*dto.JenisPerizinanResponse, error) {
-	jp, err := s.repo.FindByID(id)
+func (s *templateEmailService) GetAll() ([]dto.TemplateEmailResponse, error) {
+	list, err := s.repo.FindAll()
 	if err != nil {
 		return nil, err
 	}
-	return &dto.JenisPerizinanResponse{
-		ID:          jp.ID,
-		Nama:        jp.Nama,
-		Deskripsi:   jp.Deskripsi,
-		Persyaratan: jp.Persyaratan,
-		Aktif:       jp.Aktif,
-		CreatedAt:   jp.CreatedAt,
-	}, nil
+
+	var responses []dto.TemplateEmailResponse
+	for _, tpl := range list {
+		responses = append(responses, toTemplateEmailResponse(&tpl))
+	}
+	return responses, nil
 }
 
-func (s *jenisPerizinanService) Update(id uuid.UUID, req dto.UpdateJenisPerizinanRequest) (*models.JenisPerizinan, error) {
-	jp, err := s.repo.FindByID(id)
+func (s *templateEmailService) GetByID(id uuid.UUID) (*dto.TemplateEmailResponse, error) {
+	tpl, err := s.repo.FindByID(id)
 	if err != nil {
-		return nil, err
+		return nil, errors.New("template email tidak ditemukan")
 	}
+	response := toTemplateEmailResponse(tpl)
+	return &response, nil
+}
 
-	if req.Nama != "" {
-		jp.Nama = req.Nama
+func (s *templateEmailService) Update(id uuid.UUID, req dto.UpdateTemplateEmailRequest) (*dto.TemplateEmailResponse, error) {
+	tpl, err := s.repo.FindByID(id)
+	if err != nil {
+		return nil, errors.New("template email tidak ditemukan")
 	}
-	if req.Deskripsi != "" {
-		jp.Deskripsi = req.Deskripsi
+
+	if req.Subjek != "" {
+		tpl.Subjek = req.Subjek
 	}
-	if req.Persyaratan != nil {
-		jp.Persyaratan = req.Persyaratan
+	if req.IsiHTML != "" {
+		tpl.IsiHTML = req.IsiHTML
+	}
+	if req.IsiTeks != "" {
+		tpl.IsiTeks = req.IsiTeks
 	}
 	if req.Aktif != nil {
-		jp.Aktif = *req.Aktif
+		tpl.Aktif = *req.Aktif
 	}
 
-	err = s.repo.Update(jp)
-	return jp, err
+	if err := validateTemplateEmail(tpl.Status, tpl.Subjek, tpl.IsiHTML, tpl.IsiTeks); err != nil {
+		return nil, err
+	}
+
+	// Avoid saving the preloaded association back
+	tpl.JenisPerizinan = nil
+	if err := s.repo.Update(tpl); err != nil {
+		return nil, err
+	}
+	return s.GetByID(tpl.ID)
 }
 
-func (s *jenisPerizinanService) Delete(id uuid.UUID) error {
+func (s *templateEmailService) Delete(id uuid.UUID) error {
+	if _, err := s.repo.FindByID(id); err != nil {
+		return errors.New("template email tidak ditemukan")
+	}
 	return s.repo.Delete(id)
 }
 
-// ============== Permohonan Service ==============
+func (s *templateEmailService) Preview(req dto.PreviewTemplateEmailRequest) (*dto.PreviewTemplateEmailResponse, error) {
+	status := models.StatusPermohonan(req.Status)
+	data := sampleEmailData(status)
 
-type PermohonanService interface {
-	Create(req dto.CreatePermohonanRequest, berkasFiles []models.Berkas) (*models.Permohonan, error)
-	GetAll(pagination dto.PaginationQuery) (*dto.PermohonanListResponse, error)
-	GetByID(id uuid.UUID) (*dto.PermohonanResponse, error)
-	GetByStatus(status string) ([]dto.PermohonanResponse, error)
-	UpdateStatus(id uuid.UUID, adminID uuid.UUID, req dto.UpdatePermohonanStatusRequest) error
-	KirimBalasan(id uuid.UUID, adminID uuid.UUID, balasanEmail, status, attachmentPath, catatanAdmin string) error
-	GetStatistik() (*dto.StatistikDashboard, error)
-	GetRecentPermohonan(limit int) ([]dto.PermohonanResponse, error)
+	if req.PermohonanID != "" {
+		id, err := uuid.Parse(req.PermohonanID)
+		if err != nil {
+			return nil, errors.New("permohonan ID tidak valid")
+		}
+		p, err := s.permohonanRepo.FindByID(id)
+		if err != nil {
+			return nil, errors.New("permohonan tidak ditemukan")
+		}
+		sample := data
+		data = NewEmailData(p, status)
+		data.Balasan = sample.Balasan
+		if p.BalasanEmail != "" {
+			data.Balasan = p.BalasanEmail
+		}
+		data.PersyaratanRevisi = sample.PersyaratanRevisi
+		if len(p.PersyaratanRevisi) > 0 {
+			data.PersyaratanRevisi = p.PersyaratanRevisi
+		}
+		data.CatatanRevisi = sample.CatatanRevisi
+		data.LinkRevisi = sample.LinkRevisi
+		data.BatasRevisi = sample.BatasRevisi
+		data.AdaLampiran = p.LampiranSurat != ""
+	}
+
+	subject, body, text, err := RenderTemplateEmail(req.Subjek, req.IsiHTML, req.IsiTeks, data)
+	if err != nil {
+		return nil, fmt.Errorf("template tidak valid: %w", err)
+	}
+
+	return &dto.PreviewTemplateEmailResponse{
+		Subjek:  subject,
+		IsiHTML: body,
+		IsiTeks: text,
+	}, nil
 }
 
-type permohonanService struct {
-	permohonanRepo repositories.PermohonanRepository
-	pemohonRepo    repositories.PemohonRepository
-	jpRepo         repositories.JenisPerizinanRepository
-	notifRepo      repositories.NotifikasiRepository
-	adminRepo      repositories.AdminRepository
-	emailService   EmailService
+// validateTemplateEmail renders the template against sample data so syntax
+// errors and unknown placeholders are reported when saving
+func validateTemplateEmail(status models.StatusPermohonan, subjek, isiHTML, isiTeks string) error {
+	if _, _, _, err := RenderTemplateEmail(subjek, isiHTML, isiTeks, sampleEmailData(status)); err != nil {
+		return fmt.Errorf("template tidak valid: %w", err)
+	}
+	return nil
 }
 
-func NewPermohonanService(
-	permohonanRepo repositories.PermohonanRepository,
-	pemohonRepo repositories.PemohonRepository,
-	jpRepo repositories.JenisPerizinanRepository,
-	notifRepo repositories.NotifikasiRepository,
-	adminRepo repositories.AdminRepository,
-	emailService EmailService,
-) PermohonanService {
-	return &permohonanService{
-		permohonanRepo: permohonanRepo,
-		pemohonRepo:    pemohonRepo,
-		jpRepo:         jpRepo,
-		notifRepo:      notifRepo,
-		adminRepo:      adminRepo,
-		emailService:   emailService,
+// sampleEmailData is used to preview templates without a real permohonan
+func sampleEmailData(status models.StatusPermohonan) EmailData {
+	return EmailData{
+		NamaPemohon:       "Andi Pratama",
+		NomorPermohonan:   "0123456789",
+		JenisPerizinan:    "Izin Penelitian",
+		Status:            string(status),
+		StatusText:        getStatusText(string(status)),
+		WarnaStatus:       getStatusColor(string(status)),
+		TanggalMasuk:      time.Now().Format("02-01-2006"),
+		Balasan:           "Contoh isi balasan dari petugas.",
+		CatatanRevisi:     "Contoh catatan revisi dari petugas.",
+		PersyaratanRevisi: []string{"KTP", "Proposal penelitian"},
+		LinkRevisi:        "https://contoh.go.id/permohonan/revisi?token=contoh",
+		BatasRevisi:       time.Now().Add(7 * 24 * time.Hour).Format("02-01-2006 15:04"),
+		AdaLampiran:       true,
 	}
 }
 
-func (s *permohonanService) Create(req dto.CreatePermohonanRequest, berkasFiles []models.Berkas) (*models.Permohonan, error) {
-	// Create or find pemohon
-	pemohon := &models.Pemohon{
-		NamaLengkap:  req.Pemohon.NamaLengkap,
-		NomorTelepon: req.Pemohon.NomorTelepon,
-		Email:        req.Pemohon.Email,
-		Alamat:       req.Pemohon.Alamat,
+func toTemplateEmailResponse(tpl *models.TemplateEmail) dto.TemplateEmailResponse {
+	namaJP := ""
+	if tpl.JenisPerizinan != nil {
+		namaJP = tpl.JenisPerizinan.Nama
+	}
+	return dto.TemplateEmailResponse{
+		ID:                  tpl.ID,
+		Status:              string(tpl.Status),
+		JenisPerizinanID:    tpl.JenisPerizinanID,
+		NamaJenisPerizinan:  namaJP,
+		Subjek:              tpl.Subjek,
+		IsiHTML:             tpl.IsiHTML,
+		IsiTeks:             tpl.IsiTeks,
+		Aktif:               tpl.Aktif,
+		PlaceholderTersedia: EmailPlaceholders,
+		UpdatedAt:           tpl.UpdatedAt,
+	}
+}
+
+func getStatusText(status string) string {
+	switch status {
+	case "disetujui":
+		return "Disetujui"
+	case "ditolak":
+		return "Ditolak"
+	case "perlu_revisi":
+		return "Perlu Revisi"
+	default:
+		return "Diproses"
+	}
+}
+
+func getStatusColor(status string) string {
+	switch status {
+	case "disetujui":
+		return "#10b981" // green
+	case "diproses":
+		return "#3b82f6" // blue
+	case "ditolak":
+		return "#ef4444" // red
+	case "perlu_revisi":
+		return "#f97316" // orange
+	default:
+		return "#f59e0b" // yellow (baru)
+	}
+}
+
+// ============== Storage ==============
+
+var (
+	// ErrFileTidakDitemukan is returned by Storage when a key does not exist
+	ErrFileTidakDitemukan = errors.New("file tidak ditemukan")
+	// ErrKeyTidakValid is returned for keys outside the storage root
+	ErrKeyTidakValid = errors.New("key file tidak valid")
+	// ErrFileRusak is returned when a stored file no longer matches the
+	// checksum taken at upload
+	ErrFileRusak = errors.New("file rusak: checksum tidak cocok")
+)
+
+// StorageObject describes a stored file
+type StorageObject struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// Storage keeps uploaded and generated files. Keys are slash separated and
+// relative to the storage root, e.g. "surat/1700000000_ab12cd34_izin.pdf".
+type Storage interface {
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open returns a seekable reader so downloads can serve range requests
+	Open(ctx context.Context, key string) (io.ReadSeekCloser, *StorageObject, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	Delete(ctx context.Context, key string) error
+	List(ctx context.Context, prefix string) ([]StorageObject, error)
+}
+
+// NewStorage returns the storage selected by STORAGE_DRIVER in the config
+func NewStorage(cfg *config.Config) (Storage, error) {
+	switch cfg.StorageDriver {
+	case "s3":
+		return NewS3Storage(cfg)
+	case "", "local":
+		return NewLocalStorage(cfg.UploadPath), nil
+	default:
+		return nil, fmt.Errorf("STORAGE_DRIVER %q tidak dikenal", cfg.StorageDriver)
+	}
+}
+
+// BacaTerverifikasi wraps a stored file so its SHA-256 is computed while it
+// is served instead of reading it twice. When the whole file was read in
+// order and the sum differs from expected, the last chunk is held back and
+// ErrFileRusak returned, so a corrupt file never reaches the client
+// complete. Range requests read only part of the file and are not verified.
+func BacaTerverifikasi(r io.ReadSeeker, size int64, expected string) io.ReadSeeker {
+	return &pembacaTerverifikasi{r: r, size: size, expected: expected, hash: sha256.New(), urut: true}
+}
+
+type pembacaTerverifikasi struct {
+	r        io.ReadSeeker
+	size     int64
+	expected string
+	hash     hash.Hash
+	pos      int64
+	urut     bool // everything from offset 0 up to pos went through hash
+}
+
+func (v *pembacaTerverifikasi) Read(p []byte) (int, error) {
+	n, err := v.r.Read(p)
+	if !v.urut {
+		v.pos += int64(n)
+		return n, err
+	}
+
+	v.hash.Write(p[:n])
+	v.pos += int64(n)
+	if v.pos == v.size && n > 0 {
+		v.urut = false
+		if sum := hex.EncodeToString(v.hash.Sum(nil)); sum != v.expected {
+			log.Printf("Warning: checksum tidak cocok saat diunduh (tercatat %s, dihitung %s)", v.expected, sum)
+			return 0, ErrFileRusak
+		}
+	}
+	return n, err
+}
+
+func (v *pembacaTerverifikasi) Seek(offset int64, whence int) (int64, error) {
+	pos, err := v.r.Seek(offset, whence)
+	if err != nil {
+		return pos, err
+	}
+	if pos == 0 {
+		v.hash.Reset()
+		v.urut = true
+	} else if pos != v.pos {
+		v.urut = false
+	}
+	v.pos = pos
+	return pos, nil
+}
+
+// ChecksumFile returns the hex SHA-256 of a stored file
+func ChecksumFile(ctx context.Context, storage Storage, key string) (string, error) {
+	r, _, err := storage.Open(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// StorageKey turns a stored path into a storage key. Records written
+// before files went through Storage hold paths relative to the working
+// directory (e.g. "uploads/surat/x.pdf"); the upload root is stripped so
+// they resolve to the same key.
+func StorageKey(root, key string) (string, error) {
+	key = path.Clean(filepath.ToSlash(key))
+	root = path.Clean(filepath.ToSlash(root))
+	key = strings.TrimPrefix(key, root+"/")
+	key = strings.TrimPrefix(key, "/")
+	if key == "." || key == "" || key == ".." || strings.HasPrefix(key, "../") {
+		return "", fmt.Errorf("%w: %q", ErrKeyTidakValid, key)
+	}
+	return key, nil
+}
+
+// localStorage keeps files on disk below root
+type localStorage struct {
+	root string
+}
+
+func NewLocalStorage(root string) Storage {
+	return &localStorage{root: root}
+}
+
+func (s *localStorage) path(key string) (string, string, error) {
+	key, err := StorageKey(s.root, key)
+	if err != nil {
+		return "", "", err
+	}
+	return key, filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+func (s *localStorage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	_, filePath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(filePath), ".upload-*")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
 	}
-	err := s.pemohonRepo.Create(pemohon)
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+func (s *localStorage) Open(ctx context.Context, key string) (io.ReadSeekCloser, *StorageObject, error) {
+	key, filePath, err := s.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Open(filePath)
+	if os.IsNotExist(err) {
+		return nil, nil, ErrFileTidakDitemukan
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return f, &StorageObject{
+		Key:         key,
+		Size:        info.Size(),
+		ContentType: mime.TypeByExtension(filepath.Ext(key)),
+		ModTime:     info.ModTime(),
+	}, nil
+}
+
+func (s *localStorage) Exists(ctx context.Context, key string) (bool, error) {
+	_, filePath, err := s.path(key)
+	if err != nil {
+		return false, err
+	}
+	_, err = os.Stat(filePath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *localStorage) Delete(ctx context.Context, key string) error {
+	_, filePath, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *localStorage) List(ctx context.Context, prefix string) ([]StorageObject, error) {
+	var list []StorageObject
+	err := filepath.WalkDir(s.root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".upload-") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		list = append(list, StorageObject{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	return list, err
+}
+
+// s3Storage keeps files in an S3 compatible bucket (AWS S3, MinIO, ...)
+type s3Storage struct {
+	client *minio.Client
+	bucket string
+	root   string
+}
+
+func NewS3Storage(cfg *config.Config) (Storage, error) {
+	useSSL, _ := strconv.ParseBool(cfg.S3UseSSL)
+	client, err := minio.New(cfg.S3Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.S3AccessKey, cfg.S3SecretKey, ""),
+		Secure: useSSL,
+		Region: cfg.S3Region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("gagal membuat klien S3: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	exists, err := client.BucketExists(ctx, cfg.S3Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("gagal terhubung ke bucket %s: %w", cfg.S3Bucket, err)
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, cfg.S3Bucket, minio.MakeBucketOptions{Region: cfg.S3Region}); err != nil {
+			return nil, fmt.Errorf("gagal membuat bucket %s: %w", cfg.S3Bucket, err)
+		}
+	}
+
+	return &s3Storage{client: client, bucket: cfg.S3Bucket, root: cfg.UploadPath}, nil
+}
+
+func (s *s3Storage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	key, err := StorageKey(s.root, key)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+func (s *s3Storage) Open(ctx context.Context, key string) (io.ReadSeekCloser, *StorageObject, error) {
+	key, err := StorageKey(s.root, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, nil, ErrFileTidakDitemukan
+		}
+		return nil, nil, err
+	}
+	return obj, &StorageObject{
+		Key:         key,
+		Size:        info.Size,
+		ContentType: info.ContentType,
+		ModTime:     info.LastModified,
+	}, nil
+}
+
+func (s *s3Storage) Exists(ctx context.Context, key string) (bool, error) {
+	key, err := StorageKey(s.root, key)
 	if err != nil {
-		return nil, fmt.Errorf("gagal menyimpan data pemohon: %w", err)
+		return false, err
 	}
-
-	// Parse jenis perizinan ID
-	jpID, err := uuid.Parse(req.JenisPerizinanID)
+	_, err = s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("jenis perizinan ID tidak valid: %w", err)
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return false, nil
+		}
+		return false, err
 	}
+	return true, nil
+}
 
-	// Verify jenis perizinan exists
-	_, err = s.jpRepo.FindByID(jpID)
+func (s *s3Storage) Delete(ctx context.Context, key string) error {
+	key, err := StorageKey(s.root, key)
 	if err != nil {
-		return nil, fmt.Errorf("jenis perizinan tidak ditemukan: %w", err)
+		return err
 	}
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
 
-	// Create permohonan
-	permohonan := &models.Permohonan{
-		NomorPermohonan:  models.GenerateNomorPermohonan(),
-		PemohonID:        pemohon.ID,
-		JenisPerizinanID: jpID,
-		Catatan:          req.Catatan,
-		Status:           models.StatusBaru,
-		TanggalMasuk:     time.Now(),
-		Berkas:           berkasFiles,
+func (s *s3Storage) List(ctx context.Context, prefix string) ([]StorageObject, error) {
+	var list []StorageObject
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		list = append(list, StorageObject{Key: obj.Key, Size: obj.Size, ContentType: obj.ContentType, ModTime: obj.LastModified})
 	}
+	return list, nil
+}
 
-	err = s.permohonanRepo.Create(permohonan)
-	if err != nil {
-		return nil, fmt.Errorf("gagal menyimpan permohonan: %w", err)
-	}
+// ============== Scanner ==============
 
-	// Create notification for all admins
-	go s.createNotificationForAllAdmins(permohonan, pemohon)
+// ErrScannerTidakTersedia is returned when an upload cannot be scanned. Files
+// are rejected rather than accepted unscanned.
+var ErrScannerTidakTersedia = errors.New("layanan pemindaian berkas tidak tersedia")
 
-	return permohonan, nil
+// ScanResult is the verdict of a malware scan
+type ScanResult struct {
+	Bersih    bool
+	Signature string
+	Engine    string
 }
 
-func (s *permohonanService) createNotificationForAllAdmins(permohonan *models.Permohonan, pemohon *models.Pemohon) {
-	// Get jenis perizinan name
-	jp, _ := s.jpRepo.FindByID(permohonan.JenisPerizinanID)
-	jpNama := ""
-	if jp != nil {
-		jpNama = jp.Nama
-	}
+// Scanner checks uploaded content for malware
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (*ScanResult, error)
+}
 
-	// For now, we'll create notification for the first admin
-	// In production, you'd want to get all admins and create notifications for each
-	admin, _ := s.adminRepo.FindByUsername("admin")
-	if admin != nil {
-		notif := &models.Notifikasi{
-			AdminID:      admin.ID,
-			PermohonanID: permohonan.ID,
-			Pesan:        fmt.Sprintf("Permohonan baru dari %s - %s", pemohon.NamaLengkap, jpNama),
-			Dibaca:       false,
-			Tanggal:      time.Now(),
+// NewScanner returns the scanner selected by SCANNER in the config
+func NewScanner(cfg *config.Config) Scanner {
+	switch cfg.Scanner {
+	case "clamav":
+		timeout, err := strconv.Atoi(cfg.ClamAVTimeoutSeconds)
+		if err != nil || timeout <= 0 {
+			timeout = 60
 		}
-		s.notifRepo.Create(notif)
+		return NewClamAVScanner(cfg.ClamAVAddress, time.Duration(timeout)*time.Second)
+	default:
+		return NewNoopScanner()
 	}
 }
 
-func (s *permohonanService) GetAll(pagination dto.PaginationQuery) (*dto.PermohonanListResponse, error) {
-	list, total, err := s.permohonanRepo.FindAll(pagination.Page, pagination.GetLimit(), pagination.Status, pagination.Search)
+type noopScanner struct{}
+
+// NewNoopScanner returns a scanner that accepts everything. Berkas scanned
+// with it are marked as not scanned.
+func NewNoopScanner() Scanner {
+	return noopScanner{}
+}
+
+func (noopScanner) Scan(ctx context.Context, r io.Reader) (*ScanResult, error) {
+	return &ScanResult{Bersih: true}, nil
+}
+
+// clamAVScanner talks to clamd using the INSTREAM command
+type clamAVScanner struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// clamAVChunkSize must stay below clamd's StreamMaxLength per chunk
+const clamAVChunkSize = 32 * 1024
+
+// NewClamAVScanner connects to clamd at tcp://host:port, unix:///path or a
+// plain host:port
+func NewClamAVScanner(address string, timeout time.Duration) Scanner {
+	network := "tcp"
+	switch {
+	case strings.HasPrefix(address, "unix://"):
+		network, address = "unix", strings.TrimPrefix(address, "unix://")
+	case strings.HasPrefix(address, "tcp://"):
+		address = strings.TrimPrefix(address, "tcp://")
+	}
+	return &clamAVScanner{network: network, address: address, timeout: timeout}
+}
+
+func (s *clamAVScanner) Scan(ctx context.Context, r io.Reader) (*ScanResult, error) {
+	dialer := net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
 	if err != nil {
+		return nil, fmt.Errorf("gagal terhubung ke clamd: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(s.timeout))
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
 		return nil, err
 	}
 
-	var responses []dto.PermohonanResponse
-	for _, p := range list {
-		responses = append(responses, s.mapPermohonanToResponse(p))
+	// Stream the content as <uint32 length><data> chunks, ended by a zero length
+	buf := make([]byte, clamAVChunkSize)
+	size := make([]byte, 4)
+	for {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				return nil, err
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return nil, err
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return nil, readErr
+		}
+	}
+	binary.BigEndian.PutUint32(size, 0)
+	if _, err := conn.Write(size); err != nil {
+		return nil, err
 	}
 
-	totalPages := int(total) / pagination.GetLimit()
-	if int(total)%pagination.GetLimit() > 0 {
-		totalPages++
+	reply, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && err != io.EOF {
+		return nil, err
 	}
+	return parseClamAVReply(reply)
+}
 
-	return &dto.PermohonanListResponse{
-		Data:       responses,
-		Total:      total,
-		Page:       pagination.Page,
-		PerPage:    pagination.GetLimit(),
-		TotalPages: totalPages,
-	}, nil
+// parseClamAVReply interprets "stream: OK", "stream: <signature> FOUND" and
+// "<message> ERROR" replies
+func parseClamAVReply(reply string) (*ScanResult, error) {
+	reply = strings.TrimSpace(strings.TrimRight(reply, "\x00"))
+	reply = strings.TrimPrefix(reply, "stream: ")
+
+	switch {
+	case reply == "OK":
+		return &ScanResult{Bersih: true, Engine: "clamav"}, nil
+	case strings.HasSuffix(reply, " FOUND"):
+		return &ScanResult{Bersih: false, Signature: strings.TrimSuffix(reply, " FOUND"), Engine: "clamav"}, nil
+	default:
+		return nil, fmt.Errorf("balasan clamd tidak dikenal: %q", reply)
+	}
 }
 
-func (s *permohonanService) GetByID(id uuid.UUID) (*dto.PermohonanResponse, error) {
-	p, err := s.permohonanRepo.FindByID(id)
+// scanUpload scans an uploaded file before it is stored
+func scanUpload(scanner Scanner, file *multipart.FileHeader) (*ScanResult, error) {
+	src, err := file.Open()
 	if err != nil {
 		return nil, err
 	}
-	response := s.mapPermohonanToResponse(*p)
-	return &response, nil
+	defer src.Close()
+	return scanner.Scan(context.Background(), src)
 }
 
-func (s *permohonanService) GetByStatus(status string) ([]dto.PermohonanResponse, error) {
-	list, err := s.permohonanRepo.FindByStatus(models.StatusPermohonan(status))
-	if err != nil {
-		return nil, err
+// quarantineUpload keeps a copy of an infected upload on local disk so it
+// can be inspected later. It never goes into storage, so it is never
+// served to staff.
+func quarantineUpload(file *multipart.FileHeader, name, quarantineDir string) {
+	if err := os.MkdirAll(quarantineDir, 0700); err != nil {
+		log.Printf("Warning: gagal mengkarantina berkas %s: %v", name, err)
+		return
 	}
 
-	var responses []dto.PermohonanResponse
-	for _, p := range list {
-		responses = append(responses, s.mapPermohonanToResponse(p))
+	dst := filepath.Join(quarantineDir, fmt.Sprintf("%d_%s.quarantine", time.Now().Unix(), filepath.Base(name)))
+	if err := saveMultipartFile(file, dst); err != nil {
+		os.Remove(dst)
+		log.Printf("Warning: gagal mengkarantina berkas %s: %v", name, err)
+		return
 	}
-	return responses, nil
+	os.Chmod(dst, 0400)
+	log.Printf("Berkas terinfeksi dikarantina: %s", dst)
 }
 
-func (s *permohonanService) UpdateStatus(id uuid.UUID, adminID uuid.UUID, req dto.UpdatePermohonanStatusRequest) error {
-	p, err := s.permohonanRepo.FindByID(id)
+func saveMultipartFile(file *multipart.FileHeader, filePath string) error {
+	src, err := file.Open()
 	if err != nil {
 		return err
 	}
+	defer src.Close()
 
-	p.Status = models.StatusPermohonan(req.Status)
-	p.CatatanAdmin = req.CatatanAdmin
-	p.DikelolaOleh = &adminID
-
-	now := time.Now()
-	if req.Status == "diproses" {
-		p.TanggalDiproses = &now
-	} else if req.Status == "disetujui" || req.Status == "ditolak" {
-		p.TanggalSelesai = &now
-	}
-
-	err = s.permohonanRepo.Update(p)
+	dst, err := os.Create(filePath)
 	if err != nil {
 		return err
 	}
 
-	// Kirim email notifikasi saat status diproses
-	if req.Status == "diproses" {
-		emailBody := fmt.Sprintf(`Yth. %s,
-
-Dengan hormat,
-
-Permohonan %s Anda dengan nomor %s sedang dalam proses verifikasi.
+	if _, err = io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	return dst.Close()
+}
 
-Kami akan menghubungi Anda kembali setelah proses verifikasi selesai.
+// ============== Pratinjau ==============
 
-Terima kasih atas kesabaran Anda.
+const (
+	// pratinjauMaksPiksel guards against decompression bombs: larger images
+	// are not decoded for a preview
+	pratinjauMaksPiksel = 50_000_000
+	pratinjauTimeout    = 30 * time.Second
+)
 
-Hormat kami,
-Dinas Kesehatan Kota Makassar`, p.Pemohon.NamaLengkap, p.JenisPerizinan.Nama, p.NomorPermohonan)
+var peringatanPratinjauPDF sync.Once
 
-		go s.emailService.SendBalasanEmail(
-			p.Pemohon.Email,
-			p.Pemohon.NamaLengkap,
-			p.JenisPerizinan.Nama,
-			emailBody,
-			"diproses",
-			p.ID,
-			"", // no attachment
-		)
+// buatPratinjau renders a JPEG preview of an uploaded file: images scaled
+// down, PDFs by their first page. Other types have no preview and return nil.
+func buatPratinjau(ctx context.Context, cfg *config.Config, r io.Reader, mimeType string) ([]byte, error) {
+	ukuran, _ := strconv.Atoi(cfg.PreviewMaxSize)
+	if ukuran <= 0 {
+		ukuran = 600
 	}
 
-	return nil
-}
-
-func (s *permohonanService) KirimBalasan(id uuid.UUID, adminID uuid.UUID, balasanEmail, status, attachmentPath, catatanAdmin string) error {
-	p, err := s.permohonanRepo.FindByID(id)
-	if err != nil {
-		return err
+	var img image.Image
+	var err error
+	switch mimeType {
+	case "image/jpeg", "image/png":
+		img, err = decodeGambar(r)
+	case "application/pdf":
+		img, err = renderHalamanPDF(ctx, cfg.PDFPreviewCommand, r, ukuran)
+	default:
+		return nil, nil
 	}
-
-	// Update permohonan status
-	p.Status = models.StatusPermohonan(status)
-	p.BalasanEmail = balasanEmail
-	p.CatatanAdmin = catatanAdmin
-	p.LampiranSurat = attachmentPath
-	p.DikelolaOleh = &adminID
-	now := time.Now()
-	p.TanggalSelesai = &now
-
-	err = s.permohonanRepo.Update(p)
-	if err != nil {
-		return err
+	if err != nil || img == nil {
+		return nil, err
 	}
 
-	// Send email with optional attachment
-	go s.emailService.SendBalasanEmail(p.Pemohon.Email, p.Pemohon.NamaLengkap, p.JenisPerizinan.Nama, balasanEmail, status, p.ID, attachmentPath)
-
-	return nil
-}
-
-func (s *permohonanService) GetStatistik() (*dto.StatistikDashboard, error) {
-	counts, err := s.permohonanRepo.CountByStatus()
-	if err != nil {
+	img = imaging.Fit(img, ukuran, ukuran, imaging.Lanczos)
+	var buf bytes.Buffer
+	if err := imaging.Encode(&buf, img, imaging.JPEG, imaging.JPEGQuality(80)); err != nil {
 		return nil, err
 	}
-
-	return &dto.StatistikDashboard{
-		TotalPermohonan:     counts["total"],
-		PermohonanBaru:      counts["baru"],
-		PermohonanDiproses:  counts["diproses"],
-		PermohonanSelesai:   counts["selesai"],
-		PermohonanDisetujui: counts["disetujui"],
-		PermohonanDitolak:   counts["ditolak"],
-	}, nil
+	return buf.Bytes(), nil
 }
 
-func (s *permohonanService) GetRecentPermohonan(limit int) ([]dto.PermohonanResponse, error) {
-	list, err := s.permohonanRepo.GetRecentPermohonan(limit)
+// decodeGambar decodes an image after checking its dimensions
+func decodeGambar(r io.Reader) (image.Image, error) {
+	var head bytes.Buffer
+	info, _, err := image.DecodeConfig(io.TeeReader(r, &head))
 	if err != nil {
 		return nil, err
 	}
-
-	var responses []dto.PermohonanResponse
-	for _, p := range list {
-		responses = append(responses, s.mapPermohonanToResponse(p))
+	if info.Width*info.Height > pratinjauMaksPiksel {
+		return nil, fmt.Errorf("gambar terlalu besar (%dx%d)", info.Width, info.Height)
 	}
-	return responses, nil
+	return imaging.Decode(io.MultiReader(&head, r), imaging.AutoOrientation(true))
 }
 
-func (s *permohonanService) mapPermohonanToResponse(p models.Permohonan) dto.PermohonanResponse {
-	var berkasResponses []dto.BerkasResponse
-	for _, b := range p.Berkas {
-		berkasResponses = append(berkasResponses, dto.BerkasResponse{
-			ID:        b.ID,
-			NamaFile:  b.NamaFile,
-			NamaAsli:  b.NamaAsli,
-			Path:      b.Path,
-			Ukuran:    b.Ukuran,
-			MimeType:  b.MimeType,
-			CreatedAt: b.CreatedAt,
+// renderHalamanPDF renders the first page of a PDF with an external
+// renderer (pdftoppm from poppler-utils). Without it PDFs get no preview.
+func renderHalamanPDF(ctx context.Context, command string, r io.Reader, ukuran int) (image.Image, error) {
+	if command == "" {
+		return nil, nil
+	}
+	bin, err := exec.LookPath(command)
+	if err != nil {
+		peringatanPratinjauPDF.Do(func() {
+			log.Printf("Warning: %s tidak ditemukan, pratinjau PDF dinonaktifkan", command)
 		})
+		return nil, nil
 	}
 
-	return dto.PermohonanResponse{
-		ID:              p.ID,
-		NomorPermohonan: p.NomorPermohonan,
-		Pemohon: dto.PemohonResponse{
-			ID:           p.Pemohon.ID,
-			NamaLengkap:  p.Pemohon.NamaLengkap,
-			NomorTelepon: p.Pemohon.NomorTelepon,
-			Email:        p.Pemohon.Email,
-			Alamat:       p.Pemohon.Alamat,
-		},
-		JenisPerizinan: dto.JenisPerizinanResponse{
-			ID:          p.JenisPerizinan.ID,
-			Nama:        p.JenisPerizinan.Nama,
-			Deskripsi:   p.JenisPerizinan.Deskripsi,
-			Persyaratan: p.JenisPerizinan.Persyaratan,
-			Aktif:       p.JenisPerizinan.Aktif,
-			CreatedAt:   p.JenisPerizinan.CreatedAt,
-		},
-		Berkas:          berkasResponses,
-		Catatan:         p.Catatan,
-		Status:          string(p.Status),
-		TanggalMasuk:    p.TanggalMasuk,
-		TanggalDiproses: p.TanggalDiproses,
-		TanggalSelesai:  p.TanggalSelesai,
-		BalasanEmail:    p.BalasanEmail,
-		CatatanAdmin:    p.CatatanAdmin,
-		LampiranSurat:   p.LampiranSurat,
-		CreatedAt:       p.CreatedAt,
+	// Every file of the run lives in its own temp dir; the paths are made
+	// absolute and the command runs inside it, so nothing ends up in the
+	// working directory even when TMPDIR is relative.
+	dir, err := os.MkdirTemp("", "pratinjau-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	if dir, err = filepath.Abs(dir); err != nil {
+		return nil, err
 	}
-}
-
-// ============== Notifikasi Service ==============
-
-type NotifikasiService interface {
-	GetByAdminID(adminID uuid.UUID, unreadOnly bool) ([]dto.NotifikasiResponse, error)
-	MarkAsRead(id uuid.UUID) error
-	MarkAllAsRead(adminID uuid.UUID) error
-	CountUnread(adminID uuid.UUID) (int64, error)
-}
-
-type notifikasiService struct {
-	repo repositories.NotifikasiRepository
-}
-
-func NewNotifikasiService(repo repositories.NotifikasiRepository) NotifikasiService {
-	return &notifikasiService{repo: repo}
-}
 
-func (s *notifikasiService) GetByAdminID(adminID uuid.UUID, unreadOnly bool) ([]dto.NotifikasiResponse, error) {
-	list, err := s.repo.FindByAdminID(adminID, unreadOnly)
+	input := filepath.Join(dir, "input.pdf")
+	f, err := os.Create(input)
 	if err != nil {
 		return nil, err
 	}
-
-	var responses []dto.NotifikasiResponse
-	for _, n := range list {
-		responses = append(responses, dto.NotifikasiResponse{
-			ID:           n.ID,
-			PermohonanID: n.PermohonanID,
-			Pesan:        n.Pesan,
-			Dibaca:       n.Dibaca,
-			Tanggal:      n.Tanggal,
-		})
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
 	}
-	return responses, nil
-}
-
-func (s *notifikasiService) MarkAsRead(id uuid.UUID) error {
-	return s.repo.MarkAsRead(id)
-}
 
-func (s *notifikasiService) MarkAllAsRead(adminID uuid.UUID) error {
-	return s.repo.MarkAllAsRead(adminID)
-}
+	ctx, cancel := context.WithTimeout(ctx, pratinjauTimeout)
+	defer cancel()
+	output := filepath.Join(dir, "halaman")
+	cmd := exec.CommandContext(ctx, bin, "-png", "-f", "1", "-l", "1", "-singlefile",
+		"-scale-to", strconv.Itoa(ukuran), input, output)
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("%s: %v: %s", command, err, strings.TrimSpace(string(out)))
+	}
 
-func (s *notifikasiService) CountUnread(adminID uuid.UUID) (int64, error) {
-	return s.repo.CountUnread(adminID)
+	png, err := os.Open(output + ".png")
+	if err != nil {
+		return nil, err
+	}
+	defer png.Close()
+	return decodeGambar(png)
 }
 
-// ============== Email Service ==============
-
-type EmailService interface {
-	SendBalasanEmail(toEmail, namaPemohon, jenisPerizinan, balasan, status string, permohonanID uuid.UUID, attachmentPath string) error
-}
+// ============== Helpers ==============
 
-type emailService struct {
-	cfg          *config.Config
-	emailLogRepo repositories.EmailLogRepository
+// kunciRahasia is the key for secrets stored in the database: TOTP secrets
+// and the tokens of queued emails
+func kunciRahasia(cfg *config.Config) string {
+	if cfg.TOTPEncryptionKey != "" {
+		return cfg.TOTPEncryptionKey
+	}
+	return cfg.JWTSecret
 }
 
-func NewEmailService(cfg *config.Config, emailLogRepo repositories.EmailLogRepository) EmailService {
-	return &emailService{cfg: cfg, emailLogRepo: emailLogRepo}
+// enkripsiRahasia encrypts a secret stored in the database with AES-GCM
+// under a key derived from kunci
+func enkripsiRahasia(kunci, plaintext string) (string, error) {
+	gcm, err := gcmDariKunci(kunci)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
 }
 
-func (s *emailService) SendBalasanEmail(toEmail, namaPemohon, jenisPerizinan, balasan, status string, permohonanID uuid.UUID, attachmentPath string) error {
-	statusText := "Diproses"
-	if status == "disetujui" {
-		statusText = "Disetujui"
-	} else if status == "ditolak" {
-		statusText = "Ditolak"
+func dekripsiRahasia(kunci, ciphertext string) (string, error) {
+	gcm, err := gcmDariKunci(kunci)
+	if err != nil {
+		return "", err
 	}
-
-	subject := fmt.Sprintf("Balasan Permohonan %s - %s", jenisPerizinan, statusText)
-
-	// Tambahkan informasi lampiran jika ada
-	attachmentInfo := ""
-	if attachmentPath != "" {
-		attachmentInfo = `<p style="color: #666; font-size: 14px; margin-top: 15px;">📎 <strong>Surat balasan terlampir pada email ini.</strong></p>`
+	data, err := base64.StdEncoding.DecodeString(ciphertext)
+	if err != nil {
+		return "", err
 	}
-
-	body := fmt.Sprintf(`
-		<html>
-		<body style="font-family: Arial, sans-serif; line-height: 1.6;">
-			<div style="max-width: 600px; margin: 0 auto; padding: 20px;">
-				<div style="background-color: #1e40af; color: white; padding: 20px; text-align: center;">
-					<h1>Dinas Kesehatan Kota Makassar</h1>
-				</div>
-				<div style="padding: 20px; background-color: #f8fafc;">
-					<h2>Yth. %s,</h2>
-					<p>Berikut adalah balasan untuk permohonan <strong>%s</strong> Anda:</p>
-					<div style="background-color: white; border-left: 4px solid %s; padding: 15px; margin: 20px 0;">
-						<p><strong>Status: %s</strong></p>
-						<p>%s</p>
-					</div>
-					%s
-					<p>Jika ada pertanyaan lebih lanjut, silakan hubungi kami.</p>
-					<hr style="margin: 20px 0;">
-					<p style="color: #666; font-size: 12px;">
-						Email ini dikirim secara otomatis dari sistem perizinan Dinas Kesehatan Kota Makassar.
-					</p>
-				</div>
-			</div>
-		</body>
-		</html>
-	`, namaPemohon, jenisPerizinan, getStatusColor(status), statusText, balasan, attachmentInfo)
-
-	// Create email log entry
-	emailLog := &models.EmailLog{
-		PermohonanID: permohonanID,
-		EmailTujuan:  toEmail,
-		Subjek:       subject,
-		Isi:          balasan,
-		Status:       "pending",
+	if len(data) < gcm.NonceSize() {
+		return "", errors.New("ciphertext terlalu pendek")
 	}
-
-	// Send email via SMTP
-	port, _ := strconv.Atoi(s.cfg.SMTPPort)
-	m := gomail.NewMessage()
-	m.SetHeader("From", s.cfg.SMTPFrom)
-	m.SetHeader("To", toEmail)
-	m.SetHeader("Subject", subject)
-	m.SetBody("text/html", body)
-
-	// Attach file if provided
-	if attachmentPath != "" {
-		m.Attach(attachmentPath)
+	plaintext, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
 	}
+	return string(plaintext), nil
+}
 
-	d := gomail.NewDialer(s.cfg.SMTPHost, port, s.cfg.SMTPUsername, s.cfg.SMTPPassword)
-
-	err := d.DialAndSend(m)
-	now := time.Now()
+func gcmDariKunci(kunci string) (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte(kunci))
+	block, err := aes.NewCipher(key[:])
 	if err != nil {
-		emailLog.Status = "failed"
-		emailLog.Error = err.Error()
-		s.emailLogRepo.Create(emailLog)
-		return err
+		return nil, err
 	}
+	return cipher.NewGCM(block)
+}
 
-	emailLog.Status = "sent"
-	emailLog.SentAt = &now
-	s.emailLogRepo.Create(emailLog)
+// generateToken returns a random URL-safe token for links sent by email
+func generateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
 
-	return nil
+// hashToken returns the SHA-256 hex digest stored instead of the raw token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
-func getStatusColor(status string) string {
-	switch status {
-	case "disetujui":
-		return "#10b981" // green
-	case "diproses":
-		return "#3b82f6" // blue
-	case "ditolak":
-		return "#ef4444" // red
-	default:
-		return "#f59e0b" // yellow (baru)
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
 	}
+	return false
 }
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeClamd accepts one INSTREAM session per connection, records the
+// streamed content and the chunk sizes, and answers with reply
+type fakeClamd struct {
+	t        *testing.T
+	listener net.Listener
+	reply    string
+	received chan []byte
+	chunks   chan []int
+}
+
+func startFakeClamd(t *testing.T, reply string) *fakeClamd {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	f := &fakeClamd{
+		t:        t,
+		listener: listener,
+		reply:    reply,
+		received: make(chan []byte, 1),
+		chunks:   make(chan []int, 1),
+	}
+	t.Cleanup(func() { listener.Close() })
+	go f.serve()
+	return f
+}
+
+func (f *fakeClamd) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeClamd) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	command := make([]byte, len("zINSTREAM\x00"))
+	if _, err := io.ReadFull(conn, command); err != nil {
+		f.t.Errorf("read command: %v", err)
+		return
+	}
+	if string(command) != "zINSTREAM\x00" {
+		f.t.Errorf("command = %q, want %q", command, "zINSTREAM\x00")
+		return
+	}
+
+	var data bytes.Buffer
+	var sizes []int
+	size := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(conn, size); err != nil {
+			f.t.Errorf("read chunk length: %v", err)
+			return
+		}
+		n := binary.BigEndian.Uint32(size)
+		if n == 0 {
+			break
+		}
+		sizes = append(sizes, int(n))
+		if _, err := io.CopyN(&data, conn, int64(n)); err != nil {
+			f.t.Errorf("read chunk: %v", err)
+			return
+		}
+	}
+	f.received <- data.Bytes()
+	f.chunks <- sizes
+
+	if f.reply != "" {
+		conn.Write([]byte(f.reply + "\x00"))
+	}
+}
+
+func TestClamAVScannerInstream(t *testing.T) {
+	// Larger than one chunk so the framing of several chunks is exercised
+	content := bytes.Repeat([]byte("berkas-pemohon "), 5000)
+
+	tests := []struct {
+		name          string
+		reply         string
+		wantErr       bool
+		wantBersih    bool
+		wantSignature string
+	}{
+		{name: "bersih", reply: "stream: OK", wantBersih: true},
+		{name: "terinfeksi", reply: "stream: Eicar-Test-Signature FOUND", wantSignature: "Eicar-Test-Signature"},
+		{name: "error dari clamd", reply: "INSTREAM size limit exceeded. ERROR", wantErr: true},
+		{name: "koneksi ditutup tanpa balasan", reply: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := startFakeClamd(t, tt.reply)
+			scanner := NewClamAVScanner("tcp://"+fake.listener.Addr().String(), 5*time.Second)
+
+			result, err := scanner.Scan(context.Background(), bytes.NewReader(content))
+
+			select {
+			case got := <-fake.received:
+				if !bytes.Equal(got, content) {
+					t.Errorf("clamd received %d bytes, want %d", len(got), len(content))
+				}
+				for _, n := range <-fake.chunks {
+					if n > clamAVChunkSize {
+						t.Errorf("chunk of %d bytes exceeds %d", n, clamAVChunkSize)
+					}
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("clamd did not receive a complete stream")
+			}
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Scan() = %+v, want error", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Scan() error = %v", err)
+			}
+			if result.Bersih != tt.wantBersih || result.Signature != tt.wantSignature || result.Engine != "clamav" {
+				t.Errorf("Scan() = %+v, want Bersih=%v Signature=%q Engine=clamav", result, tt.wantBersih, tt.wantSignature)
+			}
+		})
+	}
+}
+
+func TestClamAVScannerTidakTerhubung(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+
+	scanner := NewClamAVScanner(address, time.Second)
+	if _, err := scanner.Scan(context.Background(), bytes.NewReader([]byte("isi"))); err == nil {
+		t.Fatal("Scan() without clamd returned no error")
+	}
+}
+
+func TestParseClamAVReply(t *testing.T) {
+	tests := []struct {
+		reply         string
+		wantErr       bool
+		wantBersih    bool
+		wantSignature string
+	}{
+		{reply: "stream: OK\x00", wantBersih: true},
+		{reply: "stream: OK\n", wantBersih: true},
+		{reply: "stream: Win.Test.EICAR_HDB-1 FOUND\x00", wantSignature: "Win.Test.EICAR_HDB-1"},
+		{reply: "INSTREAM size limit exceeded. ERROR\x00", wantErr: true},
+		{reply: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		result, err := parseClamAVReply(tt.reply)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseClamAVReply(%q) = %+v, want error", tt.reply, result)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseClamAVReply(%q) error = %v", tt.reply, err)
+			continue
+		}
+		if result.Bersih != tt.wantBersih || result.Signature != tt.wantSignature {
+			t.Errorf("parseClamAVReply(%q) = %+v, want Bersih=%v Signature=%q", tt.reply, result, tt.wantBersih, tt.wantSignature)
+		}
+	}
+}
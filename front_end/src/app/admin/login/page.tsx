@@ -3,7 +3,11 @@
 import { useState } from "react";
 import { useRouter } from "next/navigation";
 import Image from "next/image";
-import { authAPI } from "@/lib/api";
+import { authAPI, LoginResponse, Setup2FAData } from "@/lib/api";
+
+// Login steps: password, then a TOTP or recovery code for admins with 2FA,
+// or enrollment when the role requires 2FA
+type TahapLogin = "password" | "kode" | "setup" | "pemulihan";
 
 export default function AdminLogin() {
   const router = useRouter();
@@ -14,6 +18,49 @@ export default function AdminLogin() {
   const [error, setError] = useState("");
   const [isLoading, setIsLoading] = useState(false);
   const [showPassword, setShowPassword] = useState(false);
+  const [tahap, setTahap] = useState<TahapLogin>("password");
+  const [tokenTantangan, setTokenTantangan] = useState("");
+  const [kode, setKode] = useState("");
+  const [setup, setSetup] = useState<Setup2FAData | null>(null);
+  const [kodePemulihan, setKodePemulihan] = useState<string[]>([]);
+
+  const simpanSesi = (data: LoginResponse) => {
+    // Simpan token dan status login ke localStorage
+    localStorage.setItem("adminToken", data.token);
+    localStorage.setItem("adminRefreshToken", data.refresh_token);
+    localStorage.setItem("adminLoggedIn", "true");
+    localStorage.setItem("adminLoginTime", new Date().toISOString());
+    localStorage.setItem("adminData", JSON.stringify(data.admin));
+  };
+
+  const handleKodeSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setIsLoading(true);
+    setError("");
+
+    try {
+      const response = tahap === "setup"
+        ? await authAPI.aktifkanSetup2FA(tokenTantangan, kode)
+        : await authAPI.verifikasi2FA(tokenTantangan, kode);
+
+      if (response.success && response.data) {
+        simpanSesi(response.data);
+        if (response.data.kode_pemulihan?.length) {
+          // Show the recovery codes once before entering the dashboard
+          setKodePemulihan(response.data.kode_pemulihan);
+          setTahap("pemulihan");
+        } else {
+          router.push("/admin");
+        }
+      } else {
+        setError(response.error || response.message || "Kode autentikasi tidak valid");
+      }
+    } catch {
+      setError("Gagal terhubung ke server. Pastikan backend sudah berjalan.");
+    }
+
+    setIsLoading(false);
+  };
 
   const handleChange = (e: React.ChangeEvent<HTMLInputElement>) => {
     const { name, value } = e.target;
@@ -35,15 +82,27 @@ export default function AdminLogin() {
         password: formData.password,
       });
 
-      if (response.success && response.data) {
-        // Simpan token dan status login ke localStorage
-        localStorage.setItem("adminToken", response.data.token);
-        localStorage.setItem("adminLoggedIn", "true");
-        localStorage.setItem("adminLoginTime", new Date().toISOString());
-        localStorage.setItem("adminData", JSON.stringify(response.data.admin));
+      if (response.success && response.data?.token_tantangan) {
+        const token = response.data.token_tantangan;
+        setTokenTantangan(token);
+        setKode("");
+        if (response.data.setup_2fa) {
+          const setupResponse = await authAPI.mulaiSetup2FA(token);
+          if (!setupResponse.success || !setupResponse.data) {
+            setError(setupResponse.error || setupResponse.message || "Gagal memulai pendaftaran 2FA");
+            setIsLoading(false);
+            return;
+          }
+          setSetup(setupResponse.data);
+          setTahap("setup");
+        } else {
+          setTahap("kode");
+        }
+      } else if (response.success && response.data) {
+        simpanSesi(response.data);
         router.push("/admin");
       } else {
-        setError(response.message || "Username atau password salah!");
+        setError(response.error || response.message || "Username atau password salah!");
       }
     } catch {
       setError("Gagal terhubung ke server. Pastikan backend sudah berjalan.");
@@ -81,6 +140,89 @@ export default function AdminLogin() {
               <p className="text-gray-500 text-sm mt-1">Silakan masuk untuk melanjutkan</p>
             </div>
 
+            {tahap === "pemulihan" ? (
+              <div className="space-y-5">
+                <p className="text-sm text-gray-600">
+                  Simpan kode pemulihan berikut di tempat yang aman. Setiap kode hanya dapat
+                  dipakai sekali jika Anda kehilangan aplikasi autentikator, dan tidak akan
+                  ditampilkan lagi.
+                </p>
+                <div className="grid grid-cols-2 gap-2 bg-gray-50 border border-gray-200 rounded-lg p-4 font-mono text-sm text-gray-800">
+                  {kodePemulihan.map((k) => (
+                    <span key={k}>{k}</span>
+                  ))}
+                </div>
+                <button
+                  type="button"
+                  onClick={() => router.push("/admin")}
+                  className="w-full bg-blue-600 hover:bg-blue-700 text-white font-semibold py-3 px-4 rounded-lg transition-all duration-200"
+                >
+                  Saya sudah menyimpan kode ini
+                </button>
+              </div>
+            ) : tahap !== "password" ? (
+              <form onSubmit={handleKodeSubmit} className="space-y-5">
+                {tahap === "setup" && setup && (
+                  <div className="space-y-3 text-center">
+                    <p className="text-sm text-gray-600">
+                      Akun Anda wajib memakai autentikasi dua faktor. Pindai QR code berikut
+                      dengan aplikasi autentikator (Google Authenticator, Authy, dll.).
+                    </p>
+                    {/* eslint-disable-next-line @next/next/no-img-element */}
+                    <img src={setup.qr_code} alt="QR code 2FA" className="mx-auto w-48 h-48" />
+                    <p className="text-xs text-gray-500 break-all">
+                      Atau masukkan kunci secara manual: <span className="font-mono">{setup.secret}</span>
+                    </p>
+                  </div>
+                )}
+                <div>
+                  <label htmlFor="kode" className="block text-sm font-medium text-gray-700 mb-2">
+                    {tahap === "setup" ? "Kode dari aplikasi autentikator" : "Kode autentikasi atau kode pemulihan"}
+                  </label>
+                  <input
+                    type="text"
+                    id="kode"
+                    name="kode"
+                    value={kode}
+                    onChange={(e) => {
+                      setKode(e.target.value);
+                      setError("");
+                    }}
+                    autoComplete="one-time-code"
+                    autoFocus
+                    className="w-full px-4 py-3 border border-gray-300 rounded-lg focus:ring-2 focus:ring-blue-500 focus:border-blue-500 transition-colors text-gray-900 tracking-widest text-center"
+                    placeholder="123456"
+                    required
+                  />
+                </div>
+
+                {error && (
+                  <div className="bg-red-50 border border-red-200 text-red-600 px-4 py-3 rounded-lg text-sm">
+                    {error}
+                  </div>
+                )}
+
+                <button
+                  type="submit"
+                  disabled={isLoading}
+                  className="w-full bg-blue-600 hover:bg-blue-700 text-white font-semibold py-3 px-4 rounded-lg transition-all duration-200 disabled:opacity-70 disabled:cursor-not-allowed"
+                >
+                  {isLoading ? "Memproses..." : tahap === "setup" ? "Aktifkan & Masuk" : "Verifikasi"}
+                </button>
+                <button
+                  type="button"
+                  onClick={() => {
+                    setTahap("password");
+                    setTokenTantangan("");
+                    setSetup(null);
+                    setError("");
+                  }}
+                  className="w-full text-sm text-gray-500 hover:text-gray-700"
+                >
+                  Kembali
+                </button>
+              </form>
+            ) : (
             <form onSubmit={handleSubmit} className="space-y-5">
               {/* Username Field */}
               <div>
@@ -195,6 +337,11 @@ export default function AdminLogin() {
                     )}
                   </button>
                 </div>
+                <div className="text-right mt-2">
+                  <a href="/admin/lupa-password" className="text-sm text-blue-600 hover:text-blue-700 transition-colors">
+                    Lupa password?
+                  </a>
+                </div>
               </div>
 
               {/* Error Message */}
@@ -267,6 +414,7 @@ export default function AdminLogin() {
                 )}
               </button>
             </form>
+            )}
           </div>
         </div>
 
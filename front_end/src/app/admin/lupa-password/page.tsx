@@ -0,0 +1,131 @@
+"use client";
+
+import { useState } from "react";
+import Image from "next/image";
+import { authAPI } from "@/lib/api";
+
+export default function LupaPassword() {
+  const [email, setEmail] = useState("");
+  const [error, setError] = useState("");
+  const [pesan, setPesan] = useState("");
+  const [isLoading, setIsLoading] = useState(false);
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    setIsLoading(true);
+    setError("");
+
+    try {
+      const response = await authAPI.lupaPassword(email);
+      if (response.success) {
+        setPesan(response.message);
+      } else {
+        setError(response.error || response.message || "Gagal mengirim tautan reset password");
+      }
+    } catch {
+      setError("Gagal terhubung ke server. Pastikan backend sudah berjalan.");
+    }
+
+    setIsLoading(false);
+  };
+
+  return (
+    <div className="min-h-screen bg-gradient-to-br from-blue-50 to-blue-100 flex items-center justify-center p-4">
+      <div className="w-full max-w-md">
+        <div className="bg-white rounded-2xl shadow-xl border border-blue-100 overflow-hidden">
+          {/* Header */}
+          <div className="bg-gradient-to-r from-blue-600 to-blue-700 px-8 py-8 text-center">
+            <div className="inline-flex items-center justify-center w-20 h-20 bg-white rounded-full shadow-lg mb-4">
+              <Image
+                src="/logo-kotamakassar.png"
+                alt="Logo Kota Makassar"
+                width={50}
+                height={50}
+                className="object-contain"
+              />
+            </div>
+            <h1 className="text-2xl font-bold text-white mb-1">Admin Panel</h1>
+            <p className="text-blue-100 text-sm">
+              Dinas Kesehatan Kota Makassar
+            </p>
+          </div>
+
+          {/* Form */}
+          <div className="p-8">
+            <div className="text-center mb-6">
+              <h2 className="text-xl font-semibold text-gray-800">Lupa Password</h2>
+              <p className="text-gray-500 text-sm mt-1">
+                Masukkan email akun admin Anda untuk menerima tautan reset password
+              </p>
+            </div>
+
+            {pesan ? (
+              <div className="bg-green-50 border border-green-200 text-green-700 px-4 py-3 rounded-lg text-sm">
+                {pesan}
+              </div>
+            ) : (
+              <form onSubmit={handleSubmit} className="space-y-5">
+                <div>
+                  <label htmlFor="email" className="block text-sm font-medium text-gray-700 mb-2">
+                    Email
+                  </label>
+                  <input
+                    type="email"
+                    id="email"
+                    name="email"
+                    value={email}
+                    onChange={(e) => {
+                      setEmail(e.target.value);
+                      setError("");
+                    }}
+                    autoComplete="email"
+                    className="w-full px-4 py-3 border border-gray-300 rounded-lg focus:ring-2 focus:ring-blue-500 focus:border-blue-500 transition-colors text-gray-900"
+                    placeholder="nama@dinkes.makassar.go.id"
+                    required
+                  />
+                </div>
+
+                {error && (
+                  <div className="bg-red-50 border border-red-200 text-red-600 px-4 py-3 rounded-lg text-sm">
+                    {error}
+                  </div>
+                )}
+
+                <button
+                  type="submit"
+                  disabled={isLoading}
+                  className="w-full bg-blue-600 hover:bg-blue-700 text-white font-semibold py-3 px-4 rounded-lg transition-all duration-200 disabled:opacity-70 disabled:cursor-not-allowed"
+                >
+                  {isLoading ? "Memproses..." : "Kirim Tautan Reset"}
+                </button>
+              </form>
+            )}
+          </div>
+        </div>
+
+        {/* Footer */}
+        <div className="text-center mt-6">
+          <a
+            href="/admin/login"
+            className="text-blue-600 hover:text-blue-700 text-sm inline-flex items-center gap-1 transition-colors"
+          >
+            <svg
+              className="w-4 h-4"
+              fill="none"
+              stroke="currentColor"
+              viewBox="0 0 24 24"
+            >
+              <path
+                strokeLinecap="round"
+                strokeLinejoin="round"
+                strokeWidth={2}
+                d="M10 19l-7-7m0 0l7-7m-7 7h18"
+              />
+            </svg>
+            Kembali ke Login
+          </a>
+        </div>
+      </div>
+    </div>
+  );
+}
@@ -19,6 +19,7 @@ import {
   adminAPI,
   mapPermohonanToFrontend,
   mapJenisPerizinanToFrontend,
+  mapPersyaratanToAPI,
   mapNotifikasiToFrontend,
   mapAdminToFrontend,
 } from "@/lib/api";
@@ -29,7 +30,7 @@ interface Admin {
   username: string;
   email: string;
   namaLengkap: string;
-  role: 'super_admin' | 'admin';
+  role: AdminRole;
   isActive: boolean;
   createdAt: Date;
 }
@@ -48,6 +49,7 @@ export default function AdminPage() {
   const [showSuccessModal, setShowSuccessModal] = useState(false);
   const [successMessage, setSuccessMessage] = useState("");
   const [adminRole, setAdminRole] = useState<AdminRole>("admin");
+  const [adminIzin, setAdminIzin] = useState<string[]>([]);
   const [currentAdminId, setCurrentAdminId] = useState<string>("");
   const [adminName, setAdminName] = useState<string>("Admin");
   const [adminUsername, setAdminUsername] = useState<string>("");
@@ -74,8 +76,9 @@ export default function AdminPage() {
         const response = await authAPI.getProfile();
         if (response.success && response.data) {
           setIsAuthenticated(true);
-          // Set role and id from response
+          // Set role, permissions and id from response
           setAdminRole(response.data.role || "admin");
+          setAdminIzin(response.data.izin || []);
           setCurrentAdminId(response.data.id);
           setAdminName(response.data.nama_lengkap || response.data.username || "Admin");
           setAdminUsername(response.data.username || "");
@@ -90,6 +93,7 @@ export default function AdminPage() {
           try {
             const parsed = JSON.parse(adminData);
             setAdminRole(parsed.role || "admin");
+            setAdminIzin(parsed.izin || []);
             setCurrentAdminId(parsed.id || "");
           } catch {
             setAdminRole("admin");
@@ -142,8 +146,8 @@ export default function AdminPage() {
         setNotifikasi(mappedData);
       }
 
-      // Fetch admin list (only for super_admin)
-      if (adminRole === "super_admin") {
+      // Fetch admin list (only for roles that manage admins)
+      if (adminIzin.includes("admin.kelola")) {
         const adminResponse = await adminAPI.getAll(1, 100);
         if (adminResponse.success && adminResponse.data?.data) {
           const mappedData = adminResponse.data.data.map(mapAdminToFrontend);
@@ -155,7 +159,7 @@ export default function AdminPage() {
     } finally {
       setIsLoading(false);
     }
-  }, [isAuthenticated, adminRole]);
+  }, [isAuthenticated, adminIzin]);
 
   useEffect(() => {
     if (isAuthenticated) {
@@ -236,7 +240,7 @@ export default function AdminPage() {
       const response = await jenisPerizinanAPI.create({
         nama: perizinan.nama,
         deskripsi: perizinan.deskripsi,
-        persyaratan: perizinan.persyaratan,
+        persyaratan: perizinan.persyaratan.map(mapPersyaratanToAPI),
         aktif: perizinan.aktif,
       });
 
@@ -256,7 +260,7 @@ export default function AdminPage() {
       const response = await jenisPerizinanAPI.update(perizinan.id, {
         nama: perizinan.nama,
         deskripsi: perizinan.deskripsi,
-        persyaratan: perizinan.persyaratan,
+        persyaratan: perizinan.persyaratan.map(mapPersyaratanToAPI),
         aktif: perizinan.aktif,
       });
 
@@ -305,7 +309,7 @@ export default function AdminPage() {
         setShowSuccessModal(true);
         fetchData();
       } else {
-        alert("Gagal menambah admin: " + response.message);
+        alert("Gagal menambah admin: " + (response.error || response.message));
       }
     } catch {
       alert("Gagal terhubung ke server");
@@ -359,7 +363,7 @@ export default function AdminPage() {
         setSuccessMessage("Password berhasil direset!");
         setShowSuccessModal(true);
       } else {
-        alert("Gagal mereset password: " + response.message);
+        alert("Gagal mereset password: " + (response.error || response.message));
       }
     } catch {
       alert("Gagal terhubung ke server");
@@ -453,8 +457,8 @@ export default function AdminPage() {
           />
         );
       case "kelola-admin":
-        // Only super_admin can access this page
-        if (adminRole !== "super_admin") {
+        // Only roles with the admin.kelola permission can access this page
+        if (!adminIzin.includes("admin.kelola")) {
           return (
             <div className="bg-white rounded-xl shadow-sm border border-gray-200 p-8 text-center">
               <div className="w-16 h-16 bg-red-100 rounded-full flex items-center justify-center mx-auto mb-4">
@@ -463,7 +467,7 @@ export default function AdminPage() {
                 </svg>
               </div>
               <h3 className="text-lg font-bold text-gray-800 mb-2">Akses Ditolak</h3>
-              <p className="text-gray-600">Anda tidak memiliki izin untuk mengakses halaman ini. Hanya Super Admin yang dapat mengelola admin.</p>
+              <p className="text-gray-600">Anda tidak memiliki izin untuk mengakses halaman ini. Role Anda tidak memiliki izin mengelola admin.</p>
             </div>
           );
         }
@@ -510,6 +514,7 @@ export default function AdminPage() {
         onToggle={() => setIsSidebarOpen(!isSidebarOpen)}
         jumlahPermohonanBaru={statistik.permohonanBaru}
         adminRole={adminRole}
+        adminIzin={adminIzin}
       />
 
       {/* Main Content */}
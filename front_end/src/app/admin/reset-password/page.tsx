@@ -0,0 +1,198 @@
+"use client";
+
+import { Suspense, useEffect, useState } from "react";
+import { useRouter, useSearchParams } from "next/navigation";
+import Image from "next/image";
+import { authAPI, deskripsiKebijakanPassword, KebijakanPasswordData } from "@/lib/api";
+
+function FormResetPassword() {
+  const router = useRouter();
+  const token = useSearchParams().get("token") || "";
+  const [username, setUsername] = useState("");
+  const [kebijakan, setKebijakan] = useState<KebijakanPasswordData | null>(null);
+  const [tokenValid, setTokenValid] = useState<boolean | null>(null);
+  const [password, setPassword] = useState("");
+  const [konfirmasi, setKonfirmasi] = useState("");
+  const [error, setError] = useState("");
+  const [berhasil, setBerhasil] = useState(false);
+  const [isLoading, setIsLoading] = useState(false);
+
+  useEffect(() => {
+    if (!token) {
+      setTokenValid(false);
+      return;
+    }
+    authAPI.cekTokenReset(token)
+      .then((response) => {
+        setTokenValid(response.success);
+        if (response.success && response.data) setUsername(response.data.username);
+      })
+      .catch(() => setTokenValid(false));
+    authAPI.getKebijakanPassword()
+      .then((response) => {
+        if (response.success && response.data) setKebijakan(response.data);
+      })
+      .catch(() => {});
+  }, [token]);
+
+  const handleSubmit = async (e: React.FormEvent) => {
+    e.preventDefault();
+    if (password !== konfirmasi) {
+      setError("Konfirmasi password tidak sama");
+      return;
+    }
+
+    setIsLoading(true);
+    setError("");
+
+    try {
+      const response = await authAPI.resetPassword(token, password);
+      if (response.success) {
+        setBerhasil(true);
+      } else {
+        setError(response.error || response.message || "Gagal mereset password");
+      }
+    } catch {
+      setError("Gagal terhubung ke server. Pastikan backend sudah berjalan.");
+    }
+
+    setIsLoading(false);
+  };
+
+  if (tokenValid === null) {
+    return <p className="text-center text-sm text-gray-500">Memeriksa tautan...</p>;
+  }
+
+  if (!tokenValid) {
+    return (
+      <div className="space-y-5">
+        <div className="bg-red-50 border border-red-200 text-red-600 px-4 py-3 rounded-lg text-sm">
+          Tautan reset password tidak valid, sudah dipakai, atau sudah kadaluarsa.
+        </div>
+        <a
+          href="/admin/lupa-password"
+          className="block w-full text-center bg-blue-600 hover:bg-blue-700 text-white font-semibold py-3 px-4 rounded-lg transition-all duration-200"
+        >
+          Minta Tautan Baru
+        </a>
+      </div>
+    );
+  }
+
+  if (berhasil) {
+    return (
+      <div className="space-y-5">
+        <div className="bg-green-50 border border-green-200 text-green-700 px-4 py-3 rounded-lg text-sm">
+          Password berhasil direset. Silakan login dengan password baru.
+        </div>
+        <button
+          type="button"
+          onClick={() => router.push("/admin/login")}
+          className="w-full bg-blue-600 hover:bg-blue-700 text-white font-semibold py-3 px-4 rounded-lg transition-all duration-200"
+        >
+          Ke Halaman Login
+        </button>
+      </div>
+    );
+  }
+
+  return (
+    <form onSubmit={handleSubmit} className="space-y-5">
+      <p className="text-sm text-gray-600">
+        Buat password baru untuk akun <strong>{username}</strong>.
+      </p>
+      <div>
+        <label htmlFor="password" className="block text-sm font-medium text-gray-700 mb-2">
+          Password Baru
+        </label>
+        <input
+          type="password"
+          id="password"
+          name="password"
+          value={password}
+          onChange={(e) => {
+            setPassword(e.target.value);
+            setError("");
+          }}
+          autoComplete="new-password"
+          minLength={kebijakan?.min_panjang}
+          className="w-full px-4 py-3 border border-gray-300 rounded-lg focus:ring-2 focus:ring-blue-500 focus:border-blue-500 transition-colors text-gray-900"
+          required
+        />
+        {kebijakan && (
+          <p className="text-xs text-gray-500 mt-1">{deskripsiKebijakanPassword(kebijakan)}</p>
+        )}
+      </div>
+      <div>
+        <label htmlFor="konfirmasi" className="block text-sm font-medium text-gray-700 mb-2">
+          Konfirmasi Password Baru
+        </label>
+        <input
+          type="password"
+          id="konfirmasi"
+          name="konfirmasi"
+          value={konfirmasi}
+          onChange={(e) => {
+            setKonfirmasi(e.target.value);
+            setError("");
+          }}
+          autoComplete="new-password"
+          className="w-full px-4 py-3 border border-gray-300 rounded-lg focus:ring-2 focus:ring-blue-500 focus:border-blue-500 transition-colors text-gray-900"
+          required
+        />
+      </div>
+
+      {error && (
+        <div className="bg-red-50 border border-red-200 text-red-600 px-4 py-3 rounded-lg text-sm">
+          {error}
+        </div>
+      )}
+
+      <button
+        type="submit"
+        disabled={isLoading}
+        className="w-full bg-blue-600 hover:bg-blue-700 text-white font-semibold py-3 px-4 rounded-lg transition-all duration-200 disabled:opacity-70 disabled:cursor-not-allowed"
+      >
+        {isLoading ? "Memproses..." : "Simpan Password Baru"}
+      </button>
+    </form>
+  );
+}
+
+export default function ResetPassword() {
+  return (
+    <div className="min-h-screen bg-gradient-to-br from-blue-50 to-blue-100 flex items-center justify-center p-4">
+      <div className="w-full max-w-md">
+        <div className="bg-white rounded-2xl shadow-xl border border-blue-100 overflow-hidden">
+          {/* Header */}
+          <div className="bg-gradient-to-r from-blue-600 to-blue-700 px-8 py-8 text-center">
+            <div className="inline-flex items-center justify-center w-20 h-20 bg-white rounded-full shadow-lg mb-4">
+              <Image
+                src="/logo-kotamakassar.png"
+                alt="Logo Kota Makassar"
+                width={50}
+                height={50}
+                className="object-contain"
+              />
+            </div>
+            <h1 className="text-2xl font-bold text-white mb-1">Admin Panel</h1>
+            <p className="text-blue-100 text-sm">
+              Dinas Kesehatan Kota Makassar
+            </p>
+          </div>
+
+          {/* Form */}
+          <div className="p-8">
+            <div className="text-center mb-6">
+              <h2 className="text-xl font-semibold text-gray-800">Reset Password</h2>
+            </div>
+            {/* useSearchParams needs a Suspense boundary when the page is prerendered */}
+            <Suspense fallback={<p className="text-center text-sm text-gray-500">Memeriksa tautan...</p>}>
+              <FormResetPassword />
+            </Suspense>
+          </div>
+        </div>
+      </div>
+    </div>
+  );
+}
@@ -1,6 +1,6 @@
 "use client";
 
-import { useState, useEffect, useRef } from "react";
+import { useState, useEffect } from "react";
 import Header from "@/components/Header";
 import Footer from "@/components/Footer";
 import Beranda from "@/components/Beranda";
@@ -15,8 +15,8 @@ export default function Home() {
   const [isSubmitting, setIsSubmitting] = useState(false);
   const [showSuccessModal, setShowSuccessModal] = useState(false);
   const [jenisPerizinanList, setJenisPerizinanList] = useState<JenisPerizinan[]>([]);
-  const [selectedFiles, setSelectedFiles] = useState<File[]>([]);
-  const fileInputRef = useRef<HTMLInputElement>(null);
+  // Berkas terpilih per kode persyaratan
+  const [selectedFiles, setSelectedFiles] = useState<Record<string, File[]>>({});
   
   // Form state
   const [formData, setFormData] = useState({
@@ -47,20 +47,34 @@ export default function Home() {
     }
   };
 
+  const selectedJenis = jenisPerizinanList.find((jp) => jp.id === formData.jenisPerizinanId);
+
   const handleInputChange = (e: React.ChangeEvent<HTMLInputElement | HTMLTextAreaElement | HTMLSelectElement>) => {
     const { name, value } = e.target;
     setFormData(prev => ({ ...prev, [name]: value }));
+    // Persyaratan berbeda untuk tiap jenis perizinan
+    if (name === "jenisPerizinanId") {
+      setSelectedFiles({});
+    }
   };
 
-  const handleFileChange = (e: React.ChangeEvent<HTMLInputElement>) => {
+  const handleFileChange = (kode: string, e: React.ChangeEvent<HTMLInputElement>) => {
     if (e.target.files) {
       const files = Array.from(e.target.files);
-      setSelectedFiles(prev => [...prev, ...files]);
+      setSelectedFiles(prev => ({ ...prev, [kode]: [...(prev[kode] || []), ...files] }));
+      e.target.value = "";
     }
   };
 
-  const removeFile = (index: number) => {
-    setSelectedFiles(prev => prev.filter((_, i) => i !== index));
+  const removeFile = (kode: string, index: number) => {
+    setSelectedFiles(prev => ({ ...prev, [kode]: (prev[kode] || []).filter((_, i) => i !== index) }));
+  };
+
+  const formatUkuran = (bytes: number) => {
+    if (bytes >= 1024 * 1024) {
+      return `${(bytes / 1024 / 1024).toFixed(0)}MB`;
+    }
+    return `${(bytes / 1024).toFixed(0)}KB`;
   };
 
   const resetForm = () => {
@@ -72,10 +86,7 @@ export default function Home() {
       jenisPerizinanId: "",
       catatan: "",
     });
-    setSelectedFiles([]);
-    if (fileInputRef.current) {
-      fileInputRef.current.value = "";
-    }
+    setSelectedFiles({});
   };
 
   const handleSubmit = async (e: React.FormEvent) => {
@@ -87,8 +98,11 @@ export default function Home() {
       return;
     }
 
-    if (selectedFiles.length === 0) {
-      alert("Mohon upload minimal 1 berkas persyaratan");
+    const belumDiunggah = (selectedJenis?.persyaratan || [])
+      .filter((syarat) => syarat.wajib && !(selectedFiles[syarat.kode]?.length))
+      .map((syarat) => syarat.nama);
+    if (belumDiunggah.length > 0) {
+      alert("Mohon upload berkas persyaratan wajib: " + belumDiunggah.join(", "));
       return;
     }
 
@@ -108,6 +122,12 @@ export default function Home() {
         resetForm();
         setIsFormOpen(false);
         setShowSuccessModal(true);
+      } else if (Array.isArray(response.data)) {
+        // Kesalahan per berkas dari server
+        const detail = (response.data as { persyaratan: string; nama_file?: string; pesan: string }[])
+          .map((e) => `- ${e.nama_file ? e.nama_file + ": " : ""}${e.pesan}`)
+          .join("\n");
+        alert(response.message + "\n" + detail);
       } else {
         alert("Gagal mengirim permohonan: " + response.message);
       }
@@ -269,65 +289,72 @@ export default function Home() {
                       </select>
                     </div>
 
-                    {/* Upload Berkas */}
-                    <div>
-                      <label className="block text-sm font-medium text-gray-700 mb-2">
-                        Upload Berkas Persyaratan <span className="text-red-500">*</span>
-                      </label>
-                      <div className="border-2 border-dashed border-gray-300 hover:border-blue-400 rounded-xl p-5 sm:p-6 text-center transition-colors bg-white">
-                        <svg className="mx-auto h-10 w-10 sm:h-12 sm:w-12 text-gray-400" stroke="currentColor" fill="none" viewBox="0 0 48 48">
-                          <path d="M28 8H12a4 4 0 00-4 4v20m32-12v8m0 0v8a4 4 0 01-4 4H12a4 4 0 01-4-4v-4m32-4l-3.172-3.172a4 4 0 00-5.656 0L28 28M8 32l9.172-9.172a4 4 0 015.656 0L28 28m0 0l4 4m4-24h8m-4-4v8m-12 4h.02" strokeWidth={2} strokeLinecap="round" strokeLinejoin="round" />
-                        </svg>
-                        <div className="mt-3 sm:mt-4">
-                          <label htmlFor="file-upload" className="cursor-pointer bg-blue-600 hover:bg-blue-700 text-white px-4 sm:px-5 py-2 sm:py-2.5 rounded-lg font-medium transition-colors text-sm sm:text-base inline-flex items-center shadow-md">
-                            <svg className="w-4 h-4 mr-2" fill="none" stroke="currentColor" viewBox="0 0 24 24">
-                              <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M7 16a4 4 0 01-.88-7.903A5 5 0 1115.9 6L16 6a5 5 0 011 9.9M15 13l-3-3m0 0l-3 3m3-3v12" />
-                            </svg>
-                            Pilih Berkas
-                          </label>
-                          <input 
-                            id="file-upload" 
-                            ref={fileInputRef}
-                            name="file-upload" 
-                            type="file" 
-                            className="sr-only" 
-                            multiple 
-                            accept=".pdf,.doc,.docx"
-                            onChange={handleFileChange}
-                          />
-                        </div>
-                        <p className="text-xs sm:text-sm text-gray-500 mt-2 sm:mt-3">
-                          PDF, DOC, DOCX (Maksimal 10MB per file)
-                        </p>
-                      </div>
-                      
-                      {/* List File yang dipilih */}
-                      {selectedFiles.length > 0 && (
-                        <div className="mt-3 space-y-2">
-                          <p className="text-sm font-medium text-gray-700">Berkas terpilih:</p>
-                          {selectedFiles.map((file, index) => (
-                            <div key={index} className="flex items-center justify-between bg-gray-50 px-3 py-2 rounded-lg">
-                              <div className="flex items-center space-x-2">
-                                <svg className="w-5 h-5 text-blue-600" fill="none" stroke="currentColor" viewBox="0 0 24 24">
-                                  <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M9 12h6m-6 4h6m2 5H7a2 2 0 01-2-2V5a2 2 0 012-2h5.586a1 1 0 01.707.293l5.414 5.414a1 1 0 01.293.707V19a2 2 0 01-2 2z" />
-                                </svg>
-                                <span className="text-sm text-gray-700 truncate max-w-[200px]">{file.name}</span>
-                                <span className="text-xs text-gray-500">({(file.size / 1024 / 1024).toFixed(2)} MB)</span>
+                    {/* Upload Berkas per Persyaratan */}
+                    {selectedJenis && selectedJenis.persyaratan.length > 0 && (
+                      <div className="space-y-3">
+                        <label className="block text-sm font-medium text-gray-700">
+                          Upload Berkas Persyaratan
+                        </label>
+                        {selectedJenis.persyaratan.map((syarat) => (
+                          <div key={syarat.kode} className="border border-gray-200 rounded-xl p-3 sm:p-4 bg-white">
+                            <div className="flex flex-col sm:flex-row sm:items-center sm:justify-between gap-2">
+                              <div>
+                                <p className="text-sm font-medium text-gray-800">
+                                  {syarat.nama} {syarat.wajib && <span className="text-red-500">*</span>}
+                                </p>
+                                <p className="text-xs text-gray-500 mt-0.5">
+                                  {syarat.tipeFile.length > 0 ? syarat.tipeFile.join(", ").toUpperCase().replace(/\./g, "") : "Semua format"}
+                                  {syarat.ukuranMaks > 0 && ` (Maksimal ${formatUkuran(syarat.ukuranMaks)} per file)`}
+                                </p>
                               </div>
-                              <button
-                                type="button"
-                                onClick={() => removeFile(index)}
-                                className="text-red-500 hover:text-red-700 p-1"
+                              <label
+                                htmlFor={`file-upload-${syarat.kode}`}
+                                className="cursor-pointer bg-blue-600 hover:bg-blue-700 text-white px-3 sm:px-4 py-2 rounded-lg font-medium transition-colors text-sm inline-flex items-center justify-center shadow-md"
                               >
-                                <svg className="w-4 h-4" fill="none" stroke="currentColor" viewBox="0 0 24 24">
-                                  <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M6 18L18 6M6 6l12 12" />
+                                <svg className="w-4 h-4 mr-2" fill="none" stroke="currentColor" viewBox="0 0 24 24">
+                                  <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M7 16a4 4 0 01-.88-7.903A5 5 0 1115.9 6L16 6a5 5 0 011 9.9M15 13l-3-3m0 0l-3 3m3-3v12" />
                                 </svg>
-                              </button>
+                                Pilih Berkas
+                              </label>
+                              <input
+                                id={`file-upload-${syarat.kode}`}
+                                type="file"
+                                className="sr-only"
+                                multiple
+                                accept={syarat.tipeFile.join(",") || undefined}
+                                onChange={(e) => handleFileChange(syarat.kode, e)}
+                              />
                             </div>
-                          ))}
-                        </div>
-                      )}
-                    </div>
+
+                            {/* List File yang dipilih */}
+                            {(selectedFiles[syarat.kode] || []).length > 0 && (
+                              <div className="mt-3 space-y-2">
+                                {selectedFiles[syarat.kode].map((file, index) => (
+                                  <div key={index} className="flex items-center justify-between bg-gray-50 px-3 py-2 rounded-lg">
+                                    <div className="flex items-center space-x-2">
+                                      <svg className="w-5 h-5 text-blue-600" fill="none" stroke="currentColor" viewBox="0 0 24 24">
+                                        <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M9 12h6m-6 4h6m2 5H7a2 2 0 01-2-2V5a2 2 0 012-2h5.586a1 1 0 01.707.293l5.414 5.414a1 1 0 01.293.707V19a2 2 0 01-2 2z" />
+                                      </svg>
+                                      <span className="text-sm text-gray-700 truncate max-w-[200px]">{file.name}</span>
+                                      <span className="text-xs text-gray-500">({(file.size / 1024 / 1024).toFixed(2)} MB)</span>
+                                    </div>
+                                    <button
+                                      type="button"
+                                      onClick={() => removeFile(syarat.kode, index)}
+                                      className="text-red-500 hover:text-red-700 p-1"
+                                    >
+                                      <svg className="w-4 h-4" fill="none" stroke="currentColor" viewBox="0 0 24 24">
+                                        <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M6 18L18 6M6 6l12 12" />
+                                      </svg>
+                                    </button>
+                                  </div>
+                                ))}
+                              </div>
+                            )}
+                          </div>
+                        ))}
+                      </div>
+                    )}
 
                     {/* Catatan */}
                     <div>
@@ -1,6 +1,7 @@
 "use client";
 
 import { Notifikasi, AdminRole } from "@/types";
+import { labelRole } from "@/lib/api";
 
 interface AdminHeaderProps {
   notifikasi: Notifikasi[];
@@ -133,7 +134,7 @@ export default function AdminHeader({
             </div>
             <div className="hidden sm:block">
               <p className="text-sm font-medium text-gray-800 leading-tight">{adminName}</p>
-              <p className="text-xs text-gray-500">{adminRole === "super_admin" ? "Super Admin" : "Administrator"}</p>
+              <p className="text-xs text-gray-500">{labelRole(adminRole || "admin")}</p>
             </div>
             {onLogout && (
               <button
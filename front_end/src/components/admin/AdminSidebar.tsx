@@ -3,6 +3,7 @@
 import Image from "next/image";
 import { useEffect } from "react";
 import { AdminRole } from "@/types";
+import { labelRole } from "@/lib/api";
 
 interface AdminSidebarProps {
   activeMenu: string;
@@ -11,21 +12,22 @@ interface AdminSidebarProps {
   onToggle: () => void;
   jumlahPermohonanBaru: number;
   adminRole?: AdminRole;
+  adminIzin?: string[];
 }
 
-export default function AdminSidebar({ activeMenu, onMenuClick, isOpen, onToggle, jumlahPermohonanBaru, adminRole = "admin" }: AdminSidebarProps) {
+export default function AdminSidebar({ activeMenu, onMenuClick, isOpen, onToggle, jumlahPermohonanBaru, adminRole = "admin", adminIzin = [] }: AdminSidebarProps) {
   const allMenuItems = [
-    { id: "dashboard", label: "Dashboard", icon: "M3 12l2-2m0 0l7-7 7 7M5 10v10a1 1 0 001 1h3m10-11l2 2m-2-2v10a1 1 0 01-1 1h-3m-6 0a1 1 0 001-1v-4a1 1 0 011-1h2a1 1 0 011 1v4a1 1 0 001 1m-6 0h6", roles: ["super_admin", "admin"] },
-    { id: "permohonan-baru", label: "Permohonan Baru", icon: "M9 5H7a2 2 0 00-2 2v12a2 2 0 002 2h10a2 2 0 002-2V7a2 2 0 00-2-2h-2M9 5a2 2 0 002 2h2a2 2 0 002-2M9 5a2 2 0 012-2h2a2 2 0 012 2", badge: jumlahPermohonanBaru > 0 ? jumlahPermohonanBaru : undefined, roles: ["super_admin", "admin"] },
-    { id: "permohonan-diproses", label: "Sedang Diproses", icon: "M12 8v4l3 3m6-3a9 9 0 11-18 0 9 9 0 0118 0z", roles: ["super_admin", "admin"] },
-    { id: "riwayat", label: "Riwayat", icon: "M9 12h6m-6 4h6m2 5H7a2 2 0 01-2-2V5a2 2 0 012-2h5.586a1 1 0 01.707.293l5.414 5.414a1 1 0 01.293.707V19a2 2 0 01-2 2z", roles: ["super_admin", "admin"] },
-    { id: "semua-permohonan", label: "Semua Permohonan", icon: "M4 6h16M4 10h16M4 14h16M4 18h16", roles: ["super_admin", "admin"] },
-    { id: "kelola-perizinan", label: "Kelola Perizinan", icon: "M10.325 4.317c.426-1.756 2.924-1.756 3.35 0a1.724 1.724 0 002.573 1.066c1.543-.94 3.31.826 2.37 2.37a1.724 1.724 0 001.065 2.572c1.756.426 1.756 2.924 0 3.35a1.724 1.724 0 00-1.066 2.573c.94 1.543-.826 3.31-2.37 2.37a1.724 1.724 0 00-2.572 1.065c-.426 1.756-2.924 1.756-3.35 0a1.724 1.724 0 00-2.573-1.066c-1.543.94-3.31-.826-2.37-2.37a1.724 1.724 0 00-1.065-2.572c-1.756-.426-1.756-2.924 0-3.35a1.724 1.724 0 001.066-2.573c-.94-1.543.826-3.31 2.37-2.37.996.608 2.296.07 2.572-1.065z", roles: ["super_admin", "admin"] },
-    { id: "kelola-admin", label: "Kelola Admin", icon: "M17 20h5v-2a3 3 0 00-5.356-1.857M17 20H7m10 0v-2c0-.656-.126-1.283-.356-1.857M7 20H2v-2a3 3 0 015.356-1.857M7 20v-2c0-.656.126-1.283.356-1.857m0 0a5.002 5.002 0 019.288 0M15 7a3 3 0 11-6 0 3 3 0 016 0zm6 3a2 2 0 11-4 0 2 2 0 014 0zM7 10a2 2 0 11-4 0 2 2 0 014 0z", roles: ["super_admin"] },
+    { id: "dashboard", label: "Dashboard", icon: "M3 12l2-2m0 0l7-7 7 7M5 10v10a1 1 0 001 1h3m10-11l2 2m-2-2v10a1 1 0 01-1 1h-3m-6 0a1 1 0 001-1v-4a1 1 0 011-1h2a1 1 0 011 1v4a1 1 0 001 1m-6 0h6", izin: "laporan.lihat" },
+    { id: "permohonan-baru", label: "Permohonan Baru", icon: "M9 5H7a2 2 0 00-2 2v12a2 2 0 002 2h10a2 2 0 002-2V7a2 2 0 00-2-2h-2M9 5a2 2 0 002 2h2a2 2 0 002-2M9 5a2 2 0 012-2h2a2 2 0 012 2", badge: jumlahPermohonanBaru > 0 ? jumlahPermohonanBaru : undefined, izin: "permohonan.lihat" },
+    { id: "permohonan-diproses", label: "Sedang Diproses", icon: "M12 8v4l3 3m6-3a9 9 0 11-18 0 9 9 0 0118 0z", izin: "permohonan.lihat" },
+    { id: "riwayat", label: "Riwayat", icon: "M9 12h6m-6 4h6m2 5H7a2 2 0 01-2-2V5a2 2 0 012-2h5.586a1 1 0 01.707.293l5.414 5.414a1 1 0 01.293.707V19a2 2 0 01-2 2z", izin: "permohonan.lihat" },
+    { id: "semua-permohonan", label: "Semua Permohonan", icon: "M4 6h16M4 10h16M4 14h16M4 18h16", izin: "permohonan.lihat" },
+    { id: "kelola-perizinan", label: "Kelola Perizinan", icon: "M10.325 4.317c.426-1.756 2.924-1.756 3.35 0a1.724 1.724 0 002.573 1.066c1.543-.94 3.31.826 2.37 2.37a1.724 1.724 0 001.065 2.572c1.756.426 1.756 2.924 0 3.35a1.724 1.724 0 00-1.066 2.573c.94 1.543-.826 3.31-2.37 2.37a1.724 1.724 0 00-2.572 1.065c-.426 1.756-2.924 1.756-3.35 0a1.724 1.724 0 00-2.573-1.066c-1.543.94-3.31-.826-2.37-2.37a1.724 1.724 0 00-1.065-2.572c-1.756-.426-1.756-2.924 0-3.35a1.724 1.724 0 001.066-2.573c-.94-1.543.826-3.31 2.37-2.37.996.608 2.296.07 2.572-1.065z", izin: "jenis_perizinan.kelola" },
+    { id: "kelola-admin", label: "Kelola Admin", icon: "M17 20h5v-2a3 3 0 00-5.356-1.857M17 20H7m10 0v-2c0-.656-.126-1.283-.356-1.857M7 20H2v-2a3 3 0 015.356-1.857M7 20v-2c0-.656.126-1.283.356-1.857m0 0a5.002 5.002 0 019.288 0M15 7a3 3 0 11-6 0 3 3 0 016 0zm6 3a2 2 0 11-4 0 2 2 0 014 0zM7 10a2 2 0 11-4 0 2 2 0 014 0z", izin: "admin.kelola" },
   ];
 
-  // Filter menu items based on admin role
-  const menuItems = allMenuItems.filter(item => item.roles.includes(adminRole));
+  // Filter menu items based on the permissions of the admin's role
+  const menuItems = allMenuItems.filter(item => adminIzin.includes(item.izin));
 
   // Handle menu click - close sidebar on mobile
   const handleMenuClick = (menuId: string) => {
@@ -143,7 +145,7 @@ export default function AdminSidebar({ activeMenu, onMenuClick, isOpen, onToggle
                   <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M16 7a4 4 0 11-8 0 4 4 0 018 0zM12 14a7 7 0 00-7 7h14a7 7 0 00-7-7z" />
                 )}
               </svg>
-              {adminRole === 'super_admin' ? 'Super Admin' : 'Admin'}
+              {labelRole(adminRole)}
             </div>
           </div>
           
@@ -2,7 +2,8 @@
 
 import { useState, useRef } from "react";
 import { Permohonan } from "@/types";
-import { getDownloadUrl } from "@/lib/api";
+import { aksesBerkasAPI, unduhDenganTautan, TautanUnduhData, APIResponse } from "@/lib/api";
+import PratinjauBerkas from "./PratinjauBerkas";
 
 interface DetailPermohonanProps {
   permohonan: Permohonan;
@@ -19,6 +20,14 @@ export default function DetailPermohonan({ permohonan, onBack, onKirimBalasan, o
   const [lampiran, setLampiran] = useState<File | null>(null);
   const fileInputRef = useRef<HTMLInputElement>(null);
 
+  const handleUnduh = async (request: Promise<APIResponse<TautanUnduhData>>) => {
+    try {
+      await unduhDenganTautan(request);
+    } catch (error) {
+      alert(error instanceof Error ? error.message : "Gagal mengunduh berkas");
+    }
+  };
+
   const formatTanggal = (date: Date) => {
     return new Date(date).toLocaleDateString('id-ID', {
       weekday: 'long',
@@ -181,26 +190,42 @@ Dinas Kesehatan Kota Makassar`;
               )}
 
               <div>
-                <p className="text-xs sm:text-sm text-gray-500 mb-2">Berkas yang Dilampirkan</p>
+                <div className="flex items-center justify-between mb-2">
+                  <p className="text-xs sm:text-sm text-gray-500">Berkas yang Dilampirkan</p>
+                  {permohonan.berkasData && permohonan.berkasData.length > 0 && (
+                    <button
+                      type="button"
+                      onClick={() => handleUnduh(aksesBerkasAPI.getTautanBundel(permohonan.id))}
+                      className="text-blue-600 hover:text-blue-800 text-xs sm:text-sm font-medium"
+                    >
+                      Unduh Semua (ZIP)
+                    </button>
+                  )}
+                </div>
                 <div className="space-y-2">
                   {permohonan.berkasData && permohonan.berkasData.length > 0 ? (
                     permohonan.berkasData.map((file, index) => (
                       <div key={index} className="flex items-center justify-between p-2 sm:p-3 bg-gray-50 rounded-lg">
                         <div className="flex items-center space-x-2 sm:space-x-3 flex-1 min-w-0">
-                          <svg className="w-6 h-6 sm:w-8 sm:h-8 text-red-500 flex-shrink-0" fill="currentColor" viewBox="0 0 24 24">
-                            <path d="M14 2H6a2 2 0 00-2 2v16a2 2 0 002 2h12a2 2 0 002-2V8l-6-6zm-1 2l5 5h-5V4zM8.5 17v-6h1v6h-1zm2.5 0v-6h1.25c.69 0 1.25.56 1.25 1.25v3.5c0 .69-.56 1.25-1.25 1.25H11zm2.5-6h1.5v1h-1.5v2h1.5v1h-1.5v2h-1v-6h1.5z"/>
-                          </svg>
+                          {file.ada_pratinjau ? (
+                            <PratinjauBerkas berkasId={file.id} namaFile={file.nama_asli} />
+                          ) : (
+                            <svg className="w-6 h-6 sm:w-8 sm:h-8 text-red-500 flex-shrink-0" fill="currentColor" viewBox="0 0 24 24">
+                              <path d="M14 2H6a2 2 0 00-2 2v16a2 2 0 002 2h12a2 2 0 002-2V8l-6-6zm-1 2l5 5h-5V4zM8.5 17v-6h1v6h-1zm2.5 0v-6h1.25c.69 0 1.25.56 1.25 1.25v3.5c0 .69-.56 1.25-1.25 1.25H11zm2.5-6h1.5v1h-1.5v2h1.5v1h-1.5v2h-1v-6h1.5z"/>
+                            </svg>
+                          )}
                           <div className="flex-1 min-w-0">
                             <span className="text-xs sm:text-sm font-medium text-gray-700 block truncate">{file.nama_asli}</span>
                             <p className="text-xs text-gray-500">{(file.ukuran / 1024).toFixed(1)} KB</p>
                           </div>
                         </div>
-                        <a 
-                          href={getDownloadUrl(file.nama_file, file.nama_asli)}
+                        <button
+                          type="button"
+                          onClick={() => handleUnduh(aksesBerkasAPI.getTautanBerkas(file.id))}
                           className="text-blue-600 hover:text-blue-800 text-xs sm:text-sm font-medium flex-shrink-0 ml-2"
                         >
                           Unduh
-                        </a>
+                        </button>
                       </div>
                     ))
                   ) : (
@@ -331,17 +356,16 @@ Dinas Kesehatan Kota Makassar`;
                           <p className="text-xs text-gray-500">Dokumen PDF</p>
                         </div>
                       </div>
-                      <a 
-                        href={getDownloadUrl(permohonan.lampiranSurat.split('/').pop() || '', 'Surat_Keputusan.pdf')}
+                      <button
+                        type="button"
+                        onClick={() => handleUnduh(aksesBerkasAPI.getTautanLampiran(permohonan.id))}
                         className="flex items-center space-x-1 px-3 py-1.5 bg-blue-600 hover:bg-blue-700 text-white rounded-lg text-xs sm:text-sm font-medium transition-colors flex-shrink-0 ml-2"
-                        target="_blank"
-                        rel="noopener noreferrer"
                       >
                         <svg className="w-4 h-4" fill="none" stroke="currentColor" viewBox="0 0 24 24">
                           <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M4 16v1a3 3 0 003 3h10a3 3 0 003-3v-1m-4-4l-4 4m0 0l-4-4m4 4V4" />
                         </svg>
                         <span>Unduh</span>
-                      </a>
+                      </button>
                     </div>
                   </div>
                 )}
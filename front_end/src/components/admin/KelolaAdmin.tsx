@@ -1,13 +1,15 @@
 "use client";
 
-import { useState } from "react";
+import { useEffect, useState } from "react";
+import { authAPI, deskripsiKebijakanPassword, KebijakanPasswordData, roleAPI, RoleData, labelRole } from "@/lib/api";
+import { AdminRole } from "@/types";
 
 interface Admin {
   id: string;
   username: string;
   email: string;
   namaLengkap: string;
-  role: 'super_admin' | 'admin';
+  role: AdminRole;
   isActive: boolean;
   createdAt: Date;
 }
@@ -42,13 +44,34 @@ export default function KelolaAdmin({
     username: "",
     email: "",
     namaLengkap: "",
-    role: "admin" as 'super_admin' | 'admin',
+    role: "admin" as AdminRole,
     password: "",
     confirmPassword: "",
   });
 
   const [newPassword, setNewPassword] = useState("");
   const [confirmNewPassword, setConfirmNewPassword] = useState("");
+  const [kebijakan, setKebijakan] = useState<KebijakanPasswordData | null>(null);
+  const [roles, setRoles] = useState<RoleData[]>([]);
+
+  useEffect(() => {
+    authAPI.getKebijakanPassword()
+      .then((response) => {
+        if (response.success && response.data) setKebijakan(response.data);
+      })
+      .catch(() => {});
+    roleAPI.getAll()
+      .then((response) => {
+        if (response.success && response.data) setRoles(response.data);
+      })
+      .catch(() => {});
+  }, []);
+
+  const namaRole = (role: string) => roles.find((r) => r.nama === role)?.label || labelRole(role);
+
+  // The server checks the full policy; the form only enforces the length
+  const minPanjang = kebijakan?.min_panjang ?? 8;
+  const petunjukPassword = kebijakan ? deskripsiKebijakanPassword(kebijakan) : `Minimal ${minPanjang} karakter`;
 
   const filteredAdmins = adminList.filter(admin => 
     admin.username.toLowerCase().includes(searchTerm.toLowerCase()) ||
@@ -96,8 +119,8 @@ export default function KelolaAdmin({
       return;
     }
 
-    if (newPassword.length < 6) {
-      alert("Password minimal 6 karakter!");
+    if (newPassword.length < minPanjang) {
+      alert(`Password minimal ${minPanjang} karakter!`);
       return;
     }
 
@@ -173,7 +196,7 @@ export default function KelolaAdmin({
           <svg className="w-3 h-3 mr-1" fill="none" stroke="currentColor" viewBox="0 0 24 24">
             <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M9 12l2 2 4-4m5.618-4.016A11.955 11.955 0 0112 2.944a11.955 11.955 0 01-8.618 3.04A12.02 12.02 0 003 9c0 5.591 3.824 10.29 9 11.622 5.176-1.332 9-6.03 9-11.622 0-1.042-.133-2.052-.382-3.016z" />
           </svg>
-          Super Admin
+          {namaRole(role)}
         </span>
       );
     }
@@ -182,7 +205,7 @@ export default function KelolaAdmin({
         <svg className="w-3 h-3 mr-1" fill="none" stroke="currentColor" viewBox="0 0 24 24">
           <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M16 7a4 4 0 11-8 0 4 4 0 018 0zM12 14a7 7 0 00-7 7h14a7 7 0 00-7-7z" />
         </svg>
-        Admin
+        {namaRole(role)}
       </span>
     );
   };
@@ -386,11 +409,18 @@ export default function KelolaAdmin({
                 <label className="block text-sm font-medium text-gray-700 mb-1">Role</label>
                 <select
                   value={formData.role}
-                  onChange={(e) => setFormData({ ...formData, role: e.target.value as 'super_admin' | 'admin' })}
+                  onChange={(e) => setFormData({ ...formData, role: e.target.value })}
                   className="w-full px-3 py-2 border border-gray-300 rounded-lg focus:ring-2 focus:ring-blue-500 focus:border-blue-500 bg-white text-gray-900"
                 >
-                  <option value="admin">Admin</option>
-                  <option value="super_admin">Super Admin</option>
+                  {roles.length === 0 ? (
+                    <option value={formData.role}>{labelRole(formData.role)}</option>
+                  ) : (
+                    roles.map((role) => (
+                      <option key={role.id} value={role.nama} title={role.deskripsi}>
+                        {role.label}
+                      </option>
+                    ))
+                  )}
                 </select>
               </div>
 
@@ -405,8 +435,9 @@ export default function KelolaAdmin({
                       className="w-full px-3 py-2 border border-gray-300 rounded-lg focus:ring-2 focus:ring-blue-500 focus:border-blue-500 bg-white text-gray-900 placeholder-gray-400"
                       placeholder="Masukkan password"
                       required
-                      minLength={6}
+                      minLength={minPanjang}
                     />
+                    <p className="text-xs text-gray-500 mt-1">{petunjukPassword}</p>
                   </div>
 
                   <div>
@@ -418,7 +449,7 @@ export default function KelolaAdmin({
                       className="w-full px-3 py-2 border border-gray-300 rounded-lg focus:ring-2 focus:ring-blue-500 focus:border-blue-500 bg-white text-gray-900 placeholder-gray-400"
                       placeholder="Konfirmasi password"
                       required
-                      minLength={6}
+                      minLength={minPanjang}
                     />
                   </div>
                 </>
@@ -506,9 +537,10 @@ export default function KelolaAdmin({
                   value={newPassword}
                   onChange={(e) => setNewPassword(e.target.value)}
                   className="w-full px-3 py-2 border border-gray-300 rounded-lg focus:ring-2 focus:ring-blue-500 focus:border-blue-500 bg-white text-gray-900 placeholder-gray-400"
-                  minLength={6}
-                  placeholder="Minimal 6 karakter"
+                  minLength={minPanjang}
+                  placeholder={`Minimal ${minPanjang} karakter`}
                 />
+                <p className="text-xs text-gray-500 mt-1">{petunjukPassword}</p>
               </div>
 
               <div>
@@ -518,7 +550,7 @@ export default function KelolaAdmin({
                   value={confirmNewPassword}
                   onChange={(e) => setConfirmNewPassword(e.target.value)}
                   className="w-full px-3 py-2 border border-gray-300 rounded-lg focus:ring-2 focus:ring-blue-500 focus:border-blue-500 bg-white text-gray-900 placeholder-gray-400"
-                  minLength={6}
+                  minLength={minPanjang}
                   placeholder="Ulangi password baru"
                 />
               </div>
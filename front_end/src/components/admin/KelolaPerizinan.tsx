@@ -1,7 +1,40 @@
 "use client";
 
 import { useState } from "react";
-import { JenisPerizinan } from "@/types";
+import { JenisPerizinan, Persyaratan } from "@/types";
+
+// Baris form persyaratan; tipe file dan ukuran diedit sebagai teks
+interface PersyaratanForm {
+  kode: string;
+  nama: string;
+  wajib: boolean;
+  tipeFile: string;
+  ukuranMaksMB: string;
+}
+
+const persyaratanKosong = (): PersyaratanForm => ({
+  kode: "",
+  nama: "",
+  wajib: true,
+  tipeFile: ".pdf, .docx",
+  ukuranMaksMB: "",
+});
+
+const toPersyaratanForm = (syarat: Persyaratan): PersyaratanForm => ({
+  kode: syarat.kode,
+  nama: syarat.nama,
+  wajib: syarat.wajib,
+  tipeFile: syarat.tipeFile.join(", "),
+  ukuranMaksMB: syarat.ukuranMaks > 0 ? String(syarat.ukuranMaks / 1024 / 1024) : "",
+});
+
+const fromPersyaratanForm = (syarat: PersyaratanForm): Persyaratan => ({
+  kode: syarat.kode,
+  nama: syarat.nama.trim(),
+  wajib: syarat.wajib,
+  tipeFile: syarat.tipeFile.split(",").map((t) => t.trim()).filter((t) => t !== ""),
+  ukuranMaks: syarat.ukuranMaksMB ? Math.round(parseFloat(syarat.ukuranMaksMB) * 1024 * 1024) || 0 : 0,
+});
 
 interface KelolaPerizinanProps {
   jenisPerizinanList: JenisPerizinan[];
@@ -13,10 +46,15 @@ interface KelolaPerizinanProps {
 export default function KelolaPerizinan({ jenisPerizinanList, onTambah, onEdit, onHapus }: KelolaPerizinanProps) {
   const [showModal, setShowModal] = useState(false);
   const [editingPerizinan, setEditingPerizinan] = useState<JenisPerizinan | null>(null);
-  const [formData, setFormData] = useState({
+  const [formData, setFormData] = useState<{
+    nama: string;
+    deskripsi: string;
+    persyaratan: PersyaratanForm[];
+    aktif: boolean;
+  }>({
     nama: "",
     deskripsi: "",
-    persyaratan: [""],
+    persyaratan: [persyaratanKosong()],
     aktif: true,
   });
   const [confirmDelete, setConfirmDelete] = useState<string | null>(null);
@@ -25,7 +63,7 @@ export default function KelolaPerizinan({ jenisPerizinanList, onTambah, onEdit,
     setFormData({
       nama: "",
       deskripsi: "",
-      persyaratan: [""],
+      persyaratan: [persyaratanKosong()],
       aktif: true,
     });
     setEditingPerizinan(null);
@@ -37,7 +75,7 @@ export default function KelolaPerizinan({ jenisPerizinanList, onTambah, onEdit,
       setFormData({
         nama: perizinan.nama,
         deskripsi: perizinan.deskripsi,
-        persyaratan: perizinan.persyaratan.length > 0 ? perizinan.persyaratan : [""],
+        persyaratan: perizinan.persyaratan.length > 0 ? perizinan.persyaratan.map(toPersyaratanForm) : [persyaratanKosong()],
         aktif: perizinan.aktif,
       });
     } else {
@@ -54,7 +92,7 @@ export default function KelolaPerizinan({ jenisPerizinanList, onTambah, onEdit,
   const handleAddPersyaratan = () => {
     setFormData({
       ...formData,
-      persyaratan: [...formData.persyaratan, ""],
+      persyaratan: [...formData.persyaratan, persyaratanKosong()],
     });
   };
 
@@ -62,13 +100,13 @@ export default function KelolaPerizinan({ jenisPerizinanList, onTambah, onEdit,
     const newPersyaratan = formData.persyaratan.filter((_, i) => i !== index);
     setFormData({
       ...formData,
-      persyaratan: newPersyaratan.length > 0 ? newPersyaratan : [""],
+      persyaratan: newPersyaratan.length > 0 ? newPersyaratan : [persyaratanKosong()],
     });
   };
 
-  const handlePersyaratanChange = (index: number, value: string) => {
+  const handlePersyaratanChange = (index: number, changes: Partial<PersyaratanForm>) => {
     const newPersyaratan = [...formData.persyaratan];
-    newPersyaratan[index] = value;
+    newPersyaratan[index] = { ...newPersyaratan[index], ...changes };
     setFormData({ ...formData, persyaratan: newPersyaratan });
   };
 
@@ -80,7 +118,9 @@ export default function KelolaPerizinan({ jenisPerizinanList, onTambah, onEdit,
       return;
     }
 
-    const persyaratanFiltered = formData.persyaratan.filter(p => p.trim() !== "");
+    const persyaratanFiltered = formData.persyaratan
+      .filter(p => p.nama.trim() !== "")
+      .map(fromPersyaratanForm);
 
     if (editingPerizinan) {
       onEdit({
@@ -167,7 +207,10 @@ export default function KelolaPerizinan({ jenisPerizinanList, onTambah, onEdit,
                         <svg className="w-3.5 h-3.5 sm:w-4 sm:h-4 mr-1.5 sm:mr-2 text-blue-500 flex-shrink-0 mt-0.5" fill="none" stroke="currentColor" viewBox="0 0 24 24">
                           <path strokeLinecap="round" strokeLinejoin="round" strokeWidth={2} d="M9 12l2 2 4-4m6 2a9 9 0 11-18 0 9 9 0 0118 0z" />
                         </svg>
-                        <span>{syarat}</span>
+                        <span>
+                          {syarat.nama}
+                          {!syarat.wajib && <span className="text-gray-400"> (opsional)</span>}
+                        </span>
                       </li>
                     ))}
                   </ul>
@@ -282,14 +325,43 @@ export default function KelolaPerizinan({ jenisPerizinanList, onTambah, onEdit,
                   </div>
                   <div className="space-y-2">
                     {formData.persyaratan.map((syarat, index) => (
-                      <div key={index} className="flex items-center space-x-2">
-                        <input
-                          type="text"
-                          value={syarat}
-                          onChange={(e) => handlePersyaratanChange(index, e.target.value)}
-                          className="flex-1 px-3 sm:px-4 py-2 text-sm border border-gray-300 rounded-lg focus:ring-2 focus:ring-blue-500 focus:border-transparent text-gray-900"
-                          placeholder={`Persyaratan ${index + 1}`}
-                        />
+                      <div key={index} className="flex items-start space-x-2">
+                        <div className="flex-1 space-y-2">
+                          <input
+                            type="text"
+                            value={syarat.nama}
+                            onChange={(e) => handlePersyaratanChange(index, { nama: e.target.value })}
+                            className="w-full px-3 sm:px-4 py-2 text-sm border border-gray-300 rounded-lg focus:ring-2 focus:ring-blue-500 focus:border-transparent text-gray-900"
+                            placeholder={`Persyaratan ${index + 1}`}
+                          />
+                          <div className="grid grid-cols-2 gap-2">
+                            <input
+                              type="text"
+                              value={syarat.tipeFile}
+                              onChange={(e) => handlePersyaratanChange(index, { tipeFile: e.target.value })}
+                              className="px-3 py-1.5 text-xs border border-gray-300 rounded-lg focus:ring-2 focus:ring-blue-500 focus:border-transparent text-gray-900"
+                              placeholder="Tipe file, contoh: .pdf, .jpg"
+                            />
+                            <input
+                              type="number"
+                              min="0"
+                              step="0.5"
+                              value={syarat.ukuranMaksMB}
+                              onChange={(e) => handlePersyaratanChange(index, { ukuranMaksMB: e.target.value })}
+                              className="px-3 py-1.5 text-xs border border-gray-300 rounded-lg focus:ring-2 focus:ring-blue-500 focus:border-transparent text-gray-900"
+                              placeholder="Ukuran maks (MB)"
+                            />
+                          </div>
+                          <label className="flex items-center space-x-2 text-xs text-gray-600">
+                            <input
+                              type="checkbox"
+                              checked={syarat.wajib}
+                              onChange={(e) => handlePersyaratanChange(index, { wajib: e.target.checked })}
+                              className="w-3.5 h-3.5 text-blue-600 border-gray-300 rounded focus:ring-blue-500"
+                            />
+                            <span>Wajib diunggah</span>
+                          </label>
+                        </div>
                         {formData.persyaratan.length > 1 && (
                           <button
                             type="button"
@@ -0,0 +1,79 @@
+"use client";
+
+import { useEffect, useState } from "react";
+import { aksesBerkasAPI } from "@/lib/api";
+
+interface PratinjauBerkasProps {
+  berkasId: string;
+  namaFile: string;
+}
+
+// Thumbnail of an uploaded document. The image is fetched with the admin
+// token, so it cannot be a plain <img src> to the API.
+export default function PratinjauBerkas({ berkasId, namaFile }: PratinjauBerkasProps) {
+  const [src, setSrc] = useState<string | null>(null);
+  const [showFull, setShowFull] = useState(false);
+
+  useEffect(() => {
+    let url: string | null = null;
+    let cancelled = false;
+
+    aksesBerkasAPI.getPratinjau(berkasId).then((objectUrl) => {
+      if (cancelled) {
+        if (objectUrl) URL.revokeObjectURL(objectUrl);
+        return;
+      }
+      url = objectUrl;
+      setSrc(objectUrl);
+    });
+
+    return () => {
+      cancelled = true;
+      if (url) URL.revokeObjectURL(url);
+    };
+  }, [berkasId]);
+
+  if (!src) {
+    return <div className="w-12 h-12 sm:w-14 sm:h-14 bg-gray-200 rounded animate-pulse flex-shrink-0" />;
+  }
+
+  return (
+    <>
+      <button
+        type="button"
+        onClick={() => setShowFull(true)}
+        className="flex-shrink-0"
+        title={`Pratinjau ${namaFile}`}
+      >
+        {/* eslint-disable-next-line @next/next/no-img-element */}
+        <img
+          src={src}
+          alt={`Pratinjau ${namaFile}`}
+          className="w-12 h-12 sm:w-14 sm:h-14 object-cover rounded border border-gray-200 bg-white"
+        />
+      </button>
+
+      {showFull && (
+        <div
+          className="fixed inset-0 bg-black/60 flex items-center justify-center z-50 p-4"
+          onClick={() => setShowFull(false)}
+        >
+          <div className="bg-white rounded-xl p-3 max-w-full max-h-full" onClick={(e) => e.stopPropagation()}>
+            <div className="flex items-center justify-between mb-2">
+              <span className="text-sm font-medium text-gray-700 truncate mr-4">{namaFile}</span>
+              <button
+                type="button"
+                onClick={() => setShowFull(false)}
+                className="text-gray-500 hover:text-gray-700 text-sm"
+              >
+                Tutup
+              </button>
+            </div>
+            {/* eslint-disable-next-line @next/next/no-img-element */}
+            <img src={src} alt={`Pratinjau ${namaFile}`} className="max-w-[80vw] max-h-[80vh] object-contain" />
+          </div>
+        </div>
+      )}
+    </>
+  );
+}
@@ -1,5 +1,7 @@
 // API Service for communicating with backend
 
+import { Persyaratan } from '@/types';
+
 const API_URL = process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8080/api/v1';
 
 // Helper function to get auth token
@@ -10,8 +12,48 @@ const getAuthToken = (): string | null => {
   return null;
 };
 
+const clearSession = () => {
+  localStorage.removeItem('adminToken');
+  localStorage.removeItem('adminRefreshToken');
+  localStorage.removeItem('adminLoggedIn');
+  localStorage.removeItem('adminLoginTime');
+};
+
+// Access tokens expire after a few minutes. Concurrent requests that hit a
+// 401 share one refresh, because each refresh token can only be used once.
+let refreshPromise: Promise<boolean> | null = null;
+
+const refreshAccessToken = (): Promise<boolean> => {
+  if (!refreshPromise) {
+    refreshPromise = (async () => {
+      const refreshToken = localStorage.getItem('adminRefreshToken');
+      if (!refreshToken) return false;
+      try {
+        const response = await fetch(`${API_URL}/auth/refresh`, {
+          method: 'POST',
+          headers: { 'Content-Type': 'application/json' },
+          body: JSON.stringify({ refresh_token: refreshToken }),
+        });
+        const result: APIResponse<LoginResponse> = await response.json();
+        if (!result.success || !result.data) {
+          clearSession();
+          return false;
+        }
+        localStorage.setItem('adminToken', result.data.token);
+        localStorage.setItem('adminRefreshToken', result.data.refresh_token);
+        return true;
+      } catch {
+        return false;
+      }
+    })().finally(() => {
+      refreshPromise = null;
+    });
+  }
+  return refreshPromise;
+};
+
 // Helper function to make authenticated requests
-const authFetch = async (url: string, options: RequestInit = {}): Promise<Response> => {
+const authFetch = async (url: string, options: RequestInit = {}, retry = true): Promise<Response> => {
   const token = getAuthToken();
   const headers: HeadersInit = {
     ...options.headers,
@@ -21,7 +63,11 @@ const authFetch = async (url: string, options: RequestInit = {}): Promise<Respon
     (headers as Record<string, string>)['Authorization'] = `Bearer ${token}`;
   }
 
-  return fetch(url, { ...options, headers });
+  const response = await fetch(url, { ...options, headers });
+  if (response.status === 401 && retry && (await refreshAccessToken())) {
+    return authFetch(url, options, false);
+  }
+  return response;
 };
 
 // Generic API response type
@@ -42,13 +88,41 @@ export interface LoginRequest {
 export interface LoginResponse {
   token: string;
   expires_at: string;
+  refresh_token: string;
+  refresh_expires_at: string;
   admin: {
     id: string;
     username: string;
     email: string;
     nama_lengkap: string;
-    role: 'super_admin' | 'admin';
+    role: string;
+    izin: string[];
+    totp_aktif: boolean;
   };
+  // Two-factor login step: no session yet, continue with token_tantangan
+  perlu_2fa?: boolean;
+  setup_2fa?: boolean;
+  token_tantangan?: string;
+  kode_pemulihan?: string[];
+}
+
+export interface KebijakanPasswordData {
+  min_panjang: number;
+  kelas_wajib: ('lower' | 'upper' | 'digit' | 'symbol')[];
+  riwayat: number;
+}
+
+export interface Setup2FAData {
+  secret: string;
+  otpauth_uri: string;
+  qr_code: string;
+}
+
+export interface Status2FAData {
+  aktif: boolean;
+  wajib: boolean;
+  aktif_pada?: string;
+  sisa_kode_pemulihan: number;
 }
 
 export const authAPI = {
@@ -61,27 +135,178 @@ export const authAPI = {
     return response.json();
   },
 
+  lupaPassword: async (email: string): Promise<APIResponse> => {
+    const response = await fetch(`${API_URL}/auth/lupa-password`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ email }),
+    });
+    return response.json();
+  },
+
+  cekTokenReset: async (token: string): Promise<APIResponse<{ username: string; berlaku_sampai: string }>> => {
+    const response = await fetch(`${API_URL}/auth/reset-password/${encodeURIComponent(token)}`);
+    return response.json();
+  },
+
+  resetPassword: async (token: string, newPassword: string): Promise<APIResponse> => {
+    const response = await fetch(`${API_URL}/auth/reset-password`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ token, new_password: newPassword }),
+    });
+    return response.json();
+  },
+
+  getKebijakanPassword: async (): Promise<APIResponse<KebijakanPasswordData>> => {
+    const response = await fetch(`${API_URL}/auth/kebijakan-password`);
+    return response.json();
+  },
+
+  verifikasi2FA: async (tokenTantangan: string, kode: string): Promise<APIResponse<LoginResponse>> => {
+    const response = await fetch(`${API_URL}/auth/login/2fa`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ token_tantangan: tokenTantangan, kode }),
+    });
+    return response.json();
+  },
+
+  mulaiSetup2FA: async (tokenTantangan: string): Promise<APIResponse<Setup2FAData>> => {
+    const response = await fetch(`${API_URL}/auth/login/2fa/setup`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ token_tantangan: tokenTantangan }),
+    });
+    return response.json();
+  },
+
+  aktifkanSetup2FA: async (tokenTantangan: string, kode: string): Promise<APIResponse<LoginResponse>> => {
+    const response = await fetch(`${API_URL}/auth/login/2fa/aktifkan`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ token_tantangan: tokenTantangan, kode }),
+    });
+    return response.json();
+  },
+
   getProfile: async (): Promise<APIResponse<LoginResponse['admin']>> => {
     const response = await authFetch(`${API_URL}/auth/profile`);
     return response.json();
   },
 
+  // Ends the session on the server as well, so the refresh token cannot be
+  // used again
   logout: () => {
     if (typeof window !== 'undefined') {
-      localStorage.removeItem('adminToken');
-      localStorage.removeItem('adminLoggedIn');
-      localStorage.removeItem('adminLoginTime');
+      const token = getAuthToken();
+      if (token) {
+        fetch(`${API_URL}/auth/logout`, {
+          method: 'POST',
+          headers: { Authorization: `Bearer ${token}` },
+          keepalive: true,
+        }).catch(() => {});
+      }
+      clearSession();
     }
   },
+
+  getSesi: async (): Promise<APIResponse<SesiData[]>> => {
+    const response = await authFetch(`${API_URL}/auth/sessions`);
+    return response.json();
+  },
+
+  cabutSesi: async (id: string): Promise<APIResponse> => {
+    const response = await authFetch(`${API_URL}/auth/sessions/${id}`, { method: 'DELETE' });
+    return response.json();
+  },
 };
 
+// ============== Two-Factor API ==============
+
+const labelKelasPassword: Record<KebijakanPasswordData['kelas_wajib'][number], string> = {
+  lower: 'huruf kecil',
+  upper: 'huruf besar',
+  digit: 'angka',
+  symbol: 'simbol',
+};
+
+// deskripsiKebijakanPassword turns the password policy into a hint for forms
+export const deskripsiKebijakanPassword = (kebijakan: KebijakanPasswordData): string => {
+  let teks = `Minimal ${kebijakan.min_panjang} karakter`;
+  if (kebijakan.kelas_wajib.length > 0) {
+    teks += `, mengandung ${kebijakan.kelas_wajib.map((k) => labelKelasPassword[k]).join(', ')}`;
+  }
+  if (kebijakan.riwayat > 0) {
+    teks += `, tidak sama dengan ${kebijakan.riwayat} password terakhir`;
+  }
+  return teks;
+};
+
+export const duaFaktorAPI = {
+  getStatus: async (): Promise<APIResponse<Status2FAData>> => {
+    const response = await authFetch(`${API_URL}/auth/2fa`);
+    return response.json();
+  },
+
+  mulai: async (): Promise<APIResponse<Setup2FAData>> => {
+    const response = await authFetch(`${API_URL}/auth/2fa/setup`, { method: 'POST' });
+    return response.json();
+  },
+
+  aktifkan: async (kode: string): Promise<APIResponse<{ kode_pemulihan: string[] }>> => {
+    const response = await authFetch(`${API_URL}/auth/2fa/aktifkan`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ kode }),
+    });
+    return response.json();
+  },
+
+  nonaktifkan: async (password: string, kode: string): Promise<APIResponse> => {
+    const response = await authFetch(`${API_URL}/auth/2fa/nonaktifkan`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ password, kode }),
+    });
+    return response.json();
+  },
+
+  buatUlangKodePemulihan: async (kode: string): Promise<APIResponse<{ kode_pemulihan: string[] }>> => {
+    const response = await authFetch(`${API_URL}/auth/2fa/kode-pemulihan`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ kode }),
+    });
+    return response.json();
+  },
+};
+
+export interface SesiData {
+  id: string;
+  ip_address: string;
+  user_agent: string;
+  created_at: string;
+  terakhir_dipakai: string;
+  kadaluarsa_pada: string;
+  sesi_ini: boolean;
+}
+
 // ============== Jenis Perizinan API ==============
 
+export interface PersyaratanData {
+  kode: string;
+  nama: string;
+  wajib: boolean;
+  tipe_file: string[] | null;
+  ukuran_maks: number;
+}
+
 export interface JenisPerizinanData {
   id: string;
   nama: string;
   deskripsi: string;
-  persyaratan: string[];
+  persyaratan: PersyaratanData[];
   aktif: boolean;
   created_at: string;
 }
@@ -89,14 +314,14 @@ export interface JenisPerizinanData {
 export interface CreateJenisPerizinanRequest {
   nama: string;
   deskripsi: string;
-  persyaratan: string[];
+  persyaratan: PersyaratanData[];
   aktif: boolean;
 }
 
 export interface UpdateJenisPerizinanRequest {
   nama?: string;
   deskripsi?: string;
-  persyaratan?: string[];
+  persyaratan?: PersyaratanData[];
   aktif?: boolean;
 }
 
@@ -149,11 +374,16 @@ export interface PemohonData {
 
 export interface BerkasData {
   id: string;
+  persyaratan_kode: string;
   nama_file: string;
   nama_asli: string;
   path: string;
   ukuran: number;
   mime_type: string;
+  sha256: string;
+  status_scan: 'bersih' | 'terinfeksi' | 'tidak_dipindai';
+  hasil_scan?: string;
+  ada_pratinjau: boolean;
   created_at: string;
 }
 
@@ -192,7 +422,7 @@ export interface CreatePermohonanRequest {
 }
 
 export interface UpdateStatusRequest {
-  status: 'baru' | 'diproses' | 'disetujui' | 'ditolak';
+  status: 'diproses';
   catatan_admin?: string;
 }
 
@@ -204,7 +434,8 @@ export interface KirimBalasanRequest {
 
 export const permohonanAPI = {
   // Public endpoint - submit permohonan baru
-  create: async (data: CreatePermohonanRequest, files: File[]): Promise<APIResponse<PermohonanData>> => {
+  // Berkas dikirim per persyaratan sebagai field berkas[<kode>]
+  create: async (data: CreatePermohonanRequest, files: Record<string, File[]>): Promise<APIResponse<PermohonanData>> => {
     const formData = new FormData();
     formData.append('nama_lengkap', data.nama_lengkap);
     formData.append('nomor_telepon', data.nomor_telepon);
@@ -215,8 +446,10 @@ export const permohonanAPI = {
       formData.append('catatan', data.catatan);
     }
 
-    files.forEach((file) => {
-      formData.append('berkas', file);
+    Object.entries(files).forEach(([kode, list]) => {
+      list.forEach((file) => {
+        formData.append(`berkas[${kode}]`, file);
+      });
     });
 
     const response = await fetch(`${API_URL}/permohonan`, {
@@ -363,12 +596,32 @@ export const mapPermohonanToFrontend = (data: PermohonanData) => {
   };
 };
 
+export const mapPersyaratanToFrontend = (data: PersyaratanData) => {
+  return {
+    kode: data.kode,
+    nama: data.nama,
+    wajib: data.wajib,
+    tipeFile: data.tipe_file || [],
+    ukuranMaks: data.ukuran_maks,
+  };
+};
+
+export const mapPersyaratanToAPI = (data: Persyaratan): PersyaratanData => {
+  return {
+    kode: data.kode,
+    nama: data.nama,
+    wajib: data.wajib,
+    tipe_file: data.tipeFile,
+    ukuran_maks: data.ukuranMaks,
+  };
+};
+
 export const mapJenisPerizinanToFrontend = (data: JenisPerizinanData) => {
   return {
     id: data.id,
     nama: data.nama,
     deskripsi: data.deskripsi,
-    persyaratan: data.persyaratan || [],
+    persyaratan: (data.persyaratan || []).map(mapPersyaratanToFrontend),
     aktif: data.aktif,
     createdAt: new Date(data.created_at),
   };
@@ -384,16 +637,49 @@ export const mapNotifikasiToFrontend = (data: NotifikasiData) => {
   };
 };
 
-// Get file download URL
-export const getFileUrl = (path: string): string => {
-  const baseUrl = API_URL.replace('/api/v1', '');
-  return `${baseUrl}/${path}`;
+// ============== Akses Berkas API ==============
+
+// Short-lived signed link; it works without the bearer token so the browser
+// can download the file directly
+export interface TautanUnduhData {
+  url: string;
+  kadaluarsa_pada: string;
+}
+
+export const aksesBerkasAPI = {
+  getTautanBerkas: async (berkasId: string): Promise<APIResponse<TautanUnduhData>> => {
+    const response = await authFetch(`${API_URL}/admin/berkas/${berkasId}/tautan`);
+    return response.json();
+  },
+
+  getTautanLampiran: async (permohonanId: string): Promise<APIResponse<TautanUnduhData>> => {
+    const response = await authFetch(`${API_URL}/admin/permohonan/${permohonanId}/lampiran/tautan`);
+    return response.json();
+  },
+
+  // Gambar pratinjau (JPEG) sebagai object URL; panggil URL.revokeObjectURL setelah dipakai
+  getPratinjau: async (berkasId: string): Promise<string | null> => {
+    const response = await authFetch(`${API_URL}/admin/berkas/${berkasId}/pratinjau`);
+    if (!response.ok) {
+      return null;
+    }
+    return URL.createObjectURL(await response.blob());
+  },
+
+  // ZIP berisi semua berkas, surat balasan, dan lembar ringkasan
+  getTautanBundel: async (permohonanId: string): Promise<APIResponse<TautanUnduhData>> => {
+    const response = await authFetch(`${API_URL}/admin/permohonan/${permohonanId}/bundel/tautan`);
+    return response.json();
+  },
 };
 
-// Get file download URL with original filename
-export const getDownloadUrl = (filename: string, originalName: string): string => {
-  const baseUrl = API_URL.replace('/api/v1', '');
-  return `${baseUrl}/download/${filename}?name=${encodeURIComponent(originalName)}`;
+// Request a signed link and start the download
+export const unduhDenganTautan = async (request: Promise<APIResponse<TautanUnduhData>>): Promise<void> => {
+  const response = await request;
+  if (!response.success || !response.data) {
+    throw new Error(response.error || response.message || 'Gagal mengunduh berkas');
+  }
+  window.location.href = response.data.url;
 };
 
 // ============== Admin Management API ==============
@@ -403,8 +689,11 @@ export interface AdminData {
   username: string;
   email: string;
   nama_lengkap: string;
-  role: 'super_admin' | 'admin';
+  role: string;
   is_active: boolean;
+  totp_aktif: boolean;
+  gagal_login: number;
+  terkunci_sampai?: string;
   created_at: string;
   updated_at: string;
 }
@@ -422,14 +711,14 @@ export interface CreateAdminRequest {
   password: string;
   email: string;
   nama_lengkap: string;
-  role: 'super_admin' | 'admin';
+  role: string;
 }
 
 export interface UpdateAdminRequest {
   username?: string;
   email?: string;
   nama_lengkap?: string;
-  role?: 'super_admin' | 'admin';
+  role?: string;
   is_active?: boolean;
 }
 
@@ -499,6 +788,147 @@ export const adminAPI = {
     });
     return response.json();
   },
+  getSesi: async (id: string): Promise<APIResponse<SesiData[]>> => {
+    const response = await authFetch(`${API_URL}/admin/admins/${id}/sessions`);
+    return response.json();
+  },
+
+  cabutSemuaSesi: async (id: string): Promise<APIResponse> => {
+    const response = await authFetch(`${API_URL}/admin/admins/${id}/sessions`, { method: 'DELETE' });
+    return response.json();
+  },
+
+  reset2FA: async (id: string): Promise<APIResponse> => {
+    const response = await authFetch(`${API_URL}/admin/admins/${id}/reset-2fa`, { method: 'POST' });
+    return response.json();
+  },
+
+  getPenguncian: async (): Promise<APIResponse<PenguncianLoginData>> => {
+    const response = await authFetch(`${API_URL}/admin/penguncian-login`);
+    return response.json();
+  },
+
+  getPercobaanLogin: async (id: string): Promise<APIResponse<PercobaanLoginData[]>> => {
+    const response = await authFetch(`${API_URL}/admin/admins/${id}/percobaan-login`);
+    return response.json();
+  },
+
+  bukaKunciAkun: async (id: string): Promise<APIResponse> => {
+    const response = await authFetch(`${API_URL}/admin/admins/${id}/penguncian`, { method: 'DELETE' });
+    return response.json();
+  },
+
+  bukaKunciIP: async (ip: string): Promise<APIResponse> => {
+    const response = await authFetch(`${API_URL}/admin/penguncian-login/ip/${encodeURIComponent(ip)}`, { method: 'DELETE' });
+    return response.json();
+  },
+};
+
+export interface PenguncianLoginData {
+  akun: {
+    admin_id: string;
+    username: string;
+    nama_lengkap: string;
+    gagal_login: number;
+    terkunci_sampai: string;
+  }[];
+  ip: {
+    ip_address: string;
+    jumlah: number;
+    terakhir: string;
+    terkunci_sampai: string;
+  }[];
+}
+
+export interface PercobaanLoginData {
+  id: string;
+  username: string;
+  ip_address: string;
+  user_agent: string;
+  alasan: string;
+  direset: boolean;
+  created_at: string;
+}
+
+// ============== Role Management API ==============
+
+export interface RoleData {
+  id: string;
+  nama: string;
+  label: string;
+  deskripsi: string;
+  izin: string[];
+  bawaan: boolean;
+  jumlah_admin: number;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface IzinData {
+  kode: string;
+  deskripsi: string;
+}
+
+export interface CreateRoleRequest {
+  nama: string;
+  label: string;
+  deskripsi: string;
+  izin: string[];
+}
+
+export interface UpdateRoleRequest {
+  label?: string;
+  deskripsi?: string;
+  izin?: string[];
+}
+
+export const roleAPI = {
+  getAll: async (): Promise<APIResponse<RoleData[]>> => {
+    const response = await authFetch(`${API_URL}/admin/roles`);
+    return response.json();
+  },
+
+  getDaftarIzin: async (): Promise<APIResponse<IzinData[]>> => {
+    const response = await authFetch(`${API_URL}/admin/izin`);
+    return response.json();
+  },
+
+  create: async (data: CreateRoleRequest): Promise<APIResponse<RoleData>> => {
+    const response = await authFetch(`${API_URL}/admin/roles`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(data),
+    });
+    return response.json();
+  },
+
+  update: async (id: string, data: UpdateRoleRequest): Promise<APIResponse<RoleData>> => {
+    const response = await authFetch(`${API_URL}/admin/roles/${id}`, {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(data),
+    });
+    return response.json();
+  },
+
+  delete: async (id: string): Promise<APIResponse> => {
+    const response = await authFetch(`${API_URL}/admin/roles/${id}`, { method: 'DELETE' });
+    return response.json();
+  },
+};
+
+// Label shown for a role name when the role list is not loaded
+export const labelRole = (role: string): string => {
+  switch (role) {
+    case 'super_admin':
+      return 'Super Admin';
+    case 'admin':
+      return 'Administrator';
+    case 'auditor':
+      return 'Auditor';
+    default:
+      return role.split('_').map((w) => w.charAt(0).toUpperCase() + w.slice(1)).join(' ');
+  }
 };
 
 export const mapAdminToFrontend = (data: AdminData) => {
@@ -1,7 +1,7 @@
 // Types untuk aplikasi perizinan
 
-// Admin Role type
-export type AdminRole = 'super_admin' | 'admin';
+// Admin Role type; built-in roles are super_admin, admin and auditor, others are stored in the database
+export type AdminRole = string;
 
 // Admin Info type
 export interface AdminInfo {
@@ -10,13 +10,23 @@ export interface AdminInfo {
   email: string;
   nama_lengkap: string;
   role: AdminRole;
+  izin: string[];
+}
+
+// Dokumen yang harus diunggah untuk satu jenis perizinan
+export interface Persyaratan {
+  kode: string;
+  nama: string;
+  wajib: boolean;
+  tipeFile: string[];   // ekstensi yang diizinkan, kosong berarti semua
+  ukuranMaks: number;   // dalam byte, 0 berarti tanpa batas khusus
 }
 
 export interface JenisPerizinan {
   id: string;
   nama: string;
   deskripsi: string;
-  persyaratan: string[];
+  persyaratan: Persyaratan[];
   aktif: boolean;
   createdAt: Date;
 }
@@ -30,11 +40,13 @@ export interface Pemohon {
 
 export interface Berkas {
   id: string;
+  persyaratan_kode?: string;
   nama_asli: string;
   nama_file: string;
   path: string;
   ukuran: number;
   tipe: string;
+  ada_pratinjau?: boolean;
 }
 
 export interface Permohonan {